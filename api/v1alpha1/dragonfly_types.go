@@ -17,8 +17,11 @@ limitations under the License.
 package v1alpha1
 
 import (
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -29,18 +32,48 @@ type DragonflySpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
+	// (Optional) Name of the DragonflyClass that provides the defaults of
+	// this spec. Fields set here take precedence over the class.
+	// +optional
+	// +kubebuilder:validation:Optional
+	ClassName string `json:"className,omitempty"`
+
 	// Replicas is the total number of Dragonfly instances including the master
 	Replicas int32 `json:"replicas,omitempty"`
 
-	// Image is the Dragonfly image to use
+	// Image is the Dragonfly image to use. It can be pinned to a digest
+	// with image@sha256:<digest>
 	Image string `json:"image,omitempty"`
 
+	// (Optional) Pull policy of the Dragonfly image. Defaults to Always, or
+	// to IfNotPresent if the image is pinned to a digest
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
 	// (Optional) Dragonfly container args to pass to the container
 	// Refer to the Dragonfly documentation for the list of supported args
 	// +optional
 	// +kubebuilder:validation:Optional
 	Args []string `json:"args,omitempty"`
 
+	// (Optional) Logging configuration of Dragonfly. The log flags of
+	// args take precedence.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Logging *Logging `json:"logging,omitempty"`
+
+	// (Optional) Command of the Dragonfly container, to run Dragonfly under
+	// a wrapper such as numactl. The last element must be the dragonfly
+	// binary, as the operator appends its managed flags and the health
+	// checks look for the dragonfly process.
+	// e.g ["numactl", "--interleave=all", "dragonfly"]
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MinItems=1
+	Command []string `json:"command,omitempty"`
+
 	// (Optional) Annotations to add to the Dragonfly pods.
 	// +optional
 	// +kubebuilder:validation:Optional
@@ -57,11 +90,48 @@ type DragonflySpec struct {
 	// +kubebuilder:validation:Optional
 	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
 
+	// (Optional) Size preset of the Dragonfly instance. It sets the
+	// resources, the number of threads (--proactor_threads) and
+	// --maxmemory. Each of them can be overridden with resources and args.
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=small;medium;large;xlarge
+	Size string `json:"size,omitempty"`
+
+	// (Optional) Pod management policy of the StatefulSet. Parallel starts
+	// all the pods at once, which is faster for large instances. The
+	// operator then waits for the pods to be ready to configure replication.
+	// Defaults to OrderedReady. It can't be changed, as it's immutable on
+	// StatefulSets.
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=OrderedReady;Parallel
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="podManagementPolicy is immutable"
+	PodManagementPolicy appsv1.PodManagementPolicyType `json:"podManagementPolicy,omitempty"`
+
+	// (Optional) Minimum number of seconds a pod must be ready before it's
+	// considered available, both by the StatefulSet and by the operator.
+	// Pods that aren't available yet are neither promoted nor given a role.
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	MinReadySeconds int32 `json:"minReadySeconds,omitempty"`
+
 	// (Optional) Dragonfly pod affinity
 	// +optional
 	// +kubebuilder:validation:Optional
 	Affinity *corev1.Affinity `json:"affinity,omitempty"`
 
+	// (Optional) Anti-affinity of the pods of the instance, used when no
+	// affinity is set. With required, no two pods share a node, and with
+	// preferred they are spread over the nodes where possible. Either way
+	// they are spread over the zones where possible. Defaults to preferred,
+	// none disables it.
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=required;preferred;none
+	AntiAffinityMode string `json:"antiAffinityMode,omitempty"`
+
 	// (Optional) Dragonfly pod tolerations
 	// +optional
 	// +kubebuilder:validation:Optional
@@ -84,54 +154,1292 @@ type DragonflySpec struct {
 	// +kubebuilder:validation:Optional
 	TLSSecretRef *corev1.SecretReference `json:"tlsSecretRef,omitempty"`
 
+	// (Optional) Key names of the certificate, private key and CA in the
+	// TLS secret, for secrets that are not of type kubernetes.io/tls (e.g
+	// produced by an ExternalSecret).
+	// +optional
+	// +kubebuilder:validation:Optional
+	TLSSecretKeys *TLSSecretKeys `json:"tlsSecretKeys,omitempty"`
+
 	// (Optional) Dragonfly Snapshot configuration
 	// +optional
 	// +kubebuilder:validation:Optional
 	Snapshot *Snapshot `json:"snapshot,omitempty"`
+
+	// (Optional) Seed the data of new pods from a snapshot, e.g to prewarm
+	// caches or import an exported dataset. Requires a snapshot volume.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Bootstrap *Bootstrap `json:"bootstrap,omitempty"`
+
+	// (Optional) Import the keys of a Redis Cluster into the instance once
+	// it's ready, e.g to migrate from a sharded Redis Cluster. The keys of
+	// every master shard are copied by a Job, and a new import is started
+	// whenever the source changes.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Import *Import `json:"import,omitempty"`
+
+	// (Optional) Inject failures into the instance, to rehearse failovers
+	// in staging clusters. Only applied by operators started with
+	// --enable-fault-injection.
+	// +optional
+	// +kubebuilder:validation:Optional
+	FaultInjection *FaultInjection `json:"faultInjection,omitempty"`
+
+	// (Optional) Dragonfly pod DNS policy
+	// +optional
+	// +kubebuilder:validation:Optional
+	DNSPolicy corev1.DNSPolicy `json:"dnsPolicy,omitempty"`
+
+	// (Optional) Dragonfly pod DNS config. Parameters specified here are
+	// merged with the ones generated from the DNS policy.
+	// +optional
+	// +kubebuilder:validation:Optional
+	DNSConfig *corev1.PodDNSConfig `json:"dnsConfig,omitempty"`
+
+	// (Optional) Dragonfly pod host aliases to be added to the pod's hosts file
+	// +optional
+	// +kubebuilder:validation:Optional
+	HostAliases []corev1.HostAlias `json:"hostAliases,omitempty"`
+
+	// (Optional) Minimum time between two SLAVEOF commands issued to the
+	// same pod for the same master. Protects full syncs from being
+	// interrupted repeatedly by flapping pods. Defaults to 10s.
+	// +optional
+	// +kubebuilder:validation:Optional
+	ReplicationCooldown *metav1.Duration `json:"replicationCooldown,omitempty"`
+
+	// (Optional) Maximum time to wait for the replica that is being promoted
+	// during a planned failover to acknowledge all writes of the master.
+	// The failover is retried later if the replica does not catch up in time.
+	// Defaults to 30s.
+	// +optional
+	// +kubebuilder:validation:Optional
+	FailoverMaxWait *metav1.Duration `json:"failoverMaxWait,omitempty"`
+
+	// (Optional) Address that the Dragonfly pods announce for replication.
+	// Useful when replicas are behind NAT or in other clusters.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Announce *Announce `json:"announce,omitempty"`
+
+	// (Optional) Listen on a unix socket as well, in an emptyDir volume
+	// named unix-socket that is mounted at /var/run/dragonfly. Sidecars
+	// added with statefulSetOverrides can mount the volume to connect
+	// through the socket without going over TCP.
+	// +optional
+	// +kubebuilder:validation:Optional
+	UnixSocket *UnixSocket `json:"unixSocket,omitempty"`
+
+	// (Optional) Dragonfly replication TLS configuration. Replication runs
+	// over the admin port, which does not use TLS unless this is set.
+	// Requires tlsSecretRef or tls.certManager.
+	// +optional
+	// +kubebuilder:validation:Optional
+	ReplicationTLS *ReplicationTLS `json:"replicationTLS,omitempty"`
+
+	// (Optional) If true, the operator manages the EndpointSlice of the
+	// master Service directly instead of relying on a role label selector.
+	// This makes the switch of write traffic during a failover a single
+	// endpoint update.
+	// +optional
+	// +kubebuilder:validation:Optional
+	ManageMasterEndpoints bool `json:"manageMasterEndpoints,omitempty"`
+
+	// (Optional) Service spec that is strategically merged into the
+	// generated Service, e.g to set sessionAffinity or
+	// externalTrafficPolicy. Ports are merged by port number.
+	// +optional
+	// +kubebuilder:validation:Optional
+	ServiceSpecOverride *corev1.ServiceSpec `json:"serviceSpecOverride,omitempty"`
+
+	// (Optional) Strategic merge patch applied last to the generated
+	// StatefulSet, to set fields that aren't part of this API yet.
+	// Containers are merged by name, the Dragonfly container is named
+	// dragonfly. Use with care, the patch isn't validated by the operator.
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	StatefulSetOverrides *runtime.RawExtension `json:"statefulSetOverrides,omitempty"`
+
+	// (Optional) Dragonfly replication tuning. Unset fields use the
+	// Dragonfly defaults.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Replication *Replication `json:"replication,omitempty"`
+
+	// (Optional) Handling of rollouts to another major version of Dragonfly
+	// +optional
+	// +kubebuilder:validation:Optional
+	VersionUpgrade *VersionUpgrade `json:"versionUpgrade,omitempty"`
+
+	// (Optional) What to do when maxmemory is reached. "NoEviction" rejects
+	// writes, "Cache" evicts keys, so it can't be combined with persistent
+	// snapshots whose data is meant to be kept. Defaults to "NoEviction"
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=NoEviction;Cache
+	EvictionPolicy string `json:"evictionPolicy,omitempty"`
+
+	// (Optional) Classes of keyspace events to notify clients about, in
+	// the notify-keyspace-events format of Redis, e.g "Ex" for expired
+	// key events. Dragonfly currently only notifies about expired keys.
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern=`^[KEg$lshzxetmdnA]*$`
+	KeyspaceNotifications string `json:"keyspaceNotifications,omitempty"`
+
+	// (Optional) Backoff of the retries after failing to configure
+	// replication. Defaults to an initial delay of 5s doubling up to 5m.
+	// +optional
+	// +kubebuilder:validation:Optional
+	ReplicationBackoff *Backoff `json:"replicationBackoff,omitempty"`
+
+	// (Optional) Metric based analysis of the updated replicas during a
+	// rollout. The rollout only continues while all queries pass.
+	// +optional
+	// +kubebuilder:validation:Optional
+	RolloutAnalysis *RolloutAnalysis `json:"rolloutAnalysis,omitempty"`
+
+	// (Optional) Update strategy of the pods. With RollingUpdate, the
+	// operator rolls the replicas and then fails over the master. With
+	// OnDelete, pods are only updated when they are deleted. The StatefulSet
+	// itself always uses OnDelete, as the operator performs the rollout.
+	// +optional
+	// +kubebuilder:validation:Optional
+	UpdateStrategy *UpdateStrategy `json:"updateStrategy,omitempty"`
+
+	// (Optional) Dragonfly TLS configuration
+	// +optional
+	// +kubebuilder:validation:Optional
+	TLS *TLS `json:"tls,omitempty"`
+
+	// (Optional) Generate a PrometheusRule with default alerts for the
+	// instance. Requires the Prometheus Operator.
+	// +optional
+	// +kubebuilder:validation:Optional
+	PrometheusRule *PrometheusRule `json:"prometheusRule,omitempty"`
+
+	// (Optional) Expose the metrics of all the pods on the <name>-metrics
+	// headless Service, on the named metrics port, separate from the
+	// client port. The Service is annotated for scraping by Prometheus.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Metrics *Metrics `json:"metrics,omitempty"`
+
+	// (Optional) Expose the replicas on the <name>-replicas Service, e.g
+	// for read only clients. It follows the role labels of the pods, so
+	// it doesn't select the master, also after a failover.
+	// +optional
+	// +kubebuilder:validation:Optional
+	ReplicaService *ReplicaService `json:"replicaService,omitempty"`
+
+	// (Optional) Generate an OpenShift Route with TLS passthrough to the
+	// master. Dragonfly serves clients and its HTTP console on the same
+	// port, so the Route exposes both. Requires TLS to be enabled.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Route *Route `json:"route,omitempty"`
+
+	// (Optional) Publish a Secret with the connection details (host, port,
+	// password and TLS CA) of the instance, for applications to mount.
+	// It's kept up to date by the operator.
+	// +optional
+	// +kubebuilder:validation:Optional
+	ConnectionSecret *ConnectionSecret `json:"connectionSecret,omitempty"`
+
+	// (Optional) Run a tier of Envoy Redis proxies in front of the
+	// instance, exposed by the <name>-proxy Service. The operator points
+	// the proxies to the new master on failovers without restarting them,
+	// so that client connections survive master switches.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Proxy *Proxy `json:"proxy,omitempty"`
+
+	// (Optional) Failover configuration. A replica is promoted when the
+	// master or its node is not ready for longer than the grace period,
+	// which this tunes.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Failover *Failover `json:"failover,omitempty"`
+
+	// (Optional) If true, a PodDisruptionBudget blocks the eviction of the
+	// master. When the node of the master is cordoned, e.g to be drained,
+	// the operator first hands over the master role to a replica, and the
+	// eviction goes through once the pod is no longer the master. Replicas
+	// are evicted as usual.
+	// +optional
+	// +kubebuilder:validation:Optional
+	EvictionProtection bool `json:"evictionProtection,omitempty"`
+
+	// (Optional) Labels and annotations to add to all the resources
+	// generated by the operator. They don't override the labels set by
+	// the operator.
+	// +optional
+	// +kubebuilder:validation:Optional
+	CommonMetadata *CommonMetadata `json:"commonMetadata,omitempty"`
+
+	// (Optional) Additional ports to open on the Dragonfly container and
+	// to expose on the Service, e.g for sidecars or custom exporters
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=name
+	ExtraPorts []ExtraPort `json:"extraPorts,omitempty"`
 }
 
-type Snapshot struct {
-	// (Optional) Dragonfly snapshot schedule
+type ExtraPort struct {
+	// Name of the port, used by the container and Service ports
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MaxLength=15
+	Name string `json:"name"`
+
+	// Port number
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+
+	// (Optional) Protocol of the port. Defaults to TCP
 	// +optional
 	// +kubebuilder:validation:Optional
-	Cron string `json:"cron,omitempty"`
+	// +kubebuilder:validation:Enum=TCP;UDP;SCTP
+	Protocol corev1.Protocol `json:"protocol,omitempty"`
+}
 
-	// (Optional) Dragonfly PVC spec
+type CommonMetadata struct {
+	// (Optional) Labels to add to the generated resources
 	// +optional
 	// +kubebuilder:validation:Optional
-	PersistentVolumeClaimSpec *corev1.PersistentVolumeClaimSpec `json:"persistentVolumeClaimSpec,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// (Optional) Annotations to add to the generated resources
+	// +optional
+	// +kubebuilder:validation:Optional
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
-type Authentication struct {
-	// (Optional) Dragonfly Password from Secret as a reference to a specific key
+type Failover struct {
+	// (Optional) Time the master may not be ready before a replica is
+	// promoted. Longer periods avoid flapping on short hiccups, shorter
+	// ones restore writes sooner. Defaults to 30
 	// +optional
-	PasswordFromSecret *corev1.SecretKeySelector `json:"passwordFromSecret,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	GracePeriodSeconds *int32 `json:"gracePeriodSeconds,omitempty"`
+}
 
-	// (Optional) If specified, the Dragonfly instance will check if the
-	// client certificate is signed by one of this CA. Server TLS must be enabled for this.
-	// Multiple CAs can be specified with various key names.
+type ConnectionSecret struct {
+	// (Optional) Name of the Secret. Defaults to <name>-connection
 	// +optional
-	ClientCaCertSecret *corev1.SecretReference `json:"clientCaCertSecret,omitempty"`
+	// +kubebuilder:validation:Optional
+	Name string `json:"name,omitempty"`
 }
 
-// DragonflyStatus defines the observed state of Dragonfly
-type DragonflyStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+type Proxy struct {
+	// (Optional) Number of proxy pods. Defaults to 2
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	Replicas *int32 `json:"replicas,omitempty"`
 
-	// Status of the Dragonfly Instance
-	// It can be one of the following:
-	// - "ready": The Dragonfly instance is ready to serve requests
-	// - "configuring-replication": The controller is updating the master of the Dragonfly instance
-	// - "resources-created": The Dragonfly instance resources were created but not yet configured
-	Phase string `json:"phase,omitempty"`
+	// (Optional) Envoy image of the proxies
+	// +optional
+	// +kubebuilder:validation:Optional
+	Image string `json:"image,omitempty"`
 
-	// IsRollingUpdate is true if the Dragonfly instance is being updated
-	IsRollingUpdate bool `json:"isRollingUpdate,omitempty"`
+	// (Optional) Resources of the proxy containers
+	// +optional
+	// +kubebuilder:validation:Optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+type Route struct {
+	// (Optional) Host of the Route. Generated by OpenShift if not set
+	// +optional
+	// +kubebuilder:validation:Optional
+	Host string `json:"host,omitempty"`
+}
+
+type Metrics struct {
+	// (Optional) Labels of the metrics Service, e.g to match the
+	// selector of a scrape configuration
+	// +optional
+	// +kubebuilder:validation:Optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// (Optional) Annotations of the metrics Service, added to the
+	// prometheus.io scrape annotations
+	// +optional
+	// +kubebuilder:validation:Optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// (Optional) Image of the sidecar that serves the metrics on the
+	// metrics port. Defaults to the image of the proxies.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Image string `json:"image,omitempty"`
+
+	// (Optional) Resources of the metrics sidecar
+	// +optional
+	// +kubebuilder:validation:Optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// (Optional) Generate a ServiceMonitor that scrapes the metrics
+	// Service, for Prometheus instances that are managed by the Prometheus
+	// Operator. It's only generated once the Prometheus Operator is
+	// installed.
+	// +optional
+	// +kubebuilder:validation:Optional
+	ServiceMonitor *ServiceMonitor `json:"serviceMonitor,omitempty"`
+}
+
+// ServiceMonitor configures the ServiceMonitor of the metrics Service
+type ServiceMonitor struct {
+	// (Optional) Labels of the ServiceMonitor, e.g to match the
+	// serviceMonitorSelector of Prometheus
+	// +optional
+	// +kubebuilder:validation:Optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// (Optional) Interval at which the pods are scraped. Defaults to the
+	// scrape interval of Prometheus
+	// +optional
+	// +kubebuilder:validation:Optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+type ReplicaService struct {
+	// (Optional) Labels of the replica Service
+	// +optional
+	// +kubebuilder:validation:Optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// (Optional) Annotations of the replica Service
+	// +optional
+	// +kubebuilder:validation:Optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type PrometheusRule struct {
+	// (Optional) Labels of the PrometheusRule, e.g to match the
+	// ruleSelector of Prometheus
+	// +optional
+	// +kubebuilder:validation:Optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// (Optional) Replication lag in records above which an alert fires.
+	// Defaults to 10000
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	ReplicationLagThreshold *int64 `json:"replicationLagThreshold,omitempty"`
+
+	// (Optional) Percentage of maxmemory in use above which an alert fires.
+	// Defaults to 90
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	MemoryUsageThresholdPercent *int32 `json:"memoryUsageThresholdPercent,omitempty"`
+
+	// (Optional) Maximum age of the last successful snapshot before an alert
+	// fires. Only used when snapshots are scheduled. Defaults to 24h
+	// +optional
+	// +kubebuilder:validation:Optional
+	MaxSnapshotAge *metav1.Duration `json:"maxSnapshotAge,omitempty"`
+}
+
+type TLS struct {
+	// (Optional) Issue the certificates with cert-manager. The operator
+	// creates the Certificate resources and serves TLS with the issued
+	// certificate, so tlsSecretRef must not be set.
+	// +optional
+	// +kubebuilder:validation:Optional
+	CertManager *CertManager `json:"certManager,omitempty"`
+}
+
+type CertManager struct {
+	// Issuer of the certificates
+	// +kubebuilder:validation:Required
+	IssuerRef IssuerReference `json:"issuerRef"`
+}
+
+type IssuerReference struct {
+	// Name of the issuer
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// (Optional) Kind of the issuer. Defaults to Issuer
+	// +optional
+	// +kubebuilder:validation:Optional
+	Kind string `json:"kind,omitempty"`
+
+	// (Optional) Group of the issuer. Defaults to cert-manager.io
+	// +optional
+	// +kubebuilder:validation:Optional
+	Group string `json:"group,omitempty"`
+}
+
+type UpdateStrategy struct {
+	// (Optional) Type of the update strategy. Defaults to RollingUpdate
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=RollingUpdate;OnDelete
+	Type appsv1.StatefulSetUpdateStrategyType `json:"type,omitempty"`
+
+	// (Optional) Parameters of the RollingUpdate strategy
+	// +optional
+	// +kubebuilder:validation:Optional
+	RollingUpdate *RollingUpdate `json:"rollingUpdate,omitempty"`
+}
+
+type RollingUpdate struct {
+	// (Optional) Ordinal at which the pods are partitioned. Pods with a
+	// lower ordinal aren't updated, e.g to stage a rollout. Defaults to 0
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	Partition *int32 `json:"partition,omitempty"`
+
+	// (Optional) Maximum number of replicas that are updated at the same
+	// time. Defaults to 1
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	MaxUnavailable *int32 `json:"maxUnavailable,omitempty"`
+}
+
+type RolloutAnalysis struct {
+	// Address of the Prometheus server to run the queries against,
+	// e.g. http://prometheus.monitoring:9090
+	PrometheusAddress string `json:"prometheusAddress"`
+
+	// Queries to run against every updated replica. The $(POD_NAME),
+	// $(POD_NAMESPACE) and $(POD_IP) variables are expanded to the values
+	// of the updated replica.
+	// +kubebuilder:validation:MinItems=1
+	Queries []AnalysisQuery `json:"queries"`
+
+	// (Optional) What to do when a query fails. "Pause" retries the analysis
+	// until it passes, "Abort" stops the rollout until the spec changes.
+	// The replicas that were already updated keep the new version, until
+	// the spec is reverted. Defaults to "Pause".
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Pause;Abort
+	FailurePolicy string `json:"failurePolicy,omitempty"`
+}
+
+type AnalysisQuery struct {
+	// Name of the query
+	Name string `json:"name"`
+
+	// PromQL query returning a single sample
+	Query string `json:"query"`
+
+	// Maximum value of the sample for the query to pass
+	// +kubebuilder:validation:Pattern=`^-?[0-9]+(\.[0-9]+)?$`
+	MaxValue string `json:"maxValue"`
+}
+
+type Backoff struct {
+	// (Optional) Delay before the first retry
+	// +optional
+	// +kubebuilder:validation:Optional
+	InitialDelay *metav1.Duration `json:"initialDelay,omitempty"`
+
+	// (Optional) Factor by which the delay grows after every failed retry
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	Multiplier *int32 `json:"multiplier,omitempty"`
+
+	// (Optional) Maximum delay between two retries
+	// +optional
+	// +kubebuilder:validation:Optional
+	MaxDelay *metav1.Duration `json:"maxDelay,omitempty"`
+}
+
+type Replication struct {
+	// (Optional) Time to wait for stuck replication writes before the
+	// replica is disconnected. Maps to --replication_timeout.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// (Optional) Time to wait for the replication output buffer to go below
+	// the throttle limit. Maps to --replication_stream_timeout.
+	// +optional
+	// +kubebuilder:validation:Optional
+	StreamTimeout *metav1.Duration `json:"streamTimeout,omitempty"`
+
+	// (Optional) Size of the replication output buffer above which writes
+	// are throttled. Maps to --replication_stream_output_limit.
+	// +optional
+	// +kubebuilder:validation:Optional
+	StreamOutputLimit *resource.Quantity `json:"streamOutputLimit,omitempty"`
+
+	// (Optional) Length of the replication backlog per shard, which allows
+	// replicas to resume with a partial sync. Maps to --shard_repl_backlog_len.
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	ShardBacklogLength *int32 `json:"shardBacklogLength,omitempty"`
+
+	// (Optional) Timeout of a replica connecting to the master.
+	// Maps to --master_connect_timeout_ms.
+	// +optional
+	// +kubebuilder:validation:Optional
+	MasterConnectTimeout *metav1.Duration `json:"masterConnectTimeout,omitempty"`
+
+	// (Optional) Timeout of a replica reconnecting to the master.
+	// Maps to --master_reconnect_timeout_ms.
+	// +optional
+	// +kubebuilder:validation:Optional
+	MasterReconnectTimeout *metav1.Duration `json:"masterReconnectTimeout,omitempty"`
+}
+
+// ReplicationTLS enables TLS on the replication link, with the certificate
+// of the instance. Dragonfly serves a single certificate on all of its
+// ports and only serves TLS on the admin port along with the client port,
+// so the link can't have a certificate of its own, and TLS can't be
+// enabled on the link alone. The CA that the link trusts can be separate.
+type ReplicationTLS struct {
+	// (Optional) CA certificate that the replicas and the operator verify
+	// the master with, e.g when the certificate of the instance is issued
+	// by a public CA for the clients. Defaults to the CA of the TLS secret
+	// +optional
+	// +kubebuilder:validation:Optional
+	CASecretRef *corev1.SecretKeySelector `json:"caSecretRef,omitempty"`
+}
+
+type Logging struct {
+	// (Optional) Verbosity of the debug logs (--v). Defaults to 0
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	Verbosity *int32 `json:"verbosity,omitempty"`
+
+	// (Optional) Verbosity per module (--vmodule), e.g replica=2,dflycmd=1
+	// +optional
+	// +kubebuilder:validation:Optional
+	VModule string `json:"vmodule,omitempty"`
+
+	// (Optional) Minimum level of the logs (--minloglevel). Defaults to INFO
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=INFO;WARNING;ERROR
+	MinLevel string `json:"minLevel,omitempty"`
+
+	// (Optional) Also write the logs to files in the logs directory of the
+	// snapshot volume, so that they outlive the pod, instead of an emptyDir.
+	// Requires a snapshot volume claim.
+	// +optional
+	// +kubebuilder:validation:Optional
+	OnSnapshotVolume bool `json:"onSnapshotVolume,omitempty"`
+
+	// (Optional) Size in megabytes at which the log files are rotated
+	// (--max_log_size)
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	MaxSizeMB *int32 `json:"maxSizeMB,omitempty"`
+}
+
+type UnixSocket struct {
+	// (Optional) Permissions of the socket file in octal, e.g 770
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern="^[0-7]{3,4}$"
+	Permissions string `json:"permissions,omitempty"`
+}
+
+type Announce struct {
+	// Source of the announced IP. With "NodeIP" the IP of the node the pod
+	// is running on is announced, with "Template" the rendered template is.
+	// +kubebuilder:validation:Enum=NodeIP;Template
+	Source string `json:"source"`
+
+	// (Optional) Template of the announced address when source is "Template".
+	// The $(POD_NAME), $(POD_IP) and $(NODE_IP) variables are expanded
+	// per pod, e.g. "$(POD_NAME).dragonfly.example.com".
+	// +optional
+	// +kubebuilder:validation:Optional
+	Template string `json:"template,omitempty"`
+
+	// (Optional) Port to announce instead of the Dragonfly port
+	// +optional
+	// +kubebuilder:validation:Optional
+	Port int32 `json:"port,omitempty"`
+}
+
+type Snapshot struct {
+	// (Optional) Dragonfly snapshot schedule
+	// +optional
+	// +kubebuilder:validation:Optional
+	Cron string `json:"cron,omitempty"`
+
+	// (Optional) Dragonfly PVC spec
+	// +optional
+	// +kubebuilder:validation:Optional
+	PersistentVolumeClaimSpec *corev1.PersistentVolumeClaimSpec `json:"persistentVolumeClaimSpec,omitempty"`
+
+	// (Optional) Stage snapshots in a memory backed (tmpfs) emptyDir
+	// instead of a PVC. This avoids disk I/O during BGSAVE for diskless
+	// deployments. Cannot be combined with persistentVolumeClaimSpec.
+	// +optional
+	// +kubebuilder:validation:Optional
+	MemoryStaging *MemoryStaging `json:"memoryStaging,omitempty"`
+
+	// (Optional) Use a generic ephemeral volume with the given PVC spec as
+	// the snapshot directory. The volume is created per pod and deleted
+	// along with it. Cannot be combined with persistentVolumeClaimSpec or
+	// memoryStaging.
+	// +optional
+	// +kubebuilder:validation:Optional
+	EphemeralVolumeClaimSpec *corev1.PersistentVolumeClaimSpec `json:"ephemeralVolumeClaimSpec,omitempty"`
+
+	// (Optional) Maximum age of the last successful scheduled snapshot
+	// before the SnapshotFailing condition is set. Defaults to 24h
+	// +optional
+	// +kubebuilder:validation:Optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+
+	// (Optional) Periodically verify that the snapshot of the master can be
+	// restored, by loading it into a throwaway pod. Requires
+	// persistentVolumeClaimSpec.
+	// +optional
+	// +kubebuilder:validation:Optional
+	RestoreVerification *RestoreVerification `json:"restoreVerification,omitempty"`
+
+	// (Optional) Periodically save a snapshot of the master and upload it
+	// to object storage. Requires persistentVolumeClaimSpec.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Backup *Backup `json:"backup,omitempty"`
+
+	// (Optional) Add Velero backup hook annotations to the pods, so that a
+	// snapshot is saved to the PVC right before Velero backs it up.
+	// Requires persistentVolumeClaimSpec.
+	// +optional
+	// +kubebuilder:validation:Optional
+	VeleroBackupHooks bool `json:"veleroBackupHooks,omitempty"`
+
+	// (Optional) VolumeSnapshotClass used to copy the PVCs of the pods when
+	// the storage class of persistentVolumeClaimSpec changes. The new
+	// storage class must use the same CSI driver. Without it, the new PVCs
+	// start empty and the pods resync from the master.
+	// +optional
+	// +kubebuilder:validation:Optional
+	VolumeSnapshotClassName *string `json:"volumeSnapshotClassName,omitempty"`
+}
+
+// +kubebuilder:validation:XValidation:rule="has(self.snapshotURI) != has(self.persistentVolumeClaim)",message="exactly one of snapshotURI and persistentVolumeClaim must be set"
+type Bootstrap struct {
+	// (Optional) URI of the snapshot, with the https or s3 scheme. It's
+	// downloaded into the snapshot directory of pods that start without
+	// any data, so its file name has to match the dbfilename of Dragonfly.
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern=`^(https|s3)://.+`
+	SnapshotURI string `json:"snapshotURI,omitempty"`
+
+	// (Optional) Existing claim with the snapshot, e.g restored from a
+	// VolumeSnapshot. It's copied into the snapshot directory of pods that
+	// start without any data. The claim is mounted read only by all the
+	// pods, so it has to be ReadOnlyMany or ReadWriteMany unless there is
+	// a single replica.
+	// +optional
+	// +kubebuilder:validation:Optional
+	PersistentVolumeClaim *BootstrapPersistentVolumeClaim `json:"persistentVolumeClaim,omitempty"`
+
+	// (Optional) Secret whose keys are set as environment variables of the
+	// download, e.g AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+	// +optional
+	// +kubebuilder:validation:Optional
+	CredentialsSecretRef *corev1.LocalObjectReference `json:"credentialsSecretRef,omitempty"`
+
+	// (Optional) Image that downloads the snapshot. Defaults to a curl
+	// image for https and an AWS CLI image for s3
+	// +optional
+	// +kubebuilder:validation:Optional
+	Image string `json:"image,omitempty"`
+}
+
+type BootstrapPersistentVolumeClaim struct {
+	// Name of the claim in the namespace of the instance
+	ClaimName string `json:"claimName"`
+
+	// (Optional) Path of the snapshot in the claim. A file is copied as
+	// is, so its name has to match the dbfilename of Dragonfly, and the
+	// content of a directory is copied, e.g for the files of a snapshot in
+	// the Dragonfly format. Defaults to the root of the claim
+	// +optional
+	// +kubebuilder:validation:Optional
+	Path string `json:"path,omitempty"`
+}
+
+type Import struct {
+	// Address of a node of the Redis Cluster, as host:port. The master
+	// shards of the cluster are discovered through it.
+	// +kubebuilder:validation:Pattern=`^[^:]+:[0-9]+$`
+	Source string `json:"source"`
+
+	// (Optional) Password of the Redis Cluster as a reference to a specific
+	// key of a Secret
+	// +optional
+	// +kubebuilder:validation:Optional
+	PasswordFromSecret *corev1.SecretKeySelector `json:"passwordFromSecret,omitempty"`
+
+	// (Optional) Image with redis-cli that copies the keys. Defaults to
+	// redis:7.2
+	// +optional
+	// +kubebuilder:validation:Optional
+	Image string `json:"image,omitempty"`
+}
+
+type FaultInjection struct {
+	// (Optional) Delay of every SLAVE OF command issued to the pods
+	// +optional
+	// +kubebuilder:validation:Optional
+	ReplicaOfDelay *metav1.Duration `json:"replicaOfDelay,omitempty"`
+
+	// (Optional) Fail the health checks of the pods by the operator, e.g
+	// as if they were unreachable
+	// +optional
+	// +kubebuilder:validation:Optional
+	DropHealthChecks bool `json:"dropHealthChecks,omitempty"`
+
+	// (Optional) Kill the master pod, without a grace period, whenever
+	// this value changes
+	// +optional
+	// +kubebuilder:validation:Optional
+	MasterFailureRequest string `json:"masterFailureRequest,omitempty"`
+}
+
+type VersionUpgrade struct {
+	// (Optional) Roll out images of another major version. Snapshots saved
+	// by a new major version may not be loadable by the previous one, so
+	// such rollouts are blocked unless allowed, as they can't be undone.
+	// +optional
+	// +kubebuilder:validation:Optional
+	AllowMajor bool `json:"allowMajor,omitempty"`
+
+	// (Optional) Save a snapshot in the format of the new version once a
+	// rollout to another major version completed
+	// +optional
+	// +kubebuilder:validation:Optional
+	SaveAfterMajorUpgrade bool `json:"saveAfterMajorUpgrade,omitempty"`
+}
+
+type RestoreVerification struct {
+	// Interval between the verifications
+	Interval metav1.Duration `json:"interval"`
+
+	// (Optional) Time after which a verification that didn't complete
+	// fails. Defaults to 10m
+	// +optional
+	// +kubebuilder:validation:Optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+type Backup struct {
+	// URI of the directory the backups are uploaded to, with the s3, gs
+	// or az scheme, e.g az://<container>/<path> for Azure Blob Storage.
+	// Each backup is uploaded to a directory of its own in it.
+	// +kubebuilder:validation:Pattern=`^(s3|gs|az)://.+`
+	Destination string `json:"destination"`
+
+	// Interval between the backups
+	Interval metav1.Duration `json:"interval"`
+
+	// (Optional) Number of backups kept in the destination. Older backups
+	// are deleted after each upload. Defaults to 7
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	Retention *int32 `json:"retention,omitempty"`
+
+	// (Optional) Secret whose keys are set as environment variables of the
+	// upload, e.g AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or
+	// AZURE_STORAGE_ACCOUNT and AZURE_STORAGE_KEY
+	// +optional
+	// +kubebuilder:validation:Optional
+	CredentialsSecretRef *corev1.LocalObjectReference `json:"credentialsSecretRef,omitempty"`
+
+	// (Optional) Service account of the upload pod, e.g for workload
+	// identity
+	// +optional
+	// +kubebuilder:validation:Optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// (Optional) Image that uploads the backup. Defaults to an AWS CLI
+	// image for s3, a Google Cloud CLI image for gs and an Azure CLI image
+	// for az
+	// +optional
+	// +kubebuilder:validation:Optional
+	Image string `json:"image,omitempty"`
+
+	// (Optional) Time after which a backup that didn't complete fails.
+	// Defaults to 30m
+	// +optional
+	// +kubebuilder:validation:Optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+type TLSSecretKeys struct {
+	// (Optional) Key of the certificate. Defaults to tls.crt
+	// +optional
+	// +kubebuilder:validation:Optional
+	Cert string `json:"cert,omitempty"`
+
+	// (Optional) Key of the private key. Defaults to tls.key
+	// +optional
+	// +kubebuilder:validation:Optional
+	Key string `json:"key,omitempty"`
+
+	// (Optional) Key of the CA certificate, used for replication TLS
+	// unless replicationTLS.caSecretRef is set. Defaults to ca.crt
+	// +optional
+	// +kubebuilder:validation:Optional
+	CA string `json:"ca,omitempty"`
+}
+
+type MemoryStaging struct {
+	// (Optional) Size limit of the tmpfs volume. Data written to it counts
+	// against the memory limit of the Dragonfly container.
+	// +optional
+	// +kubebuilder:validation:Optional
+	SizeLimit *resource.Quantity `json:"sizeLimit,omitempty"`
+}
+
+type Authentication struct {
+	// (Optional) Dragonfly Password from Secret as a reference to a specific key
+	// +optional
+	PasswordFromSecret *corev1.SecretKeySelector `json:"passwordFromSecret,omitempty"`
+
+	// (Optional) If specified, the Dragonfly instance will check if the
+	// client certificate is signed by one of this CA. Server TLS must be enabled for this.
+	// Multiple CAs can be specified with various key names.
+	// +optional
+	ClientCaCertSecret *corev1.SecretReference `json:"clientCaCertSecret,omitempty"`
+}
+
+// DragonflyStatus defines the observed state of Dragonfly
+type DragonflyStatus struct {
+	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
+	// Important: Run "make" to regenerate code after modifying this file
+
+	// Status of the Dragonfly Instance
+	// It can be one of the following:
+	// - "ready": The Dragonfly instance is ready to serve requests
+	// - "degraded": The Dragonfly instance serves requests with fewer replicas in sync than desired
+	// - "configuring-replication": The controller is updating the master of the Dragonfly instance
+	// - "resources-created": The Dragonfly instance resources were created but not yet configured
+	// - "pending": The Dragonfly instance resources aren't created as it doesn't fit in its memory budget
+	// - "standby": The Dragonfly instance replicates from the primary of a replication link
+	Phase string `json:"phase,omitempty"`
+
+	// PhaseTransitionTime is the time at which the phase last changed
+	// +optional
+	PhaseTransitionTime *metav1.Time `json:"phaseTransitionTime,omitempty"`
+
+	// IsRollingUpdate is true if the Dragonfly instance is being updated
+	IsRollingUpdate bool `json:"isRollingUpdate,omitempty"`
+
+	// PreviousMajorVersion is the major version of Dragonfly that the
+	// rollout in progress upgrades from, if it changes the major version
+	// +optional
+	PreviousMajorVersion string `json:"previousMajorVersion,omitempty"`
+
+	// AbortedRolloutRevision is the statefulset revision whose rollout
+	// was aborted by the rollout analysis
+	AbortedRolloutRevision string `json:"abortedRolloutRevision,omitempty"`
+
+	// MigratingPod is the pod whose PVC is being moved to the storage class
+	// of persistentVolumeClaimSpec
+	// +optional
+	MigratingPod string `json:"migratingPod,omitempty"`
+
+	// Master is the name of the master pod
+	// +optional
+	Master string `json:"master,omitempty"`
+
+	// ConnectedReplicas is the number of replicas whose link to the master
+	// is up
+	// +optional
+	ConnectedReplicas int32 `json:"connectedReplicas,omitempty"`
+
+	// ReplicationLag is the largest lag of the replicas behind the master,
+	// as the difference of their replication offsets
+	// +optional
+	ReplicationLag *int64 `json:"replicationLag,omitempty"`
+
+	// Replicas are the replication states of the replica pods
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Replicas []ReplicaStatus `json:"replicas,omitempty"`
+
+	// Binding references the connection Secret of the instance, so that
+	// it can be bound as a Service Binding provisioned service
+	// +optional
+	Binding *corev1.LocalObjectReference `json:"binding,omitempty"`
+
+	// Conditions of the Dragonfly instance, e.g "Ready",
+	// "ReplicationConfigured", "Degraded" and "RollingUpdate"
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Pods are the results of the last health probes of the pods by the
+	// operator
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Pods []PodStatus `json:"pods,omitempty"`
+
+	// Keyspace is the size of the keyspace of the master, as of its last
+	// collection
+	// +optional
+	Keyspace *KeyspaceStatus `json:"keyspace,omitempty"`
+
+	// RestoreVerification is the result of the last verification that the
+	// snapshot of the master can be restored
+	// +optional
+	RestoreVerification *RestoreVerificationStatus `json:"restoreVerification,omitempty"`
+
+	// Backup is the state of the backups of the instance
+	// +optional
+	Backup *BackupStatus `json:"backup,omitempty"`
+
+	// Import is the progress of the import of the Redis Cluster
+	// +optional
+	Import *ImportStatus `json:"import,omitempty"`
+
+	// LogArgs are the log flags Dragonfly runs with
+	// +optional
+	LogArgs []string `json:"logArgs,omitempty"`
+
+	// SaveRequest is the value of the save request annotation that was
+	// last handled
+	// +optional
+	SaveRequest string `json:"saveRequest,omitempty"`
+
+	// FailoverRequest is the value of the failover request annotation that
+	// was last handled
+	// +optional
+	FailoverRequest string `json:"failoverRequest,omitempty"`
+
+	// Explain is the plan of the changes the operator would make to the
+	// instance, as of the last explain request
+	// +optional
+	Explain *ExplainStatus `json:"explain,omitempty"`
+
+	// MasterFailureRequest is the value of the master failure request of
+	// the fault injection that was last handled
+	// +optional
+	MasterFailureRequest string `json:"masterFailureRequest,omitempty"`
+
+	// LastSaveTime is the time at which the last requested snapshot was
+	// saved
+	// +optional
+	LastSaveTime *metav1.Time `json:"lastSaveTime,omitempty"`
+
+	// (Optional) Save is the snapshot that the operator started on the
+	// master, and which is saved in the background
+	// +optional
+	Save *SaveStatus `json:"save,omitempty"`
+
+	// TopologyChange is the change of the replication topology in
+	// progress. If it's set while no change is in progress, e.g because
+	// the operator was stopped halfway, the change is resumed.
+	// +optional
+	TopologyChange *TopologyChange `json:"topologyChange,omitempty"`
+
+	// History are the last topology changes of the instance, oldest first
+	// +optional
+	History []TopologyTransition `json:"history,omitempty"`
+
+	// ReplicationLink is the state of the replication link that the
+	// instance is the primary or a standby of
+	// +optional
+	ReplicationLink *ReplicationLinkStatus `json:"replicationLink,omitempty"`
+}
+
+type ExplainStatus struct {
+	// Request is the value of the explain request annotation that the plan
+	// was made for
+	Request string `json:"request"`
+
+	// Time at which the plan was made
+	Time metav1.Time `json:"time"`
+
+	// Master is the pod that is the master, or would be elected as master
+	// +optional
+	Master string `json:"master,omitempty"`
+
+	// Election is true if a master would be elected, as there is no
+	// healthy one
+	// +optional
+	Election bool `json:"election,omitempty"`
+
+	// PodsToReconfigure are the pods that would be configured as replicas
+	// of the master
+	// +optional
+	PodsToReconfigure []string `json:"podsToReconfigure,omitempty"`
+
+	// ResourceChanges are the resources that would be created or updated,
+	// as "create <kind>/<name>" or "update <kind>/<name>"
+	// +optional
+	ResourceChanges []string `json:"resourceChanges,omitempty"`
+
+	// Rollout is true if the pods would be rolled
+	// +optional
+	Rollout bool `json:"rollout,omitempty"`
+
+	// Errors that prevented parts of the plan from being made
+	// +optional
+	Errors []string `json:"errors,omitempty"`
+}
+
+type ReplicationLinkStatus struct {
+	// Name of the DragonflyReplicationLink, in the local cluster of the
+	// operator
+	Name string `json:"name"`
+
+	// Role of the instance in the link, primary or standby
+	Role string `json:"role"`
+
+	// LinkStatus is up once all the pods of the standbys are in sync with
+	// the primary
+	LinkStatus string `json:"linkStatus"`
+
+	// LastUpdateTime is the time at which the link was last checked
+	LastUpdateTime metav1.Time `json:"lastUpdateTime"`
+}
+
+type KeyspaceStatus struct {
+	// Keys is the total number of keys
+	Keys int64 `json:"keys"`
+
+	// Expires is the total number of keys with an expiry
+	Expires int64 `json:"expires"`
+
+	// Databases are the key counts of the non empty databases
+	// +optional
+	// +listType=map
+	// +listMapKey=database
+	Databases []DatabaseKeyspace `json:"databases,omitempty"`
+
+	// LastUpdateTime is the time at which the keyspace was collected
+	LastUpdateTime metav1.Time `json:"lastUpdateTime"`
+}
+
+type DatabaseKeyspace struct {
+	// Database is the index of the database
+	Database int32 `json:"database"`
+
+	// Keys is the number of keys in the database
+	Keys int64 `json:"keys"`
+
+	// Expires is the number of keys with an expiry in the database
+	Expires int64 `json:"expires"`
+}
+
+type RestoreVerificationStatus struct {
+	// StartTime is the time at which the verification started
+	StartTime metav1.Time `json:"startTime"`
+
+	// (Optional) CompletionTime is the time at which the verification
+	// completed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Succeeded is true if the snapshot was restored
+	Succeeded bool `json:"succeeded"`
+
+	// RestoredKeys is the number of keys of the restored snapshot
+	// +optional
+	RestoredKeys int64 `json:"restoredKeys,omitempty"`
+
+	// MasterKeys is the number of keys of the master at the time of the
+	// verification
+	// +optional
+	MasterKeys int64 `json:"masterKeys,omitempty"`
+
+	// Message describes the result of the verification
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+type BackupStatus struct {
+	// StartTime is the time at which the last backup started
+	StartTime metav1.Time `json:"startTime"`
+
+	// (Optional) CompletionTime is the time at which the last backup
+	// completed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Succeeded is true if the last backup was uploaded
+	Succeeded bool `json:"succeeded"`
+
+	// Message describes the result of the last backup
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// (Optional) Save is the snapshot that the running backup saves in
+	// the background, before it's uploaded
+	// +optional
+	Save *SaveStatus `json:"save,omitempty"`
+
+	// (Optional) LastSuccessfulTime is the time at which the last
+	// successful backup started
+	// +optional
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+
+	// (Optional) LastSuccessfulURI is the URI of the directory of the last
+	// successful backup
+	// +optional
+	LastSuccessfulURI string `json:"lastSuccessfulURI,omitempty"`
+
+	// (Optional) LastSuccessfulSizeBytes is the size of the files of the
+	// last successful backup
+	// +optional
+	LastSuccessfulSizeBytes int64 `json:"lastSuccessfulSizeBytes,omitempty"`
+}
+
+type SaveStatus struct {
+	// Reason of the save, either Request, MajorVersionUpgrade or Backup
+	Reason string `json:"reason"`
+
+	// Pod that saves the snapshot
+	Pod string `json:"pod"`
+
+	// StartTime is the time at which the save started, as reported by
+	// the pod
+	StartTime metav1.Time `json:"startTime"`
+}
+
+type ImportStatus struct {
+	// Source is the address of the Redis Cluster that is imported
+	Source string `json:"source"`
+
+	// Phase of the import, one of Running, Succeeded or Failed
+	Phase string `json:"phase"`
+
+	// Shards is the number of master shards to import
+	Shards int32 `json:"shards"`
+
+	// CompletedShards is the number of shards whose keys were imported
+	// +optional
+	CompletedShards int32 `json:"completedShards,omitempty"`
+
+	// FailedShards is the number of failed attempts to import a shard
+	// +optional
+	FailedShards int32 `json:"failedShards,omitempty"`
+
+	// StartTime is the time at which the import started
+	StartTime metav1.Time `json:"startTime"`
+
+	// (Optional) CompletionTime is the time at which the import succeeded
+	// or failed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+type TopologyChange struct {
+	// Operation that changes the topology
+	Operation string `json:"operation"`
+
+	// (Optional) Pod the operation is about
+	// +optional
+	Pod string `json:"pod,omitempty"`
+
+	// StartTime is the time at which the operation started
+	StartTime metav1.Time `json:"startTime"`
+}
+
+type TopologyTransition struct {
+	// Operation that changed the topology
+	Operation string `json:"operation"`
+
+	// (Optional) Pod the operation was about
+	// +optional
+	Pod string `json:"pod,omitempty"`
+
+	// (Optional) Master before the operation
+	// +optional
+	OldMaster string `json:"oldMaster,omitempty"`
+
+	// (Optional) Master after the operation
+	// +optional
+	NewMaster string `json:"newMaster,omitempty"`
+
+	// StartTime is the time at which the operation started
+	StartTime metav1.Time `json:"startTime"`
+
+	// Duration of the operation
+	Duration metav1.Duration `json:"duration"`
+
+	// (Optional) Error the operation failed with
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+type PodStatus struct {
+	// Name of the pod
+	Name string `json:"name"`
+
+	// Role of the pod
+	// +optional
+	Role string `json:"role,omitempty"`
+
+	// Healthy is true if the last probe of the pod succeeded
+	Healthy bool `json:"healthy"`
+
+	// LatencyMilliseconds is the latency of the last probe of the pod
+	// +optional
+	LatencyMilliseconds int64 `json:"latencyMilliseconds,omitempty"`
+
+	// Error of the last probe of the pod, if it failed
+	// +optional
+	Error string `json:"error,omitempty"`
+
+	// LastProbeTime is the time of the last probe of the pod
+	// +optional
+	LastProbeTime *metav1.Time `json:"lastProbeTime,omitempty"`
+}
+
+// ReplicaStatus is the replication state of a replica pod
+type ReplicaStatus struct {
+	// Name of the pod
+	Name string `json:"name"`
+
+	// Connected is true if the link of the replica to the master is up
+	Connected bool `json:"connected"`
+
+	// Lag is how far the replica is behind the master, as the difference
+	// of their replication offsets. It's not set if the offsets aren't
+	// reported, e.g by older Dragonfly versions.
+	// +optional
+	Lag *int64 `json:"lag,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Master",type=string,JSONPath=`.status.master`
+//+kubebuilder:printcolumn:name="Connected",type=integer,JSONPath=`.status.connectedReplicas`,description="Replicas whose link to the master is up"
+//+kubebuilder:printcolumn:name="Lag",type=integer,JSONPath=`.status.replicationLag`,description="Largest lag of the replicas behind the master"
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Dragonfly is the Schema for the dragonflies API
 type Dragonfly struct {
@@ -0,0 +1,56 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DragonflyClassSpec defines the defaults of the Dragonfly objects of a
+// DragonflyClass
+type DragonflyClassSpec struct {
+	// Defaults of the spec of the Dragonfly objects of the class, e.g their
+	// image, resources, TLS, persistence and monitoring. Lists like args
+	// are replaced as a whole by the Dragonfly objects that set them.
+	Defaults DragonflySpec `json:"defaults"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+
+// DragonflyClass bundles the defaults of the Dragonfly objects that select
+// it with spec.className, like a StorageClass does for volumes. Changes
+// to a class are rolled out to its Dragonfly objects.
+type DragonflyClass struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec DragonflyClassSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// DragonflyClassList contains a list of DragonflyClass
+type DragonflyClassList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DragonflyClass `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DragonflyClass{}, &DragonflyClassList{})
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DragonflyMaintenanceSpec defines the desired state of DragonflyMaintenance
+type DragonflyMaintenanceSpec struct {
+	// Instance is the name of the Dragonfly object in the namespace of the
+	// maintenance that the tasks are run on
+	Instance string `json:"instance"`
+
+	// Windows are the recurring maintenance windows. The tasks are run
+	// once per window, and aren't started after its end.
+	// +kubebuilder:validation:MinItems=1
+	Windows []MaintenanceWindow `json:"windows"`
+
+	// Tasks are run in order in each window. A failed task ends the run.
+	// +kubebuilder:validation:MinItems=1
+	Tasks []MaintenanceTask `json:"tasks"`
+
+	// (Optional) If true, no new runs are started
+	// +optional
+	// +kubebuilder:validation:Optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// (Optional) Number of runs kept in the history. Defaults to 10
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	HistoryLimit *int32 `json:"historyLimit,omitempty"`
+}
+
+// MaintenanceWindow is a weekly recurring time range
+type MaintenanceWindow struct {
+	// (Optional) Days of the week of the window. Every day if empty
+	// +optional
+	// +kubebuilder:validation:Optional
+	Days []MaintenanceDay `json:"days,omitempty"`
+
+	// Start of the window in UTC, as HH:MM
+	// +kubebuilder:validation:Pattern="^([01][0-9]|2[0-3]):[0-5][0-9]$"
+	Start string `json:"start"`
+
+	// (Optional) Duration of the window. Defaults to 1h
+	// +optional
+	// +kubebuilder:validation:Optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=Monday;Tuesday;Wednesday;Thursday;Friday;Saturday;Sunday
+type MaintenanceDay string
+
+// MaintenanceTask is an operational task run in the maintenance windows
+type MaintenanceTask struct {
+	// Type of the task:
+	// - "FailoverRehearsal": hands over the master role to a replica in sync
+	// - "RollingRestart": restarts the pods one by one, the master last
+	// - "Command": runs the command on the pods of the target
+	// +kubebuilder:validation:Enum=FailoverRehearsal;RollingRestart;Command
+	Type string `json:"type"`
+
+	// (Optional) Command of the Command task, e.g ["MEMORY", "DEFRAGMENT"]
+	// +optional
+	// +kubebuilder:validation:Optional
+	Command []string `json:"command,omitempty"`
+
+	// (Optional) Pods the Command task runs on. Defaults to All
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Master;Replicas;All
+	Target string `json:"target,omitempty"`
+}
+
+// DragonflyMaintenanceStatus defines the observed state of DragonflyMaintenance
+type DragonflyMaintenanceStatus struct {
+	// (Optional) Start of the next window
+	// +optional
+	NextWindowStart *metav1.Time `json:"nextWindowStart,omitempty"`
+
+	// (Optional) Run in progress
+	// +optional
+	Current *MaintenanceRun `json:"current,omitempty"`
+
+	// (Optional) Last runs, oldest first
+	// +optional
+	History []MaintenanceRun `json:"history,omitempty"`
+}
+
+// MaintenanceRun is the run of the tasks in a window
+type MaintenanceRun struct {
+	// WindowStart is the start of the window of the run
+	WindowStart metav1.Time `json:"windowStart"`
+
+	// WindowEnd is the end of the window of the run
+	WindowEnd metav1.Time `json:"windowEnd"`
+
+	// (Optional) Time at which the first task started. The tasks wait for
+	// the instance to be ready until then.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// (Optional) Time at which the run completed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Result of the run: Running, Succeeded, Failed or Skipped
+	Result string `json:"result"`
+
+	// (Optional) Results of the tasks that were started
+	// +optional
+	Tasks []MaintenanceTaskResult `json:"tasks,omitempty"`
+
+	// (Optional) Why the run failed or was skipped
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// MaintenanceTaskResult is the result of a task of a run
+type MaintenanceTaskResult struct {
+	// Type of the task
+	Type string `json:"type"`
+
+	// Result of the task: Running, Succeeded or Failed
+	Result string `json:"result"`
+
+	// (Optional) Output or error of the task
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// DragonflyMaintenance schedules recurring operational tasks of a
+// Dragonfly instance, e.g failover rehearsals, restarts and defrags, in
+// maintenance windows
+type DragonflyMaintenance struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DragonflyMaintenanceSpec   `json:"spec,omitempty"`
+	Status DragonflyMaintenanceStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// DragonflyMaintenanceList contains a list of DragonflyMaintenance
+type DragonflyMaintenanceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DragonflyMaintenance `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DragonflyMaintenance{}, &DragonflyMaintenanceList{})
+}
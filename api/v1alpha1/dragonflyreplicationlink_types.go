@@ -0,0 +1,141 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DragonflyReplicationLinkSpec defines the desired state of DragonflyReplicationLink
+type DragonflyReplicationLinkSpec struct {
+	// Primary is the instance that the standbys replicate from
+	Primary LinkedInstance `json:"primary"`
+
+	// Standbys are the instances that replicate from the primary. All
+	// their pods are replicas of the primary until they are promoted.
+	// +kubebuilder:validation:MinItems=1
+	Standbys []LinkedInstance `json:"standbys"`
+
+	// (Optional) Endpoint the standbys replicate from. Defaults to the IP
+	// of the master pod of the primary, which has to be reachable from the
+	// clusters of the standbys.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Endpoint *LinkEndpoint `json:"endpoint,omitempty"`
+
+	// (Optional) Replicate over TLS. The primary and the standbys must
+	// have replicationTLS.
+	// +optional
+	// +kubebuilder:validation:Optional
+	TLS bool `json:"tls,omitempty"`
+
+	// (Optional) Password the standbys authenticate to the primary with.
+	// Defaults to the masterauth of the standbys, i.e their own password.
+	// +optional
+	// +kubebuilder:validation:Optional
+	PasswordFromSecret *corev1.SecretKeySelector `json:"passwordFromSecret,omitempty"`
+}
+
+// LinkedInstance is a Dragonfly object in the namespace of the link, in
+// the local cluster or one of the remote clusters of the operator
+type LinkedInstance struct {
+	// Name of the Dragonfly object
+	Name string `json:"name"`
+
+	// (Optional) Remote cluster of the Dragonfly object, i.e the name of
+	// its kubeconfig Secret. Defaults to the local cluster.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Cluster string `json:"cluster,omitempty"`
+}
+
+type LinkEndpoint struct {
+	// Host of the primary
+	Host string `json:"host"`
+
+	// (Optional) Port of the primary. Defaults to the admin port.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Port int32 `json:"port,omitempty"`
+}
+
+// DragonflyReplicationLinkStatus defines the observed state of DragonflyReplicationLink
+type DragonflyReplicationLinkStatus struct {
+	// Endpoint is the host:port that the standbys replicate from
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Standbys are the link states of the standbys
+	// +optional
+	Standbys []LinkedInstanceStatus `json:"standbys,omitempty"`
+
+	// PromoteRequest is the last handled value of the promote annotation
+	// +optional
+	PromoteRequest string `json:"promoteRequest,omitempty"`
+
+	// Error that prevented the link from being configured
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+type LinkedInstanceStatus struct {
+	// Name of the Dragonfly object
+	Name string `json:"name"`
+
+	// Cluster of the Dragonfly object
+	// +optional
+	Cluster string `json:"cluster,omitempty"`
+
+	// LinkStatus is up once all the pods of the standby are in sync with
+	// the primary
+	LinkStatus string `json:"linkStatus"`
+
+	// SyncedPods is the number of pods of the standby in sync with the primary
+	SyncedPods int32 `json:"syncedPods"`
+
+	// Error that prevented the standby from being configured
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// DragonflyReplicationLink connects a primary instance to standby instances
+// in other clusters, e.g for disaster recovery. Standbys are promoted with
+// the dragonflydb.io/promote annotation.
+type DragonflyReplicationLink struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DragonflyReplicationLinkSpec   `json:"spec,omitempty"`
+	Status DragonflyReplicationLinkStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// DragonflyReplicationLinkList contains a list of DragonflyReplicationLink
+type DragonflyReplicationLinkList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DragonflyReplicationLink `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DragonflyReplicationLink{}, &DragonflyReplicationLinkList{})
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// DragonflyTemplateSpec defines the desired state of DragonflyTemplate
+type DragonflyTemplateSpec struct {
+	// Template of the Dragonfly objects
+	Template DragonflyObjectTemplate `json:"template"`
+
+	// Instances are the Dragonfly objects stamped out from the template.
+	// Instances removed from the list are deleted.
+	// +listType=map
+	// +listMapKey=namespace
+	// +listMapKey=name
+	Instances []TemplateInstance `json:"instances"`
+}
+
+type DragonflyObjectTemplate struct {
+	// (Optional) Labels and annotations of the Dragonfly objects
+	// +optional
+	// +kubebuilder:validation:Optional
+	Metadata TemplateMetadata `json:"metadata,omitempty"`
+
+	// Spec of the Dragonfly objects
+	Spec DragonflySpec `json:"spec"`
+}
+
+type TemplateMetadata struct {
+	// (Optional) Labels of the Dragonfly objects
+	// +optional
+	// +kubebuilder:validation:Optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// (Optional) Annotations of the Dragonfly objects
+	// +optional
+	// +kubebuilder:validation:Optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type TemplateInstance struct {
+	// Name of the Dragonfly object
+	Name string `json:"name"`
+
+	// Namespace of the Dragonfly object
+	Namespace string `json:"namespace"`
+
+	// (Optional) Strategic merge patch applied to the spec of the template
+	// for this instance, e.g to change its replicas or resources
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Overrides *runtime.RawExtension `json:"overrides,omitempty"`
+}
+
+// DragonflyTemplateStatus defines the observed state of DragonflyTemplate
+type DragonflyTemplateStatus struct {
+	// ObservedGeneration is the generation of the template that the
+	// instances were last stamped out from
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ReadyInstances is the number of instances in the ready phase
+	ReadyInstances int32 `json:"readyInstances"`
+
+	// Instances are the states of the stamped out instances
+	// +optional
+	// +listType=map
+	// +listMapKey=namespace
+	// +listMapKey=name
+	Instances []TemplateInstanceStatus `json:"instances,omitempty"`
+}
+
+type TemplateInstanceStatus struct {
+	// Name of the Dragonfly object
+	Name string `json:"name"`
+
+	// Namespace of the Dragonfly object
+	Namespace string `json:"namespace"`
+
+	// Phase of the Dragonfly object
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// Error that prevented the instance from being stamped out
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+
+// DragonflyTemplate stamps out Dragonfly objects across namespaces from a
+// shared template, so that large fleets of similar instances stay
+// consistent
+type DragonflyTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DragonflyTemplateSpec   `json:"spec,omitempty"`
+	Status DragonflyTemplateStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// DragonflyTemplateList contains a list of DragonflyTemplate
+type DragonflyTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DragonflyTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DragonflyTemplate{}, &DragonflyTemplateList{})
+}
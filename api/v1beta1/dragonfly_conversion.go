@@ -0,0 +1,191 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+var _ conversion.Convertible = &Dragonfly{}
+
+// ConvertTo converts the Dragonfly object to the v1alpha1 hub version
+func (src *Dragonfly) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha1.Dragonfly)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Status = src.Status
+	dst.Spec = convertSpecToV1alpha1(&src.Spec)
+	return nil
+}
+
+// ConvertFrom converts the Dragonfly object from the v1alpha1 hub version
+func (dst *Dragonfly) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1alpha1.Dragonfly)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Status = src.Status
+	dst.Spec = convertSpecFromV1alpha1(&src.Spec)
+	return nil
+}
+
+// convertSpecToV1alpha1 converts the spec to its v1alpha1 layout
+func convertSpecToV1alpha1(src *DragonflySpec) v1alpha1.DragonflySpec {
+	dst := v1alpha1.DragonflySpec{
+		ClassName:             src.ClassName,
+		Replicas:              src.Replicas,
+		Image:                 src.Image,
+		ImagePullPolicy:       src.ImagePullPolicy,
+		Args:                  src.Args,
+		Logging:               src.Logging,
+		Command:               src.Command,
+		Annotations:           src.Annotations,
+		Env:                   src.Env,
+		Resources:             src.Resources,
+		Size:                  src.Size,
+		PodManagementPolicy:   src.PodManagementPolicy,
+		MinReadySeconds:       src.MinReadySeconds,
+		Affinity:              src.Affinity,
+		AntiAffinityMode:      src.AntiAffinityMode,
+		Tolerations:           src.Tolerations,
+		ServiceAccountName:    src.ServiceAccountName,
+		Bootstrap:             src.Bootstrap,
+		Import:                src.Import,
+		FaultInjection:        src.FaultInjection,
+		DNSPolicy:             src.DNSPolicy,
+		DNSConfig:             src.DNSConfig,
+		HostAliases:           src.HostAliases,
+		ReplicationCooldown:   src.ReplicationCooldown,
+		FailoverMaxWait:       src.FailoverMaxWait,
+		Announce:              src.Announce,
+		UnixSocket:            src.UnixSocket,
+		StatefulSetOverrides:  src.StatefulSetOverrides,
+		Replication:           src.Replication,
+		VersionUpgrade:        src.VersionUpgrade,
+		EvictionPolicy:        src.EvictionPolicy,
+		KeyspaceNotifications: src.KeyspaceNotifications,
+		ReplicationBackoff:    src.ReplicationBackoff,
+		RolloutAnalysis:       src.RolloutAnalysis,
+		UpdateStrategy:        src.UpdateStrategy,
+		PrometheusRule:        src.PrometheusRule,
+		Metrics:               src.Metrics,
+		Route:                 src.Route,
+		ConnectionSecret:      src.ConnectionSecret,
+		Proxy:                 src.Proxy,
+		Failover:              src.Failover,
+		EvictionProtection:    src.EvictionProtection,
+		CommonMetadata:        src.CommonMetadata,
+		ExtraPorts:            src.ExtraPorts,
+		Authentication:        src.Auth,
+		Snapshot:              src.Storage,
+	}
+
+	if tls := src.TLS; tls != nil {
+		dst.TLSSecretRef = tls.SecretRef
+		dst.TLSSecretKeys = tls.SecretKeys
+		dst.ReplicationTLS = tls.Replication
+		if tls.CertManager != nil {
+			dst.TLS = &v1alpha1.TLS{CertManager: tls.CertManager}
+		}
+	}
+
+	if services := src.Services; services != nil {
+		dst.ReplicaService = services.Replicas
+		if services.Master != nil {
+			dst.ManageMasterEndpoints = services.Master.ManageEndpoints
+			dst.ServiceSpecOverride = services.Master.SpecOverride
+		}
+	}
+
+	return dst
+}
+
+// convertSpecFromV1alpha1 converts the spec from its v1alpha1 layout.
+// Groups that would be empty are left unset.
+func convertSpecFromV1alpha1(src *v1alpha1.DragonflySpec) DragonflySpec {
+	dst := DragonflySpec{
+		ClassName:             src.ClassName,
+		Replicas:              src.Replicas,
+		Image:                 src.Image,
+		ImagePullPolicy:       src.ImagePullPolicy,
+		Args:                  src.Args,
+		Logging:               src.Logging,
+		Command:               src.Command,
+		Annotations:           src.Annotations,
+		Env:                   src.Env,
+		Resources:             src.Resources,
+		Size:                  src.Size,
+		PodManagementPolicy:   src.PodManagementPolicy,
+		MinReadySeconds:       src.MinReadySeconds,
+		Affinity:              src.Affinity,
+		AntiAffinityMode:      src.AntiAffinityMode,
+		Tolerations:           src.Tolerations,
+		ServiceAccountName:    src.ServiceAccountName,
+		Bootstrap:             src.Bootstrap,
+		Import:                src.Import,
+		FaultInjection:        src.FaultInjection,
+		DNSPolicy:             src.DNSPolicy,
+		DNSConfig:             src.DNSConfig,
+		HostAliases:           src.HostAliases,
+		ReplicationCooldown:   src.ReplicationCooldown,
+		FailoverMaxWait:       src.FailoverMaxWait,
+		Announce:              src.Announce,
+		UnixSocket:            src.UnixSocket,
+		StatefulSetOverrides:  src.StatefulSetOverrides,
+		Replication:           src.Replication,
+		VersionUpgrade:        src.VersionUpgrade,
+		EvictionPolicy:        src.EvictionPolicy,
+		KeyspaceNotifications: src.KeyspaceNotifications,
+		ReplicationBackoff:    src.ReplicationBackoff,
+		RolloutAnalysis:       src.RolloutAnalysis,
+		UpdateStrategy:        src.UpdateStrategy,
+		PrometheusRule:        src.PrometheusRule,
+		Metrics:               src.Metrics,
+		Route:                 src.Route,
+		ConnectionSecret:      src.ConnectionSecret,
+		Proxy:                 src.Proxy,
+		Failover:              src.Failover,
+		EvictionProtection:    src.EvictionProtection,
+		CommonMetadata:        src.CommonMetadata,
+		ExtraPorts:            src.ExtraPorts,
+		Auth:                  src.Authentication,
+		Storage:               src.Snapshot,
+	}
+
+	tls := &TLS{
+		SecretRef:   src.TLSSecretRef,
+		SecretKeys:  src.TLSSecretKeys,
+		Replication: src.ReplicationTLS,
+	}
+	if src.TLS != nil {
+		tls.CertManager = src.TLS.CertManager
+	}
+	if *tls != (TLS{}) {
+		dst.TLS = tls
+	}
+
+	master := &MasterService{
+		ManageEndpoints: src.ManageMasterEndpoints,
+		SpecOverride:    src.ServiceSpecOverride,
+	}
+	if *master != (MasterService{}) || src.ReplicaService != nil {
+		dst.Services = &Services{Replicas: src.ReplicaService}
+		if *master != (MasterService{}) {
+			dst.Services.Master = master
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"testing"
+
+	"github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConversionRoundTrip(t *testing.T) {
+	objectMeta := metav1.ObjectMeta{
+		Name:        "df",
+		Namespace:   "default",
+		Labels:      map[string]string{"app": "cache"},
+		Annotations: map[string]string{"example.com/owner": "cache"},
+	}
+	status := v1alpha1.DragonflyStatus{Phase: "ready", IsRollingUpdate: true}
+	replicas := int32(3)
+
+	tests := []struct {
+		name string
+		spec v1alpha1.DragonflySpec
+		// want is the v1beta1 spec the v1alpha1 one converts to
+		want DragonflySpec
+	}{
+		{
+			name: "minimal spec",
+			spec: v1alpha1.DragonflySpec{Replicas: 1, Image: "dragonfly:v1.12.0"},
+			want: DragonflySpec{Replicas: 1, Image: "dragonfly:v1.12.0"},
+		},
+		{
+			name: "renamed fields",
+			spec: v1alpha1.DragonflySpec{
+				Replicas: replicas,
+				Authentication: &v1alpha1.Authentication{
+					PasswordFromSecret: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "df-password"},
+						Key:                  "password",
+					},
+				},
+				Snapshot: &v1alpha1.Snapshot{Cron: "*/5 * * * *"},
+			},
+			want: DragonflySpec{
+				Replicas: replicas,
+				Auth: &v1alpha1.Authentication{
+					PasswordFromSecret: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "df-password"},
+						Key:                  "password",
+					},
+				},
+				Storage: &v1alpha1.Snapshot{Cron: "*/5 * * * *"},
+			},
+		},
+		{
+			name: "tls secret",
+			spec: v1alpha1.DragonflySpec{
+				TLSSecretRef:   &corev1.SecretReference{Name: "df-tls"},
+				TLSSecretKeys:  &v1alpha1.TLSSecretKeys{Cert: "cert.pem"},
+				ReplicationTLS: &v1alpha1.ReplicationTLS{},
+			},
+			want: DragonflySpec{
+				TLS: &TLS{
+					SecretRef:   &corev1.SecretReference{Name: "df-tls"},
+					SecretKeys:  &v1alpha1.TLSSecretKeys{Cert: "cert.pem"},
+					Replication: &v1alpha1.ReplicationTLS{},
+				},
+			},
+		},
+		{
+			name: "tls cert-manager",
+			spec: v1alpha1.DragonflySpec{
+				TLS: &v1alpha1.TLS{
+					CertManager: &v1alpha1.CertManager{IssuerRef: v1alpha1.IssuerReference{Name: "ca"}},
+				},
+			},
+			want: DragonflySpec{
+				TLS: &TLS{
+					CertManager: &v1alpha1.CertManager{IssuerRef: v1alpha1.IssuerReference{Name: "ca"}},
+				},
+			},
+		},
+		{
+			name: "replica service",
+			spec: v1alpha1.DragonflySpec{
+				ReplicaService: &v1alpha1.ReplicaService{Labels: map[string]string{"app": "cache"}},
+			},
+			want: DragonflySpec{
+				Services: &Services{
+					Replicas: &v1alpha1.ReplicaService{Labels: map[string]string{"app": "cache"}},
+				},
+			},
+		},
+		{
+			name: "master service",
+			spec: v1alpha1.DragonflySpec{
+				ManageMasterEndpoints: true,
+				ServiceSpecOverride:   &corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+			},
+			want: DragonflySpec{
+				Services: &Services{
+					Master: &MasterService{
+						ManageEndpoints: true,
+						SpecOverride:    &corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := &v1alpha1.Dragonfly{ObjectMeta: objectMeta, Spec: tt.spec, Status: status}
+
+			converted := &Dragonfly{}
+			if err := converted.ConvertFrom(hub); err != nil {
+				t.Fatalf("ConvertFrom() error = %v", err)
+			}
+			if !equality.Semantic.DeepEqual(converted.Spec, tt.want) {
+				t.Errorf("ConvertFrom() spec = %+v, want %+v", converted.Spec, tt.want)
+			}
+			if !equality.Semantic.DeepEqual(converted.ObjectMeta, objectMeta) || !equality.Semantic.DeepEqual(converted.Status, status) {
+				t.Error("ConvertFrom() did not keep the metadata and status")
+			}
+
+			roundTrip := &v1alpha1.Dragonfly{}
+			if err := converted.ConvertTo(roundTrip); err != nil {
+				t.Fatalf("ConvertTo() error = %v", err)
+			}
+			if !equality.Semantic.DeepEqual(roundTrip, hub) {
+				t.Errorf("round trip = %+v, want %+v", roundTrip, hub)
+			}
+		})
+	}
+}
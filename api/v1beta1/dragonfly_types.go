@@ -0,0 +1,445 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// DragonflySpec defines the desired state of Dragonfly. It has the fields
+// of the v1alpha1 spec, with the authentication, the TLS, the snapshot
+// volume and the Services grouped under auth, tls, storage and services.
+// The nested types are the ones of v1alpha1.
+type DragonflySpec struct {
+	// (Optional) Name of the DragonflyClass that provides the defaults of
+	// this spec. Fields set here take precedence over the class.
+	// +optional
+	// +kubebuilder:validation:Optional
+	ClassName string `json:"className,omitempty"`
+
+	// Replicas is the total number of Dragonfly instances including the master
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// Image is the Dragonfly image to use. It can be pinned to a digest
+	// with image@sha256:<digest>
+	Image string `json:"image,omitempty"`
+
+	// (Optional) Pull policy of the Dragonfly image. Defaults to Always, or
+	// to IfNotPresent if the image is pinned to a digest
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// (Optional) Dragonfly container args to pass to the container
+	// Refer to the Dragonfly documentation for the list of supported args
+	// +optional
+	// +kubebuilder:validation:Optional
+	Args []string `json:"args,omitempty"`
+
+	// (Optional) Logging configuration of Dragonfly. The log flags of
+	// args take precedence.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Logging *v1alpha1.Logging `json:"logging,omitempty"`
+
+	// (Optional) Command of the Dragonfly container, to run Dragonfly under
+	// a wrapper such as numactl. The last element must be the dragonfly
+	// binary, as the operator appends its managed flags and the health
+	// checks look for the dragonfly process.
+	// e.g ["numactl", "--interleave=all", "dragonfly"]
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MinItems=1
+	Command []string `json:"command,omitempty"`
+
+	// (Optional) Annotations to add to the Dragonfly pods.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// (Optional) Env variables to add to the Dragonfly pods.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// (Optional) Dragonfly container resource limits. Any container limits
+	// can be specified.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// (Optional) Size preset of the Dragonfly instance. It sets the
+	// resources, the number of threads (--proactor_threads) and
+	// --maxmemory. Each of them can be overridden with resources and args.
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=small;medium;large;xlarge
+	Size string `json:"size,omitempty"`
+
+	// (Optional) Pod management policy of the StatefulSet. Parallel starts
+	// all the pods at once, which is faster for large instances. The
+	// operator then waits for the pods to be ready to configure replication.
+	// Defaults to OrderedReady. It can't be changed, as it's immutable on
+	// StatefulSets.
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=OrderedReady;Parallel
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="podManagementPolicy is immutable"
+	PodManagementPolicy appsv1.PodManagementPolicyType `json:"podManagementPolicy,omitempty"`
+
+	// (Optional) Minimum number of seconds a pod must be ready before it's
+	// considered available, both by the StatefulSet and by the operator.
+	// Pods that aren't available yet are neither promoted nor given a role.
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	MinReadySeconds int32 `json:"minReadySeconds,omitempty"`
+
+	// (Optional) Dragonfly pod affinity
+	// +optional
+	// +kubebuilder:validation:Optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// (Optional) Anti-affinity of the pods of the instance, used when no
+	// affinity is set. With required, no two pods share a node, and with
+	// preferred they are spread over the nodes where possible. Either way
+	// they are spread over the zones where possible. Defaults to preferred,
+	// none disables it.
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=required;preferred;none
+	AntiAffinityMode string `json:"antiAffinityMode,omitempty"`
+
+	// (Optional) Dragonfly pod tolerations
+	// +optional
+	// +kubebuilder:validation:Optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// (Optional) Dragonfly pod service account name
+	// +optional
+	// +kubebuilder:validation:Optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// (Optional) Seed the data of new pods from a snapshot, e.g to prewarm
+	// caches or import an exported dataset. Requires a snapshot volume.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Bootstrap *v1alpha1.Bootstrap `json:"bootstrap,omitempty"`
+
+	// (Optional) Import the keys of a Redis Cluster into the instance once
+	// it's ready, e.g to migrate from a sharded Redis Cluster. The keys of
+	// every master shard are copied by a Job, and a new import is started
+	// whenever the source changes.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Import *v1alpha1.Import `json:"import,omitempty"`
+
+	// (Optional) Inject failures into the instance, to rehearse failovers
+	// in staging clusters. Only applied by operators started with
+	// --enable-fault-injection.
+	// +optional
+	// +kubebuilder:validation:Optional
+	FaultInjection *v1alpha1.FaultInjection `json:"faultInjection,omitempty"`
+
+	// (Optional) Dragonfly pod DNS policy
+	// +optional
+	// +kubebuilder:validation:Optional
+	DNSPolicy corev1.DNSPolicy `json:"dnsPolicy,omitempty"`
+
+	// (Optional) Dragonfly pod DNS config. Parameters specified here are
+	// merged with the ones generated from the DNS policy.
+	// +optional
+	// +kubebuilder:validation:Optional
+	DNSConfig *corev1.PodDNSConfig `json:"dnsConfig,omitempty"`
+
+	// (Optional) Dragonfly pod host aliases to be added to the pod's hosts file
+	// +optional
+	// +kubebuilder:validation:Optional
+	HostAliases []corev1.HostAlias `json:"hostAliases,omitempty"`
+
+	// (Optional) Minimum time between two SLAVEOF commands issued to the
+	// same pod for the same master. Protects full syncs from being
+	// interrupted repeatedly by flapping pods. Defaults to 10s.
+	// +optional
+	// +kubebuilder:validation:Optional
+	ReplicationCooldown *metav1.Duration `json:"replicationCooldown,omitempty"`
+
+	// (Optional) Maximum time to wait for the replica that is being promoted
+	// during a planned failover to acknowledge all writes of the master.
+	// The failover is retried later if the replica does not catch up in time.
+	// Defaults to 30s.
+	// +optional
+	// +kubebuilder:validation:Optional
+	FailoverMaxWait *metav1.Duration `json:"failoverMaxWait,omitempty"`
+
+	// (Optional) Address that the Dragonfly pods announce for replication.
+	// Useful when replicas are behind NAT or in other clusters.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Announce *v1alpha1.Announce `json:"announce,omitempty"`
+
+	// (Optional) Listen on a unix socket as well, in an emptyDir volume
+	// named unix-socket that is mounted at /var/run/dragonfly. Sidecars
+	// added with statefulSetOverrides can mount the volume to connect
+	// through the socket without going over TCP.
+	// +optional
+	// +kubebuilder:validation:Optional
+	UnixSocket *v1alpha1.UnixSocket `json:"unixSocket,omitempty"`
+
+	// (Optional) Strategic merge patch applied last to the generated
+	// StatefulSet, to set fields that aren't part of this API yet.
+	// Containers are merged by name, the Dragonfly container is named
+	// dragonfly. Use with care, the patch isn't validated by the operator.
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	StatefulSetOverrides *runtime.RawExtension `json:"statefulSetOverrides,omitempty"`
+
+	// (Optional) Dragonfly replication tuning. Unset fields use the
+	// Dragonfly defaults.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Replication *v1alpha1.Replication `json:"replication,omitempty"`
+
+	// (Optional) Handling of rollouts to another major version of Dragonfly
+	// +optional
+	// +kubebuilder:validation:Optional
+	VersionUpgrade *v1alpha1.VersionUpgrade `json:"versionUpgrade,omitempty"`
+
+	// (Optional) What to do when maxmemory is reached. "NoEviction" rejects
+	// writes, "Cache" evicts keys, so it can't be combined with persistent
+	// snapshots whose data is meant to be kept. Defaults to "NoEviction"
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=NoEviction;Cache
+	EvictionPolicy string `json:"evictionPolicy,omitempty"`
+
+	// (Optional) Classes of keyspace events to notify clients about, in
+	// the notify-keyspace-events format of Redis, e.g "Ex" for expired
+	// key events. Dragonfly currently only notifies about expired keys.
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern=`^[KEg$lshzxetmdnA]*$`
+	KeyspaceNotifications string `json:"keyspaceNotifications,omitempty"`
+
+	// (Optional) Backoff of the retries after failing to configure
+	// replication. Defaults to an initial delay of 5s doubling up to 5m.
+	// +optional
+	// +kubebuilder:validation:Optional
+	ReplicationBackoff *v1alpha1.Backoff `json:"replicationBackoff,omitempty"`
+
+	// (Optional) Metric based analysis of the updated replicas during a
+	// rollout. The rollout only continues while all queries pass.
+	// +optional
+	// +kubebuilder:validation:Optional
+	RolloutAnalysis *v1alpha1.RolloutAnalysis `json:"rolloutAnalysis,omitempty"`
+
+	// (Optional) Update strategy of the pods. With RollingUpdate, the
+	// operator rolls the replicas and then fails over the master. With
+	// OnDelete, pods are only updated when they are deleted. The StatefulSet
+	// itself always uses OnDelete, as the operator performs the rollout.
+	// +optional
+	// +kubebuilder:validation:Optional
+	UpdateStrategy *v1alpha1.UpdateStrategy `json:"updateStrategy,omitempty"`
+
+	// (Optional) Generate a PrometheusRule with default alerts for the
+	// instance. Requires the Prometheus Operator.
+	// +optional
+	// +kubebuilder:validation:Optional
+	PrometheusRule *v1alpha1.PrometheusRule `json:"prometheusRule,omitempty"`
+
+	// (Optional) Expose the metrics of all the pods on the <name>-metrics
+	// headless Service, on the named metrics port, separate from the
+	// client port. The Service is annotated for scraping by Prometheus.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Metrics *v1alpha1.Metrics `json:"metrics,omitempty"`
+
+	// (Optional) Generate an OpenShift Route with TLS passthrough to the
+	// master. Dragonfly serves clients and its HTTP console on the same
+	// port, so the Route exposes both. Requires TLS to be enabled.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Route *v1alpha1.Route `json:"route,omitempty"`
+
+	// (Optional) Publish a Secret with the connection details (host, port,
+	// password and TLS CA) of the instance, for applications to mount.
+	// It's kept up to date by the operator.
+	// +optional
+	// +kubebuilder:validation:Optional
+	ConnectionSecret *v1alpha1.ConnectionSecret `json:"connectionSecret,omitempty"`
+
+	// (Optional) Run a tier of Envoy Redis proxies in front of the
+	// instance, exposed by the <name>-proxy Service. The operator points
+	// the proxies to the new master on failovers without restarting them,
+	// so that client connections survive master switches.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Proxy *v1alpha1.Proxy `json:"proxy,omitempty"`
+
+	// (Optional) Failover configuration. A replica is promoted when the
+	// master or its node is not ready for longer than the grace period,
+	// which this tunes.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Failover *v1alpha1.Failover `json:"failover,omitempty"`
+
+	// (Optional) If true, a PodDisruptionBudget blocks the eviction of the
+	// master. When the node of the master is cordoned, e.g to be drained,
+	// the operator first hands over the master role to a replica, and the
+	// eviction goes through once the pod is no longer the master. Replicas
+	// are evicted as usual.
+	// +optional
+	// +kubebuilder:validation:Optional
+	EvictionProtection bool `json:"evictionProtection,omitempty"`
+
+	// (Optional) Labels and annotations to add to all the resources
+	// generated by the operator. They don't override the labels set by
+	// the operator.
+	// +optional
+	// +kubebuilder:validation:Optional
+	CommonMetadata *v1alpha1.CommonMetadata `json:"commonMetadata,omitempty"`
+
+	// (Optional) Additional ports to open on the Dragonfly container and
+	// to expose on the Service, e.g for sidecars or custom exporters
+	// +optional
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=name
+	ExtraPorts []v1alpha1.ExtraPort `json:"extraPorts,omitempty"`
+
+	// (Optional) Dragonfly Authentication mechanism
+	// +optional
+	// +kubebuilder:validation:Optional
+	Auth *v1alpha1.Authentication `json:"auth,omitempty"`
+
+	// (Optional) TLS of the connections to Dragonfly
+	// +optional
+	// +kubebuilder:validation:Optional
+	TLS *TLS `json:"tls,omitempty"`
+
+	// (Optional) Persistence of the snapshots of Dragonfly
+	// +optional
+	// +kubebuilder:validation:Optional
+	Storage *v1alpha1.Snapshot `json:"storage,omitempty"`
+
+	// (Optional) Services of the Dragonfly instance
+	// +optional
+	// +kubebuilder:validation:Optional
+	Services *Services `json:"services,omitempty"`
+}
+
+// TLS configures the TLS of the connections to Dragonfly
+type TLS struct {
+	// (Optional) Dragonfly TLS secret to used for TLS connections to
+	// Dragonfly. Dragonfly instance must have access to this secret and be
+	// in the same namespace
+	// +optional
+	// +kubebuilder:validation:Optional
+	SecretRef *corev1.SecretReference `json:"secretRef,omitempty"`
+
+	// (Optional) Key names of the certificate, private key and CA in the
+	// TLS secret, for secrets that are not of type kubernetes.io/tls (e.g
+	// produced by an ExternalSecret).
+	// +optional
+	// +kubebuilder:validation:Optional
+	SecretKeys *v1alpha1.TLSSecretKeys `json:"secretKeys,omitempty"`
+
+	// (Optional) Issue the certificates with cert-manager. The operator
+	// creates the Certificate resources and serves TLS with the issued
+	// certificate, so secretRef must not be set.
+	// +optional
+	// +kubebuilder:validation:Optional
+	CertManager *v1alpha1.CertManager `json:"certManager,omitempty"`
+
+	// (Optional) TLS of the replication link between the master and the
+	// replicas
+	// +optional
+	// +kubebuilder:validation:Optional
+	Replication *v1alpha1.ReplicationTLS `json:"replication,omitempty"`
+}
+
+// Services configures the Services of the Dragonfly instance
+type Services struct {
+	// (Optional) Service that selects the master
+	// +optional
+	// +kubebuilder:validation:Optional
+	Master *MasterService `json:"master,omitempty"`
+
+	// (Optional) Expose the replicas on the <name>-replicas Service, e.g
+	// for read only clients. It follows the role labels of the pods, so
+	// it doesn't select the master, also after a failover.
+	// +optional
+	// +kubebuilder:validation:Optional
+	Replicas *v1alpha1.ReplicaService `json:"replicas,omitempty"`
+}
+
+// MasterService configures the Service that selects the master
+type MasterService struct {
+	// (Optional) If true, the operator manages the EndpointSlice of the
+	// master Service directly instead of relying on a role label selector.
+	// This makes the switch of write traffic during a failover a single
+	// endpoint update.
+	// +optional
+	// +kubebuilder:validation:Optional
+	ManageEndpoints bool `json:"manageEndpoints,omitempty"`
+
+	// (Optional) Service spec that is strategically merged into the
+	// generated Service, e.g to set sessionAffinity or
+	// externalTrafficPolicy. Ports are merged by port number.
+	// +optional
+	// +kubebuilder:validation:Optional
+	SpecOverride *corev1.ServiceSpec `json:"specOverride,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:unservedversion
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Master",type=string,JSONPath=`.status.master`
+//+kubebuilder:printcolumn:name="Connected",type=integer,JSONPath=`.status.connectedReplicas`,description="Replicas whose link to the master is up"
+//+kubebuilder:printcolumn:name="Lag",type=integer,JSONPath=`.status.replicationLag`,description="Largest lag of the replicas behind the master"
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Dragonfly is the Schema for the dragonflies API
+type Dragonfly struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DragonflySpec            `json:"spec,omitempty"`
+	Status v1alpha1.DragonflyStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// DragonflyList contains a list of Dragonfly
+type DragonflyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Dragonfly `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Dragonfly{}, &DragonflyList{})
+}
@@ -17,27 +17,44 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	typedv1core "k8s.io/client-go/kubernetes/typed/core/v1"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/record"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	dragonflydbiov1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	dragonflydbiov1beta1 "github.com/dragonflydb/dragonfly-operator/api/v1beta1"
+	"github.com/dragonflydb/dragonfly-operator/internal/budget"
 	"github.com/dragonflydb/dragonfly-operator/internal/controller"
+	"github.com/dragonflydb/dragonfly-operator/internal/health"
+	"github.com/dragonflydb/dragonfly-operator/internal/notifications"
+	"github.com/dragonflydb/dragonfly-operator/internal/rbac"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	"github.com/dragonflydb/dragonfly-operator/internal/webhook"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -55,6 +72,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(dragonflydbiov1alpha1.AddToScheme(scheme))
+	utilruntime.Must(dragonflydbiov1beta1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -63,12 +81,77 @@ func main() {
 	var enableLeaderElection bool
 	var probeAddr string
 	var versionFlag bool
+	var notificationsConfig string
+	var memoryBudgetsConfig string
+	var checkCapacity bool
+	var enableFaultInjection bool
+	var enableWebhooks bool
+	var remoteClusterSecrets string
+	var stuckPhaseThreshold time.Duration
+	var roleLabelGCInterval time.Duration
+	var keyspaceCollectionInterval time.Duration
+	var usageCollectionInterval time.Duration
+	var costLabels string
+	var sentinelAddr string
+	var watchNamespaces string
+	var printRBAC bool
+	var rbacServiceAccount string
+	var shard controller.Shard
+	gracefulShutdownTimeout := 30 * time.Second
+	rateLimiterOptions := controller.DefaultRateLimiterOptions
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.BoolVar(&versionFlag, "version", false, "Print version and exist")
+	flag.StringVar(&remoteClusterSecrets, "remote-cluster-secrets", "",
+		"Comma separated list of namespace/name of Secrets with a kubeconfig key. "+
+			"The Dragonfly objects of the referenced clusters are managed as well.")
+	flag.DurationVar(&stuckPhaseThreshold, "stuck-phase-threshold", 10*time.Minute,
+		"Time after which an instance that is configuring replication or degraded is reported as stuck.")
+	flag.DurationVar(&roleLabelGCInterval, "role-label-gc-interval", 5*time.Minute,
+		"How often the role labels of pods that are no longer part of an instance are cleared.")
+	flag.DurationVar(&keyspaceCollectionInterval, "keyspace-collection-interval", time.Minute,
+		"How often the number of keys of the instances is recorded in their status and metrics.")
+	flag.DurationVar(&usageCollectionInterval, "usage-collection-interval", time.Minute,
+		"How often the usage metrics of the instances are recorded.")
+	flag.StringVar(&costLabels, "cost-labels", "",
+		"Comma separated list of labels of the Dragonfly objects that are added to their usage metrics, e.g. for chargeback.")
+	flag.StringVar(&sentinelAddr, "sentinel-bind-address", "",
+		"The address the Sentinel compatible endpoint binds to, e.g. :26379. Disabled if empty.")
+	flag.DurationVar(&rateLimiterOptions.BaseDelay, "rate-limiter-base-delay", rateLimiterOptions.BaseDelay,
+		"Initial delay of the retries of failed reconciles of an object, doubled on each failure.")
+	flag.DurationVar(&rateLimiterOptions.MaxDelay, "rate-limiter-max-delay", rateLimiterOptions.MaxDelay,
+		"Maximum delay of the retries of failed reconciles of an object.")
+	flag.Float64Var(&rateLimiterOptions.QPS, "rate-limiter-qps", rateLimiterOptions.QPS,
+		"Overall rate of requeued reconciles per second of each controller.")
+	flag.IntVar(&rateLimiterOptions.BucketSize, "rate-limiter-bucket-size", rateLimiterOptions.BucketSize,
+		"Burst of requeued reconciles of each controller above the rate.")
+	flag.Float64Var(&rateLimiterOptions.InstanceQPS, "rate-limiter-instance-qps", rateLimiterOptions.InstanceQPS,
+		"Rate of reconciles per second of the pod events of each instance, so that no instance monopolizes the workers. Unlimited if 0.")
+	flag.IntVar(&rateLimiterOptions.InstanceBucketSize, "rate-limiter-instance-bucket-size", rateLimiterOptions.InstanceBucketSize,
+		"Burst of reconciles of the pod events of each instance above the rate.")
+	flag.StringVar(&watchNamespaces, "watch-namespaces", "",
+		"Comma separated list of namespaces whose Dragonfly objects are managed. All namespaces are managed if empty.")
+	flag.BoolVar(&printRBAC, "print-rbac", false,
+		"Print the Roles and RoleBindings that the operator needs to manage the namespaces of --watch-namespaces, and exit.")
+	flag.StringVar(&rbacServiceAccount, "rbac-service-account", "dragonfly-operator-system/dragonfly-operator-controller-manager",
+		"namespace/name of the service account that --print-rbac binds the roles to.")
+	flag.StringVar(&notificationsConfig, "notifications-config", "", "Path to the configuration file of the webhooks to notify about operational events.")
+	flag.BoolVar(&checkCapacity, "check-capacity", false,
+		"Keep new instances pending with the PendingCapacity condition while their pods don't fit in the free memory of the nodes.")
+	flag.IntVar(&shard.Count, "shards", 1,
+		"Number of operator replicas that the Dragonfly objects are sharded over, by the hash of their namespace and name.")
+	flag.IntVar(&shard.Index, "shard-index", -1,
+		"Index of the shard of this replica. Taken from the ordinal suffix of the hostname, e.g of a StatefulSet pod, if -1.")
+	flag.BoolVar(&enableFaultInjection, "enable-fault-injection", false,
+		"Apply the faultInjection of the Dragonfly objects, to rehearse failovers. Only meant for staging clusters.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Serve the defaulting, validating and conversion webhooks of the Dragonfly objects. Requires a serving certificate for the webhook server.")
+	flag.StringVar(&memoryBudgetsConfig, "memory-budgets-config", "", "Path to the configuration file of the aggregate memory budgets of the instances.")
+	flag.StringVar(&resources.ClusterDomain, "cluster-domain", resources.ClusterDomain,
+		"DNS domain of the cluster, used for the host of the connection Secrets and the DNS names of the certificates.")
 
 	opts := zap.Options{
 		Development: true,
@@ -83,13 +166,69 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if shard.Count > 1 && shard.Index < 0 {
+		hostname, err := os.Hostname()
+		if err != nil {
+			setupLog.Error(err, "unable to get hostname for the shard index")
+			os.Exit(1)
+		}
+
+		if index, ok := controller.ShardIndexFromHostname(hostname); ok {
+			shard.Index = index
+		}
+	}
+
+	if err := shard.Validate(); err != nil {
+		setupLog.Error(err, "invalid shard")
+		os.Exit(1)
+	}
+
+	// each shard elects its own leader
+	leaderElectionID := "31079dea.dragonflydb.io"
+	if shard.Count > 1 {
+		leaderElectionID = fmt.Sprintf("shard-%d.%s", shard.Index, leaderElectionID)
+		setupLog.Info("managing a shard of the Dragonfly objects", "shard", shard.Index, "shards", shard.Count)
+	}
+
+	var namespaces []string
+	for _, namespace := range strings.Split(watchNamespaces, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+
+	if printRBAC {
+		saNamespace, saName, ok := strings.Cut(rbacServiceAccount, "/")
+		if !ok || len(namespaces) == 0 {
+			setupLog.Error(fmt.Errorf("expected --watch-namespaces and a namespace/name service account"), "unable to print RBAC manifests")
+			os.Exit(1)
+		}
+
+		serviceAccount := rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Namespace: saNamespace, Name: saName}
+		if err := rbac.WriteManifests(os.Stdout, rbac.Manifests("dragonfly-operator-manager-role", serviceAccount, namespaces)); err != nil {
+			setupLog.Error(err, "unable to print RBAC manifests")
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// only cache the objects of the managed namespaces, so that
+	// namespaced Roles are enough
+	var newCache cache.NewCacheFunc
+	if len(namespaces) > 0 {
+		newCache = cache.MultiNamespacedCacheBuilder(namespaces)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
+		NewCache:               newCache,
 		MetricsBindAddress:     metricsAddr,
 		Port:                   9443,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "31079dea.dragonflydb.io",
+		LeaderElectionID:       leaderElectionID,
+		// leave in-progress topology changes time to complete
+		GracefulShutdownTimeout: &gracefulShutdownTimeout,
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -116,28 +255,253 @@ func main() {
 	eventBroadcaster := record.NewBroadcaster()
 	eventBroadcaster.StartStructuredLogging(4)
 	eventBroadcaster.StartRecordingToSink(&typedv1core.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
-	eventRecorder := eventBroadcaster.NewRecorder(scheme, v1.EventSource{Component: "dragonfly-operator"})
+	var eventRecorder record.EventRecorder = eventBroadcaster.NewRecorder(scheme, v1.EventSource{Component: "dragonfly-operator"})
+	var notificationsCfg *notifications.Config
+	if notificationsConfig != "" {
+		notificationsCfg, err = notifications.LoadConfig(notificationsConfig)
+		if err != nil {
+			setupLog.Error(err, "unable to load the notifications config")
+			os.Exit(1)
+		}
+		eventRecorder = notifications.NewRecorder(eventRecorder, notificationsCfg)
+	}
 
 	defer eventBroadcaster.Shutdown()
 
+	var memoryBudgets []budget.MemoryBudget
+	if memoryBudgetsConfig != "" {
+		budgetsCfg, err := budget.LoadConfig(memoryBudgetsConfig)
+		if err != nil {
+			setupLog.Error(err, "unable to load the memory budgets config")
+			os.Exit(1)
+		}
+		memoryBudgets = budgetsCfg.MemoryBudgets
+	}
+
+	if enableFaultInjection {
+		setupLog.Info("fault injection is enabled")
+		controller.EnableFaultInjection()
+	}
+
+	// the defaults of the DragonflyClasses are applied to the Dragonfly
+	// objects when they are read
+	dfClient := controller.NewClassClient(mgr.GetClient())
+
 	if err = (&controller.DragonflyReconciler{
-		Client:        mgr.GetClient(),
-		Scheme:        mgr.GetScheme(),
-		EventRecorder: eventRecorder,
+		Client:             dfClient,
+		Scheme:             mgr.GetScheme(),
+		EventRecorder:      eventRecorder,
+		MemoryBudgets:      memoryBudgets,
+		CheckCapacity:      checkCapacity,
+		Shard:              shard,
+		RateLimiterOptions: &rateLimiterOptions,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Dragonfly")
 		os.Exit(1)
 	}
 
+	// the cluster scoped templates are only reconciled by the first shard
+	if shard.Index <= 0 {
+		if err = (&controller.DragonflyTemplateReconciler{
+			Client:             mgr.GetClient(),
+			Scheme:             mgr.GetScheme(),
+			EventRecorder:      eventRecorder,
+			RateLimiterOptions: &rateLimiterOptions,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "DragonflyTemplate")
+			os.Exit(1)
+		}
+	}
+
 	if err = (&controller.DfPodLifeCycleReconciler{
-		Client:        mgr.GetClient(),
-		Scheme:        mgr.GetScheme(),
-		EventRecorder: eventRecorder,
+		Client:             dfClient,
+		Scheme:             mgr.GetScheme(),
+		EventRecorder:      eventRecorder,
+		Shard:              shard,
+		RateLimiterOptions: &rateLimiterOptions,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Health")
 		os.Exit(1)
 	}
 
+	if err := mgr.Add(&controller.StuckPhaseDetector{
+		Client:        dfClient,
+		EventRecorder: eventRecorder,
+		Threshold:     stuckPhaseThreshold,
+		Shard:         shard,
+	}); err != nil {
+		setupLog.Error(err, "unable to create stuck phase detector")
+		os.Exit(1)
+	}
+
+	if err := mgr.Add(&controller.RoleLabelCollector{
+		Client:   dfClient,
+		Interval: roleLabelGCInterval,
+		Shard:    shard,
+	}); err != nil {
+		setupLog.Error(err, "unable to create role label collector")
+		os.Exit(1)
+	}
+
+	if err := mgr.Add(&controller.SnapshotVerifier{
+		Client:        dfClient,
+		EventRecorder: eventRecorder,
+		Shard:         shard,
+	}); err != nil {
+		setupLog.Error(err, "unable to create snapshot verifier")
+		os.Exit(1)
+	}
+
+	if err := mgr.Add(&controller.RestoreVerifier{
+		Client:        dfClient,
+		EventRecorder: eventRecorder,
+		Shard:         shard,
+	}); err != nil {
+		setupLog.Error(err, "unable to create restore verifier")
+		os.Exit(1)
+	}
+
+	if err := mgr.Add(&controller.BackupScheduler{
+		Client:        dfClient,
+		EventRecorder: eventRecorder,
+		Shard:         shard,
+	}); err != nil {
+		setupLog.Error(err, "unable to create backup scheduler")
+		os.Exit(1)
+	}
+
+	if err := mgr.Add(&controller.KeyspaceCollector{
+		Client:   dfClient,
+		Interval: keyspaceCollectionInterval,
+		Shard:    shard,
+	}); err != nil {
+		setupLog.Error(err, "unable to create keyspace collector")
+		os.Exit(1)
+	}
+
+	var usageCostLabels []string
+	for _, label := range strings.Split(costLabels, ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			usageCostLabels = append(usageCostLabels, label)
+		}
+	}
+
+	if err := mgr.Add(&controller.UsageCollector{
+		Client:     dfClient,
+		Interval:   usageCollectionInterval,
+		Shard:      shard,
+		CostLabels: usageCostLabels,
+	}); err != nil {
+		setupLog.Error(err, "unable to create usage collector")
+		os.Exit(1)
+	}
+
+	if sentinelAddr != "" {
+		if err := mgr.Add(&controller.SentinelServer{
+			Client: dfClient,
+			Addr:   sentinelAddr,
+		}); err != nil {
+			setupLog.Error(err, "unable to create sentinel server")
+			os.Exit(1)
+		}
+	}
+
+	if len(memoryBudgets) > 0 {
+		if err := mgr.Add(&controller.MemoryBudgetCollector{
+			Client:   dfClient,
+			Budgets:  memoryBudgets,
+			Interval: time.Minute,
+		}); err != nil {
+			setupLog.Error(err, "unable to create memory budget collector")
+			os.Exit(1)
+		}
+	}
+
+	remoteClients := make(map[string]client.Client)
+	if remoteClusterSecrets != "" {
+		for _, ref := range strings.Split(remoteClusterSecrets, ",") {
+			namespace, name, ok := strings.Cut(strings.TrimSpace(ref), "/")
+			if !ok {
+				setupLog.Error(fmt.Errorf("expected namespace/name, got %q", ref), "invalid remote cluster secret")
+				os.Exit(1)
+			}
+
+			secret, err := clientset.CoreV1().Secrets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+			if err != nil {
+				setupLog.Error(err, "unable to get remote cluster secret", "secret", ref)
+				os.Exit(1)
+			}
+
+			restConfig, err := clientcmd.RESTConfigFromKubeConfig(secret.Data["kubeconfig"])
+			if err != nil {
+				setupLog.Error(err, "unable to load remote cluster kubeconfig", "secret", ref)
+				os.Exit(1)
+			}
+
+			remoteCluster, err := cluster.New(restConfig, func(o *cluster.Options) {
+				o.Scheme = scheme
+				o.NewCache = newCache
+			})
+			if err != nil {
+				setupLog.Error(err, "unable to create remote cluster", "secret", ref)
+				os.Exit(1)
+			}
+
+			// record the events in the remote cluster, next to the objects
+			remoteEventRecorder := remoteCluster.GetEventRecorderFor("dragonfly-operator")
+			if notificationsCfg != nil {
+				remoteEventRecorder = notifications.NewRecorder(remoteEventRecorder, notificationsCfg)
+			}
+
+			if err := controller.SetupRemoteCluster(mgr, name, remoteCluster, controller.RemoteClusterOptions{
+				EventRecorder:       remoteEventRecorder,
+				Shard:               shard,
+				RateLimiterOptions:  &rateLimiterOptions,
+				MemoryBudgets:       memoryBudgets,
+				CheckCapacity:       checkCapacity,
+				StuckPhaseThreshold: stuckPhaseThreshold,
+				RoleLabelGCInterval: roleLabelGCInterval,
+			}); err != nil {
+				setupLog.Error(err, "unable to create controllers", "cluster", name)
+				os.Exit(1)
+			}
+			remoteClients[name] = controller.NewClassClient(remoteCluster.GetClient())
+		}
+	}
+
+	// the links reach the instances of all the clusters, so they are
+	// only reconciled by the first shard
+	if shard.Index <= 0 {
+		if err = (&controller.DragonflyReplicationLinkReconciler{
+			Client:             dfClient,
+			Scheme:             mgr.GetScheme(),
+			EventRecorder:      eventRecorder,
+			Clusters:           remoteClients,
+			RateLimiterOptions: &rateLimiterOptions,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "DragonflyReplicationLink")
+			os.Exit(1)
+		}
+	}
+
+	if err = (&controller.DragonflyMaintenanceReconciler{
+		Client:             dfClient,
+		Scheme:             mgr.GetScheme(),
+		EventRecorder:      eventRecorder,
+		Shard:              shard,
+		RateLimiterOptions: &rateLimiterOptions,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "DragonflyMaintenance")
+		os.Exit(1)
+	}
+
+	if enableWebhooks {
+		if err = webhook.SetupDragonflyWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Dragonfly")
+			os.Exit(1)
+		}
+	}
+
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -148,6 +512,34 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if err := mgr.AddReadyzCheck("cache-sync", health.CacheSynced(mgr.GetCache())); err != nil {
+		setupLog.Error(err, "unable to set up cache sync check")
+		os.Exit(1)
+	}
+	requiredAccess := health.RequiredAccess
+	if len(namespaces) > 0 {
+		requiredAccess = health.NamespacedAccess(requiredAccess, namespaces)
+	}
+	if err := mgr.AddReadyzCheck("access", health.Access(clientset.AuthorizationV1().SelfSubjectAccessReviews(), requiredAccess)); err != nil {
+		setupLog.Error(err, "unable to set up access check")
+		os.Exit(1)
+	}
+	if enableWebhooks {
+		// the webhook server only defaults its certificate location once it starts
+		webhookServer := mgr.GetWebhookServer()
+		webhookCertDir := webhookServer.CertDir
+		if webhookCertDir == "" {
+			webhookCertDir = filepath.Join(os.TempDir(), "k8s-webhook-server", "serving-certs")
+		}
+		webhookCertName := webhookServer.CertName
+		if webhookCertName == "" {
+			webhookCertName = "tls.crt"
+		}
+		if err := mgr.AddReadyzCheck("webhook-certificate", health.Certificate(filepath.Join(webhookCertDir, webhookCertName))); err != nil {
+			setupLog.Error(err, "unable to set up webhook certificate check")
+			os.Exit(1)
+		}
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
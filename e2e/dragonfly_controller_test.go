@@ -100,6 +100,12 @@ var _ = Describe("Dragonfly Lifecycle tests", Ordered, FlakeAttempts(3), func()
 					},
 				},
 			},
+			HostAliases: []corev1.HostAlias{
+				{
+					IP:        "10.0.0.10",
+					Hostnames: []string{"dragonfly.on-prem.local"},
+				},
+			},
 		},
 	}
 
@@ -163,6 +169,9 @@ var _ = Describe("Dragonfly Lifecycle tests", Ordered, FlakeAttempts(3), func()
 			// check for env
 			Expect(ss.Spec.Template.Spec.Containers[0].Env).To(ContainElements(df.Spec.Env))
 
+			// check for host aliases
+			Expect(ss.Spec.Template.Spec.HostAliases).To(Equal(df.Spec.HostAliases))
+
 			// Authentication
 			// PasswordFromSecret
 			Expect(ss.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{
@@ -171,6 +180,14 @@ var _ = Describe("Dragonfly Lifecycle tests", Ordered, FlakeAttempts(3), func()
 					SecretKeyRef: df.Spec.Authentication.PasswordFromSecret,
 				},
 			}))
+
+			// replicas authenticate with the same password
+			Expect(ss.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{
+				Name: "DFLY_masterauth",
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: df.Spec.Authentication.PasswordFromSecret,
+				},
+			}))
 		})
 
 		It("Check for pod values", func() {
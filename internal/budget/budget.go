@@ -0,0 +1,122 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package budget limits the aggregate memory of the Dragonfly instances
+// of a namespace or a label selector, so that a fleet shared by many teams
+// can't outgrow the capacity planned for it.
+package budget
+
+import (
+	"fmt"
+	"os"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the configuration of the memory budgets, loaded from the file
+// passed to the operator with --memory-budgets-config
+type Config struct {
+	MemoryBudgets []MemoryBudget `json:"memoryBudgets"`
+}
+
+type MemoryBudget struct {
+	// Name of the budget, used in metrics and events
+	Name string `json:"name"`
+
+	// Namespace of the instances in the budget. All namespaces if empty.
+	Namespace string `json:"namespace,omitempty"`
+
+	// Selector of the labels of the instances in the budget. All
+	// instances of the namespace if empty.
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// Limit is the aggregate memory of the instances in the budget
+	Limit resource.Quantity `json:"limit"`
+
+	selector labels.Selector
+}
+
+// LoadConfig loads and validates the memory budgets configuration file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, err
+	}
+
+	for i := range config.MemoryBudgets {
+		budget := &config.MemoryBudgets[i]
+		if budget.Name == "" {
+			return nil, fmt.Errorf("memory budget %d has no name", i)
+		}
+
+		if budget.Limit.IsZero() {
+			return nil, fmt.Errorf("memory budget %s has no limit", budget.Name)
+		}
+
+		budget.selector = labels.Everything()
+		if budget.Selector != nil {
+			budget.selector, err = metav1.LabelSelectorAsSelector(budget.Selector)
+			if err != nil {
+				return nil, fmt.Errorf("memory budget %s has an invalid selector: %w", budget.Name, err)
+			}
+		}
+	}
+
+	return &config, nil
+}
+
+// Matches returns if the instance is in the budget
+func (b *MemoryBudget) Matches(df *dfv1alpha1.Dragonfly) bool {
+	if b.Namespace != "" && b.Namespace != df.Namespace {
+		return false
+	}
+
+	return b.selector == nil || b.selector.Matches(labels.Set(df.Labels))
+}
+
+// InstanceMemory returns the memory of all pods of the instance, from the
+// memory limit of the Dragonfly container or else its request. Instances
+// without either can't be accounted for, so they don't fit any budget.
+func InstanceMemory(df *dfv1alpha1.Dragonfly) (int64, error) {
+	if df.Spec.Resources == nil {
+		return 0, fmt.Errorf("no memory limit or request")
+	}
+
+	memory := df.Spec.Resources.Limits.Memory()
+	if memory.IsZero() {
+		memory = df.Spec.Resources.Requests.Memory()
+	}
+
+	if memory.IsZero() {
+		return 0, fmt.Errorf("no memory limit or request")
+	}
+
+	replicas := int64(df.Spec.Replicas)
+	if replicas < 1 {
+		replicas = 1
+	}
+
+	return replicas * memory.Value(), nil
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// adminCAs reads the CA certificates that the admin port of the pods is
+// verified with, shared by the controllers
+var adminCAs = &caCertificates{}
+
+// caCertificates reads the CA certificate of the replication link from the
+// secret mounted in a pod. The operator may manage several clusters, so the secret is read from
+// the cluster that has the pod.
+type caCertificates struct {
+	mu      sync.RWMutex
+	readers []client.Reader
+}
+
+// addReader adds the reader of a cluster that the operator manages
+func (c *caCertificates) addReader(reader client.Reader) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.readers = append(c.readers, reader)
+}
+
+// pool returns the CA certificate of the replication link of the pod
+func (c *caCertificates) pool(ctx context.Context, pod *corev1.Pod) (*x509.CertPool, error) {
+	secretName, key := getPodCASecretKey(pod)
+	if secretName == "" {
+		return nil, fmt.Errorf("pod %s has no CA secret", pod.Name)
+	}
+
+	c.mu.RLock()
+	readers := c.readers
+	c.mu.RUnlock()
+
+	for _, reader := range readers {
+		var current corev1.Pod
+		if err := reader.Get(ctx, client.ObjectKeyFromObject(pod), &current); err != nil || current.UID != pod.UID {
+			continue
+		}
+
+		var secret corev1.Secret
+		if err := reader.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: secretName}, &secret); err != nil {
+			return nil, fmt.Errorf("could not get CA secret %s: %w", secretName, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(secret.Data[key]) {
+			return nil, fmt.Errorf("no CA certificate in key %s of secret %s", key, secretName)
+		}
+
+		return pool, nil
+	}
+
+	return nil, fmt.Errorf("no managed cluster has pod %s", pod.Name)
+}
+
+// getPodCASecretKey returns the name of the secret with the CA of the
+// replication link mounted in the pod, and the key of its CA certificate.
+// That's the TLS secret, unless the link has a CA of its own.
+func getPodCASecretKey(pod *corev1.Pod) (string, string) {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Name == resources.ReplicationCAVolumeName && volume.Secret != nil && len(volume.Secret.Items) == 1 {
+			return volume.Secret.SecretName, volume.Secret.Items[0].Key
+		}
+	}
+
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Name != resources.TLSVolumeName || volume.Secret == nil {
+			continue
+		}
+
+		for _, item := range volume.Secret.Items {
+			if item.Path == "ca.crt" {
+				return volume.Secret.SecretName, item.Key
+			}
+		}
+
+		return volume.Secret.SecretName, "ca.crt"
+	}
+
+	return "", ""
+}
+
+// getAdminTLSConfig returns the TLS config to connect to the admin port of
+// the pod with. The operator connects through the pod IP, which is usually
+// not part of the certificate, so the certificate chain is verified against
+// the CA of the pod without the host name. If the CA can't be read, no
+// certificate is trusted.
+func getAdminTLSConfig(ctx context.Context, pod *corev1.Pod) *tls.Config {
+	roots, err := adminCAs.pool(ctx, pod)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "could not read the CA certificate of the pod", "pod", pod.Name)
+		roots = x509.NewCertPool()
+	}
+
+	return &tls.Config{
+		// the chain is verified by VerifyConnection instead
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			return verifyCertificateChain(state.PeerCertificates, roots)
+		},
+	}
+}
+
+// verifyCertificateChain verifies the certificates presented by a server
+// against the given roots, without checking the host name
+func verifyCertificateChain(certificates []*x509.Certificate, roots *x509.CertPool) error {
+	if len(certificates) == 0 {
+		return errors.New("no certificate presented")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, certificate := range certificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+
+	_, err := certificates[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	return err
+}
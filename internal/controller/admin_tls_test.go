@@ -0,0 +1,58 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGetPodCASecretKey(t *testing.T) {
+	tlsVolume := corev1.Volume{Name: resources.TLSVolumeName, VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "tls"}}}
+	mappedTLSVolume := corev1.Volume{Name: resources.TLSVolumeName, VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+		SecretName: "tls",
+		Items:      []corev1.KeyToPath{{Key: "cert", Path: "tls.crt"}, {Key: "ca", Path: "ca.crt"}},
+	}}}
+	caVolume := corev1.Volume{Name: resources.ReplicationCAVolumeName, VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+		SecretName: "replication-ca",
+		Items:      []corev1.KeyToPath{{Key: "root.pem", Path: "ca.crt"}},
+	}}}
+
+	tests := []struct {
+		name       string
+		volumes    []corev1.Volume
+		wantSecret string
+		wantKey    string
+	}{
+		{name: "no TLS", wantSecret: "", wantKey: ""},
+		{name: "TLS secret", volumes: []corev1.Volume{tlsVolume}, wantSecret: "tls", wantKey: "ca.crt"},
+		{name: "mapped TLS secret", volumes: []corev1.Volume{mappedTLSVolume}, wantSecret: "tls", wantKey: "ca"},
+		{name: "replication CA", volumes: []corev1.Volume{tlsVolume, caVolume}, wantSecret: "replication-ca", wantKey: "root.pem"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{Spec: corev1.PodSpec{Volumes: tt.volumes}}
+			secret, key := getPodCASecretKey(pod)
+			if secret != tt.wantSecret || key != tt.wantKey {
+				t.Errorf("getPodCASecretKey() = %s, %s, want %s, %s", secret, key, tt.wantSecret, tt.wantKey)
+			}
+		})
+	}
+}
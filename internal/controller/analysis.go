@@ -0,0 +1,137 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// RolloutAnalysisFailurePolicyPause pauses the rollout until
+	// the analysis succeeds again
+	RolloutAnalysisFailurePolicyPause = "Pause"
+
+	// RolloutAnalysisFailurePolicyAbort stops the rollout until
+	// the spec of the instance changes again. The pods that were
+	// already updated are kept, and are rolled back by reverting the
+	// spec.
+	RolloutAnalysisFailurePolicyAbort = "Abort"
+)
+
+// prometheusQueryResponse is the subset of the Prometheus
+// instant query API response that is used by the analysis
+type prometheusQueryResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Value []interface{} `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// runRolloutAnalysis runs the rollout analysis queries of the given instance
+// against each of the given updated pods. It returns a description of the
+// first failed query, or an empty string if all queries passed for all pods.
+func runRolloutAnalysis(ctx context.Context, analysis *dfv1alpha1.RolloutAnalysis, pods []corev1.Pod) (string, error) {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	for i := range pods {
+		pod := &pods[i]
+		for _, query := range analysis.Queries {
+			maxValue, err := strconv.ParseFloat(query.MaxValue, 64)
+			if err != nil {
+				return "", fmt.Errorf("could not parse max value of query %s: %w", query.Name, err)
+			}
+
+			value, err := queryPrometheus(ctx, httpClient, analysis.PrometheusAddress, renderAnalysisQuery(query.Query, pod))
+			if err != nil {
+				return "", fmt.Errorf("could not run query %s for pod %s: %w", query.Name, pod.Name, err)
+			}
+
+			if value > maxValue {
+				return fmt.Sprintf("query %s returned %g for pod %s which exceeds %g", query.Name, value, pod.Name, maxValue), nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// renderAnalysisQuery expands the pod variables in the given query
+func renderAnalysisQuery(query string, pod *corev1.Pod) string {
+	return strings.NewReplacer(
+		"$(POD_NAME)", pod.Name,
+		"$(POD_NAMESPACE)", pod.Namespace,
+		"$(POD_IP)", pod.Status.PodIP,
+	).Replace(query)
+}
+
+// queryPrometheus runs the given instant query and returns the value
+// of its first sample. Queries without any sample return 0.
+func queryPrometheus(ctx context.Context, httpClient *http.Client, address, query string) (float64, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/query?%s", strings.TrimSuffix(address, "/"), url.Values{"query": []string{query}}.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var result prometheusQueryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("could not decode response: %w", err)
+	}
+
+	if result.Status != "success" {
+		return 0, fmt.Errorf("query failed: %s", result.Error)
+	}
+
+	if result.Data.ResultType != "vector" {
+		return 0, fmt.Errorf("unsupported result type %s", result.Data.ResultType)
+	}
+
+	if len(result.Data.Result) == 0 {
+		return 0, nil
+	}
+
+	sample := result.Data.Result[0].Value
+	if len(sample) != 2 {
+		return 0, fmt.Errorf("unexpected sample %v", sample)
+	}
+
+	value, ok := sample[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("unexpected sample value %v", sample[1])
+	}
+
+	return strconv.ParseFloat(value, 64)
+}
@@ -0,0 +1,278 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// backupCheckInterval is how often the backups are started and checked
+	backupCheckInterval = 30 * time.Second
+
+	// defaultBackupTimeout is the default time after which a backup that
+	// didn't complete fails
+	defaultBackupTimeout = 30 * time.Minute
+)
+
+// BackupScheduler periodically saves a snapshot of the master of the
+// instances with a backup, and uploads the saved files to object storage
+// from a throwaway pod on the node of the master
+type BackupScheduler struct {
+	client.Client
+	EventRecorder record.EventRecorder
+
+	// Shard is the share of the Dragonfly objects that is backed up
+	Shard Shard
+}
+
+// Start runs the scheduler until the context is done
+func (s *BackupScheduler) Start(ctx context.Context) error {
+	ticker := time.NewTicker(backupCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := s.backup(ctx); err != nil {
+				log.FromContext(ctx).Error(err, "could not back up instances")
+			}
+		}
+	}
+}
+
+func (s *BackupScheduler) backup(ctx context.Context) error {
+	log := log.FromContext(ctx)
+
+	var dfs dfv1alpha1.DragonflyList
+	if err := s.List(ctx, &dfs); err != nil {
+		return err
+	}
+
+	for i := range dfs.Items {
+		df := &dfs.Items[i]
+		if !s.Shard.Contains(df.Namespace, df.Name) {
+			continue
+		}
+
+		if df.Spec.Snapshot == nil || df.Spec.Snapshot.Backup == nil {
+			continue
+		}
+
+		if df.Status.Phase != PhaseReady && df.Status.Phase != PhaseDegraded {
+			continue
+		}
+
+		if err := s.backupInstance(ctx, df); err != nil {
+			log.Error(err, "could not back up the instance", "dragonfly", client.ObjectKeyFromObject(df))
+		}
+	}
+
+	return nil
+}
+
+// backupInstance moves the backup of the instance forward: it starts one
+// when it is due, uploads its snapshot once it's saved, and completes the
+// running one once its pod exited or it timed out
+func (s *BackupScheduler) backupInstance(ctx context.Context, df *dfv1alpha1.Dragonfly) error {
+	if df.Status.Backup != nil && df.Status.Backup.Save != nil {
+		return s.upload(ctx, df)
+	}
+
+	var pod corev1.Pod
+	err := s.Get(ctx, types.NamespacedName{Namespace: df.Namespace, Name: resources.GetBackupPodName(df)}, &pod)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	found := err == nil
+
+	status := df.Status.Backup
+	running := status != nil && status.CompletionTime == nil
+	if !found {
+		if running {
+			return s.complete(ctx, df, nil, "the backup pod disappeared")
+		}
+
+		if status != nil && time.Since(status.StartTime.Time) < df.Spec.Snapshot.Backup.Interval.Duration {
+			return nil
+		}
+
+		return s.start(ctx, df)
+	}
+
+	if !running {
+		// leftover of a backup that was completed
+		return client.IgnoreNotFound(s.Delete(ctx, &pod))
+	}
+
+	timeout := defaultBackupTimeout
+	if df.Spec.Snapshot.Backup.Timeout != nil {
+		timeout = df.Spec.Snapshot.Backup.Timeout.Duration
+	}
+
+	switch {
+	case pod.Status.Phase == corev1.PodSucceeded:
+		return s.complete(ctx, df, &pod, "")
+	case pod.Status.Phase == corev1.PodFailed:
+		message := getTerminationMessage(&pod)
+		if message == "" {
+			message = pod.Status.Message
+		}
+		return s.complete(ctx, df, &pod, fmt.Sprintf("the upload failed: %s", message))
+	case time.Since(status.StartTime.Time) > timeout:
+		return s.complete(ctx, df, &pod, fmt.Sprintf("the backup did not complete within %s", timeout))
+	}
+
+	// the backup is still being uploaded
+	return nil
+}
+
+// start starts saving a snapshot on the master of the instance in the
+// background, and records it in the status, so that it's uploaded once
+// a later check finds it saved
+func (s *BackupScheduler) start(ctx context.Context, df *dfv1alpha1.Dragonfly) error {
+	master, err := getMasterPod(ctx, s.Client, df)
+	if err != nil {
+		return err
+	}
+
+	// the names of the backups have a precision of seconds
+	start := time.Now().UTC().Truncate(time.Second)
+
+	patch := client.MergeFrom(df.DeepCopy())
+	status := &dfv1alpha1.BackupStatus{StartTime: metav1.NewTime(start)}
+	if df.Status.Backup != nil {
+		status.LastSuccessfulTime = df.Status.Backup.LastSuccessfulTime
+		status.LastSuccessfulURI = df.Status.Backup.LastSuccessfulURI
+		status.LastSuccessfulSizeBytes = df.Status.Backup.LastSuccessfulSizeBytes
+	}
+	df.Status.Backup = status
+
+	save, err := startSnapshot(ctx, master, SaveReasonBackup)
+	if err != nil {
+		if err := s.Status().Patch(ctx, df, patch); err != nil {
+			return err
+		}
+		return s.complete(ctx, df, nil, fmt.Sprintf("could not save a snapshot on master %s: %s", master.Name, err))
+	}
+	status.Save = save
+
+	return s.Status().Patch(ctx, df, patch)
+}
+
+// upload creates the pod that uploads the snapshot of the running backup
+// once the master saved it, and completes the backup if the save failed
+// or timed out
+func (s *BackupScheduler) upload(ctx context.Context, df *dfv1alpha1.Dragonfly) error {
+	save := df.Status.Backup.Save
+
+	var master corev1.Pod
+	if err := s.Get(ctx, types.NamespacedName{Namespace: df.Namespace, Name: save.Pod}, &master); err != nil {
+		if apierrors.IsNotFound(err) {
+			return s.complete(ctx, df, nil, fmt.Sprintf("master %s was deleted while it saved the snapshot", save.Pod))
+		}
+		return err
+	}
+
+	done, err := getSnapshotResult(ctx, &master, save.StartTime.Time)
+	switch {
+	case err != nil && done:
+		return s.complete(ctx, df, nil, fmt.Sprintf("could not save a snapshot on master %s: %s", master.Name, err))
+	case !done && time.Since(save.StartTime.Time) > saveTimeout:
+		return s.complete(ctx, df, nil, fmt.Sprintf("master %s did not save the snapshot within %s", master.Name, saveTimeout))
+	case !done:
+		// the snapshot is still being saved, or the master can't be
+		// queried for now
+		return nil
+	}
+
+	pod, err := resources.GetBackupPod(df, &master, df.Status.Backup.StartTime.Time)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Create(ctx, pod); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("could not create the backup pod: %w", err)
+	}
+
+	patch := client.MergeFrom(df.DeepCopy())
+	df.Status.Backup.Save = nil
+	return s.Status().Patch(ctx, df, patch)
+}
+
+// complete records the result of the backup of the instance, and deletes
+// its pod
+func (s *BackupScheduler) complete(ctx context.Context, df *dfv1alpha1.Dragonfly, pod *corev1.Pod, failure string) error {
+	patch := client.MergeFrom(df.DeepCopy())
+	if df.Status.Backup == nil {
+		df.Status.Backup = &dfv1alpha1.BackupStatus{StartTime: metav1.Now()}
+	}
+
+	now := metav1.Now()
+	status := df.Status.Backup
+	status.Save = nil
+	status.CompletionTime = &now
+	status.Succeeded = failure == ""
+	status.Message = failure
+	if failure == "" {
+		status.LastSuccessfulTime = &status.StartTime
+		status.LastSuccessfulURI = resources.GetBackupURI(df, status.StartTime.Time)
+		status.LastSuccessfulSizeBytes, _ = strconv.ParseInt(getTerminationMessage(pod), 10, 64)
+		status.Message = fmt.Sprintf("uploaded %d bytes to %s", status.LastSuccessfulSizeBytes, status.LastSuccessfulURI)
+	}
+
+	if err := s.Status().Patch(ctx, df, patch); err != nil {
+		return err
+	}
+
+	if failure == "" {
+		s.EventRecorder.Event(df, corev1.EventTypeNormal, "Backup", fmt.Sprintf("Backed up the snapshot: %s", status.Message))
+	} else {
+		s.EventRecorder.Event(df, corev1.EventTypeWarning, "Backup", fmt.Sprintf("Could not back up the snapshot: %s", failure))
+	}
+
+	if pod == nil {
+		return nil
+	}
+
+	return client.IgnoreNotFound(s.Delete(ctx, pod))
+}
+
+// getTerminationMessage returns the termination message of the first
+// container of the pod, if it terminated
+func getTerminationMessage(pod *corev1.Pod) string {
+	if pod == nil || len(pod.Status.ContainerStatuses) == 0 || pod.Status.ContainerStatuses[0].State.Terminated == nil {
+		return ""
+	}
+
+	return strings.TrimSpace(pod.Status.ContainerStatuses[0].State.Terminated.Message)
+}
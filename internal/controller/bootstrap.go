@@ -0,0 +1,67 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// isSharedClaim returns if the claim can be mounted by pods on several
+// nodes at once. The access modes of bound claims are the ones of their
+// volume.
+func isSharedClaim(claim *corev1.PersistentVolumeClaim) bool {
+	accessModes := claim.Spec.AccessModes
+	if claim.Status.Phase == corev1.ClaimBound {
+		accessModes = claim.Status.AccessModes
+	}
+
+	for _, mode := range accessModes {
+		if mode == corev1.ReadOnlyMany || mode == corev1.ReadWriteMany {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkBootstrapClaim returns if the pods of the instance are blocked, as
+// they would all mount its bootstrap claim while it can only be attached
+// to a single node. Pods on other nodes would be stuck on a Multi-Attach
+// error otherwise, and not just while they load the snapshot, as the
+// volumes of a pod can't be removed.
+func (r *DragonflyReconciler) checkBootstrapClaim(ctx context.Context, df *dfv1alpha1.Dragonfly) (bool, error) {
+	if df.Spec.Bootstrap == nil || df.Spec.Bootstrap.PersistentVolumeClaim == nil || df.Spec.Replicas <= 1 {
+		return false, nil
+	}
+
+	var claim corev1.PersistentVolumeClaim
+	if err := r.Get(ctx, types.NamespacedName{Namespace: df.Namespace, Name: df.Spec.Bootstrap.PersistentVolumeClaim.ClaimName}, &claim); err != nil {
+		return false, fmt.Errorf("could not get the bootstrap claim: %w", err)
+	}
+
+	if isSharedClaim(&claim) {
+		return false, nil
+	}
+
+	r.EventRecorder.Event(df, corev1.EventTypeWarning, "Bootstrap", fmt.Sprintf("Bootstrap claim %s has to be ReadOnlyMany or ReadWriteMany, as all %d pods mount it. Use such a claim, or bootstrap a single replica, and remove spec.bootstrap when scaling up once the data is loaded", claim.Name, df.Spec.Replicas))
+	return true, nil
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// reconcileCapacity returns if the nodes the pods of the new instance can
+// be scheduled on have enough free memory for all of them. If they don't,
+// the instance is marked pending with the PendingCapacity condition,
+// instead of letting its pods sit unschedulable. The status of instances
+// that fit has to be updated by the caller.
+func (r *DragonflyReconciler) reconcileCapacity(ctx context.Context, df *dfv1alpha1.Dragonfly) (bool, error) {
+	message, err := r.checkCapacity(ctx, df)
+	if err != nil {
+		return false, err
+	}
+
+	if message == "" {
+		if meta.FindStatusCondition(df.Status.Conditions, ConditionPendingCapacity) != nil {
+			meta.SetStatusCondition(&df.Status.Conditions, metav1.Condition{
+				Type:               ConditionPendingCapacity,
+				Status:             metav1.ConditionFalse,
+				Reason:             "CapacityAvailable",
+				Message:            "The nodes have enough free memory for the pods",
+				ObservedGeneration: df.Generation,
+			})
+		}
+		return true, nil
+	}
+
+	status := df.Status.DeepCopy()
+	setPhase(df, PhasePending)
+	meta.SetStatusCondition(&df.Status.Conditions, metav1.Condition{
+		Type:               ConditionPendingCapacity,
+		Status:             metav1.ConditionTrue,
+		Reason:             "InsufficientMemory",
+		Message:            message,
+		ObservedGeneration: df.Generation,
+	})
+
+	if equality.Semantic.DeepEqual(status, &df.Status) {
+		return false, nil
+	}
+
+	if err := r.Status().Update(ctx, df); err != nil {
+		return false, err
+	}
+
+	r.EventRecorder.Event(df, corev1.EventTypeWarning, "Capacity", message)
+	return false, nil
+}
+
+// checkCapacity returns why the pods of the instance don't fit in the
+// free memory of the nodes they can be scheduled on, or "" if they fit.
+// Only the memory requests, taints and required node affinity are taken
+// into account, so pods may still be unschedulable for other reasons.
+func (r *DragonflyReconciler) checkCapacity(ctx context.Context, df *dfv1alpha1.Dragonfly) (string, error) {
+	if df.Spec.Resources == nil || df.Spec.Resources.Requests.Memory().IsZero() {
+		return "", nil
+	}
+	request := df.Spec.Resources.Requests.Memory().Value()
+
+	var nodes corev1.NodeList
+	if err := r.List(ctx, &nodes); err != nil {
+		return "", err
+	}
+
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods); err != nil {
+		return "", err
+	}
+
+	requested := make(map[string]int64)
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName == "" || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+
+		for _, container := range pod.Spec.Containers {
+			requested[pod.Spec.NodeName] += container.Resources.Requests.Memory().Value()
+		}
+	}
+
+	replicas := int64(df.Spec.Replicas)
+	if replicas < 1 {
+		replicas = 1
+	}
+
+	var fitting, eligible int64
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if !isNodeSchedulable(node, df) {
+			continue
+		}
+		eligible++
+
+		free := node.Status.Allocatable.Memory().Value() - requested[node.Name]
+		if free > 0 {
+			fitting += free / request
+		}
+	}
+
+	if fitting >= replicas {
+		return "", nil
+	}
+
+	return fmt.Sprintf("%d of %d pods requesting %s of memory fit on the %d nodes they can be scheduled on", fitting, replicas, formatBytes(request), eligible), nil
+}
+
+// isNodeSchedulable returns if the pods of the instance can be scheduled
+// on the node as far as its readiness, taints and labels are concerned
+func isNodeSchedulable(node *corev1.Node, df *dfv1alpha1.Dragonfly) bool {
+	if node.Spec.Unschedulable || !isNodeReady(node) {
+		return false
+	}
+
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect != corev1.TaintEffectNoSchedule && taint.Effect != corev1.TaintEffectNoExecute {
+			continue
+		}
+
+		tolerated := false
+		for _, toleration := range df.Spec.Tolerations {
+			if toleration.ToleratesTaint(taint) {
+				tolerated = true
+				break
+			}
+		}
+
+		if !tolerated {
+			return false
+		}
+	}
+
+	if df.Spec.Affinity == nil || df.Spec.Affinity.NodeAffinity == nil || df.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return true
+	}
+
+	// the terms are ORed, their expressions ANDed
+	for _, term := range df.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		if matchesNodeSelectorTerm(node, term) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var nodeSelectorOperators = map[corev1.NodeSelectorOperator]selection.Operator{
+	corev1.NodeSelectorOpIn:           selection.In,
+	corev1.NodeSelectorOpNotIn:        selection.NotIn,
+	corev1.NodeSelectorOpExists:       selection.Exists,
+	corev1.NodeSelectorOpDoesNotExist: selection.DoesNotExist,
+	corev1.NodeSelectorOpGt:           selection.GreaterThan,
+	corev1.NodeSelectorOpLt:           selection.LessThan,
+}
+
+// matchesNodeSelectorTerm returns if the labels of the node match all
+// expressions of the term. Field expressions aren't checked.
+func matchesNodeSelectorTerm(node *corev1.Node, term corev1.NodeSelectorTerm) bool {
+	if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+		return false
+	}
+
+	selector := labels.NewSelector()
+	for _, expression := range term.MatchExpressions {
+		requirement, err := labels.NewRequirement(expression.Key, nodeSelectorOperators[expression.Operator], expression.Values)
+		if err != nil {
+			return false
+		}
+		selector = selector.Add(*requirement)
+	}
+
+	return selector.Matches(labels.Set(node.Labels))
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// classClient applies the defaults of the DragonflyClasses to the
+// Dragonfly objects it reads, including the ones returned by writes. The
+// applied defaults exist only in memory, so the spec of Dragonfly objects
+// with a class can't be updated through it, only patched.
+type classClient struct {
+	client.Client
+}
+
+// NewClassClient returns a client that applies the defaults of their
+// DragonflyClass to the Dragonfly objects that it reads
+func NewClassClient(c client.Client) client.Client {
+	return &classClient{Client: c}
+}
+
+func (c *classClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if err := c.Client.Get(ctx, key, obj, opts...); err != nil {
+		return err
+	}
+
+	return c.applyClass(ctx, obj)
+}
+
+func (c *classClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if err := c.Client.List(ctx, list, opts...); err != nil {
+		return err
+	}
+
+	dfs, ok := list.(*dfv1alpha1.DragonflyList)
+	if !ok {
+		return nil
+	}
+
+	// objects whose class can't be applied are listed as is, the error
+	// is reported when they are reconciled
+	for i := range dfs.Items {
+		_ = c.applyClass(ctx, &dfs.Items[i])
+	}
+
+	return nil
+}
+
+func (c *classClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if df, ok := obj.(*dfv1alpha1.Dragonfly); ok && df.Spec.ClassName != "" {
+		return fmt.Errorf("dragonfly %s/%s has a class, so it can only be patched", df.Namespace, df.Name)
+	}
+
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *classClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := c.Client.Patch(ctx, obj, patch, opts...); err != nil {
+		return err
+	}
+
+	return c.applyClass(ctx, obj)
+}
+
+func (c *classClient) Status() client.SubResourceWriter {
+	return &classStatusWriter{SubResourceWriter: c.Client.Status(), client: c}
+}
+
+// applyClass applies the defaults of its class to the object, if it's a
+// Dragonfly object with a class
+func (c *classClient) applyClass(ctx context.Context, obj client.Object) error {
+	df, ok := obj.(*dfv1alpha1.Dragonfly)
+	if !ok || df.Spec.ClassName == "" {
+		return nil
+	}
+
+	var class dfv1alpha1.DragonflyClass
+	if err := c.Client.Get(ctx, types.NamespacedName{Name: df.Spec.ClassName}, &class); err != nil {
+		return fmt.Errorf("could not get dragonfly class %s of %s/%s: %w", df.Spec.ClassName, df.Namespace, df.Name, err)
+	}
+
+	return resources.ApplyDragonflyClass(df, &class)
+}
+
+// classStatusWriter applies the class defaults to the Dragonfly objects
+// returned by status writes, as the server returns them without
+type classStatusWriter struct {
+	client.SubResourceWriter
+	client *classClient
+}
+
+func (w *classStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	if err := w.SubResourceWriter.Update(ctx, obj, opts...); err != nil {
+		return err
+	}
+
+	return w.client.applyClass(ctx, obj)
+}
+
+func (w *classStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	if err := w.SubResourceWriter.Patch(ctx, obj, patch, opts...); err != nil {
+		return err
+	}
+
+	return w.client.applyClass(ctx, obj)
+}
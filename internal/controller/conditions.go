@@ -0,0 +1,97 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// setStateConditions sets the Ready, ReplicationConfigured and
+// RollingUpdate conditions of the Dragonfly object from its phase, its
+// topology change in progress and its rollout, so that tools that don't
+// know the phases, e.g `kubectl wait`, can follow the instance
+func setStateConditions(df *dfv1alpha1.Dragonfly) {
+	ready := metav1.Condition{
+		Type:    ConditionReady,
+		Status:  metav1.ConditionFalse,
+		Reason:  "Initializing",
+		Message: "the resources of the instance are being created",
+	}
+	replication := metav1.Condition{
+		Type:    ConditionReplicationConfigured,
+		Status:  metav1.ConditionFalse,
+		Reason:  "Initializing",
+		Message: "the master wasn't elected yet",
+	}
+
+	switch df.Status.Phase {
+	case PhaseReady:
+		ready.Status, ready.Reason, ready.Message = metav1.ConditionTrue, "Ready", "the instance serves requests"
+		replication.Status, replication.Reason, replication.Message = metav1.ConditionTrue, "MasterElected", "the replicas replicate from the master"
+	case PhaseDegraded:
+		ready.Status, ready.Reason, ready.Message = metav1.ConditionTrue, "Degraded", "the instance serves requests with fewer replicas in sync than desired"
+		replication.Status, replication.Reason, replication.Message = metav1.ConditionTrue, "MasterElected", "the replicas replicate from the master"
+	case PhaseStandby:
+		ready.Status, ready.Reason, ready.Message = metav1.ConditionTrue, "Standby", "the instance serves reads as a standby"
+		replication.Status, replication.Reason, replication.Message = metav1.ConditionTrue, "Standby", "the pods replicate from the primary of the replication link"
+	case PhasePending:
+		ready.Reason, ready.Message = "Pending", "the resources of the instance aren't created yet"
+		replication.Reason = "Pending"
+	case PhaseResourcesCreated:
+		ready.Reason, ready.Message = "ResourcesCreated", "replication isn't configured yet"
+		replication.Reason = "ResourcesCreated"
+	}
+
+	if change := df.Status.TopologyChange; change != nil {
+		replication.Status = metav1.ConditionFalse
+		replication.Reason = change.Operation
+		replication.Message = fmt.Sprintf("%s is in progress", change.Operation)
+	}
+
+	rollingUpdate := metav1.Condition{
+		Type:    ConditionRollingUpdate,
+		Status:  metav1.ConditionFalse,
+		Reason:  "UpToDate",
+		Message: "no rollout is in progress",
+	}
+	if df.Status.IsRollingUpdate {
+		rollingUpdate.Status, rollingUpdate.Reason, rollingUpdate.Message = metav1.ConditionTrue, "RolloutInProgress", "the pods are being updated"
+	}
+
+	for _, condition := range []metav1.Condition{ready, replication, rollingUpdate} {
+		condition.ObservedGeneration = df.Generation
+		meta.SetStatusCondition(&df.Status.Conditions, condition)
+	}
+}
+
+// isRolloutHandlingMaster returns if the rollout of the Dragonfly object
+// takes care of its master, so that the pod lifecycle controller leaves a
+// master failure to it. A rollout doesn't while it migrates the volume of
+// a replica, as the statefulset may be gone meanwhile.
+func isRolloutHandlingMaster(df *dfv1alpha1.Dragonfly) bool {
+	return df.Status.IsRollingUpdate && df.Status.MigratingPod == ""
+}
+
+// setRollingUpdate marks whether the Dragonfly object is being updated
+func setRollingUpdate(df *dfv1alpha1.Dragonfly, rollingUpdate bool) {
+	df.Status.IsRollingUpdate = rollingUpdate
+	setStateConditions(df)
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// isDataLossBlocked returns if the instance holds the only copy of its
+// data, and losing it wasn't confirmed
+func isDataLossBlocked(df *dfv1alpha1.Dragonfly) bool {
+	return resources.IsDataLossBlocked(df)
+}
+
+// reconcileDataLossProtection adds the data loss protection finalizer to
+// instances without persistence, and removes it from the others. Once an
+// instance is being deleted, the finalizer is removed when the data loss
+// is confirmed, or when its namespace is being deleted, as that removes
+// the pods anyway. It returns if the instance is being deleted.
+func (r *DragonflyReconciler) reconcileDataLossProtection(ctx context.Context, df *dfv1alpha1.Dragonfly) (bool, error) {
+	protected := controllerutil.ContainsFinalizer(df, resources.DataLossProtectionFinalizer)
+	if df.DeletionTimestamp != nil {
+		if !protected {
+			return true, nil
+		}
+
+		if isDataLossBlocked(df) {
+			deleting, err := r.isNamespaceDeleting(ctx, df.Namespace)
+			if err != nil {
+				return true, err
+			}
+
+			if !deleting {
+				r.EventRecorder.Event(df, corev1.EventTypeWarning, "DataLossProtection", fmt.Sprintf("Deletion is blocked as the instance has no persistence. Set the %s annotation to \"true\" to confirm the loss of its data", resources.DataLossConfirmationAnnotation))
+				return true, nil
+			}
+		}
+
+		return true, r.patchDataLossProtectionFinalizer(ctx, df, false)
+	}
+
+	if resources.HasPersistence(df) == !protected {
+		return false, nil
+	}
+
+	return false, r.patchDataLossProtectionFinalizer(ctx, df, !protected)
+}
+
+// patchDataLossProtectionFinalizer adds or removes the data loss
+// protection finalizer. Only the finalizers are patched, as the spec may
+// have class defaults.
+func (r *DragonflyReconciler) patchDataLossProtectionFinalizer(ctx context.Context, df *dfv1alpha1.Dragonfly, add bool) error {
+	patch := client.MergeFrom(df.DeepCopy())
+	if add {
+		controllerutil.AddFinalizer(df, resources.DataLossProtectionFinalizer)
+	} else {
+		controllerutil.RemoveFinalizer(df, resources.DataLossProtectionFinalizer)
+	}
+
+	return r.Patch(ctx, df, patch)
+}
+
+// isNamespaceDeleting returns if the namespace is being deleted
+func (r *DragonflyReconciler) isNamespaceDeleting(ctx context.Context, name string) (bool, error) {
+	var namespace corev1.Namespace
+	if err := r.Get(ctx, types.NamespacedName{Name: name}, &namespace); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+
+	return namespace.DeletionTimestamp != nil, nil
+}
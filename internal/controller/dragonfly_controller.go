@@ -22,16 +22,28 @@ import (
 	"time"
 
 	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/budget"
 	"github.com/dragonflydb/dragonfly-operator/internal/resources"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // DragonflyReconciler reconciles a Dragonfly object
@@ -40,33 +52,228 @@ type DragonflyReconciler struct {
 	Scheme *runtime.Scheme
 
 	EventRecorder record.EventRecorder
+
+	// MemoryBudgets limit the aggregate memory of the instances that
+	// resources are created for
+	MemoryBudgets []budget.MemoryBudget
+
+	// CheckCapacity keeps new instances pending while their pods don't
+	// fit in the free memory of the nodes
+	CheckCapacity bool
+
+	// RateLimiterOptions tune the rate limiter of the work queue, the
+	// default rate limiter is used if nil
+	RateLimiterOptions *RateLimiterOptions
+
+	// Shard is the share of the Dragonfly objects that is managed
+	Shard Shard
 }
 
 //+kubebuilder:rbac:groups=dragonflydb.io,resources=dragonflies,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=dragonflydb.io,resources=dragonflies/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=dragonflydb.io,resources=dragonflies/finalizers,verbs=update
+//+kubebuilder:rbac:groups=dragonflydb.io,resources=dragonflyclasses,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 //+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
 //+kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshots,verbs=get;list;watch;create;delete
 //+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=monitoring.coreos.com,resources=prometheusrules,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=route.openshift.io,resources=routes,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=selfsubjectaccessreviews,verbs=create
+//+kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.14.1/pkg/reconcile
-func (r *DragonflyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *DragonflyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
 	log := log.FromContext(ctx)
 	var df dfv1alpha1.Dragonfly
 	if err := r.Get(ctx, req.NamespacedName, &df); err != nil {
 		log.Info(fmt.Sprintf("could not get the Dragonfly object: %s", req.NamespacedName))
+		if apierrors.IsNotFound(err) {
+			faults.forget(req.NamespacedName)
+			forgetInstanceMetrics(req.NamespacedName)
+			forgetProbeResults(req.NamespacedName)
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	faults.update(&df)
 
 	log.Info("Reconciling Dragonfly object")
+	deleting, err := r.reconcileDataLossProtection(ctx, &df)
+	if err != nil {
+		log.Error(err, "could not reconcile the data loss protection")
+		return ctrl.Result{}, err
+	}
+
+	if deleting {
+		return ctrl.Result{}, nil
+	}
+
+	// the plan is made before any change of this reconcile
+	if isExplainRequested(&df) {
+		log.Info("Explaining the planned changes")
+		if err := r.explain(ctx, &df); err != nil {
+			log.Error(err, "could not explain the planned changes")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if df.Spec.ConnectionSecret != nil {
+		if err := reconcileConnectionSecret(ctx, r.Client, &df); err != nil {
+			log.Error(err, "could not reconcile connection secret")
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+		}
+
+		// expose the secret as a provisioned service
+		binding := resources.GetConnectionSecretName(&df)
+		if df.Status.Binding == nil || df.Status.Binding.Name != binding {
+			df.Status.Binding = &corev1.LocalObjectReference{Name: binding}
+			if err := r.Status().Update(ctx, &df); err != nil {
+				log.Error(err, "could not update the Dragonfly object")
+				return ctrl.Result{Requeue: true}, err
+			}
+		}
+	}
+
+	// retry the save requests that can't be handled yet, and follow the
+	// progress of the snapshot saved in the background
+	defer func() {
+		if err == nil && result.IsZero() && (isSaveRequested(&df) || df.Status.Save != nil) {
+			result.RequeueAfter = withJitter(5 * time.Second)
+		}
+	}()
+
+	// follow the progress of the import job
+	defer func() {
+		if err == nil && result.IsZero() && df.Spec.Import != nil && isImportRunning(&df) {
+			result.RequeueAfter = withJitter(10 * time.Second)
+		}
+	}()
+
+	if df.Status.Save != nil {
+		if err := r.checkSave(ctx, &df); err != nil {
+			log.Error(err, "could not check the snapshot saved in the background")
+			return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, nil
+		}
+	}
+
+	// snapshots are only saved once replication is configured
+	if isSaveRequested(&df) && (df.Status.Phase == PhaseReady || df.Status.Phase == PhaseDegraded) {
+		log.Info("Saving the requested snapshot")
+		if err := r.handleSaveRequest(ctx, &df); err != nil {
+			log.Error(err, "could not handle the save request")
+			return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, nil
+		}
+	}
+
+	// a removed failover request is forgotten, so that the failover to the
+	// same pod can be requested again
+	if isFailoverRequestRemoved(&df) {
+		df.Status.FailoverRequest = ""
+		if err := r.Status().Update(ctx, &df); err != nil {
+			log.Error(err, "could not clear the failover request")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// failovers are only requested once replication is configured
+	if isFailoverRequested(&df) && (df.Status.Phase == PhaseReady || df.Status.Phase == PhaseDegraded) && !df.Status.IsRollingUpdate {
+		log.Info("Handling the requested failover", "pod", df.Annotations[resources.FailoverToAnnotation])
+		if err := r.handleFailoverRequest(ctx, &df); err != nil {
+			log.Error(err, "could not handle the failover request")
+			return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, nil
+		}
+	}
+
+	if isMasterFailureRequested(&df) && (df.Status.Phase == PhaseReady || df.Status.Phase == PhaseDegraded) {
+		log.Info("Injecting a master failure")
+		if err := r.injectMasterFailure(ctx, &df); err != nil {
+			log.Error(err, "could not inject the master failure")
+			return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, nil
+		}
+	}
+
+	// keys are only imported once replication is configured, so that
+	// they reach the replicas
+	if df.Spec.Import != nil && (df.Status.Phase == PhaseReady || df.Status.Phase == PhaseDegraded) {
+		if err := r.reconcileImport(ctx, &df); err != nil {
+			log.Error(err, "could not reconcile the import")
+			return ctrl.Result{RequeueAfter: withJitter(10 * time.Second)}, nil
+		}
+	}
+
+	// the proxies follow the master once replication is configured
+	if !isStandby(&df) && (df.Status.Phase == PhaseReady || df.Status.Phase == PhaseDegraded) {
+		if err := r.reconcileProxy(ctx, &df); err != nil {
+			log.Error(err, "could not reconcile the proxy")
+			return ctrl.Result{RequeueAfter: withJitter(10 * time.Second)}, nil
+		}
+	}
+
 	// Ignore if resource is already created
-	if df.Status.Phase == "" {
+	if df.Status.Phase == "" || df.Status.Phase == PhasePending {
+		message, err := r.checkMemoryBudgets(ctx, &df)
+		if err != nil {
+			log.Error(err, "could not check the memory budgets")
+			return ctrl.Result{}, err
+		}
+
+		// new instances wait until their memory budget has room
+		if message != "" {
+			log.Info("Instance doesn't fit in its memory budget", "reason", message)
+			if df.Status.Phase != PhasePending {
+				setPhase(&df, PhasePending)
+				if err := r.Status().Update(ctx, &df); err != nil {
+					log.Error(err, "could not update the Dragonfly object")
+					return ctrl.Result{}, err
+				}
+
+				r.EventRecorder.Event(&df, corev1.EventTypeWarning, "MemoryBudget", message)
+			}
+			return ctrl.Result{RequeueAfter: withJitter(time.Minute)}, nil
+		}
+
+		// and until the nodes have room for their pods
+		if r.CheckCapacity {
+			fits, err := r.reconcileCapacity(ctx, &df)
+			if err != nil {
+				log.Error(err, "could not check the capacity of the nodes")
+				return ctrl.Result{}, err
+			}
+
+			if !fits {
+				log.Info("Pods of the instance don't fit on the nodes")
+				return ctrl.Result{RequeueAfter: withJitter(time.Minute)}, nil
+			}
+		}
+
+		// and until all their pods can mount the bootstrap claim
+		blocked, err := r.checkBootstrapClaim(ctx, &df)
+		if err != nil {
+			log.Error(err, "could not check the bootstrap claim")
+			return ctrl.Result{}, err
+		}
+
+		if blocked {
+			log.Info("Bootstrap claim can't be mounted by all the pods")
+			return ctrl.Result{RequeueAfter: withJitter(time.Minute)}, nil
+		}
+
 		log.Info("Creating resources")
+		df.Status.LogArgs = resources.GetLogArgs(&df)
 		resources, err := resources.GetDragonflyResources(ctx, &df)
 		if err != nil {
 			log.Error(err, "could not get resources")
@@ -75,6 +282,23 @@ func (r *DragonflyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 
 		// create all resources
 		for _, resource := range resources {
+			if statefulSet, ok := resource.(*appsv1.StatefulSet); ok {
+				if err := setSecretsHash(ctx, r.Client, &df, statefulSet); err != nil {
+					log.Error(err, "could not hash referenced secrets")
+					return ctrl.Result{}, err
+				}
+
+				if err := setConfigMapsHash(ctx, r.Client, &df, statefulSet); err != nil {
+					log.Error(err, "could not hash referenced config maps")
+					return ctrl.Result{}, err
+				}
+			}
+
+			if isUninstalledServiceMonitor(r.Client, resource) {
+				log.Info("Prometheus Operator is not installed, skipping the service monitor")
+				continue
+			}
+
 			if err := r.Create(ctx, resource); err != nil {
 				log.Error(err, fmt.Sprintf("could not create resource %s/%s/%s", resource.GetObjectKind(), resource.GetNamespace(), resource.GetName()))
 				return ctrl.Result{}, err
@@ -82,7 +306,7 @@ func (r *DragonflyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		}
 
 		// Update Status
-		df.Status.Phase = PhaseResourcesCreated
+		setPhase(&df, PhaseResourcesCreated)
 		log.Info("Created resources for object")
 		if err := r.Status().Update(ctx, &df); err != nil {
 			log.Error(err, "could not update the Dragonfly object")
@@ -94,12 +318,40 @@ func (r *DragonflyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	} else if df.Status.IsRollingUpdate {
 		// This is a Rollout
 		log.Info("Rolling out new version")
+
+		// a volume migration in progress is finished first
+		if df.Status.MigratingPod != "" {
+			done, err := r.migrateSnapshotVolume(ctx, &df, df.Status.MigratingPod)
+			if err != nil {
+				log.Error(err, "could not migrate the volume", "pod", df.Status.MigratingPod)
+				return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, err
+			}
+
+			if !done {
+				return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, nil
+			}
+		}
+
 		var updatedStatefulset appsv1.StatefulSet
 		if err := r.Get(ctx, client.ObjectKey{Namespace: df.Namespace, Name: df.Name}, &updatedStatefulset); err != nil {
+			if apierrors.IsNotFound(err) {
+				log.Info("Recreating the statefulset")
+				if err := r.recreateStatefulSet(ctx, &df); err != nil {
+					log.Error(err, "could not recreate the statefulset")
+					return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, err
+				}
+				return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, nil
+			}
+
 			log.Error(err, "could not get statefulset")
 			return ctrl.Result{Requeue: true}, err
 		}
 
+		if updatedStatefulset.DeletionTimestamp != nil {
+			log.Info("Waiting for the statefulset to be deleted")
+			return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, nil
+		}
+
 		// get pods of the statefulset
 		var pods corev1.PodList
 		if err := r.List(ctx, &pods, client.InNamespace(df.Namespace), client.MatchingLabels(map[string]string{
@@ -115,6 +367,13 @@ func (r *DragonflyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			return ctrl.Result{Requeue: true}, nil
 		}
 
+		// a topology change that was interrupted is resumed by the pod
+		// lifecycle controller first
+		if df.Status.TopologyChange != nil {
+			log.Info("Waiting for the topology change to complete", "operation", df.Status.TopologyChange.Operation)
+			return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, nil
+		}
+
 		// filter replicas to master and replicas
 		var master corev1.Pod
 		replicas := make([]corev1.Pod, 0)
@@ -128,14 +387,14 @@ func (r *DragonflyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			} else {
 				log.Info("found pod without label", "pod", pod.Name)
 				// retry after they are ready
-				return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+				return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, nil
 			}
 		}
 
 		// We want to update the replicas first then the master
 		// We want to have at most one updated replica in full sync phase at a time
 		// if not, requeue
-		fullSyncedUpdatedReplicas := 0
+		updatedReplicas := make([]corev1.Pod, 0)
 		for _, replica := range replicas {
 			// Check only with latest replicas
 			onLatestVersion, err := isPodOnLatestVersion(ctx, r.Client, &replica, &updatedStatefulset)
@@ -157,15 +416,56 @@ func (r *DragonflyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 					return ctrl.Result{RequeueAfter: 5 * time.Second}, err
 				}
 				log.Info("Replica is in stable state", "pod", replica.Name)
-				fullSyncedUpdatedReplicas++
+				updatedReplicas = append(updatedReplicas, replica)
 			}
 		}
 
-		log.Info(fmt.Sprintf("%d/%d replicas are in stable state", fullSyncedUpdatedReplicas, len(replicas)))
+		log.Info(fmt.Sprintf("%d/%d replicas are in stable state", len(updatedReplicas), len(replicas)))
+
+		// check that all updated replicas behave before continuing
+		if df.Spec.RolloutAnalysis != nil && len(updatedReplicas) > 0 {
+			failure, err := runRolloutAnalysis(ctx, df.Spec.RolloutAnalysis, updatedReplicas)
+			if err != nil {
+				log.Error(err, "could not run rollout analysis")
+				return ctrl.Result{RequeueAfter: withJitter(30 * time.Second)}, nil
+			}
+
+			if failure != "" {
+				if df.Spec.RolloutAnalysis.FailurePolicy == RolloutAnalysisFailurePolicyAbort {
+					log.Info("Rollout analysis failed, aborting rollout", "reason", failure)
+					r.EventRecorder.Event(&df, corev1.EventTypeWarning, "Rollout", fmt.Sprintf("Aborted: %s", failure))
+
+					// the updated replicas are kept, and are rolled
+					// back along with the spec
+					setRollingUpdate(&df, false)
+					df.Status.AbortedRolloutRevision = updatedStatefulset.Status.UpdateRevision
+					if err := r.Status().Update(ctx, &df); err != nil {
+						log.Error(err, "could not update the Dragonfly object")
+						return ctrl.Result{Requeue: true}, err
+					}
+
+					return ctrl.Result{}, nil
+				}
+
+				log.Info("Rollout analysis failed, pausing rollout", "reason", failure)
+				r.EventRecorder.Event(&df, corev1.EventTypeWarning, "Rollout", fmt.Sprintf("Paused: %s", failure))
+				return ctrl.Result{RequeueAfter: withJitter(30 * time.Second)}, nil
+			}
+		}
 
 		// if we are here it means that all latest replicas are in stable sync
-		// delete older version replicas
+		// delete older version replicas, up to maxUnavailable at a time
+		deletedReplicas := 0
 		for _, replica := range replicas {
+			if deletedReplicas >= getMaxUnavailable(&df) {
+				break
+			}
+
+			// pods below the partition are not updated
+			if isPodPartitioned(&df, &replica) {
+				continue
+			}
+
 			// Check if pod is on latest version
 			onLatestVersion, err := isPodOnLatestVersion(ctx, r.Client, &replica, &updatedStatefulset)
 			if err != nil {
@@ -174,6 +474,22 @@ func (r *DragonflyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			}
 
 			if !onLatestVersion {
+				// replicas on another storage class are replaced along
+				// with their PVC, one at a time
+				onLatestStorage, err := isPodOnLatestStorageClass(ctx, r.Client, &replica, &updatedStatefulset)
+				if err != nil {
+					log.Error(err, "could not check the storage class of the pod")
+					return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+				}
+
+				if !onLatestStorage {
+					if _, err := r.migrateSnapshotVolume(ctx, &df, replica.Name); err != nil {
+						log.Error(err, "could not migrate the volume", "pod", replica.Name)
+						return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+					}
+					return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, nil
+				}
+
 				// delete the replica
 				log.Info("deleting replica", "pod", replica.Name)
 				r.EventRecorder.Event(&df, corev1.EventTypeNormal, "Rollout", "Deleting replica")
@@ -182,8 +498,25 @@ func (r *DragonflyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 					return ctrl.Result{RequeueAfter: 5 * time.Second}, err
 				}
 
-				return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+				deletedReplicas++
+			}
+		}
+
+		if deletedReplicas > 0 {
+			return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, nil
+		}
+
+		// standbys have no master, all their pods replicate from the
+		// primary of their link
+		if isStandby(&df) {
+			r.EventRecorder.Event(&df, corev1.EventTypeNormal, "Rollout", "Completed")
+			setRollingUpdate(&df, false)
+			if err := r.Status().Update(ctx, &df); err != nil {
+				log.Error(err, "could not update the Dragonfly object")
+				return ctrl.Result{Requeue: true}, err
 			}
+
+			return ctrl.Result{}, nil
 		}
 
 		latestReplica, err := getLatestReplica(ctx, r.Client, &updatedStatefulset)
@@ -200,28 +533,67 @@ func (r *DragonflyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 
 		// If we are here it means that all replicas
 		// are on latest version
-		if !masterOnLatest {
-			// Update master now
-			log.Info("Running REPLTAKEOVER on replica", "pod", master.Name)
-			if err := replTakeover(ctx, r.Client, latestReplica); err != nil {
-				log.Error(err, "could not update master")
+		if !masterOnLatest && !isPodPartitioned(&df, &master) {
+			if err := runTopologyChange(ctx, r.Client, &df, TopologyChangeRolloutTakeover, master.Name, func(ctx context.Context) error {
+				// The replica may have restarted since its sync was checked,
+				// in which case it isn't promoted until it's in sync again
+				isStableState, err := isStableState(ctx, r.Client, latestReplica)
+				if err != nil {
+					return fmt.Errorf("could not check if replica is in stable state: %w", err)
+				}
+				if !isStableState {
+					return fmt.Errorf("replica %s is not in stable state", latestReplica.Name)
+				}
+
+				// Make sure the new master has all the writes of the old one
+				log.Info("Waiting for replica to acknowledge all writes", "pod", latestReplica.Name)
+				if err := waitForReplicaAcknowledgement(ctx, &master, latestReplica, failoverMaxWait(&df)); err != nil {
+					return fmt.Errorf("replica did not acknowledge all writes: %w", err)
+				}
+
+				// Update master now
+				log.Info("Running REPLTAKEOVER on replica", "pod", master.Name)
+				if err := replTakeover(ctx, r.Client, &df, latestReplica); err != nil {
+					return fmt.Errorf("could not update master: %w", err)
+				}
+				r.EventRecorder.Event(&df, corev1.EventTypeNormal, "Rollout", fmt.Sprintf("Shutting down master %s", master.Name))
+
+				// delete the old master, so that it gets recreated with the
+				// new version. Its PVC is migrated once it's a replica.
+				log.Info("deleting master", "pod", master.Name)
+				if err := r.Delete(ctx, &master); err != nil {
+					return fmt.Errorf("could not delete pod: %w", err)
+				}
+
+				return nil
+			}); err != nil {
+				log.Error(err, "could not update the master")
 				return ctrl.Result{RequeueAfter: 5 * time.Second}, err
 			}
-			r.EventRecorder.Event(&df, corev1.EventTypeNormal, "Rollout", fmt.Sprintf("Shutting down master %s", master.Name))
 
-			// delete the old master, so that it gets recreated with the new version
-			log.Info("deleting master", "pod", master.Name)
-			if err := r.Delete(ctx, &master); err != nil {
-				log.Error(err, "could not delete pod")
+			// the old master is recreated on its PVC, which is migrated
+			// once the pod is a replica
+			onLatestStorage, err := isPodOnLatestStorageClass(ctx, r.Client, &master, &updatedStatefulset)
+			if err != nil {
+				log.Error(err, "could not check the storage class of the pod")
 				return ctrl.Result{RequeueAfter: 5 * time.Second}, err
 			}
+
+			if !onLatestStorage {
+				return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, nil
+			}
 		}
 
 		// If we are here all are on latest version
+		newMaster := &master
+		if !masterOnLatest && !isPodPartitioned(&df, &master) {
+			newMaster = latestReplica
+		}
+		r.completeMajorVersionUpgrade(ctx, &df, newMaster)
 		r.EventRecorder.Event(&df, corev1.EventTypeNormal, "Rollout", "Completed")
 
 		// update status
-		df.Status.IsRollingUpdate = false
+		setRollingUpdate(&df, false)
 		if err := r.Status().Update(ctx, &df); err != nil {
 			log.Error(err, "could not update the Dragonfly object")
 			return ctrl.Result{Requeue: true}, err
@@ -232,19 +604,63 @@ func (r *DragonflyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		// perform a rollout only if the pod spec has changed
 		var statefulSet appsv1.StatefulSet
 		if err := r.Get(ctx, client.ObjectKey{Namespace: df.Namespace, Name: df.Name}, &statefulSet); err != nil {
-			log.Error(err, "could not get statefulset")
+			if !apierrors.IsNotFound(err) {
+				log.Error(err, "could not get statefulset")
+				return ctrl.Result{}, err
+			}
+
+			// the statefulset was deleted without its pods, e.g to change
+			// its storage class. The pods on the old storage class are
+			// migrated by a rollout.
+			log.Info("Recreating the statefulset")
+			if err := r.recreateStatefulSet(ctx, &df); err != nil {
+				log.Error(err, "could not recreate the statefulset")
+				return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, err
+			}
+
+			desired, err := r.getDesiredStatefulSet(ctx, &df)
+			if err != nil {
+				log.Error(err, "could not get the statefulset")
+				return ctrl.Result{}, err
+			}
+
+			stale, err := hasStaleSnapshotVolumes(ctx, r.Client, &df, desired)
+			if err != nil {
+				log.Error(err, "could not check the storage class of the pods")
+				return ctrl.Result{}, err
+			}
+
+			if stale {
+				setRollingUpdate(&df, true)
+				if err := r.Status().Update(ctx, &df); err != nil {
+					log.Error(err, "could not update the Dragonfly object")
+					return ctrl.Result{Requeue: true}, err
+				}
+			}
+
+			return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, nil
+		}
+
+		if statefulSet.DeletionTimestamp != nil {
+			log.Info("Waiting for the statefulset to be deleted")
+			return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, nil
+		}
+
+		rolloutDue, err := isRolloutDue(ctx, r.Client, &df, &statefulSet)
+		if err != nil {
+			log.Error(err, "could not check if a rollout is due")
 			return ctrl.Result{}, err
 		}
 
 		// Check if the pod spec has changed
 		log.Info("Checking if pod spec has changed", "updatedReplicas", statefulSet.Status.UpdatedReplicas, "currentReplicas", statefulSet.Status.Replicas)
-		if statefulSet.Status.UpdatedReplicas != statefulSet.Status.Replicas {
+		if rolloutDue && statefulSet.Status.UpdatedReplicas != statefulSet.Status.Replicas && statefulSet.Status.UpdateRevision != df.Status.AbortedRolloutRevision {
 			log.Info("Pod spec has changed, performing a rollout")
 			r.EventRecorder.Event(&df, corev1.EventTypeNormal, "Rollout", "Starting a rollout")
 
 			// Start rollout and update status
 			// update status so that we can track progress
-			df.Status.IsRollingUpdate = true
+			setRollingUpdate(&df, true)
 			if err := r.Status().Update(ctx, &df); err != nil {
 				log.Error(err, "could not update the Dragonfly object")
 				return ctrl.Result{Requeue: true}, err
@@ -253,7 +669,30 @@ func (r *DragonflyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			r.EventRecorder.Event(&df, corev1.EventTypeNormal, "Resources", "Performing a rollout")
 
 			// requeue so that the rollout is processed
-			return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+			return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, nil
+		}
+
+		// Scaling to zero replicas drops the only copy of the data of
+		// instances without persistence
+		if df.Spec.Replicas == 0 && isDataLossBlocked(&df) {
+			log.Info("Not scaling to zero replicas without a data loss confirmation")
+			r.EventRecorder.Event(&df, corev1.EventTypeWarning, "DataLossProtection", fmt.Sprintf("Scaling to zero replicas is blocked as the instance has no persistence. Set the %s annotation to \"true\" to confirm the loss of its data", resources.DataLossConfirmationAnnotation))
+			return ctrl.Result{}, nil
+		}
+
+		if statefulSet.Spec.Replicas != nil && *statefulSet.Spec.Replicas != df.Spec.Replicas {
+			r.EventRecorder.Event(&df, corev1.EventTypeNormal, "Scaling", fmt.Sprintf("Scaling from %d to %d pods", *statefulSet.Spec.Replicas, df.Spec.Replicas))
+		}
+
+		blocked, err := r.checkBootstrapClaim(ctx, &df)
+		if err != nil {
+			log.Error(err, "could not check the bootstrap claim")
+			return ctrl.Result{}, err
+		}
+
+		if blocked {
+			log.Info("Bootstrap claim can't be mounted by all the pods")
+			return ctrl.Result{RequeueAfter: withJitter(time.Minute)}, nil
 		}
 
 		// Is this a Dragonfly object update?
@@ -264,26 +703,247 @@ func (r *DragonflyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			return ctrl.Result{}, err
 		}
 
+		for _, resource := range newResources {
+			if newStatefulSet, ok := resource.(*appsv1.StatefulSet); ok {
+				blocked, err := r.checkMajorVersionUpgrade(ctx, &df, &statefulSet, newStatefulSet)
+				if err != nil {
+					log.Error(err, "could not check the version upgrade")
+					return ctrl.Result{}, err
+				}
+
+				if blocked {
+					log.Info("Update to another major version is blocked")
+					return ctrl.Result{}, nil
+				}
+			}
+		}
+
+		for _, resource := range newResources {
+			if statefulSet, ok := resource.(*appsv1.StatefulSet); ok {
+				if err := setSecretsHash(ctx, r.Client, &df, statefulSet); err != nil {
+					log.Error(err, "could not hash referenced secrets")
+					return ctrl.Result{}, err
+				}
+
+				if err := setConfigMapsHash(ctx, r.Client, &df, statefulSet); err != nil {
+					log.Error(err, "could not hash referenced config maps")
+					return ctrl.Result{}, err
+				}
+			}
+		}
+
+		// Volume claim templates of a statefulset are immutable. Storage class
+		// changes are migrated by deleting the statefulset without its pods,
+		// recreating it once it's gone, and migrating the PVCs of the pods
+		// one by one in a rollout after.
+		for _, resource := range newResources {
+			newStatefulSet, ok := resource.(*appsv1.StatefulSet)
+			if !ok {
+				continue
+			}
+
+			desired := getSnapshotStorageClassName(newStatefulSet)
+			if desired == "" || desired == getSnapshotStorageClassName(&statefulSet) {
+				continue
+			}
+
+			log.Info("Storage class has changed, migrating volumes", "storageClassName", desired)
+			r.EventRecorder.Event(&df, corev1.EventTypeNormal, "StorageMigration", fmt.Sprintf("Migrating volumes to storage class %s", desired))
+			if err := r.Delete(ctx, &statefulSet, client.PropagationPolicy(metav1.DeletePropagationOrphan)); err != nil {
+				log.Error(err, "could not delete statefulset")
+				return ctrl.Result{}, err
+			}
+
+			// the deletion is asynchronous, the statefulset is recreated
+			// once it's gone
+			return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, nil
+		}
+
 		// update all resources
 		for _, resource := range newResources {
+			if isUninstalledServiceMonitor(r.Client, resource) {
+				log.Info("Prometheus Operator is not installed, skipping the service monitor")
+				continue
+			}
+
+			if object, ok := resource.(*unstructured.Unstructured); ok {
+				if err := createOrUpdateUnstructured(ctx, r.Client, object); err != nil {
+					log.Error(err, fmt.Sprintf("could not update resource %s/%s/%s", resource.GetObjectKind(), resource.GetNamespace(), resource.GetName()))
+					return ctrl.Result{}, err
+				}
+				continue
+			}
+
+			// the metrics and replica Services may not exist yet
+			if service, ok := resource.(*corev1.Service); ok && (service.Name == resources.GetMetricsServiceName(&df) || service.Name == resources.GetReplicaServiceName(&df)) {
+				if err := createOrUpdateObject(ctx, r.Client, service); err != nil {
+					log.Error(err, fmt.Sprintf("could not update resource %s/%s/%s", resource.GetObjectKind(), resource.GetNamespace(), resource.GetName()))
+					return ctrl.Result{}, err
+				}
+				continue
+			}
+
+			if pdb, ok := resource.(*policyv1.PodDisruptionBudget); ok {
+				if err := createOrUpdatePodDisruptionBudget(ctx, r.Client, pdb); err != nil {
+					log.Error(err, fmt.Sprintf("could not update resource %s/%s/%s", resource.GetObjectKind(), resource.GetNamespace(), resource.GetName()))
+					return ctrl.Result{}, err
+				}
+				continue
+			}
+
 			if err := r.Update(ctx, resource); err != nil {
 				log.Error(err, fmt.Sprintf("could not update resource %s/%s/%s", resource.GetObjectKind(), resource.GetNamespace(), resource.GetName()))
 				return ctrl.Result{}, err
 			}
 		}
 
+		if !df.Spec.EvictionProtection {
+			if err := deleteMasterPodDisruptionBudget(ctx, r.Client, &df); err != nil {
+				log.Error(err, "could not delete the master pod disruption budget")
+				return ctrl.Result{}, err
+			}
+		}
+
+		if df.Spec.Metrics == nil {
+			if err := deleteMetricsService(ctx, r.Client, &df); err != nil {
+				log.Error(err, "could not delete the metrics service")
+				return ctrl.Result{}, err
+			}
+		}
+
+		if df.Spec.Metrics == nil || df.Spec.Metrics.ServiceMonitor == nil {
+			if err := deleteServiceMonitor(ctx, r.Client, &df); err != nil {
+				log.Error(err, "could not delete the service monitor")
+				return ctrl.Result{}, err
+			}
+		}
+
+		if df.Spec.ReplicaService == nil {
+			if err := deleteReplicaService(ctx, r.Client, &df); err != nil {
+				log.Error(err, "could not delete the replica service")
+				return ctrl.Result{}, err
+			}
+		}
+
+		if logArgs := resources.GetLogArgs(&df); !equality.Semantic.DeepEqual(logArgs, df.Status.LogArgs) {
+			df.Status.LogArgs = logArgs
+			if err := r.Status().Update(ctx, &df); err != nil {
+				log.Error(err, "could not update the Dragonfly object")
+				return ctrl.Result{Requeue: true}, err
+			}
+		}
+
 		log.Info("Updated resources for object")
 		r.EventRecorder.Event(&df, corev1.EventTypeNormal, "Resources", "Updated resources")
 		return ctrl.Result{Requeue: true}, nil
 	}
 }
 
-// SetupWithManager sets up the controller with the Manager.
+// failoverMaxWait returns the maximum time to wait for a replica to
+// acknowledge all writes before it is promoted in a planned failover
+func failoverMaxWait(df *dfv1alpha1.Dragonfly) time.Duration {
+	if df.Spec.FailoverMaxWait != nil {
+		return df.Spec.FailoverMaxWait.Duration
+	}
+
+	return defaultFailoverMaxWait
+}
+
+// SetupWithManager sets up the controllers with the Manager, one per
+// priority tier
 func (r *DragonflyReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		// Listen only to spec changes
-		For(&dfv1alpha1.Dragonfly{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
-		Owns(&appsv1.StatefulSet{}).
-		Owns(&corev1.Service{}).
-		Complete(r)
+	return r.setupWithCluster(mgr, mgr, "dragonfly")
+}
+
+// setupWithCluster sets up the controllers of the given name, one per
+// priority tier, for the objects of the cluster, i.e. the cluster of the
+// Manager or a remote one
+func (r *DragonflyReconciler) setupWithCluster(mgr ctrl.Manager, cl cluster.Cluster, name string) error {
+	adminCAs.addReader(cl.GetClient())
+
+	kind := func(obj client.Object) source.Source {
+		return source.NewKindWithCache(obj, cl.GetCache())
+	}
+	owner := &handler.EnqueueRequestForOwner{OwnerType: &dfv1alpha1.Dragonfly{}, IsController: true}
+
+	for _, critical := range priorities {
+		filter := objectFilter{reader: cl.GetClient(), shard: r.Shard, critical: critical}
+		if err := ctrl.NewControllerManagedBy(mgr).
+			Named(filter.controllerName(name)).
+			// Listen only to spec changes
+			Watches(kind(&dfv1alpha1.Dragonfly{}), &handler.EnqueueRequestForObject{}, builder.WithPredicates(filter.predicate(), predicate.Or(predicate.GenerationChangedPredicate{}, annotationChangedPredicate(resources.SaveRequestAnnotation, resources.DataLossConfirmationAnnotation, resources.ExplainRequestAnnotation, resources.RestartRequestAnnotation, resources.FailoverToAnnotation)))).
+			Watches(kind(&appsv1.StatefulSet{}), owner, builder.WithPredicates(filter.predicate())).
+			Watches(kind(&corev1.Service{}), owner, builder.WithPredicates(filter.predicate())).
+			Watches(kind(&appsv1.Deployment{}), owner, builder.WithPredicates(filter.predicate())).
+			Watches(kind(&batchv1.Job{}), owner, builder.WithPredicates(filter.predicate())).
+			// Re-reconcile when a referenced secret is created or synced
+			Watches(kind(&corev1.Secret{}), handler.EnqueueRequestsFromMapFunc(filter.dragonflies(r.findDragonfliesForSecret))).
+			Watches(kind(&corev1.ConfigMap{}), handler.EnqueueRequestsFromMapFunc(filter.dragonflies(r.findDragonfliesForConfigMap))).
+			// Roll out the changes of a class to its objects
+			Watches(kind(&dfv1alpha1.DragonflyClass{}), handler.EnqueueRequestsFromMapFunc(filter.dragonflies(r.findDragonfliesForClass))).
+			WithOptions(controller.Options{RateLimiter: newRateLimiter(r.RateLimiterOptions)}).
+			Complete(reconcileErrorRecorder("dragonfly", dragonflyInstance, r)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// findDragonfliesForSecret returns the Dragonfly objects referencing the secret
+func (r *DragonflyReconciler) findDragonfliesForSecret(secret client.Object) []reconcile.Request {
+	var dfs dfv1alpha1.DragonflyList
+	if err := r.List(context.Background(), &dfs, client.InNamespace(secret.GetNamespace())); err != nil {
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0)
+	for _, df := range dfs.Items {
+		for _, name := range getReferencedSecretNames(&df) {
+			if name == secret.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&df)})
+				break
+			}
+		}
+	}
+
+	return requests
+}
+
+// findDragonfliesForConfigMap returns the Dragonfly objects referencing the
+// config map
+func (r *DragonflyReconciler) findDragonfliesForConfigMap(configMap client.Object) []reconcile.Request {
+	var dfs dfv1alpha1.DragonflyList
+	if err := r.List(context.Background(), &dfs, client.InNamespace(configMap.GetNamespace())); err != nil {
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0)
+	for _, df := range dfs.Items {
+		for _, name := range getReferencedConfigMapNames(&df) {
+			if name == configMap.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&df)})
+				break
+			}
+		}
+	}
+
+	return requests
+}
+
+// findDragonfliesForClass returns the Dragonfly objects of the class
+func (r *DragonflyReconciler) findDragonfliesForClass(class client.Object) []reconcile.Request {
+	var dfs dfv1alpha1.DragonflyList
+	if err := r.List(context.Background(), &dfs); err != nil {
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0)
+	for _, df := range dfs.Items {
+		if df.Spec.ClassName == class.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&df)})
+		}
+	}
+
+	return requests
 }
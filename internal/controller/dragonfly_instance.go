@@ -20,18 +20,44 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strings"
+	"sync"
+	"time"
 
 	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
 	resourcesv1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
 	"github.com/dragonflydb/dragonfly-operator/internal/resources"
 	"github.com/go-logr/logr"
-	"github.com/redis/go-redis/v9"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+const (
+	// maxConcurrentReplicaConfigurations is the maximum number of replicas
+	// that are configured in parallel
+	maxConcurrentReplicaConfigurations = 10
+
+	// defaultReplicationCooldown is the default minimum time between two
+	// SLAVE OF commands to the same master for a pod
+	defaultReplicationCooldown = 10 * time.Second
+
+	// fencePause is how long writes are paused on an old master while
+	// it is made a replica
+	fencePause = 10 * time.Second
+
+	// defaultFailoverGracePeriod is the default time a master may
+	// not be ready before a replica is promoted
+	defaultFailoverGracePeriod = 30 * time.Second
+)
+
+// errReplicationCooldown is returned when a pod was recently
+// configured as a replica of the same master
+var errReplicationCooldown = errors.New("replication was reconfigured recently")
+
 // DragonflyInstance is an abstraction over the `Dragonfly` CRD
 // and provides methods to handle replication.
 type DragonflyInstance struct {
@@ -40,9 +66,13 @@ type DragonflyInstance struct {
 
 	client client.Client
 	log    logr.Logger
+
+	// eventRecorder records the replication and role changes as events of
+	// the Dragonfly object. No events are recorded if nil.
+	eventRecorder record.EventRecorder
 }
 
-func GetDragonflyInstanceFromPod(ctx context.Context, c client.Client, pod *corev1.Pod, log logr.Logger) (*DragonflyInstance, error) {
+func GetDragonflyInstanceFromPod(ctx context.Context, c client.Client, eventRecorder record.EventRecorder, pod *corev1.Pod, log logr.Logger) (*DragonflyInstance, error) {
 	dfName, ok := pod.Labels["app"]
 	if !ok {
 		return nil, errors.New("can't find the `app` label")
@@ -59,12 +89,21 @@ func GetDragonflyInstanceFromPod(ctx context.Context, c client.Client, pod *core
 	}
 
 	return &DragonflyInstance{
-		df:     &df,
-		client: c,
-		log:    log,
+		df:            &df,
+		client:        c,
+		log:           log,
+		eventRecorder: eventRecorder,
 	}, nil
 }
 
+// event records an event of the Dragonfly object, if the instance has an
+// event recorder
+func (dfi *DragonflyInstance) event(eventType, reason, message string) {
+	if dfi.eventRecorder != nil {
+		dfi.eventRecorder.Event(dfi.df, eventType, reason, message)
+	}
+}
+
 func (dfi *DragonflyInstance) getStatus(ctx context.Context) (string, error) {
 	if err := dfi.client.Get(ctx, types.NamespacedName{
 		Name:      dfi.df.Name,
@@ -76,7 +115,13 @@ func (dfi *DragonflyInstance) getStatus(ctx context.Context) (string, error) {
 	return dfi.df.Status.Phase, nil
 }
 
+// configureReplication elects a master, and configures the other pods
+// as its replicas
 func (dfi *DragonflyInstance) configureReplication(ctx context.Context) error {
+	return runTopologyChange(ctx, dfi.client, dfi.df, TopologyChangeConfigureReplication, "", dfi.electMaster)
+}
+
+func (dfi *DragonflyInstance) electMaster(ctx context.Context) error {
 	dfi.log.Info("Configuring replication")
 
 	pods, err := dfi.getPods(ctx)
@@ -84,11 +129,22 @@ func (dfi *DragonflyInstance) configureReplication(ctx context.Context) error {
 		return err
 	}
 
+	// Resume an initialization that failed halfway from the observed
+	// state, instead of electing another master and resyncing all replicas
+	var configuredMaster *corev1.Pod
+	if dfi.df.Status.Phase == PhaseResourcesCreated {
+		configuredMaster = dfi.getConfiguredMaster(ctx, pods)
+	}
+
 	// remove master pod label if it exists
 	// This is important as the pod termination could take a while in
 	// the deleted case causing unnecessary master reconcilation as 2 masters
 	// could exist at the same time.
 	for _, pod := range pods.Items {
+		if configuredMaster != nil && pod.Name == configuredMaster.Name {
+			continue
+		}
+
 		if pod.Labels[resources.Role] == resources.Master {
 			delete(pod.Labels, resources.Role)
 			if err := dfi.client.Update(ctx, &pod); err != nil {
@@ -99,16 +155,32 @@ func (dfi *DragonflyInstance) configureReplication(ctx context.Context) error {
 
 	var master string
 	var masterIp string
-	for _, pod := range pods.Items {
-		if pod.Status.Phase == corev1.PodRunning && pod.Status.ContainerStatuses[0].Ready && pod.DeletionTimestamp == nil && pod.Status.PodIP != "" {
+	// among the pods of the same failover priority, the one that lost the
+	// fewest writes of the old master is preferred
+	sortByReplicationOffset(ctx, pods.Items)
+	sortByFailoverPriority(pods.Items)
+	if dfi.isColdStart(pods) {
+		// the pod with the most recent data has to become the master, as
+		// the others would drop their data when replicating from it
+		dfi.log.Info("All pods were restarted, electing the pod with the most recent snapshot as master")
+		sortByLastSnapshot(ctx, pods.Items)
+	}
+
+	if configuredMaster != nil {
+		master = configuredMaster.Name
+		masterIp = configuredMaster.Status.PodIP
+		dfi.log.Info("Resuming replication with the configured master", "podName", master, "ip", masterIp)
+	}
+
+	if master == "" {
+		if pod := dfi.selectMaster(ctx, pods); pod != nil {
 			master = pod.Name
 			masterIp = pod.Status.PodIP
 			dfi.log.Info("Marking pod as master", "podName", master, "ip", masterIp)
-			if err := dfi.replicaOfNoOne(ctx, &pod); err != nil {
+			if err := dfi.replicaOfNoOne(ctx, pod); err != nil {
 				dfi.log.Error(err, "Failed to mark pod as master", "podName", pod.Name)
 				return err
 			}
-			break
 		}
 	}
 
@@ -117,23 +189,61 @@ func (dfi *DragonflyInstance) configureReplication(ctx context.Context) error {
 		return errors.New("couldn't find a healthy pod to configure as master")
 	}
 
-	// Mark others as replicas
-	markedPods := 0
-	for _, pod := range pods.Items {
-		// only mark the running non-master pods
+	// Mark others as replicas concurrently, so that instances with a large
+	// number of replicas converge quickly
+	var (
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		markedPods int
+		markErr    error
+	)
+	sem := make(chan struct{}, maxConcurrentReplicaConfigurations)
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		// only mark the ready non-master pods, the others are
+		// configured once they become ready
 		dfi.log.Info("Checking pod", "podName", pod.Name, "ip", pod.Status.PodIP, "status", pod.Status.Phase, "deletiontimestamp", pod.DeletionTimestamp)
-		if pod.Name != master && pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil && pod.Status.PodIP != "" {
-			dfi.log.Info("Marking pod as replica", "podName", pod.Name, "ip", pod.Status.PodIP, "status", pod.Status.Phase)
-			if err := dfi.replicaOf(ctx, &pod, masterIp); err != nil {
-				// TODO: Why does this fail every now and then?
-				// Should replication be continued if it fails?
-				dfi.log.Error(err, "Failed to mark pod as replica", "podName", pod.Name)
-				return err
-			} else {
-				markedPods++
+		if pod.Name != master && pod.Status.Phase == corev1.PodRunning && pod.Status.ContainerStatuses[0].Ready && pod.DeletionTimestamp == nil && pod.Status.PodIP != "" && dfi.isNodeReady(ctx, pod) {
+			// skip the pods that already replicate from the master
+			if pod.Labels[resources.Role] == resources.Replica && pod.Labels[resources.MasterIp] == masterIp {
+				if ok, err := dfi.checkReplicaRole(ctx, pod, masterIp); err == nil && ok {
+					dfi.log.Info("Pod is already a replica of the master", "podName", pod.Name)
+					mu.Lock()
+					markedPods++
+					mu.Unlock()
+					continue
+				}
 			}
+
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				dfi.log.Info("Marking pod as replica", "podName", pod.Name, "ip", pod.Status.PodIP, "status", pod.Status.Phase)
+				err := dfi.replicaOf(ctx, pod, masterIp)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					// TODO: Why does this fail every now and then?
+					// Should replication be continued if it fails?
+					dfi.log.Error(err, "Failed to mark pod as replica", "podName", pod.Name)
+					if markErr == nil {
+						markErr = err
+					}
+					return
+				}
+				markedPods++
+			}()
 		}
 	}
+	wg.Wait()
+
+	if markErr != nil {
+		return markErr
+	}
 
 	dfi.log.Info(fmt.Sprintf("Successfully marked %d/%d replicas", markedPods, len(pods.Items)-1))
 	if err := dfi.updateStatus(ctx, PhaseReady); err != nil {
@@ -143,6 +253,170 @@ func (dfi *DragonflyInstance) configureReplication(ctx context.Context) error {
 	return nil
 }
 
+// selectMaster returns the first of the given pods, in the order of the
+// election, that can be promoted to master, or nil if none of them can
+func (dfi *DragonflyInstance) selectMaster(ctx context.Context, pods *corev1.PodList) *corev1.Pod {
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if getFailoverPriority(pod) == 0 {
+			dfi.log.Info("Skipping pod with a failover priority of 0", "podName", pod.Name)
+			continue
+		}
+
+		// pods that are still warming up aren't promoted
+		if available, _ := isPodAvailable(pod, dfi.df.Spec.MinReadySeconds); !available {
+			dfi.log.Info("Skipping pod that isn't available yet", "podName", pod.Name)
+			continue
+		}
+
+		if pod.Status.Phase == corev1.PodRunning && pod.Status.ContainerStatuses[0].Ready && pod.DeletionTimestamp == nil && pod.Status.PodIP != "" && dfi.isNodeReady(ctx, pod) {
+			// the replicas would drop the data of a pod that is still
+			// loading its snapshot, e.g a bootstrap snapshot
+			if isLoadingSnapshot(ctx, pod) {
+				dfi.log.Info("Skipping pod that is loading its snapshot", "podName", pod.Name)
+				continue
+			}
+			return pod
+		}
+	}
+
+	return nil
+}
+
+// getConfiguredMaster returns the pod that is labeled and running as
+// master, if it can still serve as one
+func (dfi *DragonflyInstance) getConfiguredMaster(ctx context.Context, pods *corev1.PodList) *corev1.Pod {
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Labels[resources.Role] != resources.Master {
+			continue
+		}
+
+		if pod.Status.Phase != corev1.PodRunning || !pod.Status.ContainerStatuses[0].Ready || pod.DeletionTimestamp != nil || pod.Status.PodIP == "" {
+			continue
+		}
+
+		info, err := getReplicationInfo(ctx, pod)
+		if err != nil || info["role"] != resources.Master {
+			continue
+		}
+
+		// the pods get reordered afterwards
+		return pod.DeepCopy()
+	}
+
+	return nil
+}
+
+// isColdStart returns if all the pods of an instance with snapshots were
+// restarted, e.g after an outage of the cluster. None of them has a role then.
+func (dfi *DragonflyInstance) isColdStart(pods *corev1.PodList) bool {
+	if dfi.df.Spec.Snapshot == nil || dfi.df.Spec.Snapshot.PersistentVolumeClaimSpec == nil {
+		return false
+	}
+
+	for _, pod := range pods.Items {
+		if _, ok := pod.Labels[resources.Role]; ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// coldStartReady returns if a master can be elected. After a cold start,
+// all the pods are waited for up to coldStartMaxWait, so that the pod with
+// the most recent snapshot is among the candidates.
+func (dfi *DragonflyInstance) coldStartReady(ctx context.Context) (bool, error) {
+	pods, err := dfi.getPods(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if !dfi.isColdStart(pods) {
+		return true, nil
+	}
+
+	return dfi.podsReady(pods), nil
+}
+
+// podsReady returns if all the pods are ready, or if some of them have
+// been ready for longer than coldStartMaxWait
+func (dfi *DragonflyInstance) podsReady(pods *corev1.PodList) bool {
+	ready := 0
+	var firstReady time.Time
+	for _, pod := range pods.Items {
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+				ready++
+				if firstReady.IsZero() || condition.LastTransitionTime.Time.Before(firstReady) {
+					firstReady = condition.LastTransitionTime.Time
+				}
+			}
+		}
+	}
+
+	if ready >= int(dfi.df.Spec.Replicas) {
+		return true
+	}
+
+	if ready > 0 && time.Since(firstReady) > coldStartMaxWait {
+		dfi.log.Info("Not all pods are ready, electing a master among the ready ones", "ready", ready)
+		return true
+	}
+
+	return false
+}
+
+// initialReplicationReady returns if replication can be configured for
+// the first time. With the Parallel pod management policy, the pods start
+// together, so they are waited for to configure replication once, instead
+// of racing to elect a master as each of them becomes ready.
+func (dfi *DragonflyInstance) initialReplicationReady(ctx context.Context) (bool, error) {
+	if dfi.df.Spec.PodManagementPolicy != appsv1.ParallelPodManagement {
+		return true, nil
+	}
+
+	pods, err := dfi.getPods(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return dfi.podsReady(pods), nil
+}
+
+// isNodeReady returns if the node of the given pod is ready. The pods of
+// a node that is not ready can't be reached, even if they look ready.
+func (dfi *DragonflyInstance) isNodeReady(ctx context.Context, pod *corev1.Pod) bool {
+	ready, _, err := getNodeReadiness(ctx, dfi.client, pod.Spec.NodeName)
+	if err != nil {
+		dfi.log.Error(err, "could not get node readiness, assuming it is ready", "node", pod.Spec.NodeName)
+		return true
+	}
+
+	return ready
+}
+
+// failoverGracePeriod returns how long a master may not be
+// ready before a replica is promoted
+func (dfi *DragonflyInstance) failoverGracePeriod() time.Duration {
+	if dfi.df.Spec.Failover != nil && dfi.df.Spec.Failover.GracePeriodSeconds != nil {
+		return time.Duration(*dfi.df.Spec.Failover.GracePeriodSeconds) * time.Second
+	}
+
+	return defaultFailoverGracePeriod
+}
+
+// replicationCooldown returns the minimum time between two
+// SLAVE OF commands to the same master for a pod
+func (dfi *DragonflyInstance) replicationCooldown() time.Duration {
+	if dfi.df.Spec.ReplicationCooldown != nil {
+		return dfi.df.Spec.ReplicationCooldown.Duration
+	}
+
+	return defaultReplicationCooldown
+}
+
 func (dfi *DragonflyInstance) updateStatus(ctx context.Context, phase string) error {
 	// get latest df object first
 	if err := dfi.client.Get(ctx, types.NamespacedName{
@@ -153,14 +427,130 @@ func (dfi *DragonflyInstance) updateStatus(ctx context.Context, phase string) er
 	}
 
 	dfi.log.Info("Updating status", "phase", phase)
-	dfi.df.Status.Phase = phase
+	previous := dfi.df.Status.Phase
+	setPhase(dfi.df, phase)
 	if err := dfi.client.Status().Update(ctx, dfi.df); err != nil {
 		return err
 	}
 
+	if previous != phase {
+		dfi.event(corev1.EventTypeNormal, "Phase", fmt.Sprintf("Phase changed from %s to %s", previous, phase))
+	}
+
 	return nil
 }
 
+// updateDegradedCondition sets the Degraded condition and phase of the
+// instance based on the replicas that are in sync with the master. It
+// returns the condition if its status has changed, nil otherwise.
+func (dfi *DragonflyInstance) updateDegradedCondition(ctx context.Context) (*metav1.Condition, error) {
+	pods, err := dfi.getPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var master *corev1.Pod
+	var replicaPods []*corev1.Pod
+	infos := make(map[string]map[string]string)
+	replicas := 0
+	for i, pod := range pods.Items {
+		if pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning || !pod.Status.ContainerStatuses[0].Ready {
+			continue
+		}
+
+		// every ready pod is probed, so that the probe results are recent
+		info, err := getReplicationInfo(ctx, &pods.Items[i])
+		if err == nil {
+			infos[pod.Name] = info
+		}
+
+		switch pod.Labels[resources.Role] {
+		case resources.Master:
+			master = &pods.Items[i]
+		case resources.Replica:
+			replicaPods = append(replicaPods, &pods.Items[i])
+			// only replicas that are in sync with the master count
+			if err == nil && info["master_link_status"] == "up" {
+				replicas++
+			}
+		}
+	}
+	podStatuses := getPodStatuses(client.ObjectKeyFromObject(dfi.df), pods)
+
+	masterName := ""
+	var masterInfo map[string]string
+	if master != nil {
+		masterName = master.Name
+		masterInfo = infos[master.Name]
+	}
+	replicaStatuses := getReplicaStatuses(replicaPods, masterInfo, infos)
+	recordMaster(dfi.df, masterName)
+
+	desired := int(dfi.df.Spec.Replicas) - 1
+	condition := metav1.Condition{
+		Type:    ConditionDegraded,
+		Status:  metav1.ConditionFalse,
+		Reason:  "ReplicasAvailable",
+		Message: fmt.Sprintf("%d/%d replicas are in sync", replicas, desired),
+	}
+
+	// the master keeps serving writes, while the statefulset recreates
+	// the replicas and they are configured as they become ready
+	if master != nil && replicas < desired {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "ReplicasUnavailable"
+		if replicas == 0 {
+			condition.Reason = "NoReplicas"
+			condition.Message = fmt.Sprintf("master %s has lost all of its replicas", master.Name)
+		}
+	}
+
+	// get latest df object first
+	if err := dfi.client.Get(ctx, types.NamespacedName{
+		Name:      dfi.df.Name,
+		Namespace: dfi.df.Namespace,
+	}, dfi.df); err != nil {
+		return nil, err
+	}
+
+	podStatusesChanged := setPodStatuses(dfi.df, podStatuses)
+	// the lags are refreshed along with the probe results
+	replicationStatusChanged := setReplicationStatus(dfi.df, masterName, replicaStatuses, podStatusesChanged)
+	// instances that were ready before the state conditions were
+	// introduced get them here, as their phase doesn't change
+	stateConditionsMissing := meta.FindStatusCondition(dfi.df.Status.Conditions, ConditionReady) == nil
+	if stateConditionsMissing {
+		setStateConditions(dfi.df)
+	}
+	existing := meta.FindStatusCondition(dfi.df.Status.Conditions, ConditionDegraded)
+	if existing != nil && existing.Status == condition.Status && existing.Reason == condition.Reason && existing.Message == condition.Message {
+		if podStatusesChanged || replicationStatusChanged || stateConditionsMissing {
+			if err := dfi.client.Status().Update(ctx, dfi.df); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	}
+	changed := existing == nil || existing.Status != condition.Status
+
+	condition.ObservedGeneration = dfi.df.Generation
+	meta.SetStatusCondition(&dfi.df.Status.Conditions, condition)
+	if condition.Status == metav1.ConditionTrue && dfi.df.Status.Phase == PhaseReady {
+		setPhase(dfi.df, PhaseDegraded)
+	} else if condition.Status == metav1.ConditionFalse && dfi.df.Status.Phase == PhaseDegraded {
+		setPhase(dfi.df, PhaseReady)
+	}
+	if err := dfi.client.Status().Update(ctx, dfi.df); err != nil {
+		return nil, err
+	}
+
+	if !changed {
+		return nil, nil
+	}
+
+	return &condition, nil
+}
+
 func (dfi *DragonflyInstance) masterExists(ctx context.Context) (bool, error) {
 	dfi.log.Info("checking if a master exists already")
 	pods, err := dfi.getPods(ctx)
@@ -216,35 +606,20 @@ func (dfi *DragonflyInstance) configureReplica(ctx context.Context, pod *corev1.
 // checkReplicaRole checks if the given pod is a replica and if it is
 // connected to the right master
 func (dfi *DragonflyInstance) checkReplicaRole(ctx context.Context, pod *corev1.Pod, masterIp string) (bool, error) {
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: fmt.Sprintf("%s:%d", pod.Status.PodIP, resources.DragonflyAdminPort),
-	})
-
-	resp, err := redisClient.Info(ctx, "replication").Result()
+	info, err := getReplicationInfo(ctx, pod)
 	if err != nil {
 		return false, err
 	}
 
-	var redisRole string
-	for _, line := range strings.Split(resp, "\n") {
-		if strings.Contains(line, "role") {
-			redisRole = strings.Trim(strings.Split(line, ":")[1], "\r")
-		}
-	}
-
-	if redisRole != resources.Replica {
+	if info["role"] != resources.Replica {
 		return false, nil
 	}
 
-	var redisMasterIp string
-	// check if it is connected to the right master
-	for _, line := range strings.Split(resp, "\n") {
-		if strings.Contains(line, "master_host") {
-			redisMasterIp = strings.Trim(strings.Split(line, ":")[1], "\r")
-		}
-	}
-
-	if masterIp != redisMasterIp && masterIp != pod.Labels[resources.MasterIp] {
+	// check if it is connected to the right master. A master that was
+	// restarted with a new IP keeps its role, while its replicas keep
+	// trying to reach its old IP.
+	if info["master_host"] != masterIp {
+		dfi.log.Info("replica is targeting a stale master address", "pod", pod.Name, "masterHost", info["master_host"], "masterIp", masterIp)
 		return false, nil
 	}
 
@@ -318,6 +693,15 @@ func (dfi *DragonflyInstance) checkAndConfigureReplication(ctx context.Context)
 		}
 	}
 
+	// the master may have a new IP
+	for _, pod := range pods.Items {
+		if pod.Labels[resources.Role] == resources.Master && pod.Status.PodIP == masterIp {
+			if err := updateMasterEndpoints(ctx, dfi.client, dfi.df, &pod); err != nil {
+				return err
+			}
+		}
+	}
+
 	dfi.log.Info("all pods are configured correctly", "dfi", dfi.df.Name)
 	return nil
 }
@@ -339,26 +723,78 @@ func (dfi *DragonflyInstance) getPods(ctx context.Context) (*corev1.PodList, err
 // replicaOf configures the pod as a replica
 // to the given master instance
 func (dfi *DragonflyInstance) replicaOf(ctx context.Context, pod *corev1.Pod, masterIp string) error {
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: fmt.Sprintf("%s:%d", pod.Status.PodIP, resources.DragonflyAdminPort),
-	})
+	// Re-issuing SLAVE OF for the same master interrupts an ongoing full sync,
+	// so it is only allowed once the cooldown has passed
+	if pod.Labels[resources.MasterIp] == masterIp {
+		if lastReplicaOf, err := time.Parse(time.RFC3339, pod.Annotations[resources.LastReplicaOfAnnotation]); err == nil {
+			if remaining := dfi.replicationCooldown() - time.Since(lastReplicaOf); remaining > 0 {
+				return fmt.Errorf("%w: pod %s, retry in %s", errReplicationCooldown, pod.Name, remaining.Round(time.Second))
+			}
+		}
+	}
+
+	if err := faults.delayReplicaOf(ctx, dfi.df); err != nil {
+		return err
+	}
+
+	redisClient := newAdminClient(ctx, pod)
+	defer redisClient.Close()
+
+	// Once the instance is initialized, a pod that acts as master may be an
+	// old master coming back after a failover. Block writes on it until
+	// it's a replica, so that no stale writes are accepted.
+	fence := false
+	if dfi.df.Status.Phase != PhaseResourcesCreated {
+		if info, err := fetchInfo(ctx, pod, "replication"); err == nil && info["role"] == resources.Master {
+			fence = true
+		}
+	}
+
+	if fence {
+		dfi.log.Info("Pod acts as master, fencing it", "pod", pod.Name)
+		if err := redisClient.Do(ctx, "CLIENT", "PAUSE", fencePause.Milliseconds(), "WRITE").Err(); err != nil {
+			dfi.log.Error(err, "could not pause writes on old master", "pod", pod.Name)
+		}
+	}
 
 	dfi.log.Info("Trying to invoke SLAVE OF command", "pod", pod.Name, "master", masterIp, "addr", redisClient.Options().Addr)
 	resp, err := redisClient.SlaveOf(ctx, masterIp, fmt.Sprint(resources.DragonflyAdminPort)).Result()
 	if err != nil {
+		dfi.event(corev1.EventTypeWarning, "Replication", fmt.Sprintf("SLAVE OF %s failed on pod %s: %s", masterIp, pod.Name, err))
+		recordReplicaSyncFailure(dfi.df)
 		return fmt.Errorf("error running SLAVE OF command: %s", err)
 	}
 
 	if resp != "OK" {
+		dfi.event(corev1.EventTypeWarning, "Replication", fmt.Sprintf("SLAVE OF %s failed on pod %s: %s", masterIp, pod.Name, resp))
+		recordReplicaSyncFailure(dfi.df)
 		return fmt.Errorf("response of `SLAVE OF` on replica is not OK: %s", resp)
 	}
+	infoCache.invalidate(pod)
+
+	if fence {
+		// disconnect the clients, so that they reconnect to the new master
+		if err := killClientConnections(ctx, redisClient); err != nil {
+			dfi.log.Error(err, "could not disconnect clients of old master", "pod", pod.Name)
+		}
+
+		// replicas reject writes themselves
+		if err := redisClient.Do(ctx, "CLIENT", "UNPAUSE").Err(); err != nil {
+			dfi.log.Error(err, "could not unpause writes on old master", "pod", pod.Name)
+		}
+	}
 
 	dfi.log.Info("Marking pod role as replica", "pod", pod.Name)
 	pod.Labels[resources.Role] = resources.Replica
 	pod.Labels[resources.MasterIp] = masterIp
+	if pod.Annotations == nil {
+		pod.Annotations = make(map[string]string)
+	}
+	pod.Annotations[resources.LastReplicaOfAnnotation] = time.Now().UTC().Format(time.RFC3339)
 	if err := dfi.client.Update(ctx, pod); err != nil {
 		return fmt.Errorf("could not update replica label")
 	}
+	dfi.event(corev1.EventTypeNormal, "Replication", fmt.Sprintf("Configured pod %s as a replica of %s", pod.Name, masterIp))
 
 	return nil
 }
@@ -366,25 +802,34 @@ func (dfi *DragonflyInstance) replicaOf(ctx context.Context, pod *corev1.Pod, ma
 // replicaOfNoOne configures the pod as a master
 // along while updating other pods to be replicas
 func (dfi *DragonflyInstance) replicaOfNoOne(ctx context.Context, pod *corev1.Pod) error {
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: fmt.Sprintf("%s:%d", pod.Status.PodIP, resources.DragonflyAdminPort),
-	})
+	redisClient := newAdminClient(ctx, pod)
 
 	dfi.log.Info("Running SLAVE OF NO ONE command", "pod", pod.Name, "addr", redisClient.Options().Addr)
 	resp, err := redisClient.SlaveOf(ctx, "NO", "ONE").Result()
 	if err != nil {
+		dfi.event(corev1.EventTypeWarning, "Replication", fmt.Sprintf("SLAVE OF NO ONE failed on pod %s: %s", pod.Name, err))
+		recordReplicaSyncFailure(dfi.df)
 		return fmt.Errorf("error running SLAVE OF NO ONE command: %w", err)
 	}
 
 	if resp != "OK" {
+		dfi.event(corev1.EventTypeWarning, "Replication", fmt.Sprintf("SLAVE OF NO ONE failed on pod %s: %s", pod.Name, resp))
+		recordReplicaSyncFailure(dfi.df)
 		return fmt.Errorf("response of `SLAVE OF NO ONE` on master is not OK: %s", resp)
 	}
+	infoCache.invalidate(pod)
 
 	dfi.log.Info("Marking pod role as master", "pod", pod.Name)
 	pod.Labels[resources.Role] = resources.Master
 	if err := dfi.client.Update(ctx, pod); err != nil {
 		return err
 	}
+	recordNewMaster(ctx, pod.Name)
+	dfi.event(corev1.EventTypeNormal, "Replication", fmt.Sprintf("Promoted pod %s to master", pod.Name))
+
+	if err := updateMasterEndpoints(ctx, dfi.client, dfi.df, pod); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -19,27 +19,51 @@ package controller
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 type DfPodLifeCycleReconciler struct {
 	client.Client
 	Scheme        *runtime.Scheme
 	EventRecorder record.EventRecorder
+
+	// RateLimiterOptions tune the rate limiter of the work queue, the
+	// default rate limiter is used if nil
+	RateLimiterOptions *RateLimiterOptions
+
+	// Shard is the share of the Dragonfly objects whose pods are managed
+	Shard Shard
+
+	// replicationFailures is the number of consecutive failures
+	// to configure replication per pod
+	replicationFailures   map[types.NamespacedName]int
+	replicationFailuresMu sync.Mutex
 }
 
 // +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -54,26 +78,111 @@ func (r *DfPodLifeCycleReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	var pod corev1.Pod
 	err := r.Client.Get(ctx, req.NamespacedName, &pod, &client.GetOptions{})
 	if err != nil {
+		if apierrors.IsNotFound(err) {
+			// the failures of deleted pods are forgotten, so that the map
+			// doesn't grow with every pod ever seen
+			r.resetReplicationBackoff(req.NamespacedName)
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
 	// check for pod readiness
-	if pod.Status.PodIP == "" || pod.Status.Phase != corev1.PodRunning || !pod.Status.ContainerStatuses[0].Ready {
+	if pod.Status.PodIP == "" || pod.Status.Phase != corev1.PodRunning || len(pod.Status.ContainerStatuses) == 0 || !pod.Status.ContainerStatuses[0].Ready {
 		log.Info("Pod is not ready yet")
-		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+		// a replica may have become unavailable
+		if dfi, err := GetDragonflyInstanceFromPod(ctx, r.Client, r.EventRecorder, &pod, log); err == nil {
+			r.updateDegradedCondition(ctx, dfi)
+
+			// a master that is being deleted, e.g after it crashed, won't
+			// become ready again, so a replica is promoted right away
+			if pod.Labels[resources.Role] == resources.Master && pod.DeletionTimestamp != nil && !isRolloutHandlingMaster(dfi.df) && !isStandby(dfi.df) {
+				log.Info("Master is being deleted and is not ready. Configuring replication", "pod", pod.Name)
+				if err := dfi.configureReplication(ctx); err != nil {
+					log.Error(err, "couldn't find healthy and mark active")
+					return ctrl.Result{RequeueAfter: r.replicationBackoff(dfi, req.NamespacedName)}, nil
+				}
+
+				r.EventRecorder.Event(dfi.df, corev1.EventTypeWarning, "Replication", fmt.Sprintf("Master %s is being deleted, updated master instance", pod.Name))
+				r.resetReplicationBackoff(req.NamespacedName)
+				return ctrl.Result{}, nil
+			}
+
+			// an unready master is failed over once it wasn't ready for
+			// the failover grace period
+			if pod.Labels[resources.Role] == resources.Master && pod.DeletionTimestamp == nil && !isStandby(dfi.df) {
+				notReadySince := pod.CreationTimestamp.Time
+				for _, condition := range pod.Status.Conditions {
+					if condition.Type == corev1.PodReady && condition.Status != corev1.ConditionTrue {
+						notReadySince = condition.LastTransitionTime.Time
+					}
+				}
+
+				return r.failoverNotReadyMaster(ctx, dfi, &pod, notReadySince)
+			}
+		}
+		return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, nil
 	}
 
-	dfi, err := GetDragonflyInstanceFromPod(ctx, r.Client, &pod, log)
+	dfi, err := GetDragonflyInstanceFromPod(ctx, r.Client, r.EventRecorder, &pod, log)
 	if err != nil {
 		log.Info("Pod does not belong to a Dragonfly instance")
 		return ctrl.Result{}, nil
 	}
 
+	// the replication of standbys is configured by their link
+	if isStandby(dfi.df) {
+		log.Info("Dragonfly object is a standby of a replication link", "link", dfi.df.Annotations[resources.StandbyOfAnnotation])
+		return ctrl.Result{}, nil
+	}
+
+	// A topology change that is no longer in progress was interrupted,
+	// e.g by a restart of the operator, so replication is configured again
+	if change := dfi.df.Status.TopologyChange; isTopologyChangeInterrupted(dfi.df) && (dfi.df.Status.Phase == PhaseReady || dfi.df.Status.Phase == PhaseDegraded) {
+		log.Info("Resuming an interrupted topology change", "operation", change.Operation, "pod", change.Pod)
+		if err := dfi.configureReplication(ctx); err != nil {
+			log.Error(err, "could not resume the topology change")
+			return ctrl.Result{RequeueAfter: r.replicationBackoff(dfi, req.NamespacedName)}, nil
+		}
+
+		r.EventRecorder.Event(dfi.df, corev1.EventTypeNormal, "Replication", fmt.Sprintf("Resumed the interrupted %s", change.Operation))
+		r.resetReplicationBackoff(req.NamespacedName)
+		return ctrl.Result{}, nil
+	}
+
+	// The pods of a node that went NotReady keep looking ready until they
+	// are evicted, so the master is failed over based on the node instead
+	if pod.Labels[resources.Role] == resources.Master && pod.DeletionTimestamp == nil {
+		ready, notReadySince, err := getNodeReadiness(ctx, r.Client, pod.Spec.NodeName)
+		if err != nil {
+			log.Error(err, "could not get node readiness", "node", pod.Spec.NodeName)
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+		}
+
+		if !ready {
+			log.Info("Node of the master is not ready", "node", pod.Spec.NodeName)
+			return r.failoverNotReadyMaster(ctx, dfi, &pod, notReadySince)
+		}
+	}
+
+	// The master of a cordoned node hands over its role before it's evicted
+	if pod.Labels[resources.Role] == resources.Master && pod.DeletionTimestamp == nil && dfi.df.Spec.EvictionProtection && !dfi.df.Status.IsRollingUpdate {
+		cordoned, err := isNodeCordoned(ctx, r.Client, pod.Spec.NodeName)
+		if err != nil {
+			log.Error(err, "could not check if node is cordoned", "node", pod.Spec.NodeName)
+			return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, nil
+		}
+
+		if cordoned {
+			log.Info("Node of the master is cordoned", "node", pod.Spec.NodeName)
+			return r.handOverMasterForEviction(ctx, dfi, &pod)
+		}
+	}
+
 	if dfi.df.Status.Phase == "" {
 		// retry after resources are created
 		// Phase should be initialized by the time this is called
 		log.Info("Dragonfly object is not initialized yet")
-		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+		return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, nil
 	}
 
 	// Given a Pod Update, What do you do?
@@ -85,17 +194,35 @@ func (r *DfPodLifeCycleReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	role, ok := pod.Labels[resources.Role]
 	// New pod with No resources.Role
 	if !ok {
+		// pods are only given a role, and so added to the Services, once
+		// they are available
+		if available, remaining := isPodAvailable(&pod, dfi.df.Spec.MinReadySeconds); !available {
+			log.Info("Pod is not available yet", "remaining", remaining)
+			return ctrl.Result{RequeueAfter: remaining}, nil
+		}
+
 		log.Info("No replication role was set yet", "phase", dfi.df.Status.Phase)
 		if dfi.df.Status.Phase == PhaseResourcesCreated {
+			ready, err := dfi.initialReplicationReady(ctx)
+			if err != nil {
+				log.Error(err, "could not check if replication can be configured")
+				return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+			}
+
+			if !ready {
+				log.Info("Waiting for all pods to be ready to configure replication")
+				return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, nil
+			}
+
 			// Make it ready
 			log.Info("Dragonfly object is only initialized. Configuring replication for the first time")
 			if err = dfi.configureReplication(ctx); err != nil {
 				log.Info("could not initialize replication. will retry", "error", err)
-				return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+				return ctrl.Result{RequeueAfter: r.replicationBackoff(dfi, req.NamespacedName)}, nil
 			}
 
 			r.EventRecorder.Event(dfi.df, corev1.EventTypeNormal, "Replication", "configured replication for first time")
-		} else if dfi.df.Status.Phase == PhaseReady {
+		} else if dfi.df.Status.Phase == PhaseReady || dfi.df.Status.Phase == PhaseDegraded {
 			// Pod event either from a restart or a resource update (i.e less/more replicas)
 			log.Info("Pod restart from a ready Dragonfly instance")
 
@@ -110,10 +237,21 @@ func (r *DfPodLifeCycleReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 			}
 
 			if !exists {
+				ready, err := dfi.coldStartReady(ctx)
+				if err != nil {
+					log.Error(err, "could not check for a cold start")
+					return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+				}
+
+				if !ready {
+					log.Info("Waiting for all pods to be ready after a cold start")
+					return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, nil
+				}
+
 				log.Info("Master does not exist. Configuring Replication")
 				if err := dfi.configureReplication(ctx); err != nil {
 					log.Error(err, "couldn't find healthy and mark active")
-					return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+					return ctrl.Result{RequeueAfter: r.replicationBackoff(dfi, req.NamespacedName)}, nil
 				}
 
 				r.EventRecorder.Event(dfi.df, corev1.EventTypeNormal, "Replication", "Updated master instance")
@@ -121,7 +259,7 @@ func (r *DfPodLifeCycleReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 				log.Info(fmt.Sprintf("Master exists. Configuring %s as replica", pod.Status.PodIP))
 				if err := dfi.configureReplica(ctx, &pod); err != nil {
 					log.Error(err, "could not mark replica from db. retrying")
-					return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+					return ctrl.Result{RequeueAfter: r.replicationBackoff(dfi, req.NamespacedName)}, nil
 				}
 
 				r.EventRecorder.Event(dfi.df, corev1.EventTypeNormal, "Replication", "Configured a new replica")
@@ -135,7 +273,7 @@ func (r *DfPodLifeCycleReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		// Check if there is an active master
 		if pod.Labels[resources.Role] == resources.Master {
 			log.Info("master is being removed")
-			if dfi.df.Status.IsRollingUpdate {
+			if isRolloutHandlingMaster(dfi.df) {
 				log.Info("rolling update in progress. nothing to do")
 				return ctrl.Result{}, nil
 			}
@@ -143,11 +281,15 @@ func (r *DfPodLifeCycleReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 			log.Info("master is being removed. configuring replication")
 			if err := dfi.configureReplication(ctx); err != nil {
 				log.Error(err, "couldn't find healthy and mark active")
-				return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+				return ctrl.Result{RequeueAfter: r.replicationBackoff(dfi, req.NamespacedName)}, nil
 			}
 			r.EventRecorder.Event(dfi.df, corev1.EventTypeNormal, "Replication", "Updated master instance")
 		} else if pod.Labels[resources.Role] == resources.Replica {
+			if isPodEvicted(&pod) {
+				r.EventRecorder.Event(dfi.df, corev1.EventTypeNormal, "Eviction", fmt.Sprintf("Allowed the eviction of replica %s", pod.Name))
+			}
 			log.Info("replica is being deleted. nothing to do")
+			r.updateDegradedCondition(ctx, dfi)
 		}
 	} else {
 		if dfi.df.Status.IsRollingUpdate {
@@ -160,40 +302,232 @@ func (r *DfPodLifeCycleReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 
 		if err := dfi.checkAndConfigureReplication(ctx); err != nil {
 			log.Error(err, "could not check and configure replication. retrying")
-			return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+			return ctrl.Result{RequeueAfter: r.replicationBackoff(dfi, req.NamespacedName)}, nil
 		}
 
 		r.EventRecorder.Event(dfi.df, corev1.EventTypeNormal, "Replication", "Checked and configured replication")
 	}
 
+	degraded := r.updateDegradedCondition(ctx, dfi)
+	r.resetReplicationBackoff(req.NamespacedName)
+	if degraded {
+		// replicas don't emit events once they are in sync
+		return ctrl.Result{RequeueAfter: withJitter(10 * time.Second)}, nil
+	}
+
 	return ctrl.Result{}, nil
 }
 
-// SetupWithManager sets up the controller with the Manager.
+// updateDegradedCondition updates the Degraded condition of the instance,
+// and records an event when it changes. It returns if the instance is degraded.
+func (r *DfPodLifeCycleReconciler) updateDegradedCondition(ctx context.Context, dfi *DragonflyInstance) bool {
+	// replicas are still being configured for the first time
+	if dfi.df.Status.Phase != PhaseReady && dfi.df.Status.Phase != PhaseDegraded {
+		return false
+	}
+
+	condition, err := dfi.updateDegradedCondition(ctx)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "could not update degraded condition")
+		return dfi.df.Status.Phase == PhaseDegraded
+	}
+
+	if condition == nil {
+		return dfi.df.Status.Phase == PhaseDegraded
+	}
+
+	if condition.Status == metav1.ConditionTrue {
+		r.EventRecorder.Event(dfi.df, corev1.EventTypeWarning, "Replication", fmt.Sprintf("Degraded: %s", condition.Message))
+	} else {
+		r.EventRecorder.Event(dfi.df, corev1.EventTypeNormal, "Replication", fmt.Sprintf("Redundancy restored: %s", condition.Message))
+	}
+
+	return dfi.df.Status.Phase == PhaseDegraded
+}
+
+// failoverNotReadyMaster promotes a replica once the given master
+// has not been ready for longer than the failover grace period
+func (r *DfPodLifeCycleReconciler) failoverNotReadyMaster(ctx context.Context, dfi *DragonflyInstance, master *corev1.Pod, notReadySince time.Time) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	if (dfi.df.Status.Phase != PhaseReady && dfi.df.Status.Phase != PhaseDegraded) || isRolloutHandlingMaster(dfi.df) {
+		return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, nil
+	}
+
+	gracePeriod := dfi.failoverGracePeriod()
+	if remaining := gracePeriod - time.Since(notReadySince); remaining > 0 {
+		log.Info("Master is not ready, waiting for the failover grace period", "pod", master.Name, "remaining", remaining)
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+
+	log.Info("Master has not been ready for the failover grace period. Configuring replication", "pod", master.Name)
+	if err := dfi.configureReplication(ctx); err != nil {
+		log.Error(err, "couldn't find healthy and mark active")
+		return ctrl.Result{RequeueAfter: r.replicationBackoff(dfi, client.ObjectKeyFromObject(master))}, nil
+	}
+
+	r.EventRecorder.Event(dfi.df, corev1.EventTypeWarning, "Replication", fmt.Sprintf("Master %s was not ready for %s, updated master instance", master.Name, gracePeriod))
+	r.resetReplicationBackoff(client.ObjectKeyFromObject(master))
+	return ctrl.Result{}, nil
+}
+
+// replicationBackoff records a failure to configure replication for
+// the given pod and returns the delay before retrying
+func (r *DfPodLifeCycleReconciler) replicationBackoff(dfi *DragonflyInstance, pod types.NamespacedName) time.Duration {
+	r.replicationFailuresMu.Lock()
+	defer r.replicationFailuresMu.Unlock()
+
+	if r.replicationFailures == nil {
+		r.replicationFailures = make(map[types.NamespacedName]int)
+	}
+	r.replicationFailures[pod]++
+
+	return withJitter(getReplicationBackoff(dfi.df, r.replicationFailures[pod]))
+}
+
+// resetReplicationBackoff forgets the failures to configure
+// replication for the given pod
+func (r *DfPodLifeCycleReconciler) resetReplicationBackoff(pod types.NamespacedName) {
+	r.replicationFailuresMu.Lock()
+	defer r.replicationFailuresMu.Unlock()
+
+	delete(r.replicationFailures, pod)
+}
+
+// SetupWithManager sets up the controllers with the Manager, one per
+// priority tier
 func (r *DfPodLifeCycleReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		WithEventFilter(
-			predicate.Funcs{
-				UpdateFunc: func(e event.UpdateEvent) bool {
-					if e.ObjectNew.GetLabels()[resources.KubernetesAppNameLabelKey] == "dragonfly" {
-						return true
-					}
+	return r.setupWithCluster(mgr, mgr, "pod")
+}
 
-					return false
-				},
-				CreateFunc: func(e event.CreateEvent) bool {
-					if e.Object.GetLabels()[resources.KubernetesAppNameLabelKey] == "dragonfly" {
-						return true
-					}
-					return false
-				},
-				DeleteFunc: func(e event.DeleteEvent) bool {
-					if e.Object.GetLabels()[resources.KubernetesAppNameLabelKey] == "dragonfly" {
-						return true
-					}
-					return false
-				},
-			}).
-		For(&corev1.Pod{}).
-		Complete(r)
+// setupWithCluster sets up the controllers of the given name, one per
+// priority tier, for the pods of the cluster, i.e. the cluster of the
+// Manager or a remote one
+func (r *DfPodLifeCycleReconciler) setupWithCluster(mgr ctrl.Manager, cl cluster.Cluster, name string) error {
+	for _, critical := range priorities {
+		filter := objectFilter{reader: cl.GetClient(), shard: r.Shard, critical: critical}
+		fair := newFairQueue(filter.controllerName(name), r.RateLimiterOptions, podInstance)
+		if err := ctrl.NewControllerManagedBy(mgr).
+			Named(filter.controllerName(name)).
+			Watches(source.NewKindWithCache(&corev1.Pod{}, cl.GetCache()), fair.handler(&handler.EnqueueRequestForObject{}), builder.WithPredicates(dragonflyPodPredicate(), filter.predicate())).
+			// Fail over masters of nodes that go NotReady
+			Watches(source.NewKindWithCache(&corev1.Node{}, cl.GetCache()), fair.handler(handler.EnqueueRequestsFromMapFunc(filter.pods(r.findMastersOnNode))), builder.WithPredicates(nodeReadinessPredicate())).
+			WithOptions(controller.Options{RateLimiter: newRateLimiter(r.RateLimiterOptions)}).
+			Complete(fair.reconciler(reconcileErrorRecorder("pod", podInstance, r))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// findMastersOnNode returns the Dragonfly master pods on the given node
+func (r *DfPodLifeCycleReconciler) findMastersOnNode(node client.Object) []reconcile.Request {
+	var pods corev1.PodList
+	if err := r.List(context.Background(), &pods, client.MatchingLabels{
+		resources.KubernetesAppNameLabelKey: "dragonfly",
+		resources.Role:                      resources.Master,
+	}); err != nil {
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0)
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName == node.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&pod)})
+		}
+	}
+
+	return requests
+}
+
+// nodeReadinessPredicate filters the events of nodes to changes of their
+// readiness, or to them being cordoned
+func nodeReadinessPredicate() predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldNode, ok := e.ObjectOld.(*corev1.Node)
+			if !ok {
+				return false
+			}
+			newNode, ok := e.ObjectNew.(*corev1.Node)
+			if !ok {
+				return false
+			}
+
+			return isNodeReady(oldNode) != isNodeReady(newNode) || oldNode.Spec.Unschedulable != newNode.Spec.Unschedulable
+		},
+		CreateFunc: func(e event.CreateEvent) bool {
+			return false
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return true
+		},
+	}
+}
+
+// dragonflyPodPredicate filters the events of pods to Dragonfly pods
+func dragonflyPodPredicate() predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectNew.GetLabels()[resources.KubernetesAppNameLabelKey] != "dragonfly" {
+				return false
+			}
+
+			oldPod, ok := e.ObjectOld.(*corev1.Pod)
+			if !ok {
+				return true
+			}
+
+			newPod, ok := e.ObjectNew.(*corev1.Pod)
+			if !ok {
+				return true
+			}
+
+			return podTopologyChanged(oldPod, newPod)
+		},
+		CreateFunc: func(e event.CreateEvent) bool {
+			if e.Object.GetLabels()[resources.KubernetesAppNameLabelKey] == "dragonfly" {
+				return true
+			}
+			return false
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			if e.Object.GetLabels()[resources.KubernetesAppNameLabelKey] == "dragonfly" {
+				return true
+			}
+			return false
+		},
+	}
+}
+
+// podTopologyChanged returns if the update of a pod may affect the
+// replication topology. Other updates, e.g of the restart count of
+// containers or of unrelated labels, don't trigger a reconcile.
+func podTopologyChanged(oldPod, newPod *corev1.Pod) bool {
+	if oldPod.Status.Phase != newPod.Status.Phase ||
+		oldPod.Status.PodIP != newPod.Status.PodIP ||
+		oldPod.Spec.NodeName != newPod.Spec.NodeName ||
+		isPodReady(oldPod) != isPodReady(newPod) ||
+		(oldPod.DeletionTimestamp == nil) != (newPod.DeletionTimestamp == nil) {
+		return true
+	}
+
+	for _, label := range []string{resources.Role, resources.MasterIp, appsv1.StatefulSetRevisionLabel} {
+		if oldPod.Labels[label] != newPod.Labels[label] {
+			return true
+		}
+	}
+
+	return oldPod.Annotations[resources.FailoverPriorityAnnotation] != newPod.Annotations[resources.FailoverPriorityAnnotation]
+}
+
+// isPodReady returns if the Ready condition of the given pod is true
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
 }
@@ -0,0 +1,183 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPodTopologyChanged(t *testing.T) {
+	now := metav1.Now()
+
+	tests := []struct {
+		name   string
+		update func(pod *corev1.Pod)
+		want   bool
+	}{
+		{
+			name:   "no change",
+			update: func(pod *corev1.Pod) {},
+			want:   false,
+		},
+		{
+			name: "restart count",
+			update: func(pod *corev1.Pod) {
+				pod.Status.ContainerStatuses[0].RestartCount++
+			},
+			want: false,
+		},
+		{
+			name: "unrelated label",
+			update: func(pod *corev1.Pod) {
+				pod.Labels["team"] = "cache"
+			},
+			want: false,
+		},
+		{
+			name: "unrelated annotation",
+			update: func(pod *corev1.Pod) {
+				pod.Annotations = map[string]string{"example.com/owner": "cache"}
+			},
+			want: false,
+		},
+		{
+			name: "phase",
+			update: func(pod *corev1.Pod) {
+				pod.Status.Phase = corev1.PodFailed
+			},
+			want: true,
+		},
+		{
+			name: "pod IP",
+			update: func(pod *corev1.Pod) {
+				pod.Status.PodIP = "10.0.0.2"
+			},
+			want: true,
+		},
+		{
+			name: "node",
+			update: func(pod *corev1.Pod) {
+				pod.Spec.NodeName = "node-b"
+			},
+			want: true,
+		},
+		{
+			name: "readiness",
+			update: func(pod *corev1.Pod) {
+				pod.Status.Conditions[0].Status = corev1.ConditionFalse
+			},
+			want: true,
+		},
+		{
+			name: "deletion",
+			update: func(pod *corev1.Pod) {
+				pod.DeletionTimestamp = &now
+			},
+			want: true,
+		},
+		{
+			name: "role",
+			update: func(pod *corev1.Pod) {
+				pod.Labels[resources.Role] = resources.Master
+			},
+			want: true,
+		},
+		{
+			name: "master IP",
+			update: func(pod *corev1.Pod) {
+				pod.Labels[resources.MasterIp] = "10.0.0.3"
+			},
+			want: true,
+		},
+		{
+			name: "revision",
+			update: func(pod *corev1.Pod) {
+				pod.Labels[appsv1.StatefulSetRevisionLabel] = "df-2"
+			},
+			want: true,
+		},
+		{
+			name: "failover priority",
+			update: func(pod *corev1.Pod) {
+				pod.Annotations = map[string]string{resources.FailoverPriorityAnnotation: "0"}
+			},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldPod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "df-0",
+					Labels: map[string]string{
+						resources.Role:                  resources.Replica,
+						resources.MasterIp:              "10.0.0.1",
+						appsv1.StatefulSetRevisionLabel: "df-1",
+					},
+				},
+				Spec: corev1.PodSpec{NodeName: "node-a"},
+				Status: corev1.PodStatus{
+					Phase: corev1.PodRunning,
+					PodIP: "10.0.0.4",
+					Conditions: []corev1.PodCondition{
+						{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+					},
+					ContainerStatuses: []corev1.ContainerStatus{
+						{Name: "dragonfly"},
+					},
+				},
+			}
+			newPod := oldPod.DeepCopy()
+			tt.update(newPod)
+
+			if got := podTopologyChanged(oldPod, newPod); got != tt.want {
+				t.Errorf("podTopologyChanged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileForgetsReplicationFailuresOfDeletedPods(t *testing.T) {
+	deleted := types.NamespacedName{Namespace: "default", Name: "df-1"}
+	other := types.NamespacedName{Namespace: "default", Name: "df-2"}
+	r := &DfPodLifeCycleReconciler{
+		Client:              fake.NewClientBuilder().Build(),
+		replicationFailures: map[types.NamespacedName]int{deleted: 3, other: 1},
+	}
+
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: deleted}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	if _, ok := r.replicationFailures[deleted]; ok {
+		t.Error("the failures of the deleted pod weren't forgotten")
+	}
+
+	if r.replicationFailures[other] != 1 {
+		t.Errorf("failures of another pod = %d, want 1", r.replicationFailures[other])
+	}
+}
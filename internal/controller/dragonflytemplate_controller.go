@@ -0,0 +1,189 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// DragonflyTemplateReconciler stamps out the Dragonfly objects of
+// DragonflyTemplates
+type DragonflyTemplateReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	EventRecorder record.EventRecorder
+
+	// RateLimiterOptions tune the rate limiter of the work queue, the
+	// default rate limiter is used if nil
+	RateLimiterOptions *RateLimiterOptions
+}
+
+//+kubebuilder:rbac:groups=dragonflydb.io,resources=dragonflytemplates,verbs=get;list;watch
+//+kubebuilder:rbac:groups=dragonflydb.io,resources=dragonflytemplates/status,verbs=get;update;patch
+
+// Reconcile creates, updates and deletes the Dragonfly objects of the
+// template so that they match its instances
+func (r *DragonflyTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	var tpl dfv1alpha1.DragonflyTemplate
+	if err := r.Get(ctx, req.NamespacedName, &tpl); err != nil {
+		log.Info(fmt.Sprintf("could not get the DragonflyTemplate object: %s", req.Name))
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	log.Info("Reconciling DragonflyTemplate object")
+	status := dfv1alpha1.DragonflyTemplateStatus{
+		ObservedGeneration: tpl.Generation,
+	}
+
+	desired := make(map[types.NamespacedName]bool, len(tpl.Spec.Instances))
+	for i := range tpl.Spec.Instances {
+		instance := &tpl.Spec.Instances[i]
+		desired[types.NamespacedName{Namespace: instance.Namespace, Name: instance.Name}] = true
+
+		instanceStatus := dfv1alpha1.TemplateInstanceStatus{
+			Name:      instance.Name,
+			Namespace: instance.Namespace,
+		}
+
+		df, err := r.stampInstance(ctx, &tpl, instance)
+		if err != nil {
+			log.Error(err, "could not stamp out instance", "namespace", instance.Namespace, "name", instance.Name)
+			instanceStatus.Error = err.Error()
+		} else {
+			instanceStatus.Phase = df.Status.Phase
+			if df.Status.Phase == PhaseReady {
+				status.ReadyInstances++
+			}
+		}
+
+		status.Instances = append(status.Instances, instanceStatus)
+	}
+
+	// delete the instances that were removed from the template
+	var dfs dfv1alpha1.DragonflyList
+	if err := r.List(ctx, &dfs, client.MatchingLabels{resources.TemplateLabel: tpl.Name}); err != nil {
+		log.Error(err, "could not list the instances of the template")
+		return ctrl.Result{}, err
+	}
+
+	for i := range dfs.Items {
+		df := &dfs.Items[i]
+		if desired[client.ObjectKeyFromObject(df)] || !metav1.IsControlledBy(df, &tpl) || df.DeletionTimestamp != nil {
+			continue
+		}
+
+		log.Info("Deleting instance removed from the template", "namespace", df.Namespace, "name", df.Name)
+		if err := r.Delete(ctx, df); err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "could not delete instance", "namespace", df.Namespace, "name", df.Name)
+			return ctrl.Result{}, err
+		}
+		r.EventRecorder.Event(&tpl, corev1.EventTypeNormal, "Instances", fmt.Sprintf("Deleted instance %s/%s", df.Namespace, df.Name))
+	}
+
+	if equality.Semantic.DeepEqual(status, tpl.Status) {
+		return ctrl.Result{}, nil
+	}
+
+	tpl.Status = status
+	if err := r.Status().Update(ctx, &tpl); err != nil {
+		log.Error(err, "could not update the DragonflyTemplate object")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// stampInstance creates or updates the Dragonfly object of the instance
+// of the template, and returns it
+func (r *DragonflyTemplateReconciler) stampInstance(ctx context.Context, tpl *dfv1alpha1.DragonflyTemplate, instance *dfv1alpha1.TemplateInstance) (*dfv1alpha1.Dragonfly, error) {
+	desired, err := resources.GetTemplateInstance(tpl, instance)
+	if err != nil {
+		return nil, err
+	}
+
+	var df dfv1alpha1.Dragonfly
+	if err := r.Get(ctx, client.ObjectKeyFromObject(desired), &df); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, err
+		}
+
+		if err := r.Create(ctx, desired); err != nil {
+			return nil, err
+		}
+
+		r.EventRecorder.Event(tpl, corev1.EventTypeNormal, "Instances", fmt.Sprintf("Created instance %s/%s", desired.Namespace, desired.Name))
+		return desired, nil
+	}
+
+	if !metav1.IsControlledBy(&df, tpl) {
+		return nil, fmt.Errorf("dragonfly %s/%s exists and isn't stamped out by the template", df.Namespace, df.Name)
+	}
+
+	// keep the labels and annotations set on the instance itself, e.g
+	// save requests
+	updated := df.DeepCopy()
+	updated.Spec = desired.Spec
+	if updated.Labels == nil {
+		updated.Labels = map[string]string{}
+	}
+	for key, value := range desired.Labels {
+		updated.Labels[key] = value
+	}
+	if len(desired.Annotations) > 0 && updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	for key, value := range desired.Annotations {
+		updated.Annotations[key] = value
+	}
+
+	if equality.Semantic.DeepEqual(updated, &df) {
+		return &df, nil
+	}
+
+	if err := r.Update(ctx, updated); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *DragonflyTemplateReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&dfv1alpha1.DragonflyTemplate{}).
+		// follow the phases of the instances
+		Owns(&dfv1alpha1.Dragonfly{}).
+		WithOptions(controller.Options{RateLimiter: newRateLimiter(r.RateLimiterOptions)}).
+		Complete(r)
+}
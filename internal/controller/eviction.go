@@ -0,0 +1,90 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// evictionByEvictionAPIReason is the reason of the DisruptionTarget
+// condition of pods evicted through the Eviction API
+const evictionByEvictionAPIReason = "EvictionByEvictionAPI"
+
+// handOverMasterForEviction hands over the master role to a replica on a
+// schedulable node, so that the master pod can be evicted from its
+// cordoned node. Its eviction is blocked by the PodDisruptionBudget until
+// then.
+func (r *DfPodLifeCycleReconciler) handOverMasterForEviction(ctx context.Context, dfi *DragonflyInstance, master *corev1.Pod) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	replica, err := getHandOverTarget(ctx, r.Client, dfi, master)
+	if err != nil {
+		log.Error(err, "could not find a replica to hand over the master role to")
+		return ctrl.Result{RequeueAfter: withJitter(5 * time.Second)}, nil
+	}
+
+	if replica == nil {
+		r.EventRecorder.Event(dfi.df, corev1.EventTypeWarning, "Eviction", fmt.Sprintf("Node %s of master %s is cordoned, but no replica in sync can take over. Its eviction stays blocked", master.Spec.NodeName, master.Name))
+		return ctrl.Result{RequeueAfter: withJitter(30 * time.Second)}, nil
+	}
+
+	message := fmt.Sprintf("Handing over the master role from %s to %s, as node %s is cordoned", master.Name, replica.Name, master.Spec.NodeName)
+	if err := handOverMaster(ctx, r.Client, dfi, TopologyChangeEvictionHandover, master, replica, func() {
+		r.EventRecorder.Event(dfi.df, corev1.EventTypeNormal, "Eviction", message)
+	}); err != nil {
+		log.Error(err, "could not hand over the master role")
+		return ctrl.Result{RequeueAfter: r.replicationBackoff(dfi, client.ObjectKeyFromObject(master))}, nil
+	}
+
+	r.resetReplicationBackoff(client.ObjectKeyFromObject(master))
+	return ctrl.Result{}, nil
+}
+
+// isNodeCordoned returns if the given node is unschedulable, e.g because
+// it's being drained
+func isNodeCordoned(ctx context.Context, c client.Client, name string) (bool, error) {
+	var node corev1.Node
+	if err := c.Get(ctx, types.NamespacedName{Name: name}, &node); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return node.Spec.Unschedulable, nil
+}
+
+// isPodEvicted returns if the given pod is being terminated after an
+// eviction through the Eviction API
+func isPodEvicted(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.DisruptionTarget && condition.Status == corev1.ConditionTrue {
+			return condition.Reason == evictionByEvictionAPIReason
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// isExplainRequested returns if the explain request annotation of the
+// instance wasn't handled yet
+func isExplainRequested(df *dfv1alpha1.Dragonfly) bool {
+	request, ok := df.Annotations[resources.ExplainRequestAnnotation]
+	return ok && request != "" && (df.Status.Explain == nil || df.Status.Explain.Request != request)
+}
+
+// explain records the plan of the changes that the operator would make to
+// the instance right now in its status, without making them, so that its
+// decisions can be audited before they happen
+func (r *DragonflyReconciler) explain(ctx context.Context, df *dfv1alpha1.Dragonfly) error {
+	plan := &dfv1alpha1.ExplainStatus{
+		Request: df.Annotations[resources.ExplainRequestAnnotation],
+		Time:    metav1.Now(),
+	}
+
+	if df.Status.Phase == "" || df.Status.Phase == PhasePending {
+		plan.Errors = append(plan.Errors, "the resources of the instance aren't created yet")
+	} else {
+		r.explainReplication(ctx, df, plan)
+		r.explainResources(ctx, df, plan)
+	}
+
+	df.Status.Explain = plan
+	return r.Status().Update(ctx, df)
+}
+
+// explainReplication adds the master and the pods to reconfigure to the plan
+func (r *DragonflyReconciler) explainReplication(ctx context.Context, df *dfv1alpha1.Dragonfly, plan *dfv1alpha1.ExplainStatus) {
+	if isStandby(df) {
+		plan.Errors = append(plan.Errors, fmt.Sprintf("replication is configured by the replication link %s", df.Annotations[resources.StandbyOfAnnotation]))
+		return
+	}
+
+	dfi := &DragonflyInstance{df: df, client: r.Client, log: log.FromContext(ctx)}
+	pods, err := dfi.getPods(ctx)
+	if err != nil {
+		plan.Errors = append(plan.Errors, fmt.Sprintf("could not list the pods: %s", err))
+		return
+	}
+
+	// the same master is elected as by electMaster
+	master := dfi.getConfiguredMaster(ctx, pods)
+	if master == nil {
+		plan.Election = true
+		sortByFailoverPriority(pods.Items)
+		if dfi.isColdStart(pods) {
+			sortByLastSnapshot(ctx, pods.Items)
+		}
+
+		master = dfi.selectMaster(ctx, pods)
+		if master == nil {
+			plan.Errors = append(plan.Errors, "no pod can be elected as master")
+			return
+		}
+	}
+	plan.Master = master.Name
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Name == master.Name || pod.Status.Phase != corev1.PodRunning || !pod.Status.ContainerStatuses[0].Ready || pod.DeletionTimestamp != nil || pod.Status.PodIP == "" {
+			continue
+		}
+
+		if !plan.Election && pod.Labels[resources.Role] == resources.Replica && pod.Labels[resources.MasterIp] == master.Status.PodIP {
+			if ok, err := dfi.checkReplicaRole(ctx, pod, master.Status.PodIP); err == nil && ok {
+				continue
+			}
+		}
+
+		plan.PodsToReconfigure = append(plan.PodsToReconfigure, pod.Name)
+	}
+}
+
+// explainResources adds the resources that would be created or updated to
+// the plan, by comparing them with the result of a dry run update
+func (r *DragonflyReconciler) explainResources(ctx context.Context, df *dfv1alpha1.Dragonfly, plan *dfv1alpha1.ExplainStatus) {
+	newResources, err := resources.GetDragonflyResources(ctx, df)
+	if err != nil {
+		plan.Errors = append(plan.Errors, fmt.Sprintf("could not get the resources: %s", err))
+		return
+	}
+
+	for _, resource := range newResources {
+		if statefulSet, ok := resource.(*appsv1.StatefulSet); ok {
+			if err := setSecretsHash(ctx, r.Client, df, statefulSet); err != nil {
+				plan.Errors = append(plan.Errors, fmt.Sprintf("could not hash the referenced secrets: %s", err))
+				return
+			}
+
+			if err := setConfigMapsHash(ctx, r.Client, df, statefulSet); err != nil {
+				plan.Errors = append(plan.Errors, fmt.Sprintf("could not hash the referenced config maps: %s", err))
+				return
+			}
+		}
+	}
+
+	for _, resource := range newResources {
+		gvk, err := apiutil.GVKForObject(resource, r.Scheme)
+		if err != nil {
+			plan.Errors = append(plan.Errors, err.Error())
+			continue
+		}
+		name := fmt.Sprintf("%s/%s", gvk.Kind, resource.GetName())
+
+		existing := resource.DeepCopyObject().(client.Object)
+		if err := r.Get(ctx, client.ObjectKeyFromObject(resource), existing); err != nil {
+			if apierrors.IsNotFound(err) {
+				plan.ResourceChanges = append(plan.ResourceChanges, fmt.Sprintf("create %s", name))
+			} else {
+				plan.Errors = append(plan.Errors, fmt.Sprintf("could not get %s: %s", name, err))
+			}
+			continue
+		}
+
+		updated := resource.DeepCopyObject().(client.Object)
+		updated.SetResourceVersion(existing.GetResourceVersion())
+		if err := r.Update(ctx, updated, client.DryRunAll); err != nil {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("could not update %s: %s", name, err))
+			continue
+		}
+
+		changed, err := isObjectChanged(existing, updated)
+		if err != nil {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("could not compare %s: %s", name, err))
+			continue
+		}
+
+		if !changed {
+			continue
+		}
+		plan.ResourceChanges = append(plan.ResourceChanges, fmt.Sprintf("update %s", name))
+
+		// the pods are rolled when their template changes
+		if existingStatefulSet, ok := existing.(*appsv1.StatefulSet); ok {
+			if !equality.Semantic.DeepEqual(existingStatefulSet.Spec.Template, updated.(*appsv1.StatefulSet).Spec.Template) {
+				plan.Rollout = true
+			}
+		}
+	}
+
+	if df.Status.IsRollingUpdate {
+		plan.Rollout = true
+		return
+	}
+
+	var statefulSet appsv1.StatefulSet
+	if err := r.Get(ctx, client.ObjectKey{Namespace: df.Namespace, Name: df.Name}, &statefulSet); err != nil {
+		return
+	}
+
+	rolloutDue, err := isRolloutDue(ctx, r.Client, df, &statefulSet)
+	if err != nil {
+		plan.Errors = append(plan.Errors, fmt.Sprintf("could not check if a rollout is due: %s", err))
+		return
+	}
+
+	if rolloutDue && statefulSet.Status.UpdatedReplicas != statefulSet.Status.Replicas && statefulSet.Status.UpdateRevision != df.Status.AbortedRolloutRevision {
+		plan.Rollout = true
+	}
+}
+
+// isObjectChanged returns if the updated object differs from the existing
+// one, apart from the metadata maintained by the API server and the status
+func isObjectChanged(existing, updated client.Object) (bool, error) {
+	normalize := func(obj client.Object) (map[string]interface{}, error) {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, field := range []string{"resourceVersion", "managedFields", "generation", "creationTimestamp", "uid"} {
+			unstructured.RemoveNestedField(content, "metadata", field)
+		}
+		unstructured.RemoveNestedField(content, "status")
+		unstructured.RemoveNestedField(content, "apiVersion")
+		unstructured.RemoveNestedField(content, "kind")
+		return content, nil
+	}
+
+	before, err := normalize(existing)
+	if err != nil {
+		return false, err
+	}
+
+	after, err := normalize(updated)
+	if err != nil {
+		return false, err
+	}
+
+	return !equality.Semantic.DeepEqual(before, after), nil
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// isFailoverRequested returns if the failover request annotation of the
+// instance wasn't handled yet
+func isFailoverRequested(df *dfv1alpha1.Dragonfly) bool {
+	request, ok := df.Annotations[resources.FailoverToAnnotation]
+	return ok && request != df.Status.FailoverRequest
+}
+
+// isFailoverRequestRemoved returns if the failover request annotation that
+// was handled last was removed since
+func isFailoverRequestRemoved(df *dfv1alpha1.Dragonfly) bool {
+	_, ok := df.Annotations[resources.FailoverToAnnotation]
+	return !ok && df.Status.FailoverRequest != ""
+}
+
+// handleFailoverRequest hands over the master role of the instance to the
+// requested replica, and records the handled request in the status,
+// whether the failover succeeded or not. A failover to the same pod can be
+// requested again by removing the annotation first.
+func (r *DragonflyReconciler) handleFailoverRequest(ctx context.Context, df *dfv1alpha1.Dragonfly) error {
+	request := df.Annotations[resources.FailoverToAnnotation]
+
+	if err := r.failoverTo(ctx, df, request); err != nil {
+		r.EventRecorder.Event(df, corev1.EventTypeWarning, "Failover", fmt.Sprintf("Requested failover to %s failed: %s", request, err))
+	}
+
+	df.Status.FailoverRequest = request
+	return r.Status().Update(ctx, df)
+}
+
+// failoverTo hands over the master role to the given replica of the
+// instance, once it acknowledged all the writes of the master
+func (r *DragonflyReconciler) failoverTo(ctx context.Context, df *dfv1alpha1.Dragonfly, name string) error {
+	var replica corev1.Pod
+	if err := r.Get(ctx, client.ObjectKey{Namespace: df.Namespace, Name: name}, &replica); err != nil {
+		return fmt.Errorf("could not get pod %s: %w", name, err)
+	}
+
+	if replica.Labels["app"] != df.Name {
+		return fmt.Errorf("pod %s is not a pod of the instance", name)
+	}
+
+	master, err := getMasterPod(ctx, r.Client, df)
+	if err != nil {
+		return err
+	}
+
+	if master.Name == replica.Name {
+		r.EventRecorder.Event(df, corev1.EventTypeNormal, "Failover", fmt.Sprintf("Pod %s is already the master", name))
+		return nil
+	}
+
+	if replica.Labels[resources.Role] != resources.Replica || replica.DeletionTimestamp != nil || !isPodReady(&replica) {
+		return fmt.Errorf("pod %s is not a ready replica", name)
+	}
+
+	if getFailoverPriority(&replica) == 0 {
+		return fmt.Errorf("pod %s has a failover priority of 0, and is never promoted", name)
+	}
+
+	stable, err := isStableState(ctx, r.Client, &replica)
+	if err != nil {
+		return err
+	}
+	if !stable {
+		return fmt.Errorf("pod %s is not in sync with the master", name)
+	}
+
+	dfi := &DragonflyInstance{df: df, client: r.Client, log: log.FromContext(ctx), eventRecorder: r.EventRecorder}
+	message := fmt.Sprintf("Handing over the master role from %s to %s, as requested", master.Name, replica.Name)
+	return handOverMaster(ctx, r.Client, dfi, TopologyChangeManualFailover, master, &replica, func() {
+		r.EventRecorder.Event(df, corev1.EventTypeNormal, "Failover", message)
+	})
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// queueWaitSeconds is how long the requests of the instances waited in
+// the work queues after their events
+var queueWaitSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "dragonfly_operator_queue_wait_seconds",
+	Help:    "Time the requests of a Dragonfly instance waited in the work queue of a controller before they were reconciled",
+	Buckets: []float64{0.01, 0.1, 1, 10, 60, 300},
+}, []string{"controller", "namespace", "name"})
+
+func init() {
+	metrics.Registry.MustRegister(queueWaitSeconds)
+}
+
+// fairQueue shares the work queue of a controller fairly between the
+// instances. The requests of each instance are added through a token
+// bucket of its own, so that an instance with a burst of events has its
+// requests delayed instead of taking up the workers ahead of the other
+// instances. Requests that are ready are handled in the order they were
+// added.
+type fairQueue struct {
+	name     string
+	limit    rate.Limit
+	burst    int
+	instance func(types.NamespacedName) types.NamespacedName
+
+	mu       sync.Mutex
+	limiters map[types.NamespacedName]*rate.Limiter
+	// added is when the requests that weren't reconciled yet were added
+	added map[reconcile.Request]time.Time
+}
+
+// newFairQueue returns the fair queue of the named controller, for
+// requests that belong to the instance returned by the given function.
+// The requests aren't limited per instance without rate limiter options.
+func newFairQueue(name string, opts *RateLimiterOptions, instance func(types.NamespacedName) types.NamespacedName) *fairQueue {
+	f := &fairQueue{
+		name:     name,
+		limit:    rate.Inf,
+		instance: instance,
+		limiters: make(map[types.NamespacedName]*rate.Limiter),
+		added:    make(map[reconcile.Request]time.Time),
+	}
+
+	if opts != nil && opts.InstanceQPS > 0 {
+		f.limit = rate.Limit(opts.InstanceQPS)
+		f.burst = opts.InstanceBucketSize
+		if f.burst < 1 {
+			f.burst = 1
+		}
+	}
+
+	return f
+}
+
+// dragonflyInstance returns the instance of the Dragonfly object with the
+// given name, i.e the object itself
+func dragonflyInstance(dragonfly types.NamespacedName) types.NamespacedName {
+	return dragonfly
+}
+
+// podInstance returns the instance of the Dragonfly pod with the given
+// name, i.e. the name of its StatefulSet
+func podInstance(pod types.NamespacedName) types.NamespacedName {
+	name := pod.Name
+	if i := strings.LastIndex(name, "-"); i >= 0 {
+		name = name[:i]
+	}
+
+	return types.NamespacedName{Namespace: pod.Namespace, Name: name}
+}
+
+// admit returns how long the request should wait before it is added,
+// or false if it is already waiting in the queue
+func (f *fairQueue) admit(request reconcile.Request) (time.Duration, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.added[request]; ok {
+		return 0, false
+	}
+	f.added[request] = time.Now()
+
+	if f.limit == rate.Inf {
+		return 0, true
+	}
+
+	instance := f.instance(request.NamespacedName)
+	limiter, ok := f.limiters[instance]
+	if !ok {
+		limiter = rate.NewLimiter(f.limit, f.burst)
+		f.limiters[instance] = limiter
+	}
+
+	return limiter.Reserve().Delay(), true
+}
+
+// done records how long the request waited, as it is being reconciled
+func (f *fairQueue) done(request reconcile.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	instance := f.instance(request.NamespacedName)
+	if added, ok := f.added[request]; ok {
+		queueWaitSeconds.WithLabelValues(f.name, instance.Namespace, instance.Name).Observe(time.Since(added).Seconds())
+		delete(f.added, request)
+	}
+
+	// forget the instances that are quiet again
+	if limiter, ok := f.limiters[instance]; ok && limiter.Tokens() >= float64(f.burst) {
+		delete(f.limiters, instance)
+	}
+}
+
+// handler returns the given event handler, with its requests added
+// through the fair queue
+func (f *fairQueue) handler(h handler.EventHandler) handler.EventHandler {
+	return handler.Funcs{
+		CreateFunc: func(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+			h.Create(e, fairQueueAdder{q, f})
+		},
+		UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+			h.Update(e, fairQueueAdder{q, f})
+		},
+		DeleteFunc: func(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+			h.Delete(e, fairQueueAdder{q, f})
+		},
+		GenericFunc: func(e event.GenericEvent, q workqueue.RateLimitingInterface) {
+			h.Generic(e, fairQueueAdder{q, f})
+		},
+	}
+}
+
+// reconciler returns the given reconciler, recording how long the
+// requests waited in the fair queue
+func (f *fairQueue) reconciler(r reconcile.Reconciler) reconcile.Reconciler {
+	return reconcile.Func(func(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+		f.done(request)
+		return r.Reconcile(ctx, request)
+	})
+}
+
+// fairQueueAdder is a work queue whose added requests go through the
+// fair queue
+type fairQueueAdder struct {
+	workqueue.RateLimitingInterface
+	fair *fairQueue
+}
+
+// Add adds the request to the work queue once the token bucket of its
+// instance allows it, unless it is already waiting
+func (q fairQueueAdder) Add(item interface{}) {
+	request, ok := item.(reconcile.Request)
+	if !ok {
+		q.RateLimitingInterface.Add(item)
+		return
+	}
+
+	delay, ok := q.fair.admit(request)
+	if !ok {
+		return
+	}
+
+	if delay > 0 {
+		q.AddAfter(item, delay)
+		return
+	}
+
+	q.RateLimitingInterface.Add(item)
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func podRequest(name string) reconcile.Request {
+	return reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: name}}
+}
+
+func TestFairQueueAdmit(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *RateLimiterOptions
+		// requests are admitted in order, and done is called for the
+		// requests in done before the next one is admitted
+		requests []string
+		done     []string
+		admitted []bool
+		delayed  []bool
+	}{
+		{
+			name:     "unlimited requests are admitted right away",
+			requests: []string{"df-0", "df-1", "df-2"},
+			admitted: []bool{true, true, true},
+			delayed:  []bool{false, false, false},
+		},
+		{
+			name:     "waiting requests are deduplicated",
+			requests: []string{"df-0", "df-0", "df-1"},
+			admitted: []bool{true, false, true},
+			delayed:  []bool{false, false, false},
+		},
+		{
+			name:     "reconciled requests are admitted again",
+			requests: []string{"df-0", "df-0"},
+			done:     []string{"df-0"},
+			admitted: []bool{true, true},
+			delayed:  []bool{false, false},
+		},
+		{
+			name:     "requests of a busy instance are delayed",
+			opts:     &RateLimiterOptions{InstanceQPS: 1, InstanceBucketSize: 1},
+			requests: []string{"df-0", "df-1", "other-0"},
+			admitted: []bool{true, true, true},
+			delayed:  []bool{false, true, false},
+		},
+		{
+			name:     "the bucket size defaults to 1",
+			opts:     &RateLimiterOptions{InstanceQPS: 1},
+			requests: []string{"df-0", "df-1"},
+			admitted: []bool{true, true},
+			delayed:  []bool{false, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFairQueue("test", tt.opts, podInstance)
+			for i, name := range tt.requests {
+				delay, ok := f.admit(podRequest(name))
+				if ok != tt.admitted[i] {
+					t.Errorf("request %d for %s: admitted %v, want %v", i, name, ok, tt.admitted[i])
+				}
+				if (delay > 0) != tt.delayed[i] {
+					t.Errorf("request %d for %s: delay %v, want delayed %v", i, name, delay, tt.delayed[i])
+				}
+
+				for _, done := range tt.done {
+					if done == name {
+						f.done(podRequest(name))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFairQueueDone(t *testing.T) {
+	instance := types.NamespacedName{Namespace: "default", Name: "df"}
+
+	tests := []struct {
+		name    string
+		limiter func() *rate.Limiter
+		forgets bool
+	}{
+		{
+			name:    "quiet instances are forgotten",
+			limiter: func() *rate.Limiter { return rate.NewLimiter(1, 1) },
+			forgets: true,
+		},
+		{
+			name: "busy instances are kept",
+			limiter: func() *rate.Limiter {
+				limiter := rate.NewLimiter(1, 1)
+				limiter.Reserve()
+				return limiter
+			},
+			forgets: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFairQueue("test", &RateLimiterOptions{InstanceQPS: 1, InstanceBucketSize: 1}, podInstance)
+			request := podRequest("df-0")
+			if _, ok := f.admit(request); !ok {
+				t.Fatal("request was not admitted")
+			}
+			f.limiters[instance] = tt.limiter()
+
+			f.done(request)
+			if _, ok := f.added[request]; ok {
+				t.Error("request is still waiting after it was reconciled")
+			}
+			if _, ok := f.limiters[instance]; ok == tt.forgets {
+				t.Errorf("limiter kept %v, want %v", ok, !tt.forgets)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// errInjectedFault is returned by the health checks that are dropped by
+// the fault injection of their instance
+var errInjectedFault = errors.New("health check dropped by fault injection")
+
+// faults are the faults injected into the instances
+var faults = &faultInjector{
+	dropHealthChecks: make(map[types.NamespacedName]bool),
+}
+
+// EnableFaultInjection applies the fault injection of the Dragonfly
+// objects. It's meant for staging clusters, to rehearse failovers.
+func EnableFaultInjection() {
+	faults.mu.Lock()
+	defer faults.mu.Unlock()
+
+	faults.enabled = true
+}
+
+// faultInjector keeps track of the faults injected into the instances, so
+// that they can be applied where only their pods are known
+type faultInjector struct {
+	mu      sync.RWMutex
+	enabled bool
+
+	// dropHealthChecks are the instances whose health checks are dropped
+	dropHealthChecks map[types.NamespacedName]bool
+}
+
+// get returns the fault injection of the instance, or nil if fault
+// injection isn't enabled
+func (f *faultInjector) get(df *dfv1alpha1.Dragonfly) *dfv1alpha1.FaultInjection {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if !f.enabled {
+		return nil
+	}
+
+	return df.Spec.FaultInjection
+}
+
+// update records the faults injected into the instance
+func (f *faultInjector) update(df *dfv1alpha1.Dragonfly) {
+	injection := f.get(df)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := client.ObjectKeyFromObject(df)
+	if injection != nil && injection.DropHealthChecks {
+		f.dropHealthChecks[key] = true
+	} else {
+		delete(f.dropHealthChecks, key)
+	}
+}
+
+// forget forgets the faults injected into the deleted instance
+func (f *faultInjector) forget(key types.NamespacedName) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.dropHealthChecks, key)
+}
+
+// dropsHealthChecks returns if the health checks of the pod are dropped
+func (f *faultInjector) dropsHealthChecks(pod *corev1.Pod) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.dropHealthChecks[types.NamespacedName{Namespace: pod.Namespace, Name: pod.Labels["app"]}]
+}
+
+// delayReplicaOf waits for the injected delay of the SLAVE OF commands
+// of the instance
+func (f *faultInjector) delayReplicaOf(ctx context.Context, df *dfv1alpha1.Dragonfly) error {
+	injection := f.get(df)
+	if injection == nil || injection.ReplicaOfDelay == nil {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(injection.ReplicaOfDelay.Duration):
+		return nil
+	}
+}
+
+// isMasterFailureRequested returns if the master failure request of the
+// instance wasn't handled yet
+func isMasterFailureRequested(df *dfv1alpha1.Dragonfly) bool {
+	injection := faults.get(df)
+	return injection != nil && injection.MasterFailureRequest != "" && injection.MasterFailureRequest != df.Status.MasterFailureRequest
+}
+
+// injectMasterFailure kills the master pod of the instance without a
+// grace period, as if it died, and records the handled request
+func (r *DragonflyReconciler) injectMasterFailure(ctx context.Context, df *dfv1alpha1.Dragonfly) error {
+	master, err := getMasterPod(ctx, r.Client, df)
+	if err != nil {
+		return err
+	}
+
+	if err := r.Delete(ctx, master, client.GracePeriodSeconds(0)); err != nil {
+		return err
+	}
+	r.EventRecorder.Event(df, corev1.EventTypeWarning, "FaultInjection", fmt.Sprintf("Killed master %s", master.Name))
+
+	df.Status.MasterFailureRequest = df.Spec.FaultInjection.MasterFailureRequest
+	return r.Status().Update(ctx, df)
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// handOverMaster hands over the master role of the instance to the given
+// replica in sync with it, as the given topology change, once the replica
+// acknowledged all the writes of the master. announce is called right
+// before the takeover, e.g to record an event.
+func handOverMaster(ctx context.Context, c client.Client, dfi *DragonflyInstance, operation string, master, replica *corev1.Pod, announce func()) error {
+	return runTopologyChange(ctx, c, dfi.df, operation, master.Name, func(ctx context.Context) error {
+		// Make sure the new master has all the writes of the old one
+		if err := waitForReplicaAcknowledgement(ctx, master, replica, failoverMaxWait(dfi.df)); err != nil {
+			return fmt.Errorf("replica %s did not acknowledge all writes: %w", replica.Name, err)
+		}
+
+		announce()
+		if err := replTakeover(ctx, c, dfi.df, replica); err != nil {
+			return fmt.Errorf("could not hand over the master role to %s: %w", replica.Name, err)
+		}
+
+		// the old master is configured again as a replica once it's ready,
+		// and is no longer protected by the PodDisruptionBudget
+		delete(master.Labels, resources.Role)
+		delete(master.Labels, resources.MasterIp)
+		if err := c.Update(ctx, master); err != nil {
+			return fmt.Errorf("could not clear the role of the old master: %w", err)
+		}
+
+		// point the other replicas to the new master
+		if err := dfi.checkAndConfigureReplication(ctx); err != nil {
+			return fmt.Errorf("could not reconfigure the replicas after the hand over: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// getHandOverTarget returns the preferred replica in sync with the master
+// that isn't on a cordoned or not ready node, if any
+func getHandOverTarget(ctx context.Context, c client.Client, dfi *DragonflyInstance, master *corev1.Pod) (*corev1.Pod, error) {
+	pods, err := dfi.getPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sortByFailoverPriority(pods.Items)
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Name == master.Name || pod.Labels[resources.Role] != resources.Replica || getFailoverPriority(pod) == 0 {
+			continue
+		}
+
+		if pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning || !isPodReady(pod) || !dfi.isNodeReady(ctx, pod) {
+			continue
+		}
+
+		cordoned, err := isNodeCordoned(ctx, c, pod.Spec.NodeName)
+		if err != nil || cordoned {
+			continue
+		}
+
+		stable, err := isStableState(ctx, c, pod)
+		if err != nil || !stable {
+			continue
+		}
+
+		return pod, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	"github.com/redis/go-redis/v9"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	ImportRunning   = "Running"
+	ImportSucceeded = "Succeeded"
+	ImportFailed    = "Failed"
+)
+
+// isImportRunning returns if the import of the instance is in progress
+func isImportRunning(df *dfv1alpha1.Dragonfly) bool {
+	return df.Status.Import != nil && df.Status.Import.Phase == ImportRunning
+}
+
+// reconcileImport starts the import of the Redis Cluster of the instance
+// if its source changed, and records the progress of the import job in
+// the status
+func (r *DragonflyReconciler) reconcileImport(ctx context.Context, df *dfv1alpha1.Dragonfly) error {
+	var job batchv1.Job
+	err := r.Get(ctx, types.NamespacedName{Namespace: df.Namespace, Name: resources.GetImportJobName(df)}, &job)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	jobFound := err == nil
+
+	if df.Status.Import == nil || df.Status.Import.Source != df.Spec.Import.Source {
+		if jobFound {
+			if job.Annotations[resources.ImportSourceAnnotation] != df.Spec.Import.Source {
+				// jobs are immutable, so the job of the previous source
+				// is replaced once it's deleted
+				if job.DeletionTimestamp == nil {
+					if err := r.Delete(ctx, &job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+						return err
+					}
+				}
+				return fmt.Errorf("waiting for the import job of the previous source to be deleted")
+			}
+		}
+
+		return r.startImport(ctx, df, jobFound)
+	}
+
+	if !isImportRunning(df) {
+		return nil
+	}
+
+	status := df.Status.Import.DeepCopy()
+	if !jobFound {
+		status.Phase = ImportFailed
+		r.EventRecorder.Event(df, corev1.EventTypeWarning, "Import", fmt.Sprintf("Import job of %s was deleted before it completed", status.Source))
+	} else {
+		status.CompletedShards = job.Status.Succeeded
+		status.FailedShards = job.Status.Failed
+		for _, condition := range job.Status.Conditions {
+			if condition.Status != corev1.ConditionTrue {
+				continue
+			}
+
+			switch condition.Type {
+			case batchv1.JobComplete:
+				status.Phase = ImportSucceeded
+				r.EventRecorder.Event(df, corev1.EventTypeNormal, "Import", fmt.Sprintf("Imported %d shards of %s in %s", status.Shards, status.Source, time.Since(status.StartTime.Time).Round(time.Second)))
+			case batchv1.JobFailed:
+				status.Phase = ImportFailed
+				r.EventRecorder.Event(df, corev1.EventTypeWarning, "Import", fmt.Sprintf("Import of %s failed: %s", status.Source, condition.Message))
+			}
+		}
+	}
+
+	if status.Phase != ImportRunning {
+		now := metav1.Now()
+		status.CompletionTime = &now
+	}
+
+	if *status == *df.Status.Import {
+		return nil
+	}
+
+	df.Status.Import = status
+	return r.Status().Update(ctx, df)
+}
+
+// startImport creates the import job for the master shards of the
+// Redis Cluster of the instance, unless it exists already
+func (r *DragonflyReconciler) startImport(ctx context.Context, df *dfv1alpha1.Dragonfly, jobFound bool) error {
+	shards, err := r.getClusterShards(ctx, df)
+	if err != nil {
+		return fmt.Errorf("could not discover the shards of redis cluster %s: %w", df.Spec.Import.Source, err)
+	}
+
+	if !jobFound {
+		job, err := resources.GetImportJob(df, shards)
+		if err != nil {
+			return err
+		}
+
+		if err := r.Create(ctx, job); err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+
+	r.EventRecorder.Event(df, corev1.EventTypeNormal, "Import", fmt.Sprintf("Importing %d shards of %s", len(shards), df.Spec.Import.Source))
+	df.Status.Import = &dfv1alpha1.ImportStatus{
+		Source:    df.Spec.Import.Source,
+		Phase:     ImportRunning,
+		Shards:    int32(len(shards)),
+		StartTime: metav1.Now(),
+	}
+	return r.Status().Update(ctx, df)
+}
+
+// getClusterShards returns the addresses of the master shards of the
+// Redis Cluster to import that serve slots
+func (r *DragonflyReconciler) getClusterShards(ctx context.Context, df *dfv1alpha1.Dragonfly) ([]string, error) {
+	opts := &redis.Options{
+		Addr: df.Spec.Import.Source,
+	}
+
+	if ref := df.Spec.Import.PasswordFromSecret; ref != nil {
+		password, err := getSecretValue(ctx, r.Client, df.Namespace, ref.Name, ref.Key)
+		if err != nil {
+			return nil, err
+		}
+		opts.Password = string(password)
+	}
+
+	redisClient := redis.NewClient(opts)
+	defer redisClient.Close()
+
+	nodes, err := redisClient.ClusterNodes(ctx).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sourceHost, _, err := net.SplitHostPort(df.Spec.Import.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseClusterMasters(nodes, sourceHost), nil
+}
+
+// parseClusterMasters returns the addresses of the healthy masters with
+// slots in the output of CLUSTER NODES. Nodes without an IP, like the
+// node answering in some setups, are addressed through the given host.
+func parseClusterMasters(nodes, defaultHost string) []string {
+	var masters []string
+	for _, line := range strings.Split(nodes, "\n") {
+		// <id> <ip:port@cport[,hostname]> <flags> <master> <ping-sent> <pong-recv> <config-epoch> <link-state> <slot> ...
+		fields := strings.Fields(line)
+		if len(fields) < 9 {
+			continue
+		}
+
+		isMaster, isHealthy := false, true
+		for _, flag := range strings.Split(fields[2], ",") {
+			switch flag {
+			case "master":
+				isMaster = true
+			case "fail", "noaddr":
+				isHealthy = false
+			}
+		}
+
+		if !isMaster || !isHealthy {
+			continue
+		}
+
+		addr, _, _ := strings.Cut(fields[1], "@")
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			continue
+		}
+
+		if host == "" {
+			host = defaultHost
+		}
+
+		masters = append(masters, net.JoinHostPort(host, port))
+	}
+
+	return masters
+}
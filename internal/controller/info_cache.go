@@ -0,0 +1,111 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// infoCacheTTL is how long the INFO of a pod is reused. It's short, as
+// the replication state changes quickly, but spares the pods from being
+// queried by every check of a single reconcile.
+const infoCacheTTL = 2 * time.Second
+
+// infoCache holds the recent INFO results of the pods, shared by the
+// controllers
+var infoCache = newPodInfoCache(infoCacheTTL)
+
+type podInfoKey struct {
+	uid     types.UID
+	ip      string
+	section string
+}
+
+type podInfoEntry struct {
+	info    map[string]string
+	fetched time.Time
+}
+
+// podInfoCache caches the INFO sections of pods for a TTL. Entries are
+// keyed by the IP of the pod as well, so that a restarted pod is queried
+// again.
+type podInfoCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[podInfoKey]podInfoEntry
+}
+
+func newPodInfoCache(ttl time.Duration) *podInfoCache {
+	return &podInfoCache{
+		ttl:     ttl,
+		entries: make(map[podInfoKey]podInfoEntry),
+	}
+}
+
+// get returns the cached section of INFO of the pod, if it's recent
+func (c *podInfoCache) get(pod *corev1.Pod, section string) (map[string]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := podInfoKey{uid: pod.UID, ip: pod.Status.PodIP, section: section}
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	if time.Since(entry.fetched) > c.ttl {
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	return entry.info, true
+}
+
+// set caches the section of INFO of the pod
+func (c *podInfoCache) set(pod *corev1.Pod, section string, info map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// drop the expired entries, so that deleted pods are forgotten
+	for key, entry := range c.entries {
+		if time.Since(entry.fetched) > c.ttl {
+			delete(c.entries, key)
+		}
+	}
+
+	c.entries[podInfoKey{uid: pod.UID, ip: pod.Status.PodIP, section: section}] = podInfoEntry{
+		info:    info,
+		fetched: time.Now(),
+	}
+}
+
+// invalidate forgets the INFO of the pod, after its role was changed
+func (c *podInfoCache) invalidate(pod *corev1.Pod) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.entries {
+		if key.uid == pod.UID {
+			delete(c.entries, key)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// keyspaceKeys is the number of keys per database of the instances
+	keyspaceKeys = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dragonfly_operator_keyspace_keys",
+		Help: "Number of keys in a database of the master of a Dragonfly instance",
+	}, []string{"namespace", "name", "db"})
+
+	// keyspaceExpires is the number of keys with an expiry per database of
+	// the instances
+	keyspaceExpires = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dragonfly_operator_keyspace_expires",
+		Help: "Number of keys with an expiry in a database of the master of a Dragonfly instance",
+	}, []string{"namespace", "name", "db"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(keyspaceKeys, keyspaceExpires)
+}
+
+// KeyspaceCollector periodically records the size of the keyspace of the
+// masters of the instances in their status and as metrics, so that their
+// capacity can be planned without an exporter per instance.
+type KeyspaceCollector struct {
+	client.Client
+
+	// Interval is how often the keyspaces are collected
+	Interval time.Duration
+
+	// Shard is the share of the Dragonfly objects that is collected
+	Shard Shard
+
+	// databases are the databases with metrics per instance
+	databases map[types.NamespacedName][]int32
+}
+
+// Start runs the collector until the context is done
+func (c *KeyspaceCollector) Start(ctx context.Context) error {
+	c.databases = make(map[types.NamespacedName][]int32)
+
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := c.collect(ctx); err != nil {
+				log.FromContext(ctx).Error(err, "could not collect keyspaces")
+			}
+		}
+	}
+}
+
+func (c *KeyspaceCollector) collect(ctx context.Context) error {
+	log := log.FromContext(ctx)
+
+	var dfs dfv1alpha1.DragonflyList
+	if err := c.List(ctx, &dfs); err != nil {
+		return err
+	}
+
+	seen := make(map[types.NamespacedName]bool, len(dfs.Items))
+	for i := range dfs.Items {
+		df := &dfs.Items[i]
+		if !c.Shard.Contains(df.Namespace, df.Name) {
+			continue
+		}
+
+		key := client.ObjectKeyFromObject(df)
+		seen[key] = true
+
+		if df.Status.Phase != PhaseReady && df.Status.Phase != PhaseDegraded {
+			continue
+		}
+
+		keyspace, err := c.getKeyspace(ctx, df)
+		if err != nil {
+			log.Error(err, "could not get the keyspace", "dragonfly", key)
+			continue
+		}
+
+		c.setMetrics(key, keyspace)
+
+		patch := client.MergeFrom(df.DeepCopy())
+		df.Status.Keyspace = keyspace
+		if err := c.Status().Patch(ctx, df, patch); err != nil {
+			log.Error(err, "could not update the keyspace status", "dragonfly", key)
+		}
+	}
+
+	// forget the deleted instances
+	for key := range c.databases {
+		if !seen[key] {
+			c.setMetrics(key, nil)
+		}
+	}
+
+	return nil
+}
+
+// getKeyspace returns the keyspace of the master of the instance
+func (c *KeyspaceCollector) getKeyspace(ctx context.Context, df *dfv1alpha1.Dragonfly) (*dfv1alpha1.KeyspaceStatus, error) {
+	master, err := getMasterPod(ctx, c.Client, df)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := getInfo(ctx, master, "keyspace")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseKeyspace(info)
+}
+
+// parseKeyspace parses the keyspace section of INFO, whose lines are of
+// the form db0:keys=1,expires=0,avg_ttl=0
+func parseKeyspace(info map[string]string) (*dfv1alpha1.KeyspaceStatus, error) {
+	keyspace := &dfv1alpha1.KeyspaceStatus{LastUpdateTime: metav1.Now()}
+	for name, value := range info {
+		index, ok := strings.CutPrefix(name, "db")
+		if !ok {
+			continue
+		}
+
+		database, err := strconv.ParseInt(index, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid database %s: %w", name, err)
+		}
+
+		db := dfv1alpha1.DatabaseKeyspace{Database: int32(database)}
+		for _, field := range strings.Split(value, ",") {
+			k, v, _ := strings.Cut(field, "=")
+			switch k {
+			case "keys":
+				db.Keys, err = strconv.ParseInt(v, 10, 64)
+			case "expires":
+				db.Expires, err = strconv.ParseInt(v, 10, 64)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s of database %s: %w", k, name, err)
+			}
+		}
+
+		keyspace.Keys += db.Keys
+		keyspace.Expires += db.Expires
+		keyspace.Databases = append(keyspace.Databases, db)
+	}
+
+	sort.Slice(keyspace.Databases, func(i, j int) bool {
+		return keyspace.Databases[i].Database < keyspace.Databases[j].Database
+	})
+
+	return keyspace, nil
+}
+
+// setMetrics sets the keyspace metrics of the instance, and deletes those
+// of its databases that became empty. A nil keyspace deletes all of them.
+func (c *KeyspaceCollector) setMetrics(key types.NamespacedName, keyspace *dfv1alpha1.KeyspaceStatus) {
+	current := make(map[int32]bool)
+	if keyspace != nil {
+		for _, db := range keyspace.Databases {
+			current[db.Database] = true
+			keyspaceKeys.WithLabelValues(key.Namespace, key.Name, strconv.Itoa(int(db.Database))).Set(float64(db.Keys))
+			keyspaceExpires.WithLabelValues(key.Namespace, key.Name, strconv.Itoa(int(db.Database))).Set(float64(db.Expires))
+		}
+	}
+
+	for _, database := range c.databases[key] {
+		if !current[database] {
+			keyspaceKeys.DeleteLabelValues(key.Namespace, key.Name, strconv.Itoa(int(database)))
+			keyspaceExpires.DeleteLabelValues(key.Namespace, key.Name, strconv.Itoa(int(database)))
+		}
+	}
+
+	if len(current) == 0 {
+		delete(c.databases, key)
+		return
+	}
+
+	databases := make([]int32, 0, len(current))
+	for database := range current {
+		databases = append(databases, database)
+	}
+	c.databases[key] = databases
+}
@@ -0,0 +1,580 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	MaintenanceTaskFailoverRehearsal = "FailoverRehearsal"
+	MaintenanceTaskRollingRestart    = "RollingRestart"
+	MaintenanceTaskCommand           = "Command"
+
+	MaintenanceTargetMaster   = "Master"
+	MaintenanceTargetReplicas = "Replicas"
+	MaintenanceTargetAll      = "All"
+
+	MaintenanceRunning   = "Running"
+	MaintenanceSucceeded = "Succeeded"
+	MaintenanceFailed    = "Failed"
+	MaintenanceSkipped   = "Skipped"
+
+	// maintenanceCheckInterval is how often a run in progress is checked
+	maintenanceCheckInterval = 15 * time.Second
+
+	// maintenanceCommandTimeout is how long the command of a Command task
+	// may take on each pod
+	maintenanceCommandTimeout = 5 * time.Minute
+
+	// maintenanceOverrunLimit is how long a rolling restart may continue
+	// after the end of its window before it's considered failed
+	maintenanceOverrunLimit = time.Hour
+
+	// maintenanceMessageLimit is the maximum length of the message of a
+	// task, as the replies of a command can be large
+	maintenanceMessageLimit = 1024
+
+	defaultMaintenanceWindowDuration = time.Hour
+	defaultMaintenanceHistoryLimit   = 10
+)
+
+// DragonflyMaintenanceReconciler runs the tasks of the
+// DragonflyMaintenances in their maintenance windows
+type DragonflyMaintenanceReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	EventRecorder record.EventRecorder
+
+	// Shard selects the maintenances of the Dragonfly objects managed by
+	// this replica
+	Shard Shard
+
+	// RateLimiterOptions tune the rate limiter of the work queue, the
+	// default rate limiter is used if nil
+	RateLimiterOptions *RateLimiterOptions
+}
+
+//+kubebuilder:rbac:groups=dragonflydb.io,resources=dragonflymaintenances,verbs=get;list;watch
+//+kubebuilder:rbac:groups=dragonflydb.io,resources=dragonflymaintenances/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=dragonflydb.io,resources=dragonflymaintenances/finalizers,verbs=update
+
+// Reconcile starts a run of the tasks of the maintenance in each of its
+// windows, and advances the run in progress by one task at a time
+func (r *DragonflyMaintenanceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	var maintenance dfv1alpha1.DragonflyMaintenance
+	if err := r.Get(ctx, req.NamespacedName, &maintenance); err != nil {
+		log.Info(fmt.Sprintf("could not get the DragonflyMaintenance object: %s", req.Name))
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	now := time.Now().UTC()
+	windowStart, windowEnd, inWindow, err := currentMaintenanceWindow(maintenance.Spec.Windows, now)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	next, err := nextMaintenanceWindowStart(maintenance.Spec.Windows, now)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	maintenance.Status.NextWindowStart = &metav1.Time{Time: next}
+
+	if maintenance.Status.Current == nil && inWindow && !maintenance.Spec.Suspend && !hasMaintenanceRun(&maintenance, windowStart) {
+		log.Info("Starting maintenance run", "window", windowStart)
+		maintenance.Status.Current = &dfv1alpha1.MaintenanceRun{
+			WindowStart: metav1.Time{Time: windowStart},
+			WindowEnd:   metav1.Time{Time: windowEnd},
+			Result:      MaintenanceRunning,
+		}
+	}
+
+	if maintenance.Status.Current == nil {
+		if err := r.Status().Update(ctx, &maintenance); err != nil {
+			log.Error(err, "could not update the DragonflyMaintenance status")
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{RequeueAfter: time.Until(next)}, nil
+	}
+
+	requeue, err := r.advanceRun(ctx, &maintenance)
+	if err != nil {
+		log.Error(err, "could not advance the maintenance run")
+		return ctrl.Result{}, err
+	}
+
+	if run := maintenance.Status.Current; run.Result != MaintenanceRunning {
+		r.completeRun(&maintenance)
+	}
+
+	if err := r.Status().Update(ctx, &maintenance); err != nil {
+		log.Error(err, "could not update the DragonflyMaintenance status")
+		return ctrl.Result{}, err
+	}
+
+	if maintenance.Status.Current == nil {
+		return ctrl.Result{RequeueAfter: time.Until(next)}, nil
+	}
+
+	if requeue {
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	return ctrl.Result{RequeueAfter: withJitter(maintenanceCheckInterval)}, nil
+}
+
+// advanceRun advances the run in progress of the maintenance by at most one
+// task, and sets its result once it's over. It returns if the next task can
+// be started right away.
+func (r *DragonflyMaintenanceReconciler) advanceRun(ctx context.Context, maintenance *dfv1alpha1.DragonflyMaintenance) (bool, error) {
+	run := maintenance.Status.Current
+	now := time.Now()
+
+	var df dfv1alpha1.Dragonfly
+	if err := r.Get(ctx, client.ObjectKey{Namespace: maintenance.Namespace, Name: maintenance.Spec.Instance}, &df); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return false, err
+		}
+
+		failRun(run, fmt.Sprintf("instance %s not found", maintenance.Spec.Instance))
+		return false, nil
+	}
+
+	if len(run.Tasks) > 0 {
+		task := &run.Tasks[len(run.Tasks)-1]
+		if task.Result == MaintenanceRunning {
+			if task.Type != MaintenanceTaskRollingRestart {
+				// the operator was stopped while the task was running
+				task.Result = MaintenanceFailed
+				task.Message = "interrupted"
+				failRun(run, fmt.Sprintf("task %d was interrupted", len(run.Tasks)))
+				return false, nil
+			}
+
+			done, err := r.checkRollingRestart(ctx, &df, run)
+			if err != nil {
+				task.Message = err.Error()
+			}
+
+			switch {
+			case done:
+				task.Result = MaintenanceSucceeded
+				task.Message = "all pods were restarted"
+			case now.After(run.WindowEnd.Add(maintenanceOverrunLimit)):
+				task.Result = MaintenanceFailed
+				failRun(run, fmt.Sprintf("task %d did not complete in time", len(run.Tasks)))
+			}
+
+			return done, nil
+		}
+	}
+
+	if len(run.Tasks) >= len(maintenance.Spec.Tasks) {
+		run.Result = MaintenanceSucceeded
+		return false, nil
+	}
+
+	// no new task is started after the end of the window
+	if !now.Before(run.WindowEnd.Time) {
+		if run.StartTime == nil {
+			run.Result = MaintenanceSkipped
+			run.Message = fmt.Sprintf("instance %s was not ready during the window", df.Name)
+			return false, nil
+		}
+
+		failRun(run, fmt.Sprintf("the window ended before task %d could start", len(run.Tasks)+1))
+		return false, nil
+	}
+
+	// tasks only start on a ready instance, e.g not during a rollout
+	if df.Status.Phase != PhaseReady || df.Status.IsRollingUpdate {
+		run.Message = fmt.Sprintf("waiting for instance %s to be ready", df.Name)
+		return false, nil
+	}
+	run.Message = ""
+
+	if run.StartTime == nil {
+		run.StartTime = &metav1.Time{Time: now}
+		r.EventRecorder.Event(maintenance, corev1.EventTypeNormal, "Maintenance", fmt.Sprintf("Started the maintenance of instance %s", df.Name))
+	}
+
+	spec := maintenance.Spec.Tasks[len(run.Tasks)]
+	run.Tasks = append(run.Tasks, dfv1alpha1.MaintenanceTaskResult{
+		Type:   spec.Type,
+		Result: MaintenanceRunning,
+	})
+
+	// record the task as running first, so that it's not run twice if the
+	// operator is stopped in the meantime
+	if err := r.Status().Update(ctx, maintenance); err != nil {
+		return false, err
+	}
+	run = maintenance.Status.Current
+	task := &run.Tasks[len(run.Tasks)-1]
+
+	var message string
+	var err error
+	switch spec.Type {
+	case MaintenanceTaskFailoverRehearsal:
+		message, err = r.rehearseFailover(ctx, maintenance, &df)
+	case MaintenanceTaskCommand:
+		message, err = r.runCommand(ctx, &df, spec)
+	case MaintenanceTaskRollingRestart:
+		err = r.requestRestart(ctx, &df, run)
+		message = "restarting the pods"
+	default:
+		err = fmt.Errorf("unknown task type %q", spec.Type)
+	}
+
+	if err != nil {
+		task.Result = MaintenanceFailed
+		task.Message = err.Error()
+		failRun(run, fmt.Sprintf("task %d failed", len(run.Tasks)))
+		return false, nil
+	}
+
+	if len(message) > maintenanceMessageLimit {
+		message = message[:maintenanceMessageLimit] + "..."
+	}
+
+	task.Message = message
+	if spec.Type != MaintenanceTaskRollingRestart {
+		task.Result = MaintenanceSucceeded
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// rehearseFailover hands over the master role of the instance to a replica
+// in sync, the same way as for the eviction of the master
+func (r *DragonflyMaintenanceReconciler) rehearseFailover(ctx context.Context, maintenance *dfv1alpha1.DragonflyMaintenance, df *dfv1alpha1.Dragonfly) (string, error) {
+	dfi := &DragonflyInstance{df: df, client: r.Client, log: log.FromContext(ctx), eventRecorder: r.EventRecorder}
+
+	master, err := getMasterPod(ctx, r.Client, df)
+	if err != nil {
+		return "", err
+	}
+
+	replica, err := getHandOverTarget(ctx, r.Client, dfi, master)
+	if err != nil {
+		return "", fmt.Errorf("could not find a replica to hand over the master role to: %w", err)
+	}
+
+	if replica == nil {
+		return "", fmt.Errorf("no replica in sync can take over from master %s", master.Name)
+	}
+
+	message := fmt.Sprintf("Handing over the master role from %s to %s in a failover rehearsal", master.Name, replica.Name)
+	if err := handOverMaster(ctx, r.Client, dfi, TopologyChangeMaintenanceFailover, master, replica, func() {
+		r.EventRecorder.Event(df, corev1.EventTypeNormal, "Maintenance", message)
+	}); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("handed over the master role from %s to %s", master.Name, replica.Name), nil
+}
+
+// runCommand runs the command of the task on the pods of its target, and
+// returns their replies
+func (r *DragonflyMaintenanceReconciler) runCommand(ctx context.Context, df *dfv1alpha1.Dragonfly, task dfv1alpha1.MaintenanceTask) (string, error) {
+	if len(task.Command) == 0 {
+		return "", fmt.Errorf("the Command task has no command")
+	}
+
+	dfi := &DragonflyInstance{df: df, client: r.Client, log: log.FromContext(ctx), eventRecorder: r.EventRecorder}
+	pods, err := dfi.getPods(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	args := make([]interface{}, len(task.Command))
+	for i, arg := range task.Command {
+		args[i] = arg
+	}
+
+	var replies []string
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !isMaintenanceTarget(pod, task.Target) {
+			continue
+		}
+
+		reply, err := runAdminCommand(ctx, pod, args)
+		if err != nil {
+			return strings.Join(replies, ", "), fmt.Errorf("%s failed on pod %s: %w", task.Command[0], pod.Name, err)
+		}
+		replies = append(replies, fmt.Sprintf("%s: %v", pod.Name, reply))
+	}
+
+	if len(replies) == 0 {
+		return "", fmt.Errorf("no pod of instance %s matches target %s", df.Name, task.Target)
+	}
+
+	return strings.Join(replies, ", "), nil
+}
+
+// runAdminCommand runs the given command on the admin port of the pod
+func runAdminCommand(ctx context.Context, pod *corev1.Pod, args []interface{}) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, maintenanceCommandTimeout)
+	defer cancel()
+
+	redisClient := newBlockingAdminClient(ctx, pod)
+	defer redisClient.Close()
+
+	return redisClient.Do(ctx, args...).Result()
+}
+
+// isMaintenanceTarget returns if the pod is one of the given target
+func isMaintenanceTarget(pod *corev1.Pod, target string) bool {
+	switch target {
+	case MaintenanceTargetMaster:
+		return pod.Labels[resources.Role] == resources.Master
+	case MaintenanceTargetReplicas:
+		return pod.Labels[resources.Role] == resources.Replica
+	}
+
+	return pod.Labels[resources.Role] != ""
+}
+
+// restartRequest returns the value of the restart request annotation of
+// the rolling restart of the run
+func restartRequest(run *dfv1alpha1.MaintenanceRun) string {
+	return run.WindowStart.UTC().Format(time.RFC3339)
+}
+
+// requestRestart requests a rolling restart of the pods of the instance,
+// which is then rolled by the Dragonfly controller like on any update
+func (r *DragonflyMaintenanceReconciler) requestRestart(ctx context.Context, df *dfv1alpha1.Dragonfly, run *dfv1alpha1.MaintenanceRun) error {
+	patch := client.MergeFrom(df.DeepCopy())
+	if df.Annotations == nil {
+		df.Annotations = make(map[string]string)
+	}
+	df.Annotations[resources.RestartRequestAnnotation] = restartRequest(run)
+
+	return r.Patch(ctx, df, patch)
+}
+
+// checkRollingRestart returns if the pods of the instance were restarted
+// for the rolling restart of the run
+func (r *DragonflyMaintenanceReconciler) checkRollingRestart(ctx context.Context, df *dfv1alpha1.Dragonfly, run *dfv1alpha1.MaintenanceRun) (bool, error) {
+	var statefulSet appsv1.StatefulSet
+	if err := r.Get(ctx, client.ObjectKey{Namespace: df.Namespace, Name: df.Name}, &statefulSet); err != nil {
+		return false, fmt.Errorf("could not get the statefulset: %w", err)
+	}
+
+	if statefulSet.Spec.Template.Annotations[resources.RestartRequestAnnotation] != restartRequest(run) {
+		return false, nil
+	}
+
+	if statefulSet.Status.ObservedGeneration < statefulSet.Generation || statefulSet.Spec.Replicas == nil || statefulSet.Status.UpdatedReplicas != *statefulSet.Spec.Replicas {
+		return false, nil
+	}
+
+	return df.Status.Phase == PhaseReady && !df.Status.IsRollingUpdate, nil
+}
+
+// failRun ends the run as failed
+func failRun(run *dfv1alpha1.MaintenanceRun, message string) {
+	run.Result = MaintenanceFailed
+	run.Message = message
+}
+
+// completeRun moves the run of the maintenance that is over to its history
+func (r *DragonflyMaintenanceReconciler) completeRun(maintenance *dfv1alpha1.DragonflyMaintenance) {
+	run := maintenance.Status.Current
+	now := metav1.Now()
+	run.CompletionTime = &now
+
+	eventType := corev1.EventTypeNormal
+	if run.Result == MaintenanceFailed {
+		eventType = corev1.EventTypeWarning
+	}
+
+	message := fmt.Sprintf("Maintenance of instance %s %s", maintenance.Spec.Instance, strings.ToLower(run.Result))
+	if run.Message != "" {
+		message = fmt.Sprintf("%s: %s", message, run.Message)
+	}
+	r.EventRecorder.Event(maintenance, eventType, "Maintenance", message)
+
+	limit := defaultMaintenanceHistoryLimit
+	if maintenance.Spec.HistoryLimit != nil {
+		limit = int(*maintenance.Spec.HistoryLimit)
+	}
+
+	history := append(maintenance.Status.History, *run)
+	if len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+
+	maintenance.Status.History = history
+	maintenance.Status.Current = nil
+}
+
+// hasMaintenanceRun returns if the maintenance already ran in the window
+// with the given start
+func hasMaintenanceRun(maintenance *dfv1alpha1.DragonflyMaintenance, windowStart time.Time) bool {
+	for _, run := range maintenance.Status.History {
+		if run.WindowStart.Time.Equal(windowStart) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// maintenanceWindowStarts calls fn with the start and the end of the
+// occurrences of the windows that start on the days from the given offsets
+// to the given day
+func maintenanceWindowStarts(windows []dfv1alpha1.MaintenanceWindow, day time.Time, from, to int, fn func(start, end time.Time)) error {
+	for _, window := range windows {
+		clock, err := time.Parse("15:04", window.Start)
+		if err != nil {
+			return fmt.Errorf("invalid start %q of maintenance window: %w", window.Start, err)
+		}
+
+		duration := defaultMaintenanceWindowDuration
+		if window.Duration != nil {
+			duration = window.Duration.Duration
+		}
+
+		for offset := from; offset <= to; offset++ {
+			date := day.AddDate(0, 0, offset)
+			if !isMaintenanceDay(window, date.Weekday()) {
+				continue
+			}
+
+			start := time.Date(date.Year(), date.Month(), date.Day(), clock.Hour(), clock.Minute(), 0, 0, time.UTC)
+			fn(start, start.Add(duration))
+		}
+	}
+
+	return nil
+}
+
+// isMaintenanceDay returns if the window occurs on the given day
+func isMaintenanceDay(window dfv1alpha1.MaintenanceWindow, day time.Weekday) bool {
+	if len(window.Days) == 0 {
+		return true
+	}
+
+	for _, d := range window.Days {
+		if string(d) == day.String() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// currentMaintenanceWindow returns the start and the end of the window that
+// the given time is in, i.e the one that started last if they overlap
+func currentMaintenanceWindow(windows []dfv1alpha1.MaintenanceWindow, now time.Time) (time.Time, time.Time, bool, error) {
+	var start, end time.Time
+	found := false
+	err := maintenanceWindowStarts(windows, now, -7, 0, func(s, e time.Time) {
+		if !s.After(now) && now.Before(e) && (!found || s.After(start)) {
+			start, end, found = s, e, true
+		}
+	})
+
+	return start, end, found, err
+}
+
+// nextMaintenanceWindowStart returns the first start of a window after the
+// given time
+func nextMaintenanceWindowStart(windows []dfv1alpha1.MaintenanceWindow, now time.Time) (time.Time, error) {
+	var next time.Time
+	err := maintenanceWindowStarts(windows, now, 0, 7, func(s, _ time.Time) {
+		if s.After(now) && (next.IsZero() || s.Before(next)) {
+			next = s
+		}
+	})
+
+	return next, err
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *DragonflyMaintenanceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return r.setupWithCluster(mgr, mgr, "dragonflymaintenance")
+}
+
+// setupWithCluster sets up the controller of the given name for the
+// maintenances of the cluster, i.e. the cluster of the Manager or a remote
+// one
+func (r *DragonflyMaintenanceReconciler) setupWithCluster(mgr ctrl.Manager, cl cluster.Cluster, name string) error {
+	inShard := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		maintenance, ok := obj.(*dfv1alpha1.DragonflyMaintenance)
+		return ok && r.Shard.Contains(maintenance.Namespace, maintenance.Spec.Instance)
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		Watches(source.NewKindWithCache(&dfv1alpha1.DragonflyMaintenance{}, cl.GetCache()), &handler.EnqueueRequestForObject{}, builder.WithPredicates(inShard, predicate.GenerationChangedPredicate{})).
+		// Follow the phase of the instances, as the tasks wait for them
+		// to be ready
+		Watches(source.NewKindWithCache(&dfv1alpha1.Dragonfly{}, cl.GetCache()), handler.EnqueueRequestsFromMapFunc(r.findMaintenancesForDragonfly)).
+		WithOptions(controller.Options{RateLimiter: newRateLimiter(r.RateLimiterOptions)}).
+		Complete(r)
+}
+
+// findMaintenancesForDragonfly returns the maintenances of the Dragonfly
+// object
+func (r *DragonflyMaintenanceReconciler) findMaintenancesForDragonfly(obj client.Object) []reconcile.Request {
+	if !r.Shard.Contains(obj.GetNamespace(), obj.GetName()) {
+		return nil
+	}
+
+	var maintenances dfv1alpha1.DragonflyMaintenanceList
+	if err := r.List(context.Background(), &maintenances, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0)
+	for _, maintenance := range maintenances.Items {
+		if maintenance.Spec.Instance == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&maintenance)})
+		}
+	}
+
+	return requests
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/budget"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// memoryBudgetLimit is the limit of the memory budgets
+	memoryBudgetLimit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dragonfly_operator_memory_budget_limit_bytes",
+		Help: "Aggregate memory the Dragonfly instances of a memory budget may use",
+	}, []string{"budget"})
+
+	// memoryBudgetUsed is the memory of the instances of the memory
+	// budgets whose resources are created
+	memoryBudgetUsed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dragonfly_operator_memory_budget_used_bytes",
+		Help: "Aggregate memory of the Dragonfly instances of a memory budget",
+	}, []string{"budget"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(memoryBudgetLimit, memoryBudgetUsed)
+}
+
+// checkMemoryBudgets returns why the new instance doesn't fit in one of
+// its memory budgets, or "" if it fits in all of them
+func (r *DragonflyReconciler) checkMemoryBudgets(ctx context.Context, df *dfv1alpha1.Dragonfly) (string, error) {
+	if len(r.MemoryBudgets) == 0 {
+		return "", nil
+	}
+
+	var dfs dfv1alpha1.DragonflyList
+	if err := r.List(ctx, &dfs); err != nil {
+		return "", err
+	}
+
+	for i := range r.MemoryBudgets {
+		b := &r.MemoryBudgets[i]
+		if !b.Matches(df) {
+			continue
+		}
+
+		memory, err := budget.InstanceMemory(df)
+		if err != nil {
+			return fmt.Sprintf("Instance is in memory budget %s but has %s", b.Name, err), nil
+		}
+
+		used := getMemoryBudgetUsage(b, dfs.Items)
+		if used+memory > b.Limit.Value() {
+			return fmt.Sprintf("Instance needs %s of memory budget %s, of which %s of %s is used", formatBytes(memory), b.Name, formatBytes(used), b.Limit.String()), nil
+		}
+	}
+
+	return "", nil
+}
+
+// getMemoryBudgetUsage returns the memory of the instances in the budget
+// whose resources are created
+func getMemoryBudgetUsage(b *budget.MemoryBudget, dfs []dfv1alpha1.Dragonfly) int64 {
+	var used int64
+	for i := range dfs {
+		df := &dfs[i]
+		if df.Status.Phase == "" || df.Status.Phase == PhasePending || !b.Matches(df) {
+			continue
+		}
+
+		if memory, err := budget.InstanceMemory(df); err == nil {
+			used += memory
+		}
+	}
+
+	return used
+}
+
+func formatBytes(bytes int64) string {
+	return resource.NewQuantity(bytes, resource.BinarySI).String()
+}
+
+// MemoryBudgetCollector periodically records the utilization of the
+// memory budgets as metrics
+type MemoryBudgetCollector struct {
+	client.Client
+
+	Budgets []budget.MemoryBudget
+
+	// Interval is how often the utilization is collected
+	Interval time.Duration
+}
+
+// Start runs the collector until the context is done
+func (c *MemoryBudgetCollector) Start(ctx context.Context) error {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := c.collect(ctx); err != nil {
+				log.FromContext(ctx).Error(err, "could not collect memory budget utilization")
+			}
+		}
+	}
+}
+
+func (c *MemoryBudgetCollector) collect(ctx context.Context) error {
+	var dfs dfv1alpha1.DragonflyList
+	if err := c.List(ctx, &dfs); err != nil {
+		return err
+	}
+
+	for i := range c.Budgets {
+		b := &c.Budgets[i]
+		memoryBudgetLimit.WithLabelValues(b.Name).Set(float64(b.Limit.Value()))
+		memoryBudgetUsed.WithLabelValues(b.Name).Set(float64(getMemoryBudgetUsage(b, dfs.Items)))
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// priorities are the priority tiers of the controllers. Each tier has its
+// own controller and so its own work queue, so that the reconciles of
+// critical instances, e.g failovers, aren't delayed behind the others.
+var priorities = []bool{true, false}
+
+// isCritical returns if the Dragonfly object is marked as critical
+func isCritical(df *dfv1alpha1.Dragonfly) bool {
+	return df.Labels[resources.PriorityLabel] == resources.PriorityCritical
+}
+
+// objectFilter selects the events about the Dragonfly objects of a shard,
+// and of these either the critical objects or the others
+type objectFilter struct {
+	reader   client.Reader
+	shard    Shard
+	critical bool
+}
+
+// controllerName returns the name of the controller of the tier
+func (f objectFilter) controllerName(name string) string {
+	if f.critical {
+		return name + "-critical"
+	}
+
+	return name
+}
+
+// matches returns if the Dragonfly object belongs to the shard and the
+// tier. Objects that can't be read, e.g because they were deleted, belong
+// to the regular tier.
+func (f objectFilter) matches(key types.NamespacedName) bool {
+	if !f.shard.Contains(key.Namespace, key.Name) {
+		return false
+	}
+
+	var df dfv1alpha1.Dragonfly
+	if err := f.reader.Get(context.Background(), key, &df); err != nil {
+		return !f.critical
+	}
+
+	return isCritical(&df) == f.critical
+}
+
+// matchesObject returns if the Dragonfly object that the given object
+// is, or belongs to, is in the shard and the tier
+func (f objectFilter) matchesObject(obj client.Object) bool {
+	switch obj := obj.(type) {
+	case *dfv1alpha1.Dragonfly:
+		return f.shard.Contains(obj.Namespace, obj.Name) && isCritical(obj) == f.critical
+	case *corev1.Pod:
+		return f.matches(types.NamespacedName{Namespace: obj.Namespace, Name: obj.Labels["app"]})
+	}
+
+	for _, owner := range obj.GetOwnerReferences() {
+		if owner.Kind == "Dragonfly" {
+			return f.matches(types.NamespacedName{Namespace: obj.GetNamespace(), Name: owner.Name})
+		}
+	}
+
+	return !f.critical && f.shard.Count <= 1
+}
+
+// predicate admits the events of the objects in the shard and the tier
+func (f objectFilter) predicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(f.matchesObject)
+}
+
+// dragonflies filters the Dragonfly objects returned by the given map
+// function down to the ones in the shard and the tier
+func (f objectFilter) dragonflies(fn handler.MapFunc) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		var requests []reconcile.Request
+		for _, request := range fn(obj) {
+			if f.matches(request.NamespacedName) {
+				requests = append(requests, request)
+			}
+		}
+
+		return requests
+	}
+}
+
+// pods filters the pods returned by the given map function down to the
+// ones of the Dragonfly objects in the shard and the tier
+func (f objectFilter) pods(fn handler.MapFunc) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		var requests []reconcile.Request
+		for _, request := range fn(obj) {
+			var pod corev1.Pod
+			if err := f.reader.Get(context.Background(), request.NamespacedName, &pod); err != nil {
+				if !f.critical && f.shard.Count <= 1 {
+					requests = append(requests, request)
+				}
+				continue
+			}
+
+			if f.matchesObject(&pod) {
+				requests = append(requests, request)
+			}
+		}
+
+		return requests
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sync"
+	"time"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// probeStatusRefreshInterval is how often the probe results of healthy
+// pods are written to the status, so that latency changes alone don't
+// update the status on every reconcile
+const probeStatusRefreshInterval = time.Minute
+
+type probeResult struct {
+	latency time.Duration
+	err     error
+	time    time.Time
+}
+
+// probeResults are the results of the last queries of the pods, per
+// instance, so that the results of an instance are pruned along with its
+// own pods only
+var probeResults = struct {
+	sync.Mutex
+	results map[types.NamespacedName]map[types.UID]probeResult
+}{results: make(map[types.NamespacedName]map[types.UID]probeResult)}
+
+// recordProbe records the result of a query of the pod
+func recordProbe(pod *corev1.Pod, latency time.Duration, err error) {
+	probeResults.Lock()
+	defer probeResults.Unlock()
+
+	instance := podInstance(client.ObjectKeyFromObject(pod))
+	if probeResults.results[instance] == nil {
+		probeResults.results[instance] = make(map[types.UID]probeResult)
+	}
+	probeResults.results[instance][pod.UID] = probeResult{latency: latency, err: err, time: time.Now()}
+}
+
+// forgetProbeResults forgets the probe results of a deleted instance
+func forgetProbeResults(instance types.NamespacedName) {
+	probeResults.Lock()
+	defer probeResults.Unlock()
+
+	delete(probeResults.results, instance)
+}
+
+// getPodStatuses returns the status of each of the given pods of the
+// instance, from the result of its last probe
+func getPodStatuses(instance types.NamespacedName, pods *corev1.PodList) []dfv1alpha1.PodStatus {
+	probeResults.Lock()
+	defer probeResults.Unlock()
+
+	results := probeResults.results[instance]
+	statuses := make([]dfv1alpha1.PodStatus, 0, len(pods.Items))
+	seen := make(map[types.UID]bool, len(pods.Items))
+	for _, pod := range pods.Items {
+		seen[pod.UID] = true
+		status := dfv1alpha1.PodStatus{
+			Name: pod.Name,
+			Role: pod.Labels[resources.Role],
+		}
+
+		result, ok := results[pod.UID]
+		switch {
+		case pod.Status.Phase != corev1.PodRunning || !isPodReady(&pod):
+			status.Error = "pod is not ready"
+		case !ok:
+			status.Error = "pod was not probed yet"
+		case result.err != nil:
+			status.Error = result.err.Error()
+		default:
+			status.Healthy = true
+		}
+
+		if ok {
+			status.LatencyMilliseconds = result.latency.Milliseconds()
+			status.LastProbeTime = &metav1.Time{Time: result.time}
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	// forget the results of the deleted pods of the instance
+	for uid := range results {
+		if !seen[uid] {
+			delete(results, uid)
+		}
+	}
+
+	return statuses
+}
+
+// setPodStatuses sets the pod statuses of the Dragonfly object, and
+// returns if they changed. The latency and probe time of pods whose health
+// didn't change are only refreshed every probeStatusRefreshInterval.
+func setPodStatuses(df *dfv1alpha1.Dragonfly, statuses []dfv1alpha1.PodStatus) bool {
+	existing := make(map[string]dfv1alpha1.PodStatus, len(df.Status.Pods))
+	for _, status := range df.Status.Pods {
+		existing[status.Name] = status
+	}
+
+	changed := len(statuses) != len(df.Status.Pods)
+	for _, status := range statuses {
+		old, ok := existing[status.Name]
+		if !ok || old.Role != status.Role || old.Healthy != status.Healthy || old.Error != status.Error {
+			changed = true
+			break
+		}
+
+		if status.LastProbeTime != nil && (old.LastProbeTime == nil || status.LastProbeTime.Sub(old.LastProbeTime.Time) > probeStatusRefreshInterval) {
+			changed = true
+			break
+		}
+	}
+
+	if changed {
+		df.Status.Pods = statuses
+	}
+
+	return changed
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestGetPodStatusesKeepsTheResultsOfOtherInstances(t *testing.T) {
+	a := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "a-0", Namespace: "default", UID: "a-0"}}
+	b := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "b-0", Namespace: "default", UID: "b-0"}}
+	recordProbe(&a, time.Millisecond, nil)
+	recordProbe(&b, time.Millisecond, nil)
+
+	instanceA := types.NamespacedName{Namespace: "default", Name: "a"}
+	instanceB := types.NamespacedName{Namespace: "default", Name: "b"}
+	defer forgetProbeResults(instanceA)
+	defer forgetProbeResults(instanceB)
+
+	getPodStatuses(instanceA, &corev1.PodList{Items: []corev1.Pod{a}})
+
+	statuses := getPodStatuses(instanceB, &corev1.PodList{Items: []corev1.Pod{b}})
+	if len(statuses) != 1 || statuses[0].LastProbeTime == nil {
+		t.Errorf("statuses of b = %v, want the result of its probe", statuses)
+	}
+
+	// the pods of an instance that are gone are forgotten
+	getPodStatuses(instanceA, &corev1.PodList{})
+	statuses = getPodStatuses(instanceA, &corev1.PodList{Items: []corev1.Pod{a}})
+	if statuses[0].LastProbeTime != nil {
+		t.Errorf("status of a = %v, want no probe result once the pod was gone", statuses[0])
+	}
+}
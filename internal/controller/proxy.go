@@ -0,0 +1,91 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reconcileProxy creates or updates the proxies of the given instance, or
+// deletes them once the proxy tier is disabled. The proxies follow the
+// master through the headless Service of the master.
+func (r *DragonflyReconciler) reconcileProxy(ctx context.Context, df *dfv1alpha1.Dragonfly) error {
+	if df.Spec.Proxy == nil {
+		return deleteProxy(ctx, r.Client, df)
+	}
+
+	objects, err := resources.GetProxyResources(df)
+	if err != nil {
+		return err
+	}
+
+	for _, object := range objects {
+		if err := createOrUpdateObject(ctx, r.Client, object); err != nil {
+			return fmt.Errorf("could not update the proxy %T: %w", object, err)
+		}
+	}
+
+	return nil
+}
+
+// deleteProxy deletes the proxies of the given instance, if they exist
+func deleteProxy(ctx context.Context, c client.Client, df *dfv1alpha1.Dragonfly) error {
+	key := client.ObjectKey{Namespace: df.Namespace, Name: resources.GetProxyName(df)}
+
+	var deployment appsv1.Deployment
+	if err := c.Get(ctx, key, &deployment); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	objectMeta := metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}
+	for _, object := range []client.Object{
+		&deployment,
+		&corev1.Service{ObjectMeta: objectMeta},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: resources.GetProxyUpstreamName(df)}},
+		&corev1.ConfigMap{ObjectMeta: objectMeta},
+	} {
+		if err := client.IgnoreNotFound(c.Delete(ctx, object)); err != nil {
+			return fmt.Errorf("could not delete the proxy %T: %w", object, err)
+		}
+	}
+
+	return nil
+}
+
+// createOrUpdateObject creates the given object, or updates it with the
+// resource version of the existing one
+func createOrUpdateObject(ctx context.Context, c client.Client, object client.Object) error {
+	existing := object.DeepCopyObject().(client.Object)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(object), existing); err != nil {
+		if apierrors.IsNotFound(err) {
+			return c.Create(ctx, object)
+		}
+		return err
+	}
+
+	object.SetResourceVersion(existing.GetResourceVersion())
+	return c.Update(ctx, object)
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// RateLimiterOptions are the parameters of the rate limiter of the work
+// queues of the controllers. Failing reconciles of an object are retried
+// with an exponential backoff from BaseDelay to MaxDelay, and all the
+// requeues are limited to QPS, with bursts of up to BucketSize. The pod
+// events of each instance are limited to InstanceQPS, with bursts of up to
+// InstanceBucketSize, so that no instance monopolizes the workers.
+type RateLimiterOptions struct {
+	BaseDelay          time.Duration
+	MaxDelay           time.Duration
+	QPS                float64
+	BucketSize         int
+	InstanceQPS        float64
+	InstanceBucketSize int
+}
+
+// DefaultRateLimiterOptions are the parameters of the default rate
+// limiter of the work queues
+var DefaultRateLimiterOptions = RateLimiterOptions{
+	BaseDelay:          5 * time.Millisecond,
+	MaxDelay:           1000 * time.Second,
+	QPS:                10,
+	BucketSize:         100,
+	InstanceQPS:        2,
+	InstanceBucketSize: 10,
+}
+
+// newRateLimiter returns a rate limiter with the given options, or nil
+// to use the default rate limiter of controller-runtime
+func newRateLimiter(opts *RateLimiterOptions) workqueue.RateLimiter {
+	if opts == nil {
+		return nil
+	}
+
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(opts.BaseDelay, opts.MaxDelay),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(opts.QPS), opts.BucketSize)},
+	)
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dragonflydb/dragonfly-operator/internal/budget"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+)
+
+// RemoteClusterOptions configure the controllers of a remote cluster like
+// those of the cluster of the operator
+type RemoteClusterOptions struct {
+	EventRecorder record.EventRecorder
+
+	// Shard is the share of the Dragonfly objects that is managed
+	Shard Shard
+
+	// RateLimiterOptions tune the rate limiters of the work queues
+	RateLimiterOptions *RateLimiterOptions
+
+	// MemoryBudgets limit the aggregate memory of the instances of the
+	// remote cluster
+	MemoryBudgets []budget.MemoryBudget
+
+	// CheckCapacity keeps new instances pending while their pods don't
+	// fit in the free memory of the nodes of the remote cluster
+	CheckCapacity bool
+
+	// StuckPhaseThreshold is the time after which an instance is
+	// considered stuck
+	StuckPhaseThreshold time.Duration
+
+	// RoleLabelGCInterval is how often the stale role labels are removed
+	RoleLabelGCInterval time.Duration
+}
+
+// SetupRemoteCluster sets up the controllers and the background checks of
+// the Dragonfly objects of a remote cluster, with the same wiring as those
+// of the cluster of the operator, so that a single operator can manage a
+// fleet of clusters.
+//
+// The operator connects to the Dragonfly pods directly to configure
+// replication, so the pod IPs of the remote cluster must be reachable
+// from the cluster the operator runs in.
+func SetupRemoteCluster(mgr ctrl.Manager, name string, cl cluster.Cluster, options RemoteClusterOptions) error {
+	if err := mgr.Add(cl); err != nil {
+		return err
+	}
+
+	// the defaults of the DragonflyClasses of the remote cluster are
+	// applied to its Dragonfly objects
+	dfClient := NewClassClient(cl.GetClient())
+
+	if err := (&DragonflyReconciler{
+		Client:             dfClient,
+		Scheme:             cl.GetScheme(),
+		EventRecorder:      options.EventRecorder,
+		MemoryBudgets:      options.MemoryBudgets,
+		CheckCapacity:      options.CheckCapacity,
+		Shard:              options.Shard,
+		RateLimiterOptions: options.RateLimiterOptions,
+	}).setupWithCluster(mgr, cl, fmt.Sprintf("dragonfly-%s", name)); err != nil {
+		return err
+	}
+
+	if err := (&DfPodLifeCycleReconciler{
+		Client:             dfClient,
+		Scheme:             cl.GetScheme(),
+		EventRecorder:      options.EventRecorder,
+		Shard:              options.Shard,
+		RateLimiterOptions: options.RateLimiterOptions,
+	}).setupWithCluster(mgr, cl, fmt.Sprintf("pod-%s", name)); err != nil {
+		return err
+	}
+
+	if err := (&DragonflyMaintenanceReconciler{
+		Client:             dfClient,
+		Scheme:             cl.GetScheme(),
+		EventRecorder:      options.EventRecorder,
+		Shard:              options.Shard,
+		RateLimiterOptions: options.RateLimiterOptions,
+	}).setupWithCluster(mgr, cl, fmt.Sprintf("dragonflymaintenance-%s", name)); err != nil {
+		return err
+	}
+
+	if err := mgr.Add(&StuckPhaseDetector{
+		Client:        dfClient,
+		EventRecorder: options.EventRecorder,
+		Threshold:     options.StuckPhaseThreshold,
+		Shard:         options.Shard,
+	}); err != nil {
+		return err
+	}
+
+	if err := mgr.Add(&RoleLabelCollector{
+		Client:   dfClient,
+		Interval: options.RoleLabelGCInterval,
+		Shard:    options.Shard,
+	}); err != nil {
+		return err
+	}
+
+	if err := mgr.Add(&SnapshotVerifier{
+		Client:        dfClient,
+		EventRecorder: options.EventRecorder,
+		Shard:         options.Shard,
+	}); err != nil {
+		return err
+	}
+
+	if err := mgr.Add(&RestoreVerifier{
+		Client:        dfClient,
+		EventRecorder: options.EventRecorder,
+		Shard:         options.Shard,
+	}); err != nil {
+		return err
+	}
+
+	return mgr.Add(&BackupScheduler{
+		Client:        dfClient,
+		EventRecorder: options.EventRecorder,
+		Shard:         options.Shard,
+	})
+}
@@ -0,0 +1,518 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// replicationLinkCheckInterval is how often the links are checked, as
+	// the instances in remote clusters aren't watched
+	replicationLinkCheckInterval = 30 * time.Second
+
+	LinkRolePrimary = "primary"
+	LinkRoleStandby = "standby"
+
+	LinkStatusUp   = "up"
+	LinkStatusDown = "down"
+)
+
+// isStandby returns if the instance is a standby of a replication link
+func isStandby(df *dfv1alpha1.Dragonfly) bool {
+	return df.Annotations[resources.StandbyOfAnnotation] != ""
+}
+
+// linkedInstanceName returns the name of the linked instance as used by
+// the promote annotation, i.e cluster/name for remote clusters
+func linkedInstanceName(instance dfv1alpha1.LinkedInstance) string {
+	if instance.Cluster == "" {
+		return instance.Name
+	}
+
+	return fmt.Sprintf("%s/%s", instance.Cluster, instance.Name)
+}
+
+// DragonflyReplicationLinkReconciler configures the standbys of
+// DragonflyReplicationLinks as replicas of their primary
+type DragonflyReplicationLinkReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	EventRecorder record.EventRecorder
+
+	// Clusters are the clients of the remote clusters by name
+	Clusters map[string]client.Client
+
+	// RateLimiterOptions tune the rate limiter of the work queue, the
+	// default rate limiter is used if nil
+	RateLimiterOptions *RateLimiterOptions
+}
+
+//+kubebuilder:rbac:groups=dragonflydb.io,resources=dragonflyreplicationlinks,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=dragonflydb.io,resources=dragonflyreplicationlinks/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=dragonflydb.io,resources=dragonflyreplicationlinks/finalizers,verbs=update
+
+// Reconcile configures the pods of the standbys of the link as replicas of
+// the primary, and handles the promotion of a standby
+func (r *DragonflyReplicationLinkReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	var link dfv1alpha1.DragonflyReplicationLink
+	if err := r.Get(ctx, req.NamespacedName, &link); err != nil {
+		log.Info(fmt.Sprintf("could not get the DragonflyReplicationLink object: %s", req.Name))
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if link.DeletionTimestamp != nil {
+		return ctrl.Result{}, r.deleteLink(ctx, &link)
+	}
+
+	if !controllerutil.ContainsFinalizer(&link, resources.ReplicationLinkFinalizer) {
+		controllerutil.AddFinalizer(&link, resources.ReplicationLinkFinalizer)
+		if err := r.Update(ctx, &link); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if request := link.Annotations[resources.PromoteAnnotation]; request != "" && request != link.Status.PromoteRequest {
+		log.Info("Promoting standby", "standby", request)
+		return ctrl.Result{Requeue: true}, r.promote(ctx, &link, request)
+	}
+
+	log.Info("Reconciling DragonflyReplicationLink object")
+	status := dfv1alpha1.DragonflyReplicationLinkStatus{
+		PromoteRequest: link.Status.PromoteRequest,
+	}
+
+	linkStatus, err := r.reconcileStandbys(ctx, &link, &status)
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	// the primary reports the state of the link as well
+	if primaryClient, primary, err := r.getInstance(ctx, &link, link.Spec.Primary); err == nil {
+		if err := setReplicationLinkStatus(ctx, primaryClient, primary, "", link.Name, LinkRolePrimary, linkStatus); err != nil {
+			log.Error(err, "could not update the replication link status of the primary")
+		}
+	}
+
+	r.recordLinkStatusChanges(&link, &status)
+	link.Status = status
+	if err := r.Status().Update(ctx, &link); err != nil {
+		log.Error(err, "could not update the DragonflyReplicationLink status")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: withJitter(replicationLinkCheckInterval)}, nil
+}
+
+// reconcileStandbys configures the standbys of the link and records their
+// state in the given status. It returns if all of them are in sync.
+func (r *DragonflyReplicationLinkReconciler) reconcileStandbys(ctx context.Context, link *dfv1alpha1.DragonflyReplicationLink, status *dfv1alpha1.DragonflyReplicationLinkStatus) (string, error) {
+	for _, standby := range link.Spec.Standbys {
+		status.Standbys = append(status.Standbys, dfv1alpha1.LinkedInstanceStatus{
+			Name:       standby.Name,
+			Cluster:    standby.Cluster,
+			LinkStatus: LinkStatusDown,
+		})
+	}
+
+	primaryClient, primary, err := r.getInstance(ctx, link, link.Spec.Primary)
+	if err != nil {
+		return LinkStatusDown, fmt.Errorf("could not get the primary: %w", err)
+	}
+
+	if link.Spec.TLS != (primary.Spec.ReplicationTLS != nil) {
+		return LinkStatusDown, fmt.Errorf("tls of the link requires replicationTLS on the primary, and the other way around")
+	}
+
+	host, port, err := r.getEndpoint(ctx, link, primaryClient, primary)
+	if err != nil {
+		return LinkStatusDown, err
+	}
+	status.Endpoint = net.JoinHostPort(host, strconv.Itoa(int(port)))
+
+	var password string
+	if link.Spec.PasswordFromSecret != nil {
+		var secret corev1.Secret
+		if err := r.Get(ctx, types.NamespacedName{Namespace: link.Namespace, Name: link.Spec.PasswordFromSecret.Name}, &secret); err != nil {
+			return LinkStatusDown, fmt.Errorf("could not get the password secret: %w", err)
+		}
+		password = string(secret.Data[link.Spec.PasswordFromSecret.Key])
+	}
+
+	linkStatus := LinkStatusUp
+	for i, standby := range link.Spec.Standbys {
+		standbyStatus := &status.Standbys[i]
+		synced, err := r.reconcileStandby(ctx, link, standby, host, port, password)
+		standbyStatus.SyncedPods = synced
+		if err != nil {
+			standbyStatus.Error = err.Error()
+			linkStatus = LinkStatusDown
+			continue
+		}
+
+		standbyStatus.LinkStatus = LinkStatusUp
+	}
+
+	return linkStatus, nil
+}
+
+// reconcileStandby configures the pods of the standby as replicas of the
+// given endpoint, and returns how many of them are in sync
+func (r *DragonflyReplicationLinkReconciler) reconcileStandby(ctx context.Context, link *dfv1alpha1.DragonflyReplicationLink, standby dfv1alpha1.LinkedInstance, host string, port int32, password string) (int32, error) {
+	c, df, err := r.getInstance(ctx, link, standby)
+	if err != nil {
+		return 0, err
+	}
+
+	if df.Status.Phase == "" || df.Status.Phase == PhasePending {
+		return 0, fmt.Errorf("resources of the standby aren't created yet")
+	}
+
+	if link.Spec.TLS && df.Spec.ReplicationTLS == nil {
+		return 0, fmt.Errorf("tls of the link requires replicationTLS on the standby")
+	}
+
+	// the pod lifecycle controller leaves the replication of standbys to
+	// the link
+	if df.Annotations[resources.StandbyOfAnnotation] != link.Name {
+		patch := client.MergeFrom(df.DeepCopy())
+		if df.Annotations == nil {
+			df.Annotations = make(map[string]string)
+		}
+		df.Annotations[resources.StandbyOfAnnotation] = link.Name
+		if err := c.Patch(ctx, df, patch); err != nil {
+			return 0, err
+		}
+	}
+
+	var pods corev1.PodList
+	if err := c.List(ctx, &pods, client.InNamespace(df.Namespace), client.MatchingLabels{
+		"app":                              df.Name,
+		resources.KubernetesPartOfLabelKey: "dragonfly",
+	}); err != nil {
+		return 0, err
+	}
+
+	var synced int32
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.PodIP == "" || pod.Status.Phase != corev1.PodRunning || !pod.Status.ContainerStatuses[0].Ready || pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		up, err := replicateFromEndpoint(ctx, c, pod, host, port, password)
+		if err != nil {
+			return synced, fmt.Errorf("could not configure pod %s as a replica of the primary: %w", pod.Name, err)
+		}
+
+		if up {
+			synced++
+		}
+	}
+
+	linkStatus := LinkStatusDown
+	if synced == df.Spec.Replicas {
+		linkStatus = LinkStatusUp
+	}
+
+	if err := setReplicationLinkStatus(ctx, c, df, PhaseStandby, link.Name, LinkRoleStandby, linkStatus); err != nil {
+		return synced, err
+	}
+
+	if linkStatus != LinkStatusUp {
+		return synced, fmt.Errorf("%d/%d pods are in sync with the primary", synced, df.Spec.Replicas)
+	}
+
+	return synced, nil
+}
+
+// replicateFromEndpoint configures the pod as a replica of the given
+// endpoint, unless it already is. It returns if the pod is in sync.
+func replicateFromEndpoint(ctx context.Context, c client.Client, pod *corev1.Pod, host string, port int32, password string) (bool, error) {
+	info, err := fetchInfo(ctx, pod, "replication")
+	if err != nil {
+		return false, err
+	}
+
+	if info["role"] != resources.Replica || info["master_host"] != host || info["master_port"] != strconv.Itoa(int(port)) {
+		redisClient := newAdminClient(ctx, pod)
+		defer redisClient.Close()
+
+		if password != "" {
+			if err := redisClient.ConfigSet(ctx, "masterauth", password).Err(); err != nil {
+				return false, err
+			}
+		}
+
+		if err := redisClient.SlaveOf(ctx, host, strconv.Itoa(int(port))).Err(); err != nil {
+			return false, err
+		}
+	}
+
+	if pod.Labels[resources.Role] != resources.Replica || pod.Labels[resources.MasterIp] != host {
+		pod.Labels[resources.Role] = resources.Replica
+		pod.Labels[resources.MasterIp] = host
+		if err := c.Update(ctx, pod); err != nil {
+			return false, err
+		}
+	}
+
+	return info["master_link_status"] == "up", nil
+}
+
+// setReplicationLinkStatus records the state of the link in the status
+// of the instance, along with the given phase unless it is empty
+func setReplicationLinkStatus(ctx context.Context, c client.Client, df *dfv1alpha1.Dragonfly, phase, name, role, linkStatus string) error {
+	patch := client.MergeFrom(df.DeepCopy())
+	if phase != "" {
+		setPhase(df, phase)
+	}
+	df.Status.ReplicationLink = &dfv1alpha1.ReplicationLinkStatus{
+		Name:           name,
+		Role:           role,
+		LinkStatus:     linkStatus,
+		LastUpdateTime: metav1.Now(),
+	}
+
+	return c.Status().Patch(ctx, df, patch)
+}
+
+// getEndpoint returns the host and port that the standbys replicate from
+func (r *DragonflyReplicationLinkReconciler) getEndpoint(ctx context.Context, link *dfv1alpha1.DragonflyReplicationLink, c client.Client, primary *dfv1alpha1.Dragonfly) (string, int32, error) {
+	if link.Spec.Endpoint != nil {
+		port := link.Spec.Endpoint.Port
+		if port == 0 {
+			port = resources.DragonflyAdminPort
+		}
+
+		return link.Spec.Endpoint.Host, port, nil
+	}
+
+	master, err := getMasterPod(ctx, c, primary)
+	if err != nil {
+		return "", 0, fmt.Errorf("could not get the master of the primary: %w", err)
+	}
+
+	return master.Status.PodIP, resources.DragonflyAdminPort, nil
+}
+
+// getInstance returns the client of the cluster of the linked instance,
+// and its Dragonfly object
+func (r *DragonflyReplicationLinkReconciler) getInstance(ctx context.Context, link *dfv1alpha1.DragonflyReplicationLink, instance dfv1alpha1.LinkedInstance) (client.Client, *dfv1alpha1.Dragonfly, error) {
+	c := r.Client
+	if instance.Cluster != "" {
+		var ok bool
+		if c, ok = r.Clusters[instance.Cluster]; !ok {
+			return nil, nil, fmt.Errorf("unknown cluster %s", instance.Cluster)
+		}
+	}
+
+	var df dfv1alpha1.Dragonfly
+	if err := c.Get(ctx, types.NamespacedName{Namespace: link.Namespace, Name: instance.Name}, &df); err != nil {
+		return nil, nil, err
+	}
+
+	return c, &df, nil
+}
+
+// promote releases the requested standby, so that it elects a master of
+// its own, and makes it the primary of the link. The previous primary
+// becomes a standby of it.
+func (r *DragonflyReplicationLinkReconciler) promote(ctx context.Context, link *dfv1alpha1.DragonflyReplicationLink, request string) error {
+	index := -1
+	for i, standby := range link.Spec.Standbys {
+		if linkedInstanceName(standby) == request {
+			index = i
+		}
+	}
+
+	if index < 0 {
+		r.EventRecorder.Event(link, corev1.EventTypeWarning, "Promotion", fmt.Sprintf("Unknown standby %s", request))
+		link.Status.PromoteRequest = request
+		return r.Status().Update(ctx, link)
+	}
+
+	c, df, err := r.getInstance(ctx, link, link.Spec.Standbys[index])
+	if err != nil {
+		return err
+	}
+
+	if err := releaseStandby(ctx, c, df); err != nil {
+		return err
+	}
+
+	// the endpoint pointed to the previous primary
+	link.Spec.Primary, link.Spec.Standbys[index] = link.Spec.Standbys[index], link.Spec.Primary
+	link.Spec.Endpoint = nil
+	if err := r.Update(ctx, link); err != nil {
+		return err
+	}
+
+	r.EventRecorder.Event(link, corev1.EventTypeNormal, "Promotion", fmt.Sprintf("Promoted standby %s to primary", request))
+	link.Status.PromoteRequest = request
+	return r.Status().Update(ctx, link)
+}
+
+// releaseStandby detaches the standby from the link, so that the pod
+// lifecycle controller elects a master among its pods
+func releaseStandby(ctx context.Context, c client.Client, df *dfv1alpha1.Dragonfly) error {
+	if isStandby(df) {
+		patch := client.MergeFrom(df.DeepCopy())
+		delete(df.Annotations, resources.StandbyOfAnnotation)
+		if err := c.Patch(ctx, df, patch); err != nil {
+			return err
+		}
+	}
+
+	if df.Status.Phase == PhaseStandby {
+		patch := client.MergeFrom(df.DeepCopy())
+		setPhase(df, PhaseReady)
+		df.Status.ReplicationLink = nil
+		if err := c.Status().Patch(ctx, df, patch); err != nil {
+			return err
+		}
+	}
+
+	// the pods without a role are configured again
+	var pods corev1.PodList
+	if err := c.List(ctx, &pods, client.InNamespace(df.Namespace), client.MatchingLabels{
+		"app":                              df.Name,
+		resources.KubernetesPartOfLabelKey: "dragonfly",
+	}); err != nil {
+		return err
+	}
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if _, ok := pod.Labels[resources.Role]; !ok {
+			continue
+		}
+
+		delete(pod.Labels, resources.Role)
+		delete(pod.Labels, resources.MasterIp)
+		if err := c.Update(ctx, pod); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteLink releases the standbys of the deleted link
+func (r *DragonflyReplicationLinkReconciler) deleteLink(ctx context.Context, link *dfv1alpha1.DragonflyReplicationLink) error {
+	if !controllerutil.ContainsFinalizer(link, resources.ReplicationLinkFinalizer) {
+		return nil
+	}
+
+	for _, standby := range link.Spec.Standbys {
+		c, df, err := r.getInstance(ctx, link, standby)
+		if err != nil {
+			if client.IgnoreNotFound(err) == nil {
+				continue
+			}
+			return err
+		}
+
+		if df.Annotations[resources.StandbyOfAnnotation] != link.Name {
+			continue
+		}
+
+		if err := releaseStandby(ctx, c, df); err != nil {
+			return err
+		}
+		r.EventRecorder.Event(link, corev1.EventTypeNormal, "Promotion", fmt.Sprintf("Released standby %s", linkedInstanceName(standby)))
+	}
+
+	controllerutil.RemoveFinalizer(link, resources.ReplicationLinkFinalizer)
+	return r.Update(ctx, link)
+}
+
+// recordLinkStatusChanges records an event for the standbys whose link
+// went up or down
+func (r *DragonflyReplicationLinkReconciler) recordLinkStatusChanges(link *dfv1alpha1.DragonflyReplicationLink, status *dfv1alpha1.DragonflyReplicationLinkStatus) {
+	previous := make(map[string]string, len(link.Status.Standbys))
+	for _, standby := range link.Status.Standbys {
+		previous[linkedInstanceName(dfv1alpha1.LinkedInstance{Name: standby.Name, Cluster: standby.Cluster})] = standby.LinkStatus
+	}
+
+	for _, standby := range status.Standbys {
+		name := linkedInstanceName(dfv1alpha1.LinkedInstance{Name: standby.Name, Cluster: standby.Cluster})
+		if previous[name] == standby.LinkStatus {
+			continue
+		}
+
+		if standby.LinkStatus == LinkStatusUp {
+			r.EventRecorder.Event(link, corev1.EventTypeNormal, "Replication", fmt.Sprintf("Standby %s is in sync with the primary", name))
+		} else if previous[name] == LinkStatusUp {
+			r.EventRecorder.Event(link, corev1.EventTypeWarning, "Replication", fmt.Sprintf("Standby %s is no longer in sync with the primary: %s", name, standby.Error))
+		}
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *DragonflyReplicationLinkReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&dfv1alpha1.DragonflyReplicationLink{}).
+		// Follow the changes of the local instances of the links
+		Watches(&source.Kind{Type: &dfv1alpha1.Dragonfly{}}, handler.EnqueueRequestsFromMapFunc(r.findLinksForDragonfly), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		WithOptions(controller.Options{RateLimiter: newRateLimiter(r.RateLimiterOptions)}).
+		Complete(r)
+}
+
+// findLinksForDragonfly returns the links of the local Dragonfly object
+func (r *DragonflyReplicationLinkReconciler) findLinksForDragonfly(obj client.Object) []reconcile.Request {
+	var links dfv1alpha1.DragonflyReplicationLinkList
+	if err := r.List(context.Background(), &links, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0)
+	for _, link := range links.Items {
+		for _, instance := range append([]dfv1alpha1.LinkedInstance{link.Spec.Primary}, link.Spec.Standbys...) {
+			if instance.Cluster == "" && instance.Name == obj.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&link)})
+				break
+			}
+		}
+	}
+
+	return requests
+}
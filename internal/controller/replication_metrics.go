@@ -0,0 +1,157 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sync/atomic"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var (
+	// failovers is the number of topology changes that switched the
+	// master of the instances
+	failovers = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dragonfly_operator_failovers_total",
+		Help: "Number of topology changes that switched the master of a Dragonfly instance, by operation",
+	}, []string{"namespace", "name", "operation"})
+
+	// failoverDurationSeconds is how long the topology changes that
+	// switched the master of the instances took
+	failoverDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dragonfly_operator_failover_duration_seconds",
+		Help:    "Time from the start of a topology change until another pod was the master of the Dragonfly instance, by operation",
+		Buckets: []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300},
+	}, []string{"operation"})
+
+	// reconcileErrors is the number of failed reconciles of the instances
+	reconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dragonfly_operator_reconcile_errors_total",
+		Help: "Number of reconciles of a Dragonfly instance that failed, by controller",
+	}, []string{"controller", "namespace", "name"})
+
+	// masters is 1 for the master pod of each instance
+	masters = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dragonfly_operator_master_info",
+		Help: "The master pod of a Dragonfly instance, with a value of 1",
+	}, []string{"namespace", "name", "pod"})
+
+	// replicaSyncFailures is the number of SLAVE OF commands that failed
+	// on the pods of the instances
+	replicaSyncFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dragonfly_operator_replica_sync_failures_total",
+		Help: "Number of SLAVE OF commands that failed on the pods of a Dragonfly instance",
+	}, []string{"namespace", "name"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(failovers, failoverDurationSeconds, reconcileErrors, masters, replicaSyncFailures)
+}
+
+// recordTopologyTransition records the completed topology change of the
+// instance in the failover metrics, if it switched the master
+func recordTopologyTransition(df *dfv1alpha1.Dragonfly, transition dfv1alpha1.TopologyTransition) {
+	if transition.Error != "" || transition.NewMaster == "" || transition.NewMaster == transition.OldMaster {
+		return
+	}
+
+	failovers.WithLabelValues(df.Namespace, df.Name, transition.Operation).Inc()
+	failoverDurationSeconds.WithLabelValues(transition.Operation).Observe(transition.Duration.Seconds())
+	recordMaster(df, transition.NewMaster)
+}
+
+// recordReconcileError records a failed reconcile of the instance by the
+// given controller
+func recordReconcileError(controller string, key types.NamespacedName) {
+	reconcileErrors.WithLabelValues(controller, key.Namespace, key.Name).Inc()
+}
+
+// reconcileErrorRecorder returns the given reconciler of the named
+// controller, recording its failed reconciles of the instances returned
+// by the given function. Reconciles fail if they return an error, or if
+// they log one and retry later instead.
+func reconcileErrorRecorder(controller string, instance func(types.NamespacedName) types.NamespacedName, r reconcile.Reconciler) reconcile.Reconciler {
+	return reconcile.Func(func(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+		logger := log.FromContext(ctx)
+		sink := &errorSink{LogSink: logger.GetSink(), failed: &atomic.Bool{}}
+		result, err := r.Reconcile(log.IntoContext(ctx, logger.WithSink(sink)), request)
+		if err != nil || sink.failed.Load() {
+			recordReconcileError(controller, instance(request.NamespacedName))
+		}
+
+		return result, err
+	})
+}
+
+// errorSink is a log sink that records if an error was logged through it
+// or through the sinks derived from it
+type errorSink struct {
+	logr.LogSink
+	failed *atomic.Bool
+}
+
+func (s *errorSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	s.failed.Store(true)
+	s.LogSink.Error(err, msg, keysAndValues...)
+}
+
+func (s *errorSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return &errorSink{LogSink: s.LogSink.WithValues(keysAndValues...), failed: s.failed}
+}
+
+func (s *errorSink) WithName(name string) logr.LogSink {
+	return &errorSink{LogSink: s.LogSink.WithName(name), failed: s.failed}
+}
+
+func (s *errorSink) WithCallDepth(depth int) logr.LogSink {
+	if sink, ok := s.LogSink.(logr.CallDepthLogSink); ok {
+		return &errorSink{LogSink: sink.WithCallDepth(depth), failed: s.failed}
+	}
+
+	return s
+}
+
+// recordMaster records the master pod of the instance, none if empty
+func recordMaster(df *dfv1alpha1.Dragonfly, pod string) {
+	masters.DeletePartialMatch(prometheus.Labels{"namespace": df.Namespace, "name": df.Name})
+	if pod != "" {
+		masters.WithLabelValues(df.Namespace, df.Name, pod).Set(1)
+	}
+}
+
+// recordReplicaSyncFailure records a failed SLAVE OF command on a pod of
+// the instance
+func recordReplicaSyncFailure(df *dfv1alpha1.Dragonfly) {
+	replicaSyncFailures.WithLabelValues(df.Namespace, df.Name).Inc()
+}
+
+// forgetInstanceMetrics deletes the metrics of the deleted instance
+func forgetInstanceMetrics(key types.NamespacedName) {
+	labels := prometheus.Labels{"namespace": key.Namespace, "name": key.Name}
+	failovers.DeletePartialMatch(labels)
+	reconcileErrors.DeletePartialMatch(labels)
+	masters.DeletePartialMatch(labels)
+	replicaSyncFailures.DeletePartialMatch(labels)
+	queueWaitSeconds.DeletePartialMatch(labels)
+}
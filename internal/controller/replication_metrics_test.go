@@ -0,0 +1,111 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileErrorRecorder(t *testing.T) {
+	tests := []struct {
+		name      string
+		reconcile func(ctx context.Context) error
+		want      float64
+	}{
+		{
+			name:      "successful reconcile",
+			reconcile: func(ctx context.Context) error { return nil },
+			want:      0,
+		},
+		{
+			name:      "returned error",
+			reconcile: func(ctx context.Context) error { return errors.New("failed") },
+			want:      1,
+		},
+		{
+			name: "logged error",
+			reconcile: func(ctx context.Context) error {
+				log.FromContext(ctx).Error(errors.New("failed"), "could not reconcile")
+				return nil
+			},
+			want: 1,
+		},
+		{
+			name: "error logged with a derived logger",
+			reconcile: func(ctx context.Context) error {
+				log.FromContext(ctx).WithName("replication").WithValues("pod", "df-0").Error(errors.New("failed"), "could not reconcile")
+				return nil
+			},
+			want: 1,
+		},
+		{
+			name: "logged and returned error",
+			reconcile: func(ctx context.Context) error {
+				err := errors.New("failed")
+				log.FromContext(ctx).Error(err, "could not reconcile")
+				return err
+			},
+			want: 1,
+		},
+		{
+			name: "info logs",
+			reconcile: func(ctx context.Context) error {
+				log.FromContext(ctx).Info("reconciling")
+				return nil
+			},
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := podRequest("df-0")
+			key := podInstance(request.NamespacedName)
+			forgetInstanceMetrics(key)
+
+			r := reconcileErrorRecorder("test", podInstance, reconcile.Func(func(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+				return reconcile.Result{}, tt.reconcile(ctx)
+			}))
+			_, _ = r.Reconcile(context.Background(), request)
+
+			if got := testutil.ToFloat64(reconcileErrors.WithLabelValues("test", key.Namespace, key.Name)); got != tt.want {
+				t.Errorf("reconcile errors = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForgetInstanceMetrics(t *testing.T) {
+	key := podInstance(podRequest("df-0").NamespacedName)
+	queueWaitSeconds.WithLabelValues("test", key.Namespace, key.Name).Observe(1)
+	reconcileErrors.WithLabelValues("test", key.Namespace, key.Name).Inc()
+
+	forgetInstanceMetrics(key)
+
+	if queueWaitSeconds.DeleteLabelValues("test", key.Namespace, key.Name) {
+		t.Error("the queue wait of the deleted instance wasn't forgotten")
+	}
+	if reconcileErrors.DeleteLabelValues("test", key.Namespace, key.Name) {
+		t.Error("the reconcile errors of the deleted instance weren't forgotten")
+	}
+}
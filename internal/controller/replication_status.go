@@ -0,0 +1,102 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strconv"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// getReplicaStatuses returns the replication state of each of the given
+// replicas, from the replication info of the master and of the replicas.
+// Replicas without info, e.g because they couldn't be probed, aren't
+// connected.
+func getReplicaStatuses(replicas []*corev1.Pod, masterInfo map[string]string, infos map[string]map[string]string) []dfv1alpha1.ReplicaStatus {
+	masterOffset, masterOffsetErr := strconv.ParseInt(masterInfo["master_repl_offset"], 10, 64)
+
+	statuses := make([]dfv1alpha1.ReplicaStatus, 0, len(replicas))
+	for _, replica := range replicas {
+		status := dfv1alpha1.ReplicaStatus{Name: replica.Name}
+
+		info, ok := infos[replica.Name]
+		if ok {
+			status.Connected = info["master_link_status"] == "up"
+
+			// older Dragonfly versions don't report offsets
+			if replicaOffset, err := strconv.ParseInt(info["slave_repl_offset"], 10, 64); err == nil && masterOffsetErr == nil {
+				lag := masterOffset - replicaOffset
+				if lag < 0 {
+					lag = 0
+				}
+				status.Lag = &lag
+			}
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// setReplicationStatus sets the master and the replica states of the
+// Dragonfly object, and returns if they changed. Changes of the lags alone
+// are only written if refresh is true, so that they don't update the
+// status on every reconcile.
+func setReplicationStatus(df *dfv1alpha1.Dragonfly, master string, statuses []dfv1alpha1.ReplicaStatus, refresh bool) bool {
+	var connected int32
+	var maxLag *int64
+	for _, status := range statuses {
+		if status.Connected {
+			connected++
+		}
+
+		if status.Lag != nil && (maxLag == nil || *status.Lag > *maxLag) {
+			lag := *status.Lag
+			maxLag = &lag
+		}
+	}
+
+	existing := make(map[string]dfv1alpha1.ReplicaStatus, len(df.Status.Replicas))
+	for _, status := range df.Status.Replicas {
+		existing[status.Name] = status
+	}
+
+	changed := df.Status.Master != master || df.Status.ConnectedReplicas != connected || len(statuses) != len(df.Status.Replicas)
+	for _, status := range statuses {
+		old, ok := existing[status.Name]
+		if !ok || old.Connected != status.Connected || (old.Lag == nil) != (status.Lag == nil) {
+			changed = true
+			break
+		}
+
+		if refresh && status.Lag != nil && *old.Lag != *status.Lag {
+			changed = true
+			break
+		}
+	}
+
+	if changed {
+		df.Status.Master = master
+		df.Status.ConnectedReplicas = connected
+		df.Status.ReplicationLag = maxLag
+		df.Status.Replicas = statuses
+	}
+
+	return changed
+}
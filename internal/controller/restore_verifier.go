@@ -0,0 +1,243 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// restoreVerificationCheckInterval is how often the restore
+	// verifications are started and checked
+	restoreVerificationCheckInterval = 30 * time.Second
+
+	// defaultRestoreVerificationTimeout is the default time after which a
+	// restore verification that didn't complete fails
+	defaultRestoreVerificationTimeout = 10 * time.Minute
+)
+
+// RestoreVerifier periodically loads the snapshot of the master of the
+// instances with a restore verification into a throwaway pod, and
+// compares its number of keys with the master, so that there is
+// confidence that the snapshots can actually be restored.
+type RestoreVerifier struct {
+	client.Client
+	EventRecorder record.EventRecorder
+
+	// Shard is the share of the Dragonfly objects that is verified
+	Shard Shard
+}
+
+// Start runs the verifier until the context is done
+func (v *RestoreVerifier) Start(ctx context.Context) error {
+	ticker := time.NewTicker(restoreVerificationCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := v.verify(ctx); err != nil {
+				log.FromContext(ctx).Error(err, "could not verify restores")
+			}
+		}
+	}
+}
+
+func (v *RestoreVerifier) verify(ctx context.Context) error {
+	log := log.FromContext(ctx)
+
+	var dfs dfv1alpha1.DragonflyList
+	if err := v.List(ctx, &dfs); err != nil {
+		return err
+	}
+
+	for i := range dfs.Items {
+		df := &dfs.Items[i]
+		if !v.Shard.Contains(df.Namespace, df.Name) {
+			continue
+		}
+
+		if df.Spec.Snapshot == nil || df.Spec.Snapshot.RestoreVerification == nil {
+			continue
+		}
+
+		if df.Status.Phase != PhaseReady && df.Status.Phase != PhaseDegraded {
+			continue
+		}
+
+		if err := v.verifyInstance(ctx, df); err != nil {
+			log.Error(err, "could not verify the restore", "dragonfly", client.ObjectKeyFromObject(df))
+		}
+	}
+
+	return nil
+}
+
+// verifyInstance moves the restore verification of the instance forward:
+// it starts one when it is due, and completes the running one once its pod
+// has loaded the snapshot or it timed out
+func (v *RestoreVerifier) verifyInstance(ctx context.Context, df *dfv1alpha1.Dragonfly) error {
+	var pod corev1.Pod
+	err := v.Get(ctx, types.NamespacedName{Namespace: df.Namespace, Name: resources.GetRestoreVerificationPodName(df)}, &pod)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	found := err == nil
+
+	status := df.Status.RestoreVerification
+	running := status != nil && status.CompletionTime == nil
+	if !found {
+		if running {
+			return v.complete(ctx, df, nil, 0, "the verification pod disappeared")
+		}
+
+		if status != nil && time.Since(status.CompletionTime.Time) < df.Spec.Snapshot.RestoreVerification.Interval.Duration {
+			return nil
+		}
+
+		return v.start(ctx, df)
+	}
+
+	if !running {
+		// leftover of a verification that was completed
+		return client.IgnoreNotFound(v.Delete(ctx, &pod))
+	}
+
+	timeout := defaultRestoreVerificationTimeout
+	if df.Spec.Snapshot.RestoreVerification.Timeout != nil {
+		timeout = df.Spec.Snapshot.RestoreVerification.Timeout.Duration
+	}
+
+	if pod.Status.Phase == corev1.PodFailed || pod.Status.Phase == corev1.PodSucceeded {
+		return v.complete(ctx, df, &pod, 0, fmt.Sprintf("the verification pod exited: %s", pod.Status.Message))
+	}
+
+	restoredKeys, err := v.getRestoredKeys(ctx, &pod)
+	if err != nil {
+		if time.Since(status.StartTime.Time) > timeout {
+			return v.complete(ctx, df, &pod, 0, fmt.Sprintf("the snapshot was not loaded within %s: %s", timeout, err))
+		}
+
+		// the snapshot is still being loaded
+		return nil
+	}
+
+	return v.complete(ctx, df, &pod, restoredKeys, "")
+}
+
+// start creates the pod that restores the snapshot of the master of the
+// instance
+func (v *RestoreVerifier) start(ctx context.Context, df *dfv1alpha1.Dragonfly) error {
+	master, err := getMasterPod(ctx, v.Client, df)
+	if err != nil {
+		return err
+	}
+
+	pod, err := resources.GetRestoreVerificationPod(df, master)
+	if err != nil {
+		return err
+	}
+
+	if err := v.Create(ctx, pod); err != nil {
+		return fmt.Errorf("could not create the verification pod: %w", err)
+	}
+
+	patch := client.MergeFrom(df.DeepCopy())
+	df.Status.RestoreVerification = &dfv1alpha1.RestoreVerificationStatus{StartTime: metav1.Now()}
+	return v.Status().Patch(ctx, df, patch)
+}
+
+// complete records the result of the restore verification of the
+// instance, and deletes its pod. A verification without a failure
+// succeeds if the snapshot has keys, or the master has none either.
+func (v *RestoreVerifier) complete(ctx context.Context, df *dfv1alpha1.Dragonfly, pod *corev1.Pod, restoredKeys int64, failure string) error {
+	var masterKeys int64
+	if master, err := getMasterPod(ctx, v.Client, df); err == nil {
+		redisClient := newAdminClient(ctx, master)
+		masterKeys, _ = redisClient.DBSize(ctx).Result()
+		redisClient.Close()
+	}
+
+	if failure == "" && restoredKeys == 0 && masterKeys > 0 {
+		failure = fmt.Sprintf("the restored snapshot has no keys, while the master has %d", masterKeys)
+	}
+
+	now := metav1.Now()
+	patch := client.MergeFrom(df.DeepCopy())
+	df.Status.RestoreVerification = &dfv1alpha1.RestoreVerificationStatus{
+		StartTime:      df.Status.RestoreVerification.StartTime,
+		CompletionTime: &now,
+		Succeeded:      failure == "",
+		RestoredKeys:   restoredKeys,
+		MasterKeys:     masterKeys,
+		Message:        failure,
+	}
+	if failure == "" {
+		df.Status.RestoreVerification.Message = fmt.Sprintf("restored %d keys, the master has %d", restoredKeys, masterKeys)
+	}
+
+	if err := v.Status().Patch(ctx, df, patch); err != nil {
+		return err
+	}
+
+	if failure == "" {
+		v.EventRecorder.Event(df, corev1.EventTypeNormal, "RestoreVerification", fmt.Sprintf("Verified the restore of the snapshot: %s", df.Status.RestoreVerification.Message))
+	} else {
+		v.EventRecorder.Event(df, corev1.EventTypeWarning, "RestoreVerification", fmt.Sprintf("Could not restore the snapshot: %s", failure))
+	}
+
+	if pod == nil {
+		return nil
+	}
+
+	return client.IgnoreNotFound(v.Delete(ctx, pod))
+}
+
+// getRestoredKeys returns the number of keys of the verification pod,
+// once it has loaded the snapshot
+func (v *RestoreVerifier) getRestoredKeys(ctx context.Context, pod *corev1.Pod) (int64, error) {
+	if pod.Status.PodIP == "" || pod.Status.Phase != corev1.PodRunning {
+		return 0, fmt.Errorf("pod is %s", pod.Status.Phase)
+	}
+
+	info, err := queryInfo(ctx, pod, "persistence")
+	if err != nil {
+		return 0, err
+	}
+
+	if info["loading"] == "1" {
+		return 0, fmt.Errorf("snapshot is being loaded")
+	}
+
+	redisClient := newAdminClient(ctx, pod)
+	defer redisClient.Close()
+
+	return redisClient.DBSize(ctx).Result()
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// RoleLabelCollector periodically clears the role labels of pods that are
+// no longer part of the topology of an instance, e.g pods of a deleted or
+// renamed Dragonfly object or leftovers of a scale down, so that the
+// Services don't route to them.
+type RoleLabelCollector struct {
+	client.Client
+
+	// Interval is how often the pods are checked
+	Interval time.Duration
+
+	// Shard is the share of the Dragonfly objects whose pods are checked
+	Shard Shard
+}
+
+// Start runs the collector until the context is done
+func (c *RoleLabelCollector) Start(ctx context.Context) error {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := c.collect(ctx); err != nil {
+				log.FromContext(ctx).Error(err, "could not collect stale role labels")
+			}
+		}
+	}
+}
+
+func (c *RoleLabelCollector) collect(ctx context.Context) error {
+	log := log.FromContext(ctx)
+
+	var pods corev1.PodList
+	if err := c.List(ctx, &pods, client.HasLabels{resources.Role}, client.MatchingLabels{
+		resources.KubernetesAppNameLabelKey: "dragonfly",
+	}); err != nil {
+		return err
+	}
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.DeletionTimestamp != nil || !c.Shard.Contains(pod.Namespace, pod.Labels["app"]) {
+			continue
+		}
+
+		stale, err := c.isStale(ctx, pod)
+		if err != nil {
+			log.Error(err, "could not check the role label of pod", "pod", client.ObjectKeyFromObject(pod))
+			continue
+		}
+
+		if !stale {
+			continue
+		}
+
+		log.Info("Clearing stale role label", "pod", client.ObjectKeyFromObject(pod), "role", pod.Labels[resources.Role])
+		delete(pod.Labels, resources.Role)
+		delete(pod.Labels, resources.MasterIp)
+		if err := c.Update(ctx, pod); err != nil {
+			log.Error(err, "could not clear stale role label", "pod", client.ObjectKeyFromObject(pod))
+		}
+	}
+
+	return nil
+}
+
+// isStale returns if the given pod isn't part of the topology of the
+// Dragonfly instance it's labeled with
+func (c *RoleLabelCollector) isStale(ctx context.Context, pod *corev1.Pod) (bool, error) {
+	var df dfv1alpha1.Dragonfly
+	if err := c.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: pod.Labels["app"]}, &df); err != nil {
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	// the statefulset may be recreated with its pods orphaned, e.g to
+	// migrate the storage class, and the pods are adopted again afterwards
+	var statefulSet appsv1.StatefulSet
+	if err := c.Get(ctx, types.NamespacedName{Namespace: df.Namespace, Name: df.Name}, &statefulSet); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return false, nil
+	}
+
+	if owner.UID != statefulSet.UID {
+		return true, nil
+	}
+
+	ordinal, err := getPodOrdinal(pod)
+	if err != nil {
+		return true, nil
+	}
+
+	return statefulSet.Spec.Replicas != nil && ordinal >= int(*statefulSet.Spec.Replicas), nil
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// saveTimeout is how long a snapshot may take
+	saveTimeout = 10 * time.Minute
+
+	// Reasons of the snapshots saved in the background
+	SaveReasonRequest             = "Request"
+	SaveReasonMajorVersionUpgrade = "MajorVersionUpgrade"
+	SaveReasonBackup              = "Backup"
+)
+
+// isSaveRequested returns if the save request annotation of the
+// instance wasn't handled yet
+func isSaveRequested(df *dfv1alpha1.Dragonfly) bool {
+	request, ok := df.Annotations[resources.SaveRequestAnnotation]
+	return ok && request != df.Status.SaveRequest
+}
+
+// handleSaveRequest starts a BGSAVE on the master of the instance, and
+// records the handled request in the status, whether the BGSAVE started
+// or not. The request can be repeated by changing the annotation again.
+// Requests wait for the snapshot that is already being saved.
+func (r *DragonflyReconciler) handleSaveRequest(ctx context.Context, df *dfv1alpha1.Dragonfly) error {
+	if df.Status.Save != nil {
+		return nil
+	}
+
+	request := df.Annotations[resources.SaveRequestAnnotation]
+	patch := client.MergeFrom(df.DeepCopy())
+
+	master, err := getMasterPod(ctx, r.Client, df)
+	if err != nil {
+		return err
+	}
+
+	if save, err := startSnapshot(ctx, master, SaveReasonRequest); err != nil {
+		r.EventRecorder.Event(df, corev1.EventTypeWarning, "Snapshot", fmt.Sprintf("Requested snapshot of master %s failed: %s", master.Name, err))
+	} else {
+		df.Status.Save = save
+	}
+
+	// patched, so that a conflict doesn't start the save again
+	df.Status.SaveRequest = request
+	return r.Status().Patch(ctx, df, patch)
+}
+
+// checkSave completes the snapshot that the master saves in the
+// background, once it succeeded, failed or timed out
+func (r *DragonflyReconciler) checkSave(ctx context.Context, df *dfv1alpha1.Dragonfly) error {
+	save := df.Status.Save
+	patch := client.MergeFrom(df.DeepCopy())
+
+	var failure error
+	var pod corev1.Pod
+	if err := r.Get(ctx, types.NamespacedName{Namespace: df.Namespace, Name: save.Pod}, &pod); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		failure = fmt.Errorf("the pod disappeared")
+	} else {
+		done, err := getSnapshotResult(ctx, &pod, save.StartTime.Time)
+		if err != nil && !done {
+			return err
+		}
+
+		// the start time is taken from the clock of the pod, which is
+		// close enough for the timeout
+		if !done && time.Since(save.StartTime.Time) < saveTimeout {
+			return nil
+		}
+
+		failure = err
+		if !done {
+			failure = fmt.Errorf("the snapshot was not saved within %s", saveTimeout)
+		}
+	}
+
+	description := "Requested snapshot"
+	if save.Reason == SaveReasonMajorVersionUpgrade {
+		description = "Snapshot in the new version"
+	}
+
+	if failure != nil {
+		r.EventRecorder.Event(df, corev1.EventTypeWarning, "Snapshot", fmt.Sprintf("%s of master %s failed: %s", description, save.Pod, failure))
+	} else {
+		r.EventRecorder.Event(df, corev1.EventTypeNormal, "Snapshot", fmt.Sprintf("%s of master %s saved in %s", description, save.Pod, time.Since(save.StartTime.Time).Round(time.Second)))
+		if save.Reason == SaveReasonRequest {
+			now := metav1.Now()
+			df.Status.LastSaveTime = &now
+		}
+	}
+
+	df.Status.Save = nil
+	return r.Status().Patch(ctx, df, patch)
+}
+
+// startSnapshot starts a BGSAVE on the given pod, and returns the status
+// of the save for the given reason
+func startSnapshot(ctx context.Context, pod *corev1.Pod, reason string) (*dfv1alpha1.SaveStatus, error) {
+	redisClient := newAdminClient(ctx, pod)
+	defer redisClient.Close()
+
+	// the result of the save is reported with the clock of the pod
+	now, err := redisClient.Time(ctx).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := redisClient.Do(ctx, "BGSAVE").Err(); err != nil {
+		return nil, err
+	}
+
+	return &dfv1alpha1.SaveStatus{
+		Reason:    reason,
+		Pod:       pod.Name,
+		StartTime: metav1.NewTime(now.Truncate(time.Second)),
+	}, nil
+}
+
+// getSnapshotResult returns if the snapshot that the given pod started
+// saving at the given time is done, as reported by INFO persistence, and
+// the error it failed with
+func getSnapshotResult(ctx context.Context, pod *corev1.Pod, start time.Time) (bool, error) {
+	info, err := fetchInfo(ctx, pod, "persistence")
+	if err != nil {
+		return false, err
+	}
+
+	if info["saving"] == "1" {
+		return false, nil
+	}
+
+	lastSuccess, _ := strconv.ParseInt(info["last_success_save"], 10, 64)
+	lastFailure, _ := strconv.ParseInt(info["last_failed_save"], 10, 64)
+	switch {
+	case lastFailure >= start.Unix() && lastFailure > lastSuccess:
+		return true, fmt.Errorf("%s", info["last_error"])
+	case lastSuccess >= start.Unix():
+		return true, nil
+	}
+
+	return false, nil
+}
@@ -0,0 +1,526 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// sentinelPollInterval is how often the masters are checked for the
+	// switch-master notifications
+	sentinelPollInterval = time.Second
+
+	// sentinelWriteTimeout is how long a write to a client may take
+	sentinelWriteTimeout = 5 * time.Second
+
+	// switchMasterChannel is the channel of the switch-master notifications
+	switchMasterChannel = "+switch-master"
+
+	// sentinelMaxArgs is the maximum number of arguments of a command
+	sentinelMaxArgs = 64
+
+	// sentinelMaxBulkLength is the maximum length of an argument. The
+	// arguments of the supported commands are names of instances and
+	// channels.
+	sentinelMaxBulkLength = 4096
+)
+
+// SentinelServer serves a subset of the Redis Sentinel protocol, backed by
+// the topology known to the operator, so that Sentinel aware clients can
+// discover the master of an instance without any code changes. The master
+// name of an instance is <namespace>.<name>.
+//
+// The supported commands are SENTINEL get-master-addr-by-name, replicas,
+// slaves and sentinels, SUBSCRIBE to the +switch-master channel, PING and
+// QUIT.
+type SentinelServer struct {
+	client.Client
+
+	// Addr is the address the server listens on
+	Addr string
+
+	mu sync.Mutex
+	// subscribers are the connections subscribed to each channel
+	subscribers map[string]map[*sentinelConn]bool
+	// masters is the last known master address of each master name. It's
+	// kept while an instance has no master, e.g during a failover, so that
+	// the master it switches to is published, and dropped once the
+	// Dragonfly object is deleted.
+	masters map[string]string
+}
+
+// NeedLeaderElection is false, so that all replicas of the operator serve
+// the clients
+func (s *SentinelServer) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves the clients until the context is done
+func (s *SentinelServer) Start(ctx context.Context) error {
+	s.subscribers = make(map[string]map[*sentinelConn]bool)
+	s.masters = make(map[string]string)
+
+	listener, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return fmt.Errorf("could not listen on %s: %w", s.Addr, err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	go s.watchMasters(ctx)
+
+	log.FromContext(ctx).Info("serving the sentinel protocol", "address", s.Addr)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("could not accept connection: %w", err)
+		}
+
+		go s.serve(ctx, &sentinelConn{conn: conn, writer: bufio.NewWriter(conn)})
+	}
+}
+
+// watchMasters publishes a switch-master notification whenever the master
+// of an instance changes
+func (s *SentinelServer) watchMasters(ctx context.Context) {
+	ticker := time.NewTicker(sentinelPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.checkMasters(ctx); err != nil {
+				log.FromContext(ctx).Error(err, "could not check the masters for the sentinel clients")
+			}
+		}
+	}
+}
+
+func (s *SentinelServer) checkMasters(ctx context.Context) error {
+	var pods corev1.PodList
+	if err := s.List(ctx, &pods, client.MatchingLabels{
+		resources.KubernetesPartOfLabelKey: "dragonfly",
+		resources.Role:                     resources.Master,
+	}); err != nil {
+		return fmt.Errorf("could not list the master pods: %w", err)
+	}
+
+	var dfs dfv1alpha1.DragonflyList
+	if err := s.List(ctx, &dfs); err != nil {
+		return fmt.Errorf("could not list the Dragonfly objects: %w", err)
+	}
+
+	instances := make(map[string]bool, len(dfs.Items))
+	for _, df := range dfs.Items {
+		instances[sentinelMasterName(df.Namespace, df.Name)] = true
+	}
+
+	port := strconv.Itoa(resources.DragonflyPort)
+	var switches []string
+
+	s.mu.Lock()
+	for _, pod := range pods.Items {
+		name := sentinelMasterName(pod.Namespace, pod.Labels["app"])
+		if pod.Status.PodIP == "" || !instances[name] {
+			continue
+		}
+
+		if oldIP, ok := s.masters[name]; ok && oldIP != pod.Status.PodIP {
+			switches = append(switches, strings.Join([]string{name, oldIP, port, pod.Status.PodIP, port}, " "))
+		}
+		s.masters[name] = pod.Status.PodIP
+	}
+
+	for name := range s.masters {
+		if !instances[name] {
+			delete(s.masters, name)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, message := range switches {
+		s.publish(switchMasterChannel, message)
+	}
+
+	return nil
+}
+
+// publish sends the message to the subscribers of the channel. The
+// subscribers are written to without holding the lock, so that a slow
+// client doesn't block the subscriptions of the others.
+func (s *SentinelServer) publish(channel, message string) {
+	s.mu.Lock()
+	conns := make([]*sentinelConn, 0, len(s.subscribers[channel]))
+	for conn := range s.subscribers[channel] {
+		conns = append(conns, conn)
+	}
+	s.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.writeMessage(channel, message)
+	}
+}
+
+// subscribe subscribes the connection to the channel, and returns the
+// number of channels it is subscribed to
+func (s *SentinelServer) subscribe(conn *sentinelConn, channel string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.subscribers[channel] == nil {
+		s.subscribers[channel] = make(map[*sentinelConn]bool)
+	}
+	s.subscribers[channel][conn] = true
+	conn.channels[channel] = true
+	return len(conn.channels)
+}
+
+// subscriptions returns the channels the connection is subscribed to
+func (s *SentinelServer) subscriptions(conn *sentinelConn) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var channels []string
+	for channel := range conn.channels {
+		channels = append(channels, channel)
+	}
+
+	return channels
+}
+
+// unsubscribe unsubscribes the connection from the channel, and returns
+// the number of channels it is still subscribed to
+func (s *SentinelServer) unsubscribe(conn *sentinelConn, channel string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.subscribers[channel], conn)
+	delete(conn.channels, channel)
+	return len(conn.channels)
+}
+
+func (s *SentinelServer) serve(ctx context.Context, conn *sentinelConn) {
+	conn.channels = make(map[string]bool)
+	defer func() {
+		// a bad client must not crash the operator
+		if r := recover(); r != nil {
+			log.FromContext(ctx).Error(fmt.Errorf("%v", r), "sentinel connection panicked", "remote", conn.conn.RemoteAddr().String())
+		}
+
+		for _, channel := range s.subscriptions(conn) {
+			s.unsubscribe(conn, channel)
+		}
+		conn.conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn.conn)
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				log.FromContext(ctx).V(1).Info("closing sentinel connection", "remote", conn.conn.RemoteAddr().String(), "reason", err.Error())
+			}
+			return
+		}
+
+		if len(args) == 0 {
+			continue
+		}
+
+		if !s.handle(ctx, conn, args) {
+			return
+		}
+	}
+}
+
+// handle runs the command, and returns if the connection should be kept
+func (s *SentinelServer) handle(ctx context.Context, conn *sentinelConn, args []string) bool {
+	switch command := strings.ToUpper(args[0]); command {
+	case "PING":
+		conn.writeSimple("PONG")
+	case "QUIT":
+		conn.writeSimple("OK")
+		return false
+	case "SUBSCRIBE":
+		for _, channel := range args[1:] {
+			conn.writeSubscription("subscribe", channel, s.subscribe(conn, channel))
+		}
+	case "UNSUBSCRIBE":
+		channels := args[1:]
+		if len(channels) == 0 {
+			channels = s.subscriptions(conn)
+		}
+		for _, channel := range channels {
+			conn.writeSubscription("unsubscribe", channel, s.unsubscribe(conn, channel))
+		}
+	case "SENTINEL":
+		if len(args) < 3 {
+			conn.writeError("ERR wrong number of arguments for 'sentinel' command")
+			break
+		}
+		s.handleSentinel(ctx, conn, strings.ToLower(args[1]), args[2])
+	default:
+		conn.writeError(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+	}
+
+	return true
+}
+
+func (s *SentinelServer) handleSentinel(ctx context.Context, conn *sentinelConn, subcommand, masterName string) {
+	df, err := s.getDragonfly(ctx, masterName)
+	if err != nil {
+		conn.writeError(fmt.Sprintf("ERR %s", err))
+		return
+	}
+
+	switch subcommand {
+	case "get-master-addr-by-name":
+		if df == nil {
+			conn.writeNil()
+			return
+		}
+
+		master, err := getMasterPod(ctx, s.Client, df)
+		if err != nil || master.Status.PodIP == "" {
+			conn.writeNil()
+			return
+		}
+
+		conn.writeStrings(master.Status.PodIP, strconv.Itoa(resources.DragonflyPort))
+	case "replicas", "slaves":
+		if df == nil {
+			conn.writeError("ERR No such master with that name")
+			return
+		}
+
+		var pods corev1.PodList
+		if err := s.List(ctx, &pods, client.InNamespace(df.Namespace), client.MatchingLabels{
+			"app":                              df.Name,
+			resources.KubernetesPartOfLabelKey: "dragonfly",
+			resources.Role:                     resources.Replica,
+		}); err != nil {
+			conn.writeError(fmt.Sprintf("ERR could not list the replicas: %s", err))
+			return
+		}
+
+		var replicas [][]string
+		for _, pod := range pods.Items {
+			if pod.Status.PodIP == "" {
+				continue
+			}
+
+			flags := "slave"
+			if !isPodReady(&pod) {
+				flags += ",s_down"
+			}
+
+			port := strconv.Itoa(resources.DragonflyPort)
+			replicas = append(replicas, []string{
+				"name", net.JoinHostPort(pod.Status.PodIP, port),
+				"ip", pod.Status.PodIP,
+				"port", port,
+				"flags", flags,
+			})
+		}
+		conn.writeStringArrays(replicas)
+	case "sentinels":
+		if df == nil {
+			conn.writeError("ERR No such master with that name")
+			return
+		}
+
+		// The operator is the only sentinel
+		conn.writeStringArrays(nil)
+	default:
+		conn.writeError(fmt.Sprintf("ERR unknown sentinel subcommand '%s'", subcommand))
+	}
+}
+
+// getDragonfly returns the Dragonfly object of the master name, or nil if
+// it doesn't exist
+func (s *SentinelServer) getDragonfly(ctx context.Context, masterName string) (*dfv1alpha1.Dragonfly, error) {
+	namespace, name, ok := strings.Cut(masterName, ".")
+	if !ok {
+		return nil, nil
+	}
+
+	var df dfv1alpha1.Dragonfly
+	if err := s.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &df); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+
+	return &df, nil
+}
+
+// sentinelMasterName returns the master name of the given instance.
+// Namespaces can't contain dots, so the name is unambiguous.
+func sentinelMasterName(namespace, name string) string {
+	return namespace + "." + name
+}
+
+// readCommand reads a command, either as an array of bulk strings or as
+// an inline command
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count < 1 || count > sentinelMaxArgs {
+		return nil, fmt.Errorf("invalid array length %q", line[1:])
+	}
+
+	args := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		line, err := readLine(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("expected a bulk string, got %q", line)
+		}
+
+		length, err := strconv.Atoi(line[1:])
+		if err != nil || length < 0 || length > sentinelMaxBulkLength {
+			return nil, fmt.Errorf("invalid bulk string length %q", line[1:])
+		}
+
+		buf := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:length]))
+	}
+
+	return args, nil
+}
+
+// readLine reads a line without its CRLF. Lines longer than the buffer of
+// the reader are rejected.
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadSlice('\n')
+	if err != nil {
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return "", errors.New("line too long")
+		}
+		return "", err
+	}
+
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// sentinelConn is a client connection. Its writes are serialized, as the
+// notifications are written from other goroutines.
+type sentinelConn struct {
+	conn net.Conn
+
+	mu     sync.Mutex
+	writer *bufio.Writer
+
+	// channels are the channels the connection is subscribed to, guarded
+	// by the mutex of the server
+	channels map[string]bool
+}
+
+func (c *sentinelConn) write(format string, args ...interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// A slow client must not block the notifications of the others
+	c.conn.SetWriteDeadline(time.Now().Add(sentinelWriteTimeout))
+	fmt.Fprintf(c.writer, format, args...)
+	c.writer.Flush()
+}
+
+func (c *sentinelConn) writeSimple(s string) {
+	c.write("+%s\r\n", s)
+}
+
+func (c *sentinelConn) writeError(s string) {
+	c.write("-%s\r\n", s)
+}
+
+func (c *sentinelConn) writeNil() {
+	c.write("*-1\r\n")
+}
+
+func (c *sentinelConn) writeStrings(values ...string) {
+	c.write("%s", encodeStrings(values))
+}
+
+func (c *sentinelConn) writeStringArrays(arrays [][]string) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(arrays))
+	for _, values := range arrays {
+		b.WriteString(encodeStrings(values))
+	}
+	c.write("%s", b.String())
+}
+
+func (c *sentinelConn) writeSubscription(kind, channel string, count int) {
+	c.write("*3\r\n%s%s:%d\r\n", encodeBulk(kind), encodeBulk(channel), count)
+}
+
+func (c *sentinelConn) writeMessage(channel, message string) {
+	c.writeStrings("message", channel, message)
+}
+
+// encodeStrings encodes the values as an array of bulk strings
+func encodeStrings(values []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(values))
+	for _, value := range values {
+		b.WriteString(encodeBulk(value))
+	}
+
+	return b.String()
+}
+
+// encodeBulk encodes the value as a bulk string
+func encodeBulk(value string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+}
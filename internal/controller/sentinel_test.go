@@ -0,0 +1,213 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReadCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "array of bulk strings",
+			input: "*3\r\n$8\r\nSENTINEL\r\n$23\r\nget-master-addr-by-name\r\n$10\r\ndefault.df\r\n",
+			want:  []string{"SENTINEL", "get-master-addr-by-name", "default.df"},
+		},
+		{
+			name:  "bulk strings may contain line breaks",
+			input: "*2\r\n$4\r\nECHO\r\n$4\r\na\r\nb\r\n",
+			want:  []string{"ECHO", "a\r\nb"},
+		},
+		{
+			name:  "empty bulk string",
+			input: "*2\r\n$4\r\nECHO\r\n$0\r\n\r\n",
+			want:  []string{"ECHO", ""},
+		},
+		{
+			name:  "inline command",
+			input: "PING hello \r\n",
+			want:  []string{"PING", "hello"},
+		},
+		{
+			name:  "inline command without carriage return",
+			input: "PING\n",
+			want:  []string{"PING"},
+		},
+		{
+			name:    "empty array",
+			input:   "*0\r\n",
+			wantErr: true,
+		},
+		{
+			name:    "negative array length",
+			input:   "*-1\r\n",
+			wantErr: true,
+		},
+		{
+			name:    "too many arguments",
+			input:   "*1000000\r\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid array length",
+			input:   "*two\r\n",
+			wantErr: true,
+		},
+		{
+			name:    "no bulk string",
+			input:   "*1\r\n:1\r\n",
+			wantErr: true,
+		},
+		{
+			name:    "too long bulk string",
+			input:   "*1\r\n$1000000000\r\n",
+			wantErr: true,
+		},
+		{
+			name:    "negative bulk string length",
+			input:   "*1\r\n$-1\r\n",
+			wantErr: true,
+		},
+		{
+			name:    "truncated bulk string",
+			input:   "*1\r\n$10\r\nPING\r\n",
+			wantErr: true,
+		},
+		{
+			name:    "missing arguments",
+			input:   "*2\r\n$4\r\nPING\r\n",
+			wantErr: true,
+		},
+		{
+			name:    "too long line",
+			input:   strings.Repeat("a", 8192) + "\r\n",
+			wantErr: true,
+		},
+		{
+			name:    "no line",
+			input:   "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readCommand(bufio.NewReader(strings.NewReader(tt.input)))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readCommand() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckMastersPublishesSwitchesAfterAGap(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := dfv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	df := &dfv1alpha1.Dragonfly{ObjectMeta: metav1.ObjectMeta{Name: "df", Namespace: "default"}}
+	master := func(name, ip string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{
+				"app":                              "df",
+				resources.KubernetesPartOfLabelKey: "dragonfly",
+				resources.Role:                     resources.Master,
+			}},
+			Status: corev1.PodStatus{PodIP: ip},
+		}
+	}
+
+	ctx := context.Background()
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(df, master("df-0", "10.0.0.1")).Build()
+	server, client := net.Pipe()
+	defer client.Close()
+	s := &SentinelServer{Client: c, subscribers: make(map[string]map[*sentinelConn]bool), masters: make(map[string]string)}
+	s.subscribe(&sentinelConn{conn: server, writer: bufio.NewWriter(server), channels: make(map[string]bool)}, switchMasterChannel)
+
+	if err := s.checkMasters(ctx); err != nil {
+		t.Fatalf("checkMasters() error = %v", err)
+	}
+
+	// the instance has no master while it fails over
+	if err := c.Delete(ctx, master("df-0", "")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.checkMasters(ctx); err != nil {
+		t.Fatalf("checkMasters() error = %v", err)
+	}
+
+	if err := c.Create(ctx, master("df-1", "10.0.0.2")); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := make(chan []string, 1)
+	go func() {
+		args, _ := readCommand(bufio.NewReader(client))
+		messages <- args
+	}()
+
+	if err := s.checkMasters(ctx); err != nil {
+		t.Fatalf("checkMasters() error = %v", err)
+	}
+
+	want := []string{"message", switchMasterChannel, "default.df 10.0.0.1 6379 10.0.0.2 6379"}
+	select {
+	case got := <-messages:
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("message = %q, want %q", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no switch-master message was published")
+	}
+
+	// the master name is dropped once the Dragonfly object is deleted
+	if err := c.Delete(ctx, df); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.checkMasters(ctx); err != nil {
+		t.Fatalf("checkMasters() error = %v", err)
+	}
+	if _, ok := s.masters["default.df"]; ok {
+		t.Error("the master of the deleted instance is still known")
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// Shard is the share of the Dragonfly objects that an operator replica
+// manages, when the objects are sharded over several replicas by the hash
+// of their namespace and name. The zero value manages all objects.
+type Shard struct {
+	// Index of the shard, from 0 to Count-1
+	Index int
+
+	// Count is the number of shards
+	Count int
+}
+
+// Validate returns an error if the index isn't one of the shards
+func (s Shard) Validate() error {
+	if s.Count > 1 && (s.Index < 0 || s.Index >= s.Count) {
+		return fmt.Errorf("shard index %d is not between 0 and %d", s.Index, s.Count-1)
+	}
+
+	return nil
+}
+
+// Contains returns if the object with the given namespace and name is in
+// the shard
+func (s Shard) Contains(namespace, name string) bool {
+	if s.Count <= 1 {
+		return true
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(namespace + "/" + name))
+	return int(h.Sum32()%uint32(s.Count)) == s.Index
+}
+
+// ShardIndexFromHostname returns the shard index of the ordinal suffix of
+// the hostname, e.g of a StatefulSet pod, if it has one
+func ShardIndexFromHostname(hostname string) (int, bool) {
+	i := strings.LastIndex(hostname, "-")
+	if i < 0 {
+		return 0, false
+	}
+
+	index, err := strconv.Atoi(hostname[i+1:])
+	if err != nil || index < 0 {
+		return 0, false
+	}
+
+	return index, true
+}
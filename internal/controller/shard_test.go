@@ -0,0 +1,98 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestShardValidate(t *testing.T) {
+	tests := []struct {
+		shard   Shard
+		wantErr bool
+	}{
+		{shard: Shard{}},
+		{shard: Shard{Index: -1, Count: 1}},
+		{shard: Shard{Index: 0, Count: 3}},
+		{shard: Shard{Index: 2, Count: 3}},
+		{shard: Shard{Index: 3, Count: 3}, wantErr: true},
+		{shard: Shard{Index: -1, Count: 3}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d of %d", tt.shard.Index, tt.shard.Count), func(t *testing.T) {
+			if err := tt.shard.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestShardContains(t *testing.T) {
+	objects := []struct{ namespace, name string }{
+		{"default", "df"},
+		{"default", "df-1"},
+		{"prod", "cache"},
+		{"prod", "sessions"},
+		{"staging", "cache"},
+		{"team-a", "queue"},
+	}
+
+	for _, count := range []int{0, 1, 2, 3, 5} {
+		t.Run(fmt.Sprintf("%d shards", count), func(t *testing.T) {
+			for _, object := range objects {
+				shards := 0
+				for index := 0; index < count || index == 0; index++ {
+					if (Shard{Index: index, Count: count}).Contains(object.namespace, object.name) {
+						shards++
+					}
+				}
+
+				if shards != 1 {
+					t.Errorf("%s/%s is in %d shards, want 1", object.namespace, object.name, shards)
+				}
+			}
+		})
+	}
+}
+
+func TestShardIndexFromHostname(t *testing.T) {
+	tests := []struct {
+		hostname string
+		index    int
+		ok       bool
+	}{
+		{hostname: "dragonfly-operator-0", index: 0, ok: true},
+		{hostname: "dragonfly-operator-12", index: 12, ok: true},
+		{hostname: "operator-7", index: 7, ok: true},
+		{hostname: "dragonfly-operator-5d8f7b9c4-x2k9p", ok: false},
+		{hostname: "operator", ok: false},
+		{hostname: "operator-", ok: false},
+		{hostname: "operator-1a", ok: false},
+		{hostname: "", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hostname, func(t *testing.T) {
+			index, ok := ShardIndexFromHostname(tt.hostname)
+			if ok != tt.ok || index != tt.index {
+				t.Errorf("ShardIndexFromHostname(%q) = %d, %v, want %d, %v", tt.hostname, index, ok, tt.index, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// snapshotVerificationInterval is how often the snapshots of the
+	// instances are verified
+	snapshotVerificationInterval = time.Minute
+
+	// defaultSnapshotMaxAge is the default maximum age of the last
+	// successful scheduled snapshot
+	defaultSnapshotMaxAge = 24 * time.Hour
+)
+
+// SnapshotVerifier periodically verifies that the scheduled snapshots of
+// the instances succeed, and sets their SnapshotFailing condition along
+// with an event when they fail or are overdue, so that silently failing
+// snapshots aren't only discovered when restoring one.
+type SnapshotVerifier struct {
+	client.Client
+	EventRecorder record.EventRecorder
+
+	// Shard is the share of the Dragonfly objects that is verified
+	Shard Shard
+}
+
+// Start runs the verifier until the context is done
+func (v *SnapshotVerifier) Start(ctx context.Context) error {
+	ticker := time.NewTicker(snapshotVerificationInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := v.verify(ctx); err != nil {
+				log.FromContext(ctx).Error(err, "could not verify snapshots")
+			}
+		}
+	}
+}
+
+func (v *SnapshotVerifier) verify(ctx context.Context) error {
+	log := log.FromContext(ctx)
+
+	var dfs dfv1alpha1.DragonflyList
+	if err := v.List(ctx, &dfs); err != nil {
+		return err
+	}
+
+	for i := range dfs.Items {
+		df := &dfs.Items[i]
+		if !v.Shard.Contains(df.Namespace, df.Name) {
+			continue
+		}
+
+		if df.Spec.Snapshot == nil || df.Spec.Snapshot.Cron == "" {
+			continue
+		}
+
+		if df.Status.Phase != PhaseReady && df.Status.Phase != PhaseDegraded {
+			continue
+		}
+
+		condition, err := v.getSnapshotCondition(ctx, df)
+		if err != nil {
+			log.Error(err, "could not verify the snapshots", "dragonfly", client.ObjectKeyFromObject(df))
+			continue
+		}
+
+		existing := meta.FindStatusCondition(df.Status.Conditions, ConditionSnapshotFailing)
+		if existing != nil && existing.Status == condition.Status && existing.Reason == condition.Reason {
+			continue
+		}
+
+		patch := client.MergeFromWithOptions(df.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		condition.ObservedGeneration = df.Generation
+		meta.SetStatusCondition(&df.Status.Conditions, condition)
+		if err := v.Status().Patch(ctx, df, patch); err != nil {
+			log.Error(err, "could not update the snapshot condition", "dragonfly", client.ObjectKeyFromObject(df))
+			continue
+		}
+
+		if condition.Status == metav1.ConditionTrue {
+			v.EventRecorder.Event(df, corev1.EventTypeWarning, "Snapshot", fmt.Sprintf("Snapshots are failing: %s", condition.Message))
+		} else if existing != nil && existing.Status == metav1.ConditionTrue {
+			v.EventRecorder.Event(df, corev1.EventTypeNormal, "Snapshot", fmt.Sprintf("Snapshots recovered: %s", condition.Message))
+		}
+	}
+
+	return nil
+}
+
+// getSnapshotCondition returns the SnapshotFailing condition of the
+// instance, based on the last successful and failed saves of its master
+func (v *SnapshotVerifier) getSnapshotCondition(ctx context.Context, df *dfv1alpha1.Dragonfly) (metav1.Condition, error) {
+	master, err := getMasterPod(ctx, v.Client, df)
+	if err != nil {
+		return metav1.Condition{}, err
+	}
+
+	info, err := fetchInfo(ctx, master, "persistence")
+	if err != nil {
+		return metav1.Condition{}, err
+	}
+
+	maxAge := defaultSnapshotMaxAge
+	if df.Spec.Snapshot.MaxAge != nil {
+		maxAge = df.Spec.Snapshot.MaxAge.Duration
+	}
+
+	lastSuccess, _ := strconv.ParseInt(info["last_success_save"], 10, 64)
+	lastFailure, _ := strconv.ParseInt(info["last_failed_save"], 10, 64)
+
+	if lastFailure > lastSuccess {
+		return metav1.Condition{
+			Type:    ConditionSnapshotFailing,
+			Status:  metav1.ConditionTrue,
+			Reason:  "SaveFailed",
+			Message: fmt.Sprintf("the last snapshot of master %s failed at %s: %s", master.Name, time.Unix(lastFailure, 0).UTC().Format(time.RFC3339), info["last_error"]),
+		}, nil
+	}
+
+	// a master that was started recently may not have saved a snapshot yet
+	since := time.Unix(lastSuccess, 0)
+	if lastSuccess == 0 && master.Status.StartTime != nil {
+		since = master.Status.StartTime.Time
+	}
+
+	if time.Since(since) > maxAge {
+		return metav1.Condition{
+			Type:    ConditionSnapshotFailing,
+			Status:  metav1.ConditionTrue,
+			Reason:  "SnapshotOverdue",
+			Message: fmt.Sprintf("master %s has not saved a snapshot since %s", master.Name, since.UTC().Format(time.RFC3339)),
+		}, nil
+	}
+
+	if lastSuccess == 0 {
+		return metav1.Condition{
+			Type:    ConditionSnapshotFailing,
+			Status:  metav1.ConditionFalse,
+			Reason:  "NoSnapshotYet",
+			Message: fmt.Sprintf("master %s has not saved a snapshot yet", master.Name),
+		}, nil
+	}
+
+	return metav1.Condition{
+		Type:    ConditionSnapshotFailing,
+		Status:  metav1.ConditionFalse,
+		Reason:  "SnapshotSaved",
+		Message: fmt.Sprintf("master %s saved its last snapshot at %s", master.Name, since.UTC().Format(time.RFC3339)),
+	}, nil
+}
@@ -0,0 +1,392 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// volumeSnapshotGVK is the CSI VolumeSnapshot kind
+var volumeSnapshotGVK = schema.GroupVersionKind{
+	Group:   "snapshot.storage.k8s.io",
+	Version: "v1",
+	Kind:    "VolumeSnapshot",
+}
+
+// getDesiredStatefulSet returns the statefulset of the instance as it's
+// created, with the hashes of the referenced objects, so that recreating
+// it doesn't change the revision of the pods
+func (r *DragonflyReconciler) getDesiredStatefulSet(ctx context.Context, df *dfv1alpha1.Dragonfly) (*appsv1.StatefulSet, error) {
+	objects, err := resources.GetDragonflyResources(ctx, df)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, object := range objects {
+		statefulSet, ok := object.(*appsv1.StatefulSet)
+		if !ok {
+			continue
+		}
+
+		if err := setSecretsHash(ctx, r.Client, df, statefulSet); err != nil {
+			return nil, fmt.Errorf("could not hash referenced secrets: %w", err)
+		}
+
+		if err := setConfigMapsHash(ctx, r.Client, df, statefulSet); err != nil {
+			return nil, fmt.Errorf("could not hash referenced config maps: %w", err)
+		}
+
+		return statefulSet, nil
+	}
+
+	return nil, fmt.Errorf("no statefulset in the resources of %s", df.Name)
+}
+
+// recreateStatefulSet creates the statefulset of the instance again after it
+// was deleted without its pods, e.g to change its volume claim templates
+func (r *DragonflyReconciler) recreateStatefulSet(ctx context.Context, df *dfv1alpha1.Dragonfly) error {
+	statefulSet, err := r.getDesiredStatefulSet(ctx, df)
+	if err != nil {
+		return err
+	}
+
+	if err := r.Create(ctx, statefulSet); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("could not recreate statefulset: %w", err)
+	}
+
+	return nil
+}
+
+// hasStaleSnapshotVolumes returns if any pod of the instance has a PVC on
+// another storage class than the statefulset
+func hasStaleSnapshotVolumes(ctx context.Context, c client.Client, df *dfv1alpha1.Dragonfly, statefulSet *appsv1.StatefulSet) (bool, error) {
+	var pods corev1.PodList
+	if err := c.List(ctx, &pods, client.InNamespace(df.Namespace), client.MatchingLabels{
+		"app":                               df.Name,
+		resources.KubernetesAppNameLabelKey: "dragonfly",
+	}); err != nil {
+		return false, err
+	}
+
+	for _, pod := range pods.Items {
+		onLatestStorage, err := isPodOnLatestStorageClass(ctx, c, &pod, statefulSet)
+		if err != nil {
+			return false, err
+		}
+
+		if !onLatestStorage {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// migrateSnapshotVolume moves the PVC of the given pod to the storage class
+// of the statefulset, and returns once it's done. The PVC of a pod can only
+// be replaced while no statefulset recreates the pod, so each step is taken
+// in its own reconcile, in order:
+//
+//  1. the PVC is copied to a VolumeSnapshot, if a VolumeSnapshotClass is set
+//  2. the statefulset is deleted without its pods
+//  3. the pod is deleted, then its PVC
+//  4. the new PVC is created, from the VolumeSnapshot if there's one
+//  5. the statefulset is recreated, which recreates the pod on the new PVC
+//
+// The pod then resyncs from the master like any updated replica. The pod in
+// migration is kept in the status, so that the steps are resumed after a
+// restart of the operator. The statefulset is only deleted while all the
+// other pods run, and recreated early if one of them has to be recreated
+// before the old PVC is deleted. Masters are failed over meanwhile.
+func (r *DragonflyReconciler) migrateSnapshotVolume(ctx context.Context, df *dfv1alpha1.Dragonfly, podName string) (bool, error) {
+	log := log.FromContext(ctx).WithValues("pod", podName)
+
+	if df.Status.MigratingPod != podName {
+		r.EventRecorder.Event(df, corev1.EventTypeNormal, "StorageMigration", fmt.Sprintf("Migrating the volume of pod %s", podName))
+		df.Status.MigratingPod = podName
+		if err := r.Status().Update(ctx, df); err != nil {
+			return false, err
+		}
+	}
+
+	desired, err := r.getDesiredStatefulSet(ctx, df)
+	if err != nil {
+		return false, err
+	}
+
+	template := getSnapshotVolumeClaimTemplate(desired)
+	if template == nil {
+		// persistence was removed in the meantime
+		return r.finishSnapshotVolumeMigration(ctx, df)
+	}
+
+	pvcName := fmt.Sprintf("%s-%s", resources.SnapshotVolumeName, podName)
+	snapshotName := pvcName + "-migration"
+	useSnapshot := df.Spec.Snapshot != nil && df.Spec.Snapshot.VolumeSnapshotClassName != nil
+
+	var pvc corev1.PersistentVolumeClaim
+	err = r.Get(ctx, types.NamespacedName{Namespace: df.Namespace, Name: pvcName}, &pvc)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return false, err
+	}
+
+	if err == nil {
+		if pvc.DeletionTimestamp != nil {
+			log.Info("Waiting for the old PVC to be deleted")
+			return false, nil
+		}
+
+		if pvc.Spec.StorageClassName != nil && *pvc.Spec.StorageClassName == *template.Spec.StorageClassName {
+			// the PVC was replaced, the statefulset may still have to be
+			// recreated
+			done, err := r.finishSnapshotVolumeMigration(ctx, df)
+			if err != nil || !done {
+				return done, err
+			}
+
+			// the restored PVC no longer needs the VolumeSnapshot
+			if useSnapshot && pvc.Status.Phase == corev1.ClaimBound {
+				if err := deleteVolumeSnapshot(ctx, r.Client, df.Namespace, snapshotName); err != nil {
+					return false, err
+				}
+			}
+
+			return true, nil
+		}
+
+		// 1. copy the old PVC
+		if useSnapshot {
+			ready, err := ensureVolumeSnapshot(ctx, r.Client, df, snapshotName, pvcName)
+			if err != nil {
+				return false, err
+			}
+
+			if !ready {
+				log.Info("Waiting for the volume snapshot to be ready to use", "volumeSnapshot", snapshotName)
+				return false, nil
+			}
+		}
+
+		// the statefulset can't recreate the other pods while it's gone
+		needed, err := isStatefulSetNeeded(ctx, r.Client, df, podName)
+		if err != nil {
+			return false, err
+		}
+
+		// 2. stop the statefulset from recreating the pod
+		var statefulSet appsv1.StatefulSet
+		if err := r.Get(ctx, types.NamespacedName{Namespace: df.Namespace, Name: df.Name}, &statefulSet); err == nil {
+			if needed {
+				log.Info("Waiting for the other pods to be recreated before deleting the statefulset")
+				return false, nil
+			}
+
+			if statefulSet.DeletionTimestamp == nil {
+				log.Info("Deleting the statefulset without its pods")
+				if err := r.Delete(ctx, &statefulSet, client.PropagationPolicy(metav1.DeletePropagationOrphan)); err != nil && !apierrors.IsNotFound(err) {
+					return false, err
+				}
+			}
+			return false, nil
+		} else if !apierrors.IsNotFound(err) {
+			return false, err
+		}
+
+		// another pod that crashed or was evicted meanwhile is recreated
+		// right away, which is only safe while the old PVC isn't deleted,
+		// and the migration starts over once it's back
+		if needed {
+			log.Info("Recreating the statefulset for the other pods")
+			r.EventRecorder.Event(df, corev1.EventTypeWarning, "StorageMigration", fmt.Sprintf("Interrupted the migration of the volume of pod %s, as other pods have to be recreated", podName))
+			return false, r.recreateStatefulSet(ctx, df)
+		}
+
+		// 3. delete the pod first, so that it never runs on a claim that
+		// is being deleted
+		var pod corev1.Pod
+		if err := r.Get(ctx, types.NamespacedName{Namespace: df.Namespace, Name: podName}, &pod); err == nil {
+			if pod.DeletionTimestamp == nil {
+				log.Info("Deleting the pod")
+				if err := r.Delete(ctx, &pod); err != nil && !apierrors.IsNotFound(err) {
+					return false, err
+				}
+			}
+			return false, nil
+		} else if !apierrors.IsNotFound(err) {
+			return false, err
+		}
+
+		log.Info("Deleting the old PVC")
+		if err := r.Delete(ctx, &pvc); err != nil && !apierrors.IsNotFound(err) {
+			return false, err
+		}
+
+		return false, nil
+	}
+
+	// 4. create the new PVC, before the statefulset would create an empty
+	// one
+	newPVC := corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pvcName,
+			Namespace: df.Namespace,
+			Labels:    map[string]string{},
+		},
+		Spec: *template.Spec.DeepCopy(),
+	}
+	for k, v := range template.Labels {
+		newPVC.Labels[k] = v
+	}
+	for k, v := range desired.Spec.Selector.MatchLabels {
+		newPVC.Labels[k] = v
+	}
+
+	if useSnapshot {
+		apiGroup := volumeSnapshotGVK.Group
+		newPVC.Spec.DataSource = &corev1.TypedLocalObjectReference{
+			APIGroup: &apiGroup,
+			Kind:     volumeSnapshotGVK.Kind,
+			Name:     snapshotName,
+		}
+	}
+
+	log.Info("Creating the new PVC", "storageClassName", *template.Spec.StorageClassName)
+	if err := r.Create(ctx, &newPVC); err != nil && !apierrors.IsAlreadyExists(err) {
+		return false, err
+	}
+
+	// 5. recreate the pod on the new PVC
+	return r.finishSnapshotVolumeMigration(ctx, df)
+}
+
+// isStatefulSetNeeded returns if a pod of the instance other than the
+// given one is missing or being deleted, so that the statefulset has to
+// recreate it
+func isStatefulSetNeeded(ctx context.Context, c client.Client, df *dfv1alpha1.Dragonfly, podName string) (bool, error) {
+	var pods corev1.PodList
+	if err := c.List(ctx, &pods, client.InNamespace(df.Namespace), client.MatchingLabels{
+		"app":                               df.Name,
+		resources.KubernetesAppNameLabelKey: "dragonfly",
+	}); err != nil {
+		return false, err
+	}
+
+	others := 0
+	for _, pod := range pods.Items {
+		if pod.Name == podName {
+			continue
+		}
+
+		if pod.DeletionTimestamp != nil {
+			return true, nil
+		}
+		others++
+	}
+
+	return others < int(df.Spec.Replicas)-1, nil
+}
+
+// finishSnapshotVolumeMigration recreates the statefulset if it's gone, and
+// clears the pod in migration once it's back
+func (r *DragonflyReconciler) finishSnapshotVolumeMigration(ctx context.Context, df *dfv1alpha1.Dragonfly) (bool, error) {
+	var statefulSet appsv1.StatefulSet
+	if err := r.Get(ctx, types.NamespacedName{Namespace: df.Namespace, Name: df.Name}, &statefulSet); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return false, err
+		}
+
+		if err := r.recreateStatefulSet(ctx, df); err != nil {
+			return false, err
+		}
+	} else if statefulSet.DeletionTimestamp != nil {
+		return false, nil
+	}
+
+	if df.Status.MigratingPod != "" {
+		r.EventRecorder.Event(df, corev1.EventTypeNormal, "StorageMigration", fmt.Sprintf("Migrated the volume of pod %s", df.Status.MigratingPod))
+		df.Status.MigratingPod = ""
+		if err := r.Status().Update(ctx, df); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// ensureVolumeSnapshot creates a VolumeSnapshot of the given PVC, and
+// returns if it's ready to use
+func ensureVolumeSnapshot(ctx context.Context, c client.Client, df *dfv1alpha1.Dragonfly, name, pvcName string) (bool, error) {
+	var snapshot unstructured.Unstructured
+	snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+	if err := c.Get(ctx, types.NamespacedName{Namespace: df.Namespace, Name: name}, &snapshot); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return false, err
+		}
+
+		snapshot.SetNamespace(df.Namespace)
+		snapshot.SetName(name)
+		snapshot.SetLabels(map[string]string{
+			"app":                              df.Name,
+			resources.KubernetesPartOfLabelKey: "dragonfly",
+		})
+		snapshot.Object["spec"] = map[string]interface{}{
+			"volumeSnapshotClassName": *df.Spec.Snapshot.VolumeSnapshotClassName,
+			"source": map[string]interface{}{
+				"persistentVolumeClaimName": pvcName,
+			},
+		}
+		if err := c.Create(ctx, &snapshot); err != nil {
+			return false, fmt.Errorf("could not create volume snapshot: %w", err)
+		}
+
+		return false, nil
+	}
+
+	if message, found, _ := unstructured.NestedString(snapshot.Object, "status", "error", "message"); found {
+		return false, fmt.Errorf("volume snapshot %s failed: %s", name, message)
+	}
+
+	ready, _, _ := unstructured.NestedBool(snapshot.Object, "status", "readyToUse")
+	return ready, nil
+}
+
+// deleteVolumeSnapshot deletes the VolumeSnapshot a PVC was restored from
+func deleteVolumeSnapshot(ctx context.Context, c client.Client, namespace, name string) error {
+	var snapshot unstructured.Unstructured
+	snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+	snapshot.SetNamespace(namespace)
+	snapshot.SetName(name)
+	if err := c.Delete(ctx, &snapshot); err != nil && !apierrors.IsNotFound(err) && !meta.IsNoMatchError(err) {
+		return err
+	}
+
+	return nil
+}
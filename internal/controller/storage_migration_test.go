@@ -0,0 +1,76 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestIsStatefulSetNeeded(t *testing.T) {
+	pod := func(name string, deleting bool) *corev1.Pod {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{"app": "df", resources.KubernetesAppNameLabelKey: "dragonfly"},
+		}}
+		if deleting {
+			now := metav1.Now()
+			pod.DeletionTimestamp = &now
+			pod.Finalizers = []string{"test"}
+		}
+		return pod
+	}
+
+	tests := []struct {
+		name string
+		pods []client.Object
+		want bool
+	}{
+		{name: "all pods run", pods: []client.Object{pod("df-0", false), pod("df-1", false), pod("df-2", false)}, want: false},
+		{name: "migrating pod is gone", pods: []client.Object{pod("df-0", false), pod("df-2", false)}, want: false},
+		{name: "migrating pod is being deleted", pods: []client.Object{pod("df-0", false), pod("df-1", true), pod("df-2", false)}, want: false},
+		{name: "another pod is gone", pods: []client.Object{pod("df-0", false), pod("df-1", false)}, want: true},
+		{name: "another pod is being deleted", pods: []client.Object{pod("df-0", true), pod("df-1", false), pod("df-2", false)}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df := &dfv1alpha1.Dragonfly{
+				ObjectMeta: metav1.ObjectMeta{Name: "df", Namespace: "default"},
+				Spec:       dfv1alpha1.DragonflySpec{Replicas: 3},
+			}
+			c := fake.NewClientBuilder().WithObjects(tt.pods...).Build()
+
+			got, err := isStatefulSetNeeded(context.Background(), c, df, "df-1")
+			if err != nil {
+				t.Fatalf("isStatefulSetNeeded() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("isStatefulSetNeeded() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// stuckPhaseCheckInterval is how often the phases of the instances are checked
+const stuckPhaseCheckInterval = 30 * time.Second
+
+// stuckInstances is 1 for the instances that are stuck in a non ready phase
+var stuckInstances = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "dragonfly_operator_instance_stuck",
+	Help: "Whether a Dragonfly instance has been in a non ready phase for longer than the threshold",
+}, []string{"namespace", "name", "phase"})
+
+func init() {
+	metrics.Registry.MustRegister(stuckInstances)
+}
+
+// StuckPhaseDetector periodically checks for Dragonfly instances that have
+// been configuring replication or degraded for longer than the threshold,
+// and records an event and a metric for them.
+type StuckPhaseDetector struct {
+	client.Client
+	EventRecorder record.EventRecorder
+
+	// Threshold is the time after which an instance is considered stuck
+	Threshold time.Duration
+
+	// Shard is the share of the Dragonfly objects that is checked
+	Shard Shard
+
+	// stuck is the phase each stuck instance is stuck in
+	stuck map[types.NamespacedName]string
+}
+
+// Start runs the detector until the context is done
+func (d *StuckPhaseDetector) Start(ctx context.Context) error {
+	d.stuck = make(map[types.NamespacedName]string)
+
+	ticker := time.NewTicker(stuckPhaseCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := d.check(ctx); err != nil {
+				log.FromContext(ctx).Error(err, "could not check for stuck instances")
+			}
+		}
+	}
+}
+
+func (d *StuckPhaseDetector) check(ctx context.Context) error {
+	var dfs dfv1alpha1.DragonflyList
+	if err := d.List(ctx, &dfs); err != nil {
+		return err
+	}
+
+	seen := make(map[types.NamespacedName]bool, len(dfs.Items))
+	for i := range dfs.Items {
+		df := &dfs.Items[i]
+		if !d.Shard.Contains(df.Namespace, df.Name) {
+			continue
+		}
+
+		key := client.ObjectKeyFromObject(df)
+		seen[key] = true
+
+		phase := df.Status.Phase
+		isStuck := (phase == PhaseResourcesCreated || phase == PhaseDegraded) &&
+			df.Status.PhaseTransitionTime != nil && time.Since(df.Status.PhaseTransitionTime.Time) > d.Threshold
+
+		if stuckPhase, ok := d.stuck[key]; ok && (!isStuck || stuckPhase != phase) {
+			stuckInstances.DeleteLabelValues(key.Namespace, key.Name, stuckPhase)
+			delete(d.stuck, key)
+		}
+
+		if !isStuck || d.stuck[key] == phase {
+			continue
+		}
+
+		d.stuck[key] = phase
+		stuckInstances.WithLabelValues(key.Namespace, key.Name, phase).Set(1)
+		d.EventRecorder.Event(df, corev1.EventTypeWarning, "Stuck", fmt.Sprintf("Instance has been in phase %s since %s", phase, df.Status.PhaseTransitionTime.Format(time.RFC3339)))
+	}
+
+	// forget the deleted instances
+	for key, phase := range d.stuck {
+		if !seen[key] {
+			stuckInstances.DeleteLabelValues(key.Namespace, key.Name, phase)
+			delete(d.stuck, key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,230 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// Operations that change the replication topology
+	TopologyChangeConfigureReplication = "ConfigureReplication"
+	TopologyChangeEvictionHandover     = "EvictionHandover"
+	TopologyChangeRolloutTakeover      = "RolloutTakeover"
+	TopologyChangeMaintenanceFailover  = "MaintenanceFailover"
+	TopologyChangeManualFailover       = "ManualFailover"
+
+	// topologyHistoryLimit is the number of topology changes kept in the
+	// history of an instance
+	topologyHistoryLimit = 10
+
+	// topologyChangeShutdownGracePeriod is how long a topology change
+	// may continue once the operator is stopped. It has to be shorter
+	// than the graceful shutdown timeout of the manager.
+	topologyChangeShutdownGracePeriod = 20 * time.Second
+)
+
+type topologyChangeKey struct{}
+
+// topologyChangeState is the state of the topology change in progress,
+// carried in its context
+type topologyChangeState struct {
+	operation string
+
+	// newMaster is the pod that was promoted by the change, if any
+	newMaster string
+}
+
+var (
+	// topologyChangesInProgress are the UIDs of the instances this process
+	// is changing the topology of
+	topologyChangesInProgress sync.Map
+
+	// topologyChangeLocks has a mutex per instance UID, so that the
+	// controllers don't change the topology of an instance concurrently
+	topologyChangeLocks sync.Map
+)
+
+// lockTopologyChanges waits for the topology changes of the instance in
+// progress, and returns the function that unlocks them again
+func lockTopologyChanges(uid types.UID) func() {
+	mu, _ := topologyChangeLocks.LoadOrStore(uid, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// runTopologyChange runs the given change of the replication topology of
+// the instance, marking it in the status while it's in progress. The
+// change isn't interrupted when the operator is stopped, until the
+// shutdown grace period has passed, so that it doesn't leave a half
+// configured topology behind. If it's interrupted anyway, the marker is
+// kept so that the change can be resumed. Nested changes are part of
+// the outer one, and other changes of the instance wait for it.
+func runTopologyChange(ctx context.Context, c client.Client, df *dfv1alpha1.Dragonfly, operation, pod string, change func(context.Context) error) error {
+	if ctx.Value(topologyChangeKey{}) != nil {
+		return change(ctx)
+	}
+
+	unlock := lockTopologyChanges(df.UID)
+	defer unlock()
+
+	ctx, cancel := withShutdownGracePeriod(ctx, topologyChangeShutdownGracePeriod)
+	defer cancel()
+	topologyChangesInProgress.Store(df.UID, operation)
+	defer topologyChangesInProgress.Delete(df.UID)
+
+	// the change that was waited for updated the status
+	if err := c.Get(ctx, client.ObjectKeyFromObject(df), df); err != nil {
+		return err
+	}
+
+	state := &topologyChangeState{operation: operation}
+	ctx = context.WithValue(ctx, topologyChangeKey{}, state)
+
+	transition := dfv1alpha1.TopologyTransition{
+		Operation: operation,
+		Pod:       pod,
+		StartTime: metav1.Now(),
+	}
+	if master, err := getMasterPod(ctx, c, df); err == nil {
+		transition.OldMaster = master.Name
+	}
+
+	marker := &dfv1alpha1.TopologyChange{
+		Operation: operation,
+		Pod:       pod,
+		StartTime: transition.StartTime,
+	}
+	if err := setTopologyChange(ctx, c, df, marker); err != nil {
+		return err
+	}
+
+	err := change(ctx)
+	if ctx.Err() != nil {
+		// the change was interrupted, and is resumed later on
+		return err
+	}
+
+	transition.Duration = metav1.Duration{Duration: time.Since(transition.StartTime.Time).Round(time.Millisecond)}
+	transition.NewMaster = transition.OldMaster
+	if state.newMaster != "" {
+		transition.NewMaster = state.newMaster
+	}
+	if err != nil {
+		transition.Error = err.Error()
+	}
+
+	if clearErr := completeTopologyChange(ctx, c, df, marker, transition); clearErr != nil && err == nil {
+		return clearErr
+	}
+
+	return err
+}
+
+// recordNewMaster records the pod that was promoted to master in the
+// topology change in progress
+func recordNewMaster(ctx context.Context, pod string) {
+	if state, ok := ctx.Value(topologyChangeKey{}).(*topologyChangeState); ok {
+		state.newMaster = pod
+	}
+}
+
+// isTopologyChangeInterrupted returns if the status of the instance marks
+// a topology change that isn't in progress anymore
+func isTopologyChangeInterrupted(df *dfv1alpha1.Dragonfly) bool {
+	if df.Status.TopologyChange == nil {
+		return false
+	}
+
+	_, inProgress := topologyChangesInProgress.Load(df.UID)
+	return !inProgress
+}
+
+// setTopologyChange sets the topology change in progress in the status
+// of the instance
+func setTopologyChange(ctx context.Context, c client.Client, df *dfv1alpha1.Dragonfly, change *dfv1alpha1.TopologyChange) error {
+	patch := client.MergeFrom(df.DeepCopy())
+	df.Status.TopologyChange = change
+	setStateConditions(df)
+	return c.Status().Patch(ctx, df, patch)
+}
+
+// completeTopologyChange clears the given topology change marker from the
+// status of the instance, unless it was replaced since, and adds the
+// transition to the history
+func completeTopologyChange(ctx context.Context, c client.Client, df *dfv1alpha1.Dragonfly, marker *dfv1alpha1.TopologyChange, transition dfv1alpha1.TopologyTransition) error {
+	if err := c.Get(ctx, client.ObjectKeyFromObject(df), df); err != nil {
+		return err
+	}
+
+	// the status keeps the start time in seconds
+	patch := client.MergeFrom(df.DeepCopy())
+	if change := df.Status.TopologyChange; change != nil && change.Operation == marker.Operation && change.Pod == marker.Pod && change.StartTime.Unix() == marker.StartTime.Unix() {
+		df.Status.TopologyChange = nil
+	}
+	df.Status.History = append(df.Status.History, transition)
+	if len(df.Status.History) > topologyHistoryLimit {
+		df.Status.History = df.Status.History[len(df.Status.History)-topologyHistoryLimit:]
+	}
+	setStateConditions(df)
+	recordTopologyTransition(df, transition)
+	return c.Status().Patch(ctx, df, patch)
+}
+
+// withShutdownGracePeriod returns a context that isn't canceled along
+// with the given one, but only the grace period after it
+func withShutdownGracePeriod(ctx context.Context, gracePeriod time.Duration) (context.Context, context.CancelFunc) {
+	detached, cancel := context.WithCancel(detachedContext{ctx})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-detached.Done():
+			return
+		}
+
+		timer := time.NewTimer(gracePeriod)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			cancel()
+		case <-detached.Done():
+		}
+	}()
+
+	return detached, cancel
+}
+
+// detachedContext carries the values of its parent, e.g the logger,
+// without its deadline and cancellation
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (c detachedContext) Value(key any) any {
+	return c.parent.Value(key)
+}
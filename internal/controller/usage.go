@@ -0,0 +1,199 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"time"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// invalidLabelChars are the characters of label keys that aren't valid in
+// the names of Prometheus labels
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// UsageCollector periodically exports the resources provisioned for each
+// instance as metrics, labeled with its namespace, name and cost labels,
+// so that platform teams can charge the usage back to the tenants.
+type UsageCollector struct {
+	client.Client
+
+	// Interval is how often the usage is collected
+	Interval time.Duration
+
+	// Shard is the share of the Dragonfly objects that is collected
+	Shard Shard
+
+	// CostLabels are the labels of the Dragonfly objects that are added to
+	// the metrics, as label_<key> with the invalid characters replaced by _
+	CostLabels []string
+
+	memory   *prometheus.GaugeVec
+	storage  *prometheus.GaugeVec
+	replicas *prometheus.GaugeVec
+	uptime   *prometheus.GaugeVec
+
+	// labels are the label values of the metrics per instance
+	labels map[types.NamespacedName][]string
+}
+
+// Start registers the metrics and runs the collector until the context
+// is done
+func (c *UsageCollector) Start(ctx context.Context) error {
+	labelNames := []string{"namespace", "name"}
+	for _, key := range c.CostLabels {
+		labelNames = append(labelNames, "label_"+invalidLabelChars.ReplaceAllString(key, "_"))
+	}
+
+	c.memory = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dragonfly_operator_instance_memory_requested_bytes",
+		Help: "Memory requested by all the pods of a Dragonfly instance",
+	}, labelNames)
+	c.storage = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dragonfly_operator_instance_storage_provisioned_bytes",
+		Help: "Storage provisioned for the snapshots of all the pods of a Dragonfly instance",
+	}, labelNames)
+	c.replicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dragonfly_operator_instance_replicas",
+		Help: "Number of pods of a Dragonfly instance, including the master",
+	}, labelNames)
+	c.uptime = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dragonfly_operator_instance_uptime_seconds",
+		Help: "Time since a Dragonfly instance was created",
+	}, labelNames)
+	for _, collector := range []prometheus.Collector{c.memory, c.storage, c.replicas, c.uptime} {
+		if err := metrics.Registry.Register(collector); err != nil {
+			return err
+		}
+	}
+	c.labels = make(map[types.NamespacedName][]string)
+
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := c.collect(ctx); err != nil {
+				log.FromContext(ctx).Error(err, "could not collect usage")
+			}
+		}
+	}
+}
+
+func (c *UsageCollector) collect(ctx context.Context) error {
+	var dfs dfv1alpha1.DragonflyList
+	if err := c.List(ctx, &dfs); err != nil {
+		return err
+	}
+
+	seen := make(map[types.NamespacedName]bool, len(dfs.Items))
+	for i := range dfs.Items {
+		df := &dfs.Items[i]
+		if !c.Shard.Contains(df.Namespace, df.Name) {
+			continue
+		}
+
+		key := client.ObjectKeyFromObject(df)
+		seen[key] = true
+
+		storage, err := c.getProvisionedStorage(ctx, df)
+		if err != nil {
+			log.FromContext(ctx).Error(err, "could not get the provisioned storage", "dragonfly", key)
+			continue
+		}
+
+		labels := []string{df.Namespace, df.Name}
+		for _, label := range c.CostLabels {
+			labels = append(labels, df.Labels[label])
+		}
+
+		// the cost labels of the instance changed
+		if previous, ok := c.labels[key]; ok && !reflect.DeepEqual(previous, labels) {
+			c.deleteMetrics(key)
+		}
+		c.labels[key] = labels
+
+		c.memory.WithLabelValues(labels...).Set(float64(instanceMemoryRequests(df)))
+		c.storage.WithLabelValues(labels...).Set(float64(storage))
+		c.replicas.WithLabelValues(labels...).Set(float64(df.Spec.Replicas))
+		c.uptime.WithLabelValues(labels...).Set(time.Since(df.CreationTimestamp.Time).Seconds())
+	}
+
+	// forget the deleted instances
+	for key := range c.labels {
+		if !seen[key] {
+			c.deleteMetrics(key)
+		}
+	}
+
+	return nil
+}
+
+// deleteMetrics deletes the metrics of the instance
+func (c *UsageCollector) deleteMetrics(key types.NamespacedName) {
+	labels := c.labels[key]
+	c.memory.DeleteLabelValues(labels...)
+	c.storage.DeleteLabelValues(labels...)
+	c.replicas.DeleteLabelValues(labels...)
+	c.uptime.DeleteLabelValues(labels...)
+	delete(c.labels, key)
+}
+
+// getProvisionedStorage returns the capacity of the snapshot volumes of
+// the pods of the instance, or their requested size while they are bound
+func (c *UsageCollector) getProvisionedStorage(ctx context.Context, df *dfv1alpha1.Dragonfly) (int64, error) {
+	var pvcs corev1.PersistentVolumeClaimList
+	if err := c.List(ctx, &pvcs, client.InNamespace(df.Namespace), client.MatchingLabels{
+		"app":                               df.Name,
+		resources.KubernetesAppNameLabelKey: "dragonfly",
+	}); err != nil {
+		return 0, err
+	}
+
+	var storage int64
+	for _, pvc := range pvcs.Items {
+		capacity := pvc.Status.Capacity.Storage()
+		if capacity.IsZero() {
+			capacity = pvc.Spec.Resources.Requests.Storage()
+		}
+		storage += capacity.Value()
+	}
+
+	return storage, nil
+}
+
+// instanceMemoryRequests returns the memory requested by all the pods of
+// the instance
+func instanceMemoryRequests(df *dfv1alpha1.Dragonfly) int64 {
+	if df.Spec.Resources == nil {
+		return 0
+	}
+
+	return int64(df.Spec.Replicas) * df.Spec.Resources.Requests.Memory().Value()
+}
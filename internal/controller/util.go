@@ -18,26 +18,131 @@ package controller
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
 	"github.com/dragonflydb/dragonfly-operator/internal/resources"
 	"github.com/redis/go-redis/v9"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
 const (
 	PhaseResourcesCreated string = "resources-created"
 
 	PhaseReady string = "ready"
+
+	// PhasePending is a new instance whose resources aren't created as it
+	// doesn't fit in its memory budget
+	PhasePending string = "pending"
+
+	// PhaseDegraded is a ready instance with fewer replicas in sync than desired
+	PhaseDegraded string = "degraded"
+
+	// PhaseStandby is an instance whose pods all replicate from the
+	// primary of a replication link
+	PhaseStandby string = "standby"
+
+	// ConditionReady is true while the instance serves requests
+	ConditionReady string = "Ready"
+
+	// ConditionReplicationConfigured is true while the pods of the
+	// instance replicate from its master, and no topology change is in
+	// progress
+	ConditionReplicationConfigured string = "ReplicationConfigured"
+
+	// ConditionRollingUpdate is true while the pods of the instance are
+	// being updated
+	ConditionRollingUpdate string = "RollingUpdate"
+
+	// ConditionDegraded is true while the instance has lost redundancy
+	ConditionDegraded string = "Degraded"
+
+	// ConditionSnapshotFailing is true while the scheduled snapshots of
+	// the instance fail or are overdue
+	ConditionSnapshotFailing string = "SnapshotFailing"
+
+	// ConditionUpgradeBlocked is true while a rollout to another major
+	// version of Dragonfly is blocked
+	ConditionUpgradeBlocked string = "UpgradeBlocked"
+
+	// ConditionPendingCapacity is true while the pods of a new instance
+	// don't fit in the free memory of the nodes
+	ConditionPendingCapacity string = "PendingCapacity"
+
+	// coldStartMaxWait is the maximum time to wait for all pods to be
+	// ready after a restart of the whole instance, before electing
+	// a master among the ready ones
+	coldStartMaxWait = 2 * time.Minute
+
+	// defaultFailoverMaxWait is the default maximum time to wait for
+	// a replica to catch up with the master in a planned failover
+	defaultFailoverMaxWait = 30 * time.Second
+
+	// defaults of the backoff after failing to configure replication
+	defaultReplicationBackoffInitialDelay       = 5 * time.Second
+	defaultReplicationBackoffMultiplier   int32 = 2
+	defaultReplicationBackoffMaxDelay           = 5 * time.Minute
+
+	// requeueJitterFactor is the maximum share of a requeue delay that is
+	// added at random, so that instances aren't all checked at once
+	requeueJitterFactor = 0.5
 )
 
+// withJitter returns the given requeue delay with a random jitter of up
+// to requeueJitterFactor of it
+func withJitter(delay time.Duration) time.Duration {
+	return wait.Jitter(delay, requeueJitterFactor)
+}
+
+// setPhase sets the phase of the Dragonfly object, along with
+// the time of the transition if it changes
+func setPhase(df *dfv1alpha1.Dragonfly, phase string) {
+	if df.Status.Phase == phase && df.Status.PhaseTransitionTime != nil {
+		return
+	}
+
+	now := metav1.Now()
+	df.Status.Phase = phase
+	df.Status.PhaseTransitionTime = &now
+	setStateConditions(df)
+}
+
+// annotationChangedPredicate filters the update events of objects to
+// changes of the given annotations
+func annotationChangedPredicate(annotations ...string) predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			for _, annotation := range annotations {
+				if e.ObjectOld.GetAnnotations()[annotation] != e.ObjectNew.GetAnnotations()[annotation] {
+					return true
+				}
+			}
+
+			return false
+		},
+	}
+}
+
 // isPodOnLatestVersion returns if the Given pod is on the updatedRevision
 // of the given statefulset or not
 func isPodOnLatestVersion(ctx context.Context, c client.Client, pod *corev1.Pod, statefulSet *appsv1.StatefulSet) (bool, error) {
@@ -48,11 +153,67 @@ func isPodOnLatestVersion(ctx context.Context, c client.Client, pod *corev1.Pod,
 	}
 
 	// Compare the two
-	if podRevision == statefulSet.Status.UpdateRevision {
+	if podRevision != statefulSet.Status.UpdateRevision {
+		return false, nil
+	}
+
+	// A pod whose volume is on a stale storage class is not on the
+	// latest version either, as the volume has to be recreated.
+	onLatestStorage, err := isPodOnLatestStorageClass(ctx, c, pod, statefulSet)
+	if err != nil {
+		return false, err
+	}
+
+	return onLatestStorage, nil
+}
+
+// isPodOnLatestStorageClass returns if the snapshot volume of the given pod
+// uses the storage class requested in the volume claim template of the
+// given statefulset
+func isPodOnLatestStorageClass(ctx context.Context, c client.Client, pod *corev1.Pod, statefulSet *appsv1.StatefulSet) (bool, error) {
+	desired := getSnapshotStorageClassName(statefulSet)
+	if desired == "" {
 		return true, nil
 	}
 
-	return false, nil
+	var pvc corev1.PersistentVolumeClaim
+	if err := c.Get(ctx, types.NamespacedName{
+		Name:      fmt.Sprintf("%s-%s", resources.SnapshotVolumeName, pod.Name),
+		Namespace: pod.Namespace,
+	}, &pvc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	if pvc.Spec.StorageClassName == nil {
+		return false, nil
+	}
+
+	return *pvc.Spec.StorageClassName == desired, nil
+}
+
+// getSnapshotStorageClassName returns the storage class name of
+// the snapshot volume claim template of the given statefulset
+func getSnapshotStorageClassName(statefulSet *appsv1.StatefulSet) string {
+	if template := getSnapshotVolumeClaimTemplate(statefulSet); template != nil {
+		return *template.Spec.StorageClassName
+	}
+
+	return ""
+}
+
+// getSnapshotVolumeClaimTemplate returns the snapshot volume claim template
+// of the given statefulset, if it has a storage class
+func getSnapshotVolumeClaimTemplate(statefulSet *appsv1.StatefulSet) *corev1.PersistentVolumeClaim {
+	for i, vct := range statefulSet.Spec.VolumeClaimTemplates {
+		if vct.Name == resources.SnapshotVolumeName && vct.Spec.StorageClassName != nil {
+			return &statefulSet.Spec.VolumeClaimTemplates[i]
+		}
+	}
+
+	return nil
 }
 
 // getLatestReplica returns a replica pod which is on the latest version
@@ -72,7 +233,11 @@ func getLatestReplica(ctx context.Context, c client.Client, statefulSet *appsv1.
 	}
 
 	// Iterate over the pods and find a replica which is on the latest version
+	sortByFailoverPriority(podList.Items)
 	for _, pod := range podList.Items {
+		if getFailoverPriority(&pod) == 0 {
+			continue
+		}
 
 		isLatest, err := isPodOnLatestVersion(ctx, c, &pod, statefulSet)
 		if err != nil {
@@ -88,11 +253,363 @@ func getLatestReplica(ctx context.Context, c client.Client, statefulSet *appsv1.
 
 }
 
-// replTakeover runs the replTakeOver on the given replica pod
-func replTakeover(ctx context.Context, c client.Client, newMaster *corev1.Pod) error {
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: fmt.Sprintf("%s:%d", newMaster.Status.PodIP, resources.DragonflyAdminPort),
+// getReplicationBackoff returns the delay before retrying to configure
+// replication after the given number of consecutive failures
+func getReplicationBackoff(df *dfv1alpha1.Dragonfly, failures int) time.Duration {
+	initialDelay := defaultReplicationBackoffInitialDelay
+	multiplier := defaultReplicationBackoffMultiplier
+	maxDelay := defaultReplicationBackoffMaxDelay
+	if backoff := df.Spec.ReplicationBackoff; backoff != nil {
+		if backoff.InitialDelay != nil {
+			initialDelay = backoff.InitialDelay.Duration
+		}
+		if backoff.Multiplier != nil {
+			multiplier = *backoff.Multiplier
+		}
+		if backoff.MaxDelay != nil {
+			maxDelay = backoff.MaxDelay.Duration
+		}
+	}
+
+	delay := initialDelay
+	for i := 1; i < failures && delay < maxDelay; i++ {
+		delay *= time.Duration(multiplier)
+	}
+
+	if delay > maxDelay {
+		return maxDelay
+	}
+
+	return delay
+}
+
+// getFailoverPriority returns the failover priority of the given pod
+func getFailoverPriority(pod *corev1.Pod) int {
+	value, ok := pod.Annotations[resources.FailoverPriorityAnnotation]
+	if !ok {
+		return resources.DefaultFailoverPriority
+	}
+
+	priority, err := strconv.Atoi(value)
+	if err != nil || priority < 0 {
+		return resources.DefaultFailoverPriority
+	}
+
+	return priority
+}
+
+// isRolloutDue returns if the operator has to roll pods that aren't on the
+// latest version of the statefulset. Pods are never rolled with the OnDelete
+// update strategy, nor below the partition of the RollingUpdate strategy.
+func isRolloutDue(ctx context.Context, c client.Client, df *dfv1alpha1.Dragonfly, statefulSet *appsv1.StatefulSet) (bool, error) {
+	if df.Spec.UpdateStrategy != nil && df.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType {
+		return false, nil
+	}
+
+	var pods corev1.PodList
+	if err := c.List(ctx, &pods, client.InNamespace(df.Namespace), client.MatchingLabels(map[string]string{
+		"app":                               df.Name,
+		resources.KubernetesAppNameLabelKey: "dragonfly",
+	})); err != nil {
+		return false, err
+	}
+
+	for _, pod := range pods.Items {
+		if isPodPartitioned(df, &pod) {
+			continue
+		}
+
+		onLatestVersion, err := isPodOnLatestVersion(ctx, c, &pod, statefulSet)
+		if err != nil {
+			return false, err
+		}
+
+		if !onLatestVersion {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// isPodPartitioned returns if the given pod is below the partition of the
+// RollingUpdate strategy, and so isn't updated during rollouts
+func isPodPartitioned(df *dfv1alpha1.Dragonfly, pod *corev1.Pod) bool {
+	if df.Spec.UpdateStrategy == nil || df.Spec.UpdateStrategy.RollingUpdate == nil || df.Spec.UpdateStrategy.RollingUpdate.Partition == nil {
+		return false
+	}
+
+	ordinal, err := getPodOrdinal(pod)
+	if err != nil {
+		return false
+	}
+
+	return ordinal < int(*df.Spec.UpdateStrategy.RollingUpdate.Partition)
+}
+
+// getPodOrdinal returns the ordinal of the given statefulset pod
+func getPodOrdinal(pod *corev1.Pod) (int, error) {
+	i := strings.LastIndex(pod.Name, "-")
+	if i == -1 {
+		return 0, fmt.Errorf("pod %s has no ordinal", pod.Name)
+	}
+
+	return strconv.Atoi(pod.Name[i+1:])
+}
+
+// getMaxUnavailable returns the number of replicas that are updated at
+// the same time during a rollout
+func getMaxUnavailable(df *dfv1alpha1.Dragonfly) int {
+	if df.Spec.UpdateStrategy == nil || df.Spec.UpdateStrategy.RollingUpdate == nil || df.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable == nil {
+		return 1
+	}
+
+	return int(*df.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable)
+}
+
+// sortByFailoverPriority sorts the given pods so that the preferred
+// promotion candidates come first. Pods that are never promoted are last.
+func sortByFailoverPriority(pods []corev1.Pod) {
+	sort.SliceStable(pods, func(i, j int) bool {
+		pi, pj := getFailoverPriority(&pods[i]), getFailoverPriority(&pods[j])
+		if pi == 0 || pj == 0 {
+			return pj == 0 && pi != 0
+		}
+		return pi < pj
 	})
+}
+
+// isPodAvailable returns if the given pod has been ready for at least
+// minReadySeconds, and otherwise the remaining time until it is
+func isPodAvailable(pod *corev1.Pod, minReadySeconds int32) (bool, time.Duration) {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type != corev1.PodReady {
+			continue
+		}
+
+		if condition.Status != corev1.ConditionTrue {
+			return false, 0
+		}
+
+		remaining := time.Duration(minReadySeconds)*time.Second - time.Since(condition.LastTransitionTime.Time)
+		return remaining <= 0, remaining
+	}
+
+	return false, 0
+}
+
+// isNodeReady returns if the given node is ready
+func isNodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+// getNodeReadiness returns if the given node is ready, and since when it
+// is not. A node that doesn't exist anymore is not ready.
+func getNodeReadiness(ctx context.Context, c client.Client, name string) (bool, time.Time, error) {
+	var node corev1.Node
+	if err := c.Get(ctx, types.NamespacedName{Name: name}, &node); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, time.Time{}, nil
+		}
+		return false, time.Time{}, err
+	}
+
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue, condition.LastTransitionTime.Time, nil
+		}
+	}
+
+	return false, node.CreationTimestamp.Time, nil
+}
+
+// sortByLastSnapshot sorts the given pods so that the pods which saved
+// a snapshot most recently come first, as reported by INFO persistence.
+// Pods which can't report it keep their order after the others.
+func sortByLastSnapshot(ctx context.Context, pods []corev1.Pod) {
+	lastSnapshot := make(map[string]int64, len(pods))
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.PodIP == "" || pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+
+		info, err := getInfo(ctx, pod, "persistence")
+		if err != nil {
+			continue
+		}
+
+		if value, err := strconv.ParseInt(info["last_success_save"], 10, 64); err == nil {
+			lastSnapshot[pod.Name] = value
+		}
+	}
+
+	sort.SliceStable(pods, func(i, j int) bool {
+		return lastSnapshot[pods[i].Name] > lastSnapshot[pods[j].Name]
+	})
+}
+
+// sortByReplicationOffset sorts the given pods so that the pods with the
+// highest replication offset, i.e the most complete copy of the data of
+// the master, come first, as reported by INFO replication. The offsets are
+// queried without the cache, as they change with every write. Pods which
+// can't report it keep their order after the others.
+func sortByReplicationOffset(ctx context.Context, pods []corev1.Pod) {
+	offsets := make(map[string]int64, len(pods))
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.PodIP == "" || pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		info, err := fetchInfo(ctx, pod, "replication")
+		if err != nil {
+			continue
+		}
+
+		key := "slave_repl_offset"
+		if info["role"] == resources.Master {
+			key = "master_repl_offset"
+		}
+
+		if value, err := strconv.ParseInt(info[key], 10, 64); err == nil {
+			offsets[pod.Name] = value
+		}
+	}
+
+	sortByOffset(pods, offsets)
+}
+
+// sortByOffset sorts the given pods by their replication offsets, highest
+// first. Pods without an offset keep their order after the others.
+func sortByOffset(pods []corev1.Pod, offsets map[string]int64) {
+	sort.SliceStable(pods, func(i, j int) bool {
+		oi, iok := offsets[pods[i].Name]
+		oj, jok := offsets[pods[j].Name]
+		if iok != jok {
+			return iok
+		}
+		return oi > oj
+	})
+}
+
+// isLoadingSnapshot returns if the given pod is still loading its snapshot,
+// as reported by INFO persistence. Pods that can't report it aren't.
+func isLoadingSnapshot(ctx context.Context, pod *corev1.Pod) bool {
+	info, err := fetchInfo(ctx, pod, "persistence")
+	if err != nil {
+		return false
+	}
+
+	return info["loading"] == "1"
+}
+
+// getMasterPod returns the pod labeled as the master of the instance
+func getMasterPod(ctx context.Context, c client.Client, df *dfv1alpha1.Dragonfly) (*corev1.Pod, error) {
+	var pods corev1.PodList
+	if err := c.List(ctx, &pods, client.InNamespace(df.Namespace), client.MatchingLabels{
+		"app":                              df.Name,
+		resources.KubernetesPartOfLabelKey: "dragonfly",
+		resources.Role:                     resources.Master,
+	}); err != nil {
+		return nil, fmt.Errorf("could not list the master pod: %w", err)
+	}
+
+	if len(pods.Items) != 1 {
+		return nil, fmt.Errorf("expected one master, found %d", len(pods.Items))
+	}
+
+	return &pods.Items[0], nil
+}
+
+// newAdminClient returns a client connected to the admin port of the given
+// pod. TLS is used if the pod serves replication over TLS.
+func newAdminClient(ctx context.Context, pod *corev1.Pod) *redis.Client {
+	return redis.NewClient(getAdminClientOptions(ctx, pod))
+}
+
+// newBlockingAdminClient returns a client of the admin port of the given
+// pod for commands that may take longer than the read timeout. Its
+// commands time out with their context instead, which needs a deadline.
+func newBlockingAdminClient(ctx context.Context, pod *corev1.Pod) *redis.Client {
+	opts := getAdminClientOptions(ctx, pod)
+	opts.ReadTimeout = -1
+	opts.ContextTimeoutEnabled = true
+	return redis.NewClient(opts)
+}
+
+func getAdminClientOptions(ctx context.Context, pod *corev1.Pod) *redis.Options {
+	opts := &redis.Options{
+		Addr: fmt.Sprintf("%s:%d", pod.Status.PodIP, resources.DragonflyAdminPort),
+	}
+
+	for _, arg := range pod.Spec.Containers[0].Args {
+		if arg == resources.TLSReplicationArg {
+			opts.TLSConfig = getAdminTLSConfig(ctx, pod)
+			break
+		}
+	}
+
+	return opts
+}
+
+// updateMasterEndpoints points the master Service of the given instance to
+// the given pod, if the operator manages the endpoints of the Service
+func updateMasterEndpoints(ctx context.Context, c client.Client, df *dfv1alpha1.Dragonfly, master *corev1.Pod) error {
+	if !df.Spec.ManageMasterEndpoints {
+		return nil
+	}
+
+	endpointSlice, err := resources.GetMasterEndpointSlice(df, master)
+	if err != nil {
+		return err
+	}
+
+	var existing discoveryv1.EndpointSlice
+	if err := c.Get(ctx, client.ObjectKeyFromObject(endpointSlice), &existing); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		if err := c.Create(ctx, endpointSlice); err != nil {
+			return fmt.Errorf("error creating the master endpoint slice: %w", err)
+		}
+		return nil
+	}
+
+	// the address type is immutable, so a slice of another IP family is
+	// replaced
+	if existing.AddressType != endpointSlice.AddressType {
+		if err := c.Delete(ctx, &existing); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("error deleting the master endpoint slice: %w", err)
+		}
+
+		if err := c.Create(ctx, endpointSlice); err != nil {
+			return fmt.Errorf("error creating the master endpoint slice: %w", err)
+		}
+		return nil
+	}
+
+	existing.Labels = endpointSlice.Labels
+	existing.Annotations = endpointSlice.Annotations
+	existing.Endpoints = endpointSlice.Endpoints
+	existing.Ports = endpointSlice.Ports
+	if err := c.Update(ctx, &existing); err != nil {
+		return fmt.Errorf("error updating the master endpoint slice: %w", err)
+	}
+
+	return nil
+}
+
+// replTakeover runs the replTakeOver on the given replica pod
+func replTakeover(ctx context.Context, c client.Client, df *dfv1alpha1.Dragonfly, newMaster *corev1.Pod) error {
+	redisClient := newAdminClient(ctx, newMaster)
 
 	resp, err := redisClient.Do(ctx, "repltakeover", "10000").Result()
 	if err != nil {
@@ -102,12 +619,18 @@ func replTakeover(ctx context.Context, c client.Client, newMaster *corev1.Pod) e
 	if resp != "OK" {
 		return fmt.Errorf("response of `REPLTAKEOVER` on replica is not OK: %s", resp)
 	}
+	infoCache.invalidate(newMaster)
 
 	// update the label on the pod
 	newMaster.Labels[resources.Role] = resources.Master
 	if err := c.Update(ctx, newMaster); err != nil {
 		return fmt.Errorf("error updating the role label on the pod: %w", err)
 	}
+	recordNewMaster(ctx, newMaster.Name)
+
+	if err := updateMasterEndpoints(ctx, c, df, newMaster); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -153,22 +676,111 @@ func isStableState(ctx context.Context, c client.Client, pod *corev1.Pod) (bool,
 		return false, nil
 	}
 
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: fmt.Sprintf("%s:%d", pod.Status.PodIP, resources.DragonflyAdminPort),
-	})
+	data, err := getReplicationInfo(ctx, pod)
+	if err != nil {
+		return false, err
+	}
+
+	if data["master_sync_in_progress"] == "1" {
+		return false, nil
+	}
+
+	if data["master_link_status"] != "up" {
+		return false, nil
+	}
+
+	if data["master_last_io_seconds_ago"] == "-1" {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// killClientConnections closes the connections of clients on the client
+// port of a pod. Connections on the admin port, like the operator's, are kept.
+func killClientConnections(ctx context.Context, redisClient *redis.Client) error {
+	clients, err := redisClient.ClientList(ctx).Result()
+	if err != nil {
+		return err
+	}
+
+	clientPort := fmt.Sprintf(":%d", resources.DragonflyPort)
+	for _, line := range strings.Split(clients, "\n") {
+		var addr, laddr string
+		for _, field := range strings.Fields(line) {
+			if value, ok := strings.CutPrefix(field, "addr="); ok {
+				addr = value
+			} else if value, ok := strings.CutPrefix(field, "laddr="); ok {
+				laddr = value
+			}
+		}
+
+		if addr == "" || !strings.HasSuffix(laddr, clientPort) {
+			continue
+		}
+
+		if err := redisClient.ClientKill(ctx, addr).Err(); err != nil {
+			return fmt.Errorf("could not kill client %s: %w", addr, err)
+		}
+	}
+
+	return nil
+}
+
+// getReplicationInfo returns the replication section of INFO
+// of the given pod as key value pairs
+func getReplicationInfo(ctx context.Context, pod *corev1.Pod) (map[string]string, error) {
+	return getInfo(ctx, pod, "replication")
+}
+
+// getInfo returns the given section of INFO of the given pod
+// as key value pairs. Recent results are reused for infoCacheTTL.
+func getInfo(ctx context.Context, pod *corev1.Pod, section string) (map[string]string, error) {
+	if info, ok := infoCache.get(pod, section); ok {
+		return info, nil
+	}
+
+	info, err := fetchInfo(ctx, pod, section)
+	if err != nil {
+		return nil, err
+	}
+
+	infoCache.set(pod, section, info)
+	return info, nil
+}
+
+// fetchInfo queries the given section of INFO of the given pod,
+// bypassing the cache. The result is recorded as a probe of the pod.
+func fetchInfo(ctx context.Context, pod *corev1.Pod, section string) (map[string]string, error) {
+	if faults.dropsHealthChecks(pod) {
+		recordProbe(pod, 0, errInjectedFault)
+		return nil, errInjectedFault
+	}
+
+	start := time.Now()
+	info, err := queryInfo(ctx, pod, section)
+	recordProbe(pod, time.Since(start), err)
+
+	return info, err
+}
+
+// queryInfo queries the given section of INFO of the given pod
+func queryInfo(ctx context.Context, pod *corev1.Pod, section string) (map[string]string, error) {
+	redisClient := newAdminClient(ctx, pod)
+	defer redisClient.Close()
 
 	_, err := redisClient.Ping(ctx).Result()
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	info, err := redisClient.Info(ctx, "replication").Result()
+	info, err := redisClient.Info(ctx, section).Result()
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	if info == "" {
-		return false, errors.New("empty info")
+		return nil, errors.New("empty info")
 	}
 
 	data := map[string]string{}
@@ -176,21 +788,429 @@ func isStableState(ctx context.Context, c client.Client, pod *corev1.Pod) (bool,
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
-		kv := strings.Split(line, ":")
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
 		data[kv[0]] = strings.TrimSuffix(kv[1], "\r")
 	}
 
-	if data["master_sync_in_progress"] == "1" {
-		return false, nil
+	return data, nil
+}
+
+// waitForReplicaAcknowledgement waits until the given replica has acknowledged
+// all the writes the master had accepted when the wait started. Without
+// replication offsets, e.g on older Dragonfly versions, it waits until
+// the link of the replica is up and the master reports no lag for it.
+func waitForReplicaAcknowledgement(ctx context.Context, master, replica *corev1.Pod, maxDuration time.Duration) error {
+	masterInfo, err := fetchInfo(ctx, master, "replication")
+	if err != nil {
+		return fmt.Errorf("could not get replication info of master: %w", err)
 	}
 
-	if data["master_link_status"] != "up" {
-		return false, nil
+	var masterOffset int64
+	value, hasOffset := masterInfo["master_repl_offset"]
+	if hasOffset {
+		masterOffset, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse replication offset of master: %w", err)
+		}
 	}
 
-	if data["master_last_io_seconds_ago"] == "-1" {
-		return false, nil
+	ctx, cancel := context.WithTimeout(ctx, maxDuration)
+	defer cancel()
+	for {
+		replicaInfo, err := fetchInfo(ctx, replica, "replication")
+		if err != nil {
+			return fmt.Errorf("could not get replication info of replica: %w", err)
+		}
+
+		var caughtUp bool
+		var progress string
+		if value, ok := replicaInfo["slave_repl_offset"]; ok && hasOffset {
+			replicaOffset, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("could not parse replication offset of replica: %w", err)
+			}
+
+			caughtUp = replicaOffset >= masterOffset
+			progress = fmt.Sprintf("acknowledge offset %d, at %d", masterOffset, replicaOffset)
+		} else {
+			// the lag of the replica changes, so the master is asked again
+			masterInfo, err := fetchInfo(ctx, master, "replication")
+			if err != nil {
+				return fmt.Errorf("could not get replication info of master: %w", err)
+			}
+
+			caughtUp, progress, err = isReplicaCaughtUp(masterInfo, replicaInfo, replica.Status.PodIP)
+			if err != nil {
+				return fmt.Errorf("replica %s: %w", replica.Name, err)
+			}
+		}
+
+		if caughtUp {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for replica %s to %s", replica.Name, progress)
+		case <-time.After(time.Second):
+		}
 	}
+}
 
-	return true, nil
+// isReplicaCaughtUp returns if the replica with the given IP caught up
+// with the master according to their INFO replication without offsets,
+// i.e its link is up and synced, and the master reports it in stable sync
+// without lag, along with the progress otherwise. It's an error if the
+// replica doesn't report its link at all.
+func isReplicaCaughtUp(masterInfo, replicaInfo map[string]string, replicaIP string) (bool, string, error) {
+	linkStatus, ok := replicaInfo["master_link_status"]
+	if !ok {
+		return false, "", errors.New("reports neither its replication offset nor the status of its link")
+	}
+
+	if linkStatus != "up" {
+		return false, fmt.Sprintf("bring its link up, at %s", linkStatus), nil
+	}
+
+	if replicaInfo["master_sync_in_progress"] == "1" {
+		return false, "complete its full sync", nil
+	}
+
+	for key, value := range masterInfo {
+		// the replicas are reported as slave0, slave1, ...
+		index, ok := strings.CutPrefix(key, "slave")
+		if !ok {
+			continue
+		}
+
+		if _, err := strconv.Atoi(index); err != nil {
+			continue
+		}
+
+		fields := make(map[string]string)
+		for _, field := range strings.Split(value, ",") {
+			if k, v, ok := strings.Cut(field, "="); ok {
+				fields[k] = v
+			}
+		}
+
+		if fields["ip"] != replicaIP {
+			continue
+		}
+
+		if state := fields["state"]; state != "stable_sync" && state != "online" {
+			return false, fmt.Sprintf("reach stable sync, at %s", state), nil
+		}
+
+		lag, ok := fields["lag"]
+		if !ok {
+			return false, "", errors.New("the master reports no lag for it")
+		}
+
+		if lag != "0" {
+			return false, fmt.Sprintf("catch up, at a lag of %s", lag), nil
+		}
+
+		return true, "", nil
+	}
+
+	return false, "be reported by the master", nil
+}
+
+// getReferencedSecretNames returns the names of the secrets in the namespace
+// of the Dragonfly object that are referenced by its spec
+func getReferencedSecretNames(df *dfv1alpha1.Dragonfly) []string {
+	names := make([]string, 0)
+	if df.Spec.TLSSecretRef != nil {
+		names = append(names, df.Spec.TLSSecretRef.Name)
+	}
+
+	if df.Spec.TLS != nil && df.Spec.TLS.CertManager != nil {
+		names = append(names, resources.GetServerCertificateSecretName(df))
+	}
+
+	if df.Spec.ReplicationTLS != nil && df.Spec.ReplicationTLS.CASecretRef != nil {
+		names = append(names, df.Spec.ReplicationTLS.CASecretRef.Name)
+	}
+
+	if df.Spec.Authentication != nil {
+		if df.Spec.Authentication.PasswordFromSecret != nil {
+			names = append(names, df.Spec.Authentication.PasswordFromSecret.Name)
+		}
+
+		if df.Spec.Authentication.ClientCaCertSecret != nil {
+			names = append(names, df.Spec.Authentication.ClientCaCertSecret.Name)
+		}
+	}
+
+	for _, env := range df.Spec.Env {
+		if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+			names = append(names, env.ValueFrom.SecretKeyRef.Name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// getReferencedConfigMapNames returns the names of the config maps
+// referenced by the Dragonfly object
+func getReferencedConfigMapNames(df *dfv1alpha1.Dragonfly) []string {
+	names := make([]string, 0)
+	for _, env := range df.Spec.Env {
+		if env.ValueFrom != nil && env.ValueFrom.ConfigMapKeyRef != nil {
+			names = append(names, env.ValueFrom.ConfigMapKeyRef.Name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// setSecretsHash annotates the pod template of the statefulset with a hash
+// of the referenced secrets, so that a change in any of them (e.g a sync
+// by external-secrets or a rotation) rolls the pods. Secrets that don't
+// exist yet are hashed as empty.
+func setSecretsHash(ctx context.Context, c client.Client, df *dfv1alpha1.Dragonfly, statefulSet *appsv1.StatefulSet) error {
+	names := getReferencedSecretNames(df)
+	if len(names) == 0 {
+		return nil
+	}
+
+	hash := sha256.New()
+	for _, name := range names {
+		var secret corev1.Secret
+		if err := c.Get(ctx, types.NamespacedName{Namespace: df.Namespace, Name: name}, &secret); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+		}
+
+		keys := make([]string, 0, len(secret.Data))
+		for key := range secret.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		hash.Write([]byte(name))
+		for _, key := range keys {
+			hash.Write([]byte(key))
+			hash.Write(secret.Data[key])
+		}
+	}
+
+	if statefulSet.Spec.Template.Annotations == nil {
+		statefulSet.Spec.Template.Annotations = make(map[string]string)
+	}
+	statefulSet.Spec.Template.Annotations[resources.SecretsHashAnnotation] = hex.EncodeToString(hash.Sum(nil))
+
+	return nil
+}
+
+// setConfigMapsHash annotates the pod template of the statefulset with a
+// hash of the referenced config maps, so that a change in any of them
+// rolls the pods. Config maps that don't exist yet are hashed as empty.
+func setConfigMapsHash(ctx context.Context, c client.Client, df *dfv1alpha1.Dragonfly, statefulSet *appsv1.StatefulSet) error {
+	names := getReferencedConfigMapNames(df)
+	if len(names) == 0 {
+		return nil
+	}
+
+	hash := sha256.New()
+	for _, name := range names {
+		var configMap corev1.ConfigMap
+		if err := c.Get(ctx, types.NamespacedName{Namespace: df.Namespace, Name: name}, &configMap); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+		}
+
+		keys := make([]string, 0, len(configMap.Data))
+		for key := range configMap.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		hash.Write([]byte(name))
+		for _, key := range keys {
+			hash.Write([]byte(key))
+			hash.Write([]byte(configMap.Data[key]))
+		}
+	}
+
+	if statefulSet.Spec.Template.Annotations == nil {
+		statefulSet.Spec.Template.Annotations = make(map[string]string)
+	}
+	statefulSet.Spec.Template.Annotations[resources.ConfigMapsHashAnnotation] = hex.EncodeToString(hash.Sum(nil))
+
+	return nil
+}
+
+// createOrUpdateUnstructured creates or updates a resource of a kind that
+// isn't known to the operator. Unlike built-in kinds, custom resources can
+// only be updated with their current resource version.
+func createOrUpdateUnstructured(ctx context.Context, c client.Client, object *unstructured.Unstructured) error {
+	var existing unstructured.Unstructured
+	existing.SetGroupVersionKind(object.GroupVersionKind())
+	if err := c.Get(ctx, client.ObjectKeyFromObject(object), &existing); err != nil {
+		if apierrors.IsNotFound(err) {
+			return c.Create(ctx, object)
+		}
+		return err
+	}
+
+	object.SetResourceVersion(existing.GetResourceVersion())
+	return c.Update(ctx, object)
+}
+
+// createOrUpdatePodDisruptionBudget creates or updates the given
+// PodDisruptionBudget, which can only be updated with its current
+// resource version
+func createOrUpdatePodDisruptionBudget(ctx context.Context, c client.Client, pdb *policyv1.PodDisruptionBudget) error {
+	var existing policyv1.PodDisruptionBudget
+	if err := c.Get(ctx, client.ObjectKeyFromObject(pdb), &existing); err != nil {
+		if apierrors.IsNotFound(err) {
+			return c.Create(ctx, pdb)
+		}
+		return err
+	}
+
+	pdb.SetResourceVersion(existing.GetResourceVersion())
+	return c.Update(ctx, pdb)
+}
+
+// deleteMasterPodDisruptionBudget deletes the PodDisruptionBudget of the
+// master once eviction protection is disabled, so that it doesn't keep
+// blocking the eviction of the master
+func deleteMasterPodDisruptionBudget(ctx context.Context, c client.Client, df *dfv1alpha1.Dragonfly) error {
+	pdb := resources.GetMasterPodDisruptionBudget(df)
+	return client.IgnoreNotFound(c.Delete(ctx, pdb))
+}
+
+// deleteMetricsService deletes the metrics Service once the metrics are
+// no longer exposed
+func deleteMetricsService(ctx context.Context, c client.Client, df *dfv1alpha1.Dragonfly) error {
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: df.Namespace, Name: resources.GetMetricsServiceName(df)}}
+	return client.IgnoreNotFound(c.Delete(ctx, service))
+}
+
+// deleteServiceMonitor deletes the ServiceMonitor once it's no longer
+// generated, if the Prometheus Operator is installed
+func deleteServiceMonitor(ctx context.Context, c client.Client, df *dfv1alpha1.Dragonfly) error {
+	var monitor unstructured.Unstructured
+	monitor.SetGroupVersionKind(resources.ServiceMonitorGVK)
+	monitor.SetNamespace(df.Namespace)
+	monitor.SetName(resources.GetServiceMonitorName(df))
+	if err := c.Delete(ctx, &monitor); err != nil && !apierrors.IsNotFound(err) && !meta.IsNoMatchError(err) {
+		return err
+	}
+
+	return nil
+}
+
+// isUninstalledServiceMonitor returns if the object is a ServiceMonitor
+// while the Prometheus Operator isn't installed, so that it's skipped
+func isUninstalledServiceMonitor(c client.Client, object client.Object) bool {
+	if object.GetObjectKind().GroupVersionKind() != resources.ServiceMonitorGVK {
+		return false
+	}
+
+	_, err := c.RESTMapper().RESTMapping(resources.ServiceMonitorGVK.GroupKind(), resources.ServiceMonitorGVK.Version)
+	return err != nil
+}
+
+// deleteReplicaService deletes the replica Service once the replicas are
+// no longer exposed
+func deleteReplicaService(ctx context.Context, c client.Client, df *dfv1alpha1.Dragonfly) error {
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: df.Namespace, Name: resources.GetReplicaServiceName(df)}}
+	return client.IgnoreNotFound(c.Delete(ctx, service))
+}
+
+// reconcileConnectionSecret creates or updates the connection Secret of
+// the Dragonfly object with its current password and TLS CA
+func reconcileConnectionSecret(ctx context.Context, c client.Client, df *dfv1alpha1.Dragonfly) error {
+	password := resources.GetPlainPassword(df)
+	if df.Spec.Authentication != nil && df.Spec.Authentication.PasswordFromSecret != nil {
+		value, err := getSecretValue(ctx, c, df.Namespace, df.Spec.Authentication.PasswordFromSecret.Name, df.Spec.Authentication.PasswordFromSecret.Key)
+		if err != nil {
+			return fmt.Errorf("could not get password: %w", err)
+		}
+		password = string(value)
+	} else if env := resources.GetPasswordEnv(df); env != nil && env.ValueFrom != nil {
+		value, err := getEnvVarValue(ctx, c, df.Namespace, env)
+		if err != nil {
+			return fmt.Errorf("could not get password: %w", err)
+		}
+		password = value
+	}
+
+	var caCert []byte
+	if name := resources.GetTLSSecretName(df); name != "" {
+		value, err := getSecretValue(ctx, c, df.Namespace, name, resources.GetTLSCAKey(df))
+		if err != nil {
+			return fmt.Errorf("could not get TLS CA: %w", err)
+		}
+		caCert = value
+	}
+
+	secret := resources.GetConnectionSecret(df, password, caCert)
+
+	var existing corev1.Secret
+	if err := c.Get(ctx, client.ObjectKeyFromObject(secret), &existing); err != nil {
+		if apierrors.IsNotFound(err) {
+			return c.Create(ctx, secret)
+		}
+		return err
+	}
+
+	existing.Labels = secret.Labels
+	existing.Annotations = secret.Annotations
+	existing.Data = secret.Data
+	return c.Update(ctx, &existing)
+}
+
+// getEnvVarValue returns the value of the env var, read from its secret or
+// config map if it has a source
+func getEnvVarValue(ctx context.Context, c client.Client, namespace string, env *corev1.EnvVar) (string, error) {
+	if env.ValueFrom == nil {
+		return env.Value, nil
+	}
+
+	switch {
+	case env.ValueFrom.SecretKeyRef != nil:
+		value, err := getSecretValue(ctx, c, namespace, env.ValueFrom.SecretKeyRef.Name, env.ValueFrom.SecretKeyRef.Key)
+		return string(value), err
+	case env.ValueFrom.ConfigMapKeyRef != nil:
+		ref := env.ValueFrom.ConfigMapKeyRef
+		var configMap corev1.ConfigMap
+		if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, &configMap); err != nil {
+			return "", err
+		}
+
+		value, ok := configMap.Data[ref.Key]
+		if !ok {
+			return "", fmt.Errorf("config map %s/%s has no key %s", namespace, ref.Name, ref.Key)
+		}
+		return value, nil
+	}
+
+	return "", fmt.Errorf("the source of env var %s isn't supported, only secrets and config maps are", env.Name)
+}
+
+// getSecretValue returns the value of a key of a secret
+func getSecretValue(ctx context.Context, c client.Client, namespace, name, key string) ([]byte, error) {
+	var secret corev1.Secret
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &secret); err != nil {
+		return nil, err
+	}
+
+	value, ok := secret.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("secret %s/%s has no key %s", namespace, name, key)
+	}
+
+	return value, nil
 }
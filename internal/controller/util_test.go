@@ -0,0 +1,239 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+func TestSortByOffsetAndFailoverPriority(t *testing.T) {
+	type pod struct {
+		name     string
+		priority string
+	}
+
+	tests := []struct {
+		name    string
+		pods    []pod
+		offsets map[string]int64
+		want    []string
+	}{
+		{
+			name:    "the highest offset comes first",
+			pods:    []pod{{name: "df-0"}, {name: "df-1"}, {name: "df-2"}},
+			offsets: map[string]int64{"df-0": 10, "df-1": 30, "df-2": 20},
+			want:    []string{"df-1", "df-2", "df-0"},
+		},
+		{
+			name:    "pods without an offset keep their order last",
+			pods:    []pod{{name: "df-0"}, {name: "df-1"}, {name: "df-2"}},
+			offsets: map[string]int64{"df-1": 10},
+			want:    []string{"df-1", "df-0", "df-2"},
+		},
+		{
+			name:    "the priority comes before the offset",
+			pods:    []pod{{name: "df-0", priority: "200"}, {name: "df-1", priority: "200"}, {name: "df-2", priority: "10"}},
+			offsets: map[string]int64{"df-0": 10, "df-1": 30, "df-2": 5},
+			want:    []string{"df-2", "df-1", "df-0"},
+		},
+		{
+			name:    "pods with priority 0 are last whatever their offset",
+			pods:    []pod{{name: "df-0", priority: "0"}, {name: "df-1"}, {name: "df-2"}},
+			offsets: map[string]int64{"df-0": 100, "df-1": 10, "df-2": 20},
+			want:    []string{"df-2", "df-1", "df-0"},
+		},
+		{
+			name:    "invalid priorities are the default one",
+			pods:    []pod{{name: "df-0", priority: "high"}, {name: "df-1", priority: "-1"}, {name: "df-2"}},
+			offsets: map[string]int64{"df-0": 10, "df-1": 20, "df-2": 30},
+			want:    []string{"df-2", "df-1", "df-0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pods := make([]corev1.Pod, 0, len(tt.pods))
+			for _, p := range tt.pods {
+				pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: p.name}}
+				if p.priority != "" {
+					pod.Annotations = map[string]string{resources.FailoverPriorityAnnotation: p.priority}
+				}
+				pods = append(pods, pod)
+			}
+
+			sortByOffset(pods, tt.offsets)
+			sortByFailoverPriority(pods)
+
+			got := make([]string, 0, len(pods))
+			for _, pod := range pods {
+				got = append(got, pod.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got order %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetReplicationBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		backoff  *dfv1alpha1.Backoff
+		failures int
+		want     time.Duration
+	}{
+		{
+			name:     "the first failure waits the initial delay",
+			failures: 1,
+			want:     defaultReplicationBackoffInitialDelay,
+		},
+		{
+			name:     "no failures wait the initial delay",
+			failures: 0,
+			want:     defaultReplicationBackoffInitialDelay,
+		},
+		{
+			name:     "the delay grows with the failures",
+			failures: 3,
+			want:     4 * defaultReplicationBackoffInitialDelay,
+		},
+		{
+			name:     "the delay is capped",
+			failures: 100,
+			want:     defaultReplicationBackoffMaxDelay,
+		},
+		{
+			name: "the spec overrides the defaults",
+			backoff: &dfv1alpha1.Backoff{
+				InitialDelay: &metav1.Duration{Duration: time.Second},
+				Multiplier:   pointer.Int32(3),
+				MaxDelay:     &metav1.Duration{Duration: time.Minute},
+			},
+			failures: 3,
+			want:     9 * time.Second,
+		},
+		{
+			name: "the spec caps the delay",
+			backoff: &dfv1alpha1.Backoff{
+				InitialDelay: &metav1.Duration{Duration: time.Second},
+				Multiplier:   pointer.Int32(3),
+				MaxDelay:     &metav1.Duration{Duration: 10 * time.Second},
+			},
+			failures: 4,
+			want:     10 * time.Second,
+		},
+		{
+			name: "a multiplier of 1 keeps the delay",
+			backoff: &dfv1alpha1.Backoff{
+				Multiplier: pointer.Int32(1),
+			},
+			failures: 10,
+			want:     defaultReplicationBackoffInitialDelay,
+		},
+		{
+			name: "an initial delay above the maximum is capped",
+			backoff: &dfv1alpha1.Backoff{
+				InitialDelay: &metav1.Duration{Duration: time.Hour},
+			},
+			failures: 1,
+			want:     defaultReplicationBackoffMaxDelay,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df := &dfv1alpha1.Dragonfly{Spec: dfv1alpha1.DragonflySpec{ReplicationBackoff: tt.backoff}}
+			if got := getReplicationBackoff(df, tt.failures); got != tt.want {
+				t.Errorf("getReplicationBackoff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsReplicaCaughtUp(t *testing.T) {
+	tests := []struct {
+		name        string
+		masterInfo  map[string]string
+		replicaInfo map[string]string
+		want        bool
+		wantErr     bool
+	}{
+		{
+			name:        "caught up",
+			masterInfo:  map[string]string{"connected_slaves": "2", "slave0": "ip=10.0.0.3,port=9999,state=stable_sync,lag=2", "slave1": "ip=10.0.0.2,port=9999,state=stable_sync,lag=0"},
+			replicaInfo: map[string]string{"master_link_status": "up", "master_sync_in_progress": "0"},
+			want:        true,
+		},
+		{
+			name:        "lagging",
+			masterInfo:  map[string]string{"slave0": "ip=10.0.0.2,port=9999,state=stable_sync,lag=1"},
+			replicaInfo: map[string]string{"master_link_status": "up"},
+			want:        false,
+		},
+		{
+			name:        "full sync",
+			masterInfo:  map[string]string{"slave0": "ip=10.0.0.2,port=9999,state=full_sync,lag=0"},
+			replicaInfo: map[string]string{"master_link_status": "up"},
+			want:        false,
+		},
+		{
+			name:        "link down",
+			masterInfo:  map[string]string{"slave0": "ip=10.0.0.2,port=9999,state=stable_sync,lag=0"},
+			replicaInfo: map[string]string{"master_link_status": "down"},
+			want:        false,
+		},
+		{
+			name:        "unknown to the master",
+			masterInfo:  map[string]string{"slave0": "ip=10.0.0.3,port=9999,state=stable_sync,lag=0"},
+			replicaInfo: map[string]string{"master_link_status": "up"},
+			want:        false,
+		},
+		{
+			name:        "no lag",
+			masterInfo:  map[string]string{"slave0": "ip=10.0.0.2,port=9999,state=stable_sync"},
+			replicaInfo: map[string]string{"master_link_status": "up"},
+			wantErr:     true,
+		},
+		{
+			name:        "no link status",
+			masterInfo:  map[string]string{"slave0": "ip=10.0.0.2,port=9999,state=stable_sync,lag=0"},
+			replicaInfo: map[string]string{"role": "replica"},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := isReplicaCaughtUp(tt.masterInfo, tt.replicaInfo, "10.0.0.2")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("isReplicaCaughtUp() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("isReplicaCaughtUp() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// getImageMajorVersion returns the major version of the tag of the given
+// image, e.g 1 for dragonfly:v1.12.0. Images pinned by digest or with
+// tags that aren't versions have no known version.
+func getImageMajorVersion(image string) (string, bool) {
+	if strings.Contains(image, "@") {
+		return "", false
+	}
+
+	name := image[strings.LastIndex(image, "/")+1:]
+	_, tag, ok := strings.Cut(name, ":")
+	if !ok {
+		return "", false
+	}
+
+	major, _, _ := strings.Cut(strings.TrimPrefix(tag, "v"), ".")
+	if _, err := strconv.Atoi(major); err != nil {
+		return "", false
+	}
+
+	return major, true
+}
+
+// checkMajorVersionUpgrade returns if the update of the statefulset is
+// blocked, as it changes the major version of Dragonfly without being
+// allowed. It's checked before the statefulset is updated, as pods that
+// are recreated for other reasons would run the new version otherwise.
+// The UpgradeBlocked condition is updated accordingly, and allowed major
+// version changes are recorded in the status until their rollout
+// completed.
+func (r *DragonflyReconciler) checkMajorVersionUpgrade(ctx context.Context, df *dfv1alpha1.Dragonfly, current, desired *appsv1.StatefulSet) (bool, error) {
+	status := df.Status.DeepCopy()
+
+	blocked := false
+	var message string
+	from, fromKnown := getImageMajorVersion(current.Spec.Template.Spec.Containers[0].Image)
+	to, toKnown := getImageMajorVersion(desired.Spec.Template.Spec.Containers[0].Image)
+	if fromKnown && toKnown && from != to {
+		if df.Spec.VersionUpgrade != nil && df.Spec.VersionUpgrade.AllowMajor {
+			df.Status.PreviousMajorVersion = from
+		} else {
+			blocked = true
+			message = fmt.Sprintf("update from major version %s to %s is blocked, as the snapshots of version %s may not be loadable by version %s. Set spec.versionUpgrade.allowMajor to roll it out", from, to, to, from)
+		}
+	}
+
+	if blocked {
+		meta.SetStatusCondition(&df.Status.Conditions, metav1.Condition{
+			Type:               ConditionUpgradeBlocked,
+			Status:             metav1.ConditionTrue,
+			Reason:             "MajorVersionUpgrade",
+			Message:            message,
+			ObservedGeneration: df.Generation,
+		})
+	} else {
+		meta.RemoveStatusCondition(&df.Status.Conditions, ConditionUpgradeBlocked)
+	}
+
+	if equality.Semantic.DeepEqual(status, &df.Status) {
+		return blocked, nil
+	}
+
+	if err := r.Status().Update(ctx, df); err != nil {
+		return blocked, err
+	}
+
+	if blocked {
+		r.EventRecorder.Event(df, corev1.EventTypeWarning, "Rollout", fmt.Sprintf("Blocked: %s", message))
+	}
+
+	return blocked, nil
+}
+
+// completeMajorVersionUpgrade starts saving a snapshot in the format of
+// the new version on the given master, if requested, once the rollout to another
+// major version completed. The status of the given object has to be
+// updated by the caller.
+func (r *DragonflyReconciler) completeMajorVersionUpgrade(ctx context.Context, df *dfv1alpha1.Dragonfly, master *corev1.Pod) {
+	if df.Status.PreviousMajorVersion == "" {
+		return
+	}
+
+	if df.Spec.VersionUpgrade != nil && df.Spec.VersionUpgrade.SaveAfterMajorUpgrade {
+		if save, err := startSnapshot(ctx, master, SaveReasonMajorVersionUpgrade); err != nil {
+			r.EventRecorder.Event(df, corev1.EventTypeWarning, "Snapshot", fmt.Sprintf("Could not save a snapshot in the new version on master %s: %s", master.Name, err))
+		} else {
+			df.Status.Save = save
+			r.EventRecorder.Event(df, corev1.EventTypeNormal, "Snapshot", fmt.Sprintf("Saving a snapshot in the new version on master %s", master.Name))
+		}
+	}
+
+	df.Status.PreviousMajorVersion = ""
+}
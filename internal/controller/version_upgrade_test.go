@@ -0,0 +1,47 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import "testing"
+
+func TestGetImageMajorVersion(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+		ok    bool
+	}{
+		{image: "docker.dragonflydb.io/dragonflydb/dragonfly:v1.12.0", want: "1", ok: true},
+		{image: "dragonfly:v2.0.1", want: "2", ok: true},
+		{image: "dragonfly:1.12", want: "1", ok: true},
+		{image: "dragonfly:v1", want: "1", ok: true},
+		{image: "localhost:5000/dragonfly:v1.12.0", want: "1", ok: true},
+		{image: "localhost:5000/dragonfly", ok: false},
+		{image: "dragonfly", ok: false},
+		{image: "dragonfly:latest", ok: false},
+		{image: "dragonfly:v1.12.0@sha256:0123456789abcdef", ok: false},
+		{image: "dragonfly@sha256:0123456789abcdef", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			got, ok := getImageMajorVersion(tt.image)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("getImageMajorVersion(%q) = %q, %v, want %q, %v", tt.image, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package health provides the readiness checks of the operator, so that
+// deployments that can't work fail their readiness probe instead of
+// silently not reconciling.
+package health
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	authorizationclientv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+const (
+	// cacheSyncTimeout is how long the cache sync check waits for the
+	// informers to be synced
+	cacheSyncTimeout = time.Second
+
+	// accessCheckInterval is how often the permissions of the operator
+	// are checked against the API server
+	accessCheckInterval = time.Minute
+)
+
+// RequiredAccess are the permissions the operator can't work without
+var RequiredAccess = []authorizationv1.ResourceAttributes{
+	{Group: "dragonflydb.io", Resource: "dragonflies", Verb: "list"},
+	{Group: "dragonflydb.io", Resource: "dragonflies", Subresource: "status", Verb: "update"},
+	{Group: "apps", Resource: "statefulsets", Verb: "update"},
+	{Resource: "pods", Verb: "update"},
+	{Resource: "services", Verb: "update"},
+}
+
+// NamespacedAccess returns the given permissions in each of the given
+// namespaces, for operators that only manage the objects of these
+func NamespacedAccess(access []authorizationv1.ResourceAttributes, namespaces []string) []authorizationv1.ResourceAttributes {
+	var namespaced []authorizationv1.ResourceAttributes
+	for _, namespace := range namespaces {
+		for _, attributes := range access {
+			attributes.Namespace = namespace
+			namespaced = append(namespaced, attributes)
+		}
+	}
+
+	return namespaced
+}
+
+// CacheSynced returns a check that fails until the informers of the
+// given cache are synced
+func CacheSynced(c cache.Cache) healthz.Checker {
+	return func(req *http.Request) error {
+		ctx, cancel := context.WithTimeout(req.Context(), cacheSyncTimeout)
+		defer cancel()
+
+		if !c.WaitForCacheSync(ctx) {
+			return errors.New("informer caches are not synced")
+		}
+
+		return nil
+	}
+}
+
+// Access returns a check that fails if the operator lacks any of the
+// given permissions, or can't reach the API server to verify them. The
+// result is reused for accessCheckInterval.
+func Access(client authorizationclientv1.SelfSubjectAccessReviewInterface, access []authorizationv1.ResourceAttributes) healthz.Checker {
+	var (
+		mu        sync.Mutex
+		lastCheck time.Time
+		lastErr   error
+	)
+
+	return func(req *http.Request) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !lastCheck.IsZero() && lastErr == nil && time.Since(lastCheck) < accessCheckInterval {
+			return nil
+		}
+
+		lastCheck = time.Now()
+		lastErr = checkAccess(req.Context(), client, access)
+		return lastErr
+	}
+}
+
+func checkAccess(ctx context.Context, client authorizationclientv1.SelfSubjectAccessReviewInterface, access []authorizationv1.ResourceAttributes) error {
+	for _, attributes := range access {
+		attributes := attributes
+		review, err := client.Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &attributes,
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("could not reach the API server: %w", err)
+		}
+
+		if !review.Status.Allowed && attributes.Namespace != "" {
+			return fmt.Errorf("missing permission to %s %s/%s %s in namespace %s", attributes.Verb, attributes.Group, attributes.Resource, attributes.Subresource, attributes.Namespace)
+		}
+
+		if !review.Status.Allowed {
+			return fmt.Errorf("missing permission to %s %s/%s %s", attributes.Verb, attributes.Group, attributes.Resource, attributes.Subresource)
+		}
+	}
+
+	return nil
+}
+
+// Certificate returns a check that fails if the PEM certificate at the
+// given path can't be read, isn't valid yet or has expired
+func Certificate(path string) healthz.Checker {
+	return func(_ *http.Request) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("could not read certificate: %w", err)
+		}
+
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return fmt.Errorf("no PEM certificate in %s", path)
+		}
+
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("could not parse certificate: %w", err)
+		}
+
+		now := time.Now()
+		if now.Before(certificate.NotBefore) {
+			return fmt.Errorf("certificate is not valid before %s", certificate.NotBefore.Format(time.RFC3339))
+		}
+
+		if now.After(certificate.NotAfter) {
+			return fmt.Errorf("certificate expired at %s", certificate.NotAfter.Format(time.RFC3339))
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,330 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package notifications posts the events of Dragonfly objects to external
+// services like Slack, Microsoft Teams or generic webhooks.
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	WebhookTypeSlack   = "slack"
+	WebhookTypeTeams   = "teams"
+	WebhookTypeGeneric = "generic"
+
+	defaultTemplate = "{{ .Namespace }}/{{ .Name }}: [{{ .Reason }}] {{ .Message }}"
+
+	webhookTimeout = 10 * time.Second
+
+	// queueSize is the number of notifications that may wait for a
+	// worker. Further notifications are dropped until the webhooks catch
+	// up.
+	queueSize = 100
+
+	// workers is the number of notifications posted concurrently
+	workers = 4
+
+	// dedupInterval is how long the same notification isn't posted again
+	// to a webhook, e.g when an event is recorded on every reconcile
+	dedupInterval = 5 * time.Minute
+)
+
+// Config is the configuration of the notifications, loaded from the file
+// passed to the operator with --notifications-config
+type Config struct {
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+type Webhook struct {
+	// Name of the webhook, used in logs
+	Name string `json:"name"`
+
+	// Type of the webhook, one of slack, teams or generic
+	Type string `json:"type"`
+
+	// URL to post the notifications to
+	URL string `json:"url"`
+
+	// Reasons of the events to notify about, e.g Rollout or Replication.
+	// Only Warning events are notified about if empty.
+	Reasons []string `json:"reasons,omitempty"`
+
+	// Go template of the message. Its data is a Notification.
+	Template string `json:"template,omitempty"`
+}
+
+// Notification is the data of a message template and the payload of
+// generic webhooks
+type Notification struct {
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Reason    string    `json:"reason"`
+	Message   string    `json:"message"`
+	Time      time.Time `json:"time"`
+	Text      string    `json:"text"`
+}
+
+// LoadConfig reads and validates the notifications configuration file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, err
+	}
+
+	for _, webhook := range config.Webhooks {
+		switch webhook.Type {
+		case WebhookTypeSlack, WebhookTypeTeams, WebhookTypeGeneric:
+		default:
+			return nil, fmt.Errorf("webhook %s has an unknown type %q", webhook.Name, webhook.Type)
+		}
+
+		if webhook.URL == "" {
+			return nil, fmt.Errorf("webhook %s has no url", webhook.Name)
+		}
+
+		if _, err := template.New(webhook.Name).Parse(webhook.Template); err != nil {
+			return nil, fmt.Errorf("webhook %s has an invalid template: %w", webhook.Name, err)
+		}
+	}
+
+	return &config, nil
+}
+
+// Recorder is an EventRecorder that also posts the recorded events
+// to the configured webhooks
+type Recorder struct {
+	record.EventRecorder
+
+	config *Config
+	client *http.Client
+
+	// queue holds the notifications that wait for a worker
+	queue chan delivery
+
+	mu sync.Mutex
+	// posted is when each notification was last queued, for the dedup
+	posted map[delivery]time.Time
+}
+
+// delivery is a notification to post to a webhook. The time of the
+// notification is left out of the dedup key.
+type delivery struct {
+	webhook      int
+	notification Notification
+}
+
+// NewRecorder returns a Recorder wrapping the given EventRecorder, and
+// starts the workers that post its notifications
+func NewRecorder(recorder record.EventRecorder, config *Config) *Recorder {
+	r := &Recorder{
+		EventRecorder: recorder,
+		config:        config,
+		client:        &http.Client{Timeout: webhookTimeout},
+		queue:         make(chan delivery, queueSize),
+		posted:        make(map[delivery]time.Time),
+	}
+
+	for i := 0; i < workers; i++ {
+		go r.work()
+	}
+
+	return r
+}
+
+// work posts the queued notifications
+func (r *Recorder) work() {
+	for d := range r.queue {
+		webhook := r.config.Webhooks[d.webhook]
+		if err := r.post(webhook, d.notification); err != nil {
+			ctrl.Log.WithName("notifications").Error(err, "could not post notification", "webhook", webhook.Name)
+		}
+	}
+}
+
+func (r *Recorder) Event(object runtime.Object, eventtype, reason, message string) {
+	r.EventRecorder.Event(object, eventtype, reason, message)
+	r.notify(object, eventtype, reason, message)
+}
+
+func (r *Recorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.EventRecorder.Eventf(object, eventtype, reason, messageFmt, args...)
+	r.notify(object, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r *Recorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.EventRecorder.AnnotatedEventf(object, annotations, eventtype, reason, messageFmt, args...)
+	r.notify(object, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+// notify queues the event for the matching webhooks, so that a slow
+// webhook doesn't block reconciliation. Notifications that were already
+// queued within the dedup interval are skipped, and those that don't fit
+// in the queue are dropped.
+func (r *Recorder) notify(object runtime.Object, eventtype, reason, message string) {
+	notification := Notification{
+		Kind:    object.GetObjectKind().GroupVersionKind().Kind,
+		Type:    eventtype,
+		Reason:  reason,
+		Message: message,
+	}
+
+	if o, ok := object.(client.Object); ok {
+		notification.Namespace = o.GetNamespace()
+		notification.Name = o.GetName()
+	}
+
+	now := time.Now()
+	for i, webhook := range r.config.Webhooks {
+		if !matchesEvent(webhook.Reasons, eventtype, reason) {
+			continue
+		}
+
+		d := delivery{webhook: i, notification: notification}
+		if !r.dedup(d, now) {
+			continue
+		}
+
+		d.notification.Time = now
+		select {
+		case r.queue <- d:
+		default:
+			ctrl.Log.WithName("notifications").Info("dropping notification, as the queue is full", "webhook", webhook.Name, "reason", reason)
+		}
+	}
+}
+
+// dedup returns if the delivery wasn't queued within the dedup interval,
+// and records it. The records older than the interval are forgotten.
+func (r *Recorder) dedup(d delivery, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, posted := range r.posted {
+		if now.Sub(posted) >= dedupInterval {
+			delete(r.posted, key)
+		}
+	}
+
+	if _, ok := r.posted[d]; ok {
+		return false
+	}
+
+	r.posted[d] = now
+	return true
+}
+
+func (r *Recorder) post(webhook Webhook, notification Notification) error {
+	text, err := renderMessage(webhook, notification)
+	if err != nil {
+		return err
+	}
+	notification.Text = text
+
+	var payload interface{}
+	switch webhook.Type {
+	case WebhookTypeSlack, WebhookTypeTeams:
+		// both incoming webhooks accept a plain text message
+		payload = map[string]string{"text": text}
+	default:
+		payload = notification
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+
+	return nil
+}
+
+func renderMessage(webhook Webhook, notification Notification) (string, error) {
+	text := webhook.Template
+	if text == "" {
+		text = defaultTemplate
+	}
+
+	tmpl, err := template.New(webhook.Name).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var message strings.Builder
+	if err := tmpl.Execute(&message, notification); err != nil {
+		return "", err
+	}
+
+	return message.String(), nil
+}
+
+// matchesEvent returns if the event is one of the given reasons, or a
+// Warning event when no reasons are given
+func matchesEvent(reasons []string, eventtype, reason string) bool {
+	if len(reasons) == 0 {
+		return eventtype == corev1.EventTypeWarning
+	}
+
+	for _, r := range reasons {
+		if r == reason {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifications
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestMatchesEvent(t *testing.T) {
+	tests := []struct {
+		name      string
+		reasons   []string
+		eventtype string
+		reason    string
+		want      bool
+	}{
+		{name: "warning without reasons", eventtype: corev1.EventTypeWarning, reason: "Replication", want: true},
+		{name: "normal without reasons", eventtype: corev1.EventTypeNormal, reason: "Replication", want: false},
+		{name: "listed reason", reasons: []string{"Rollout"}, eventtype: corev1.EventTypeNormal, reason: "Rollout", want: true},
+		{name: "other reason", reasons: []string{"Rollout"}, eventtype: corev1.EventTypeWarning, reason: "Replication", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesEvent(tt.reasons, tt.eventtype, tt.reason); got != tt.want {
+				t.Errorf("matchesEvent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDedup(t *testing.T) {
+	r := &Recorder{posted: make(map[delivery]time.Time)}
+	d := delivery{notification: Notification{Namespace: "default", Name: "df", Reason: "Replication", Message: "failed"}}
+	now := time.Now()
+
+	if !r.dedup(d, now) {
+		t.Error("first notification was skipped")
+	}
+	if r.dedup(d, now.Add(time.Minute)) {
+		t.Error("same notification was posted again within the dedup interval")
+	}
+	if !r.dedup(delivery{webhook: 1, notification: d.notification}, now.Add(time.Minute)) {
+		t.Error("notification of another webhook was skipped")
+	}
+	if !r.dedup(d, now.Add(dedupInterval)) {
+		t.Error("same notification was skipped after the dedup interval")
+	}
+}
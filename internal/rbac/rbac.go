@@ -0,0 +1,135 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rbac generates the RBAC manifests of an operator that only
+// manages the Dragonfly objects of some namespaces, so that the operation
+// of the instances can be delegated per namespace.
+package rbac
+
+import (
+	"fmt"
+	"io"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	readVerbs = []string{"get", "list", "watch"}
+	allVerbs  = []string{"get", "list", "watch", "create", "update", "patch", "delete"}
+)
+
+// NamespacedRules are the permissions of the operator in the namespaces
+// it manages. They follow the rbac markers of the controllers, minus the
+// cluster scoped resources.
+var NamespacedRules = []rbacv1.PolicyRule{
+	{APIGroups: []string{"dragonflydb.io"}, Resources: []string{"dragonflies"}, Verbs: allVerbs},
+	{APIGroups: []string{"dragonflydb.io"}, Resources: []string{"dragonflies/status"}, Verbs: []string{"get", "update", "patch"}},
+	{APIGroups: []string{"dragonflydb.io"}, Resources: []string{"dragonflies/finalizers"}, Verbs: []string{"update"}},
+	{APIGroups: []string{"dragonflydb.io"}, Resources: []string{"dragonflyreplicationlinks"}, Verbs: []string{"get", "list", "watch", "update", "patch"}},
+	{APIGroups: []string{"dragonflydb.io"}, Resources: []string{"dragonflyreplicationlinks/status"}, Verbs: []string{"get", "update", "patch"}},
+	{APIGroups: []string{"dragonflydb.io"}, Resources: []string{"dragonflyreplicationlinks/finalizers"}, Verbs: []string{"update"}},
+	{APIGroups: []string{"dragonflydb.io"}, Resources: []string{"dragonflymaintenances"}, Verbs: []string{"get", "list", "watch"}},
+	{APIGroups: []string{"dragonflydb.io"}, Resources: []string{"dragonflymaintenances/status"}, Verbs: []string{"get", "update", "patch"}},
+	{APIGroups: []string{"dragonflydb.io"}, Resources: []string{"dragonflymaintenances/finalizers"}, Verbs: []string{"update"}},
+	{APIGroups: []string{"apps"}, Resources: []string{"statefulsets"}, Verbs: allVerbs},
+	{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: allVerbs},
+	{APIGroups: []string{"batch"}, Resources: []string{"jobs"}, Verbs: []string{"get", "list", "watch", "create", "delete"}},
+	{APIGroups: []string{""}, Resources: []string{"services", "pods"}, Verbs: allVerbs},
+	{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get", "list", "watch", "create", "update"}},
+	{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: allVerbs},
+	{APIGroups: []string{""}, Resources: []string{"persistentvolumeclaims"}, Verbs: []string{"get", "list", "watch", "create", "delete"}},
+	{APIGroups: []string{"snapshot.storage.k8s.io"}, Resources: []string{"volumesnapshots"}, Verbs: []string{"get", "list", "watch", "create", "delete"}},
+	{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create", "patch"}},
+	{APIGroups: []string{"cert-manager.io"}, Resources: []string{"certificates"}, Verbs: allVerbs},
+	{APIGroups: []string{"monitoring.coreos.com"}, Resources: []string{"prometheusrules"}, Verbs: allVerbs},
+	{APIGroups: []string{"monitoring.coreos.com"}, Resources: []string{"servicemonitors"}, Verbs: allVerbs},
+	{APIGroups: []string{"route.openshift.io"}, Resources: []string{"routes"}, Verbs: allVerbs},
+	{APIGroups: []string{"policy"}, Resources: []string{"poddisruptionbudgets"}, Verbs: allVerbs},
+	{APIGroups: []string{"discovery.k8s.io"}, Resources: []string{"endpointslices"}, Verbs: allVerbs},
+}
+
+// ClusterRules are the cluster scoped permissions that a namespace scoped
+// operator still needs, as the failover of masters follows the readiness
+// of their nodes, the data loss protection releases the instances of
+// deleted namespaces, and DragonflyClasses and DragonflyTemplates are
+// cluster scoped. Only the status of templates is written.
+var ClusterRules = []rbacv1.PolicyRule{
+	{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: readVerbs},
+	{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: readVerbs},
+	{APIGroups: []string{"dragonflydb.io"}, Resources: []string{"dragonflyclasses", "dragonflytemplates"}, Verbs: readVerbs},
+	{APIGroups: []string{"dragonflydb.io"}, Resources: []string{"dragonflytemplates/status"}, Verbs: []string{"get", "update", "patch"}},
+}
+
+// Manifests returns a Role and RoleBinding with the NamespacedRules in
+// each of the given namespaces, and a ClusterRole and ClusterRoleBinding
+// with the ClusterRules, all bound to the given service account
+func Manifests(name string, serviceAccount rbacv1.Subject, namespaces []string) []client.Object {
+	roleRef := func(kind, name string) rbacv1.RoleRef {
+		return rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: kind, Name: name}
+	}
+
+	clusterRoleName := fmt.Sprintf("%s-cluster", name)
+	objects := []client.Object{
+		&rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+			ObjectMeta: metav1.ObjectMeta{Name: clusterRoleName},
+			Rules:      ClusterRules,
+		},
+		&rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: clusterRoleName},
+			RoleRef:    roleRef("ClusterRole", clusterRoleName),
+			Subjects:   []rbacv1.Subject{serviceAccount},
+		},
+	}
+
+	for _, namespace := range namespaces {
+		objects = append(objects,
+			&rbacv1.Role{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+				Rules:      NamespacedRules,
+			},
+			&rbacv1.RoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"},
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+				RoleRef:    roleRef("Role", name),
+				Subjects:   []rbacv1.Subject{serviceAccount},
+			},
+		)
+	}
+
+	return objects
+}
+
+// WriteManifests writes the given objects as a multi document YAML
+func WriteManifests(w io.Writer, objects []client.Object) error {
+	for _, object := range objects {
+		data, err := yaml.Marshal(object)
+		if err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(w, "---\n%s", data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	resourcesv1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// BackupComponent is the component label of the pods that upload the
+	// backups
+	BackupComponent = "backup"
+
+	// Default images that upload the backups per scheme
+	BackupS3Image = "amazon/aws-cli:2.13.30"
+	BackupGSImage = "gcr.io/google.com/cloudsdktool/google-cloud-cli:450.0.0-slim"
+	BackupAZImage = "mcr.microsoft.com/azure-cli:2.63.0"
+
+	// BackupNameLayout is the layout of the names of the backups, after
+	// their start time
+	BackupNameLayout = "20060102T150405Z"
+
+	// DefaultBackupRetention is the default number of backups kept
+	DefaultBackupRetention = 7
+)
+
+// backupCommands upload the $FILES to $DESTINATION/$BACKUP_NAME, list the
+// backups in $DESTINATION and remove the $BACKUP per scheme, after the
+// optional setup. image is the default image that runs them.
+var backupCommands = map[string]struct{ image, setup, upload, list, remove string }{
+	"s3": {
+		image:  BackupS3Image,
+		upload: `for f in $FILES; do aws s3 cp "$f" "$DESTINATION/$BACKUP_NAME/$f"; done`,
+		list:   `aws s3 ls "$DESTINATION/" | awk -v d="$DESTINATION/" '$1 == "PRE" {print d $2}'`,
+		remove: `aws s3 rm --recursive "$BACKUP"`,
+	},
+	"gs": {
+		image:  BackupGSImage,
+		upload: `gsutil cp $FILES "$DESTINATION/$BACKUP_NAME/"`,
+		list:   `gsutil ls -d "$DESTINATION/*/"`,
+		remove: `gsutil -m rm -r "$BACKUP"`,
+	},
+	// az://<container>/<path> in the storage account of
+	// $AZURE_STORAGE_ACCOUNT, with the credentials of the environment or
+	// of the workload identity
+	"az": {
+		image: BackupAZImage,
+		setup: strings.Join([]string{
+			`D="${DESTINATION#az://}"; CONTAINER="${D%%/*}"; PREFIX=""`,
+			`case "$D" in */*) PREFIX="${D#*/}/";; esac`,
+			`if [ -n "$AZURE_FEDERATED_TOKEN_FILE" ]; then az login --service-principal -u "$AZURE_CLIENT_ID" -t "$AZURE_TENANT_ID" --federated-token "$(cat "$AZURE_FEDERATED_TOKEN_FILE")" --only-show-errors > /dev/null; export AZURE_STORAGE_AUTH_MODE=login; fi`,
+		}, "\n"),
+		upload: `for f in $FILES; do az storage blob upload --container-name "$CONTAINER" --name "$PREFIX$BACKUP_NAME/$f" --file "$f" --overwrite --only-show-errors > /dev/null; done`,
+		list:   `az storage blob list --container-name "$CONTAINER" --prefix "$PREFIX" --delimiter / --num-results "*" --query "[].name" -o tsv --only-show-errors | awk -v d="az://$CONTAINER/" '{print d $0}'`,
+		remove: `az storage blob delete-batch --source "$CONTAINER" --pattern "${BACKUP#az://$CONTAINER/}*" --only-show-errors > /dev/null`,
+	},
+}
+
+// GetBackupPodName returns the name of the pod that uploads the backup of
+// the Dragonfly object
+func GetBackupPodName(df *resourcesv1.Dragonfly) string {
+	return fmt.Sprintf("%s-%s", df.Name, BackupComponent)
+}
+
+// GetBackupName returns the name of the backup that started at the given
+// time
+func GetBackupName(start time.Time) string {
+	return start.UTC().Format(BackupNameLayout)
+}
+
+// GetBackupURI returns the URI of the directory of the backup that started
+// at the given time
+func GetBackupURI(df *resourcesv1.Dragonfly, start time.Time) string {
+	return fmt.Sprintf("%s/%s", strings.TrimSuffix(df.Spec.Snapshot.Backup.Destination, "/"), GetBackupName(start))
+}
+
+// GetBackupPod returns a throwaway pod that uploads the snapshot files the
+// given master saved since the start of the backup, and then deletes the
+// backups beyond the retention. The volume is mounted read only, on the
+// node of the master as it is ReadWriteOnce. The size of the uploaded
+// files is reported as the termination message of the pod.
+func GetBackupPod(df *resourcesv1.Dragonfly, master *corev1.Pod, start time.Time) (*corev1.Pod, error) {
+	if df.Spec.Snapshot == nil || df.Spec.Snapshot.Backup == nil || df.Spec.Snapshot.PersistentVolumeClaimSpec == nil {
+		return nil, fmt.Errorf("backup specified without a persistent volume claim")
+	}
+	backup := df.Spec.Snapshot.Backup
+
+	uri, err := url.Parse(backup.Destination)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup destination: %w", err)
+	}
+
+	commands, ok := backupCommands[uri.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported scheme %s of the backup destination", uri.Scheme)
+	}
+
+	image := backup.Image
+	if image == "" {
+		image = commands.image
+	}
+
+	retention := int32(DefaultBackupRetention)
+	if backup.Retention != nil {
+		retention = *backup.Retention
+	}
+
+	// the files of SAVE are the ones modified after the start of the
+	// backup, the log files are in a subdirectory and aren't part of it
+	script := strings.Join([]string{
+		"set -e",
+		commands.setup,
+		fmt.Sprintf("cd %s", snapshotDir),
+		`FILES=$(find . -maxdepth 1 -type f -newermt "@$SINCE" | sed 's|^\./||')`,
+		`if [ -z "$FILES" ]; then echo "no snapshot files were saved since the start of the backup" > /dev/termination-log; exit 1; fi`,
+		`SIZE=$(du -cb $FILES | tail -n 1 | cut -f 1)`,
+		commands.upload,
+		fmt.Sprintf(`%s | grep -E '/[0-9]{8}T[0-9]{6}Z/$' | sort | head -n -$RETENTION | while read -r BACKUP; do %s; done`, commands.list, commands.remove),
+		`echo "$SIZE" > /dev/termination-log`,
+	}, "\n")
+
+	container := corev1.Container{
+		Name:    BackupComponent,
+		Image:   image,
+		Command: []string{"/bin/sh", "-c"},
+		Args:    []string{script},
+		Env: []corev1.EnvVar{
+			{
+				Name:  "DESTINATION",
+				Value: strings.TrimSuffix(backup.Destination, "/"),
+			},
+			{
+				Name:  "BACKUP_NAME",
+				Value: GetBackupName(start),
+			},
+			{
+				Name:  "SINCE",
+				Value: fmt.Sprintf("%d", start.Add(-time.Second).Unix()),
+			},
+			{
+				Name:  "RETENTION",
+				Value: fmt.Sprintf("%d", retention),
+			},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      SnapshotVolumeName,
+				MountPath: snapshotDir,
+				ReadOnly:  true,
+			},
+		},
+	}
+
+	if backup.CredentialsSecretRef != nil {
+		container.EnvFrom = []corev1.EnvFromSource{
+			{
+				SecretRef: &corev1.SecretEnvSource{
+					LocalObjectReference: *backup.CredentialsSecretRef,
+				},
+			},
+		}
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetBackupPodName(df),
+			Namespace: df.Namespace,
+			Labels: map[string]string{
+				KubernetesAppComponentLabelKey: BackupComponent,
+				KubernetesAppInstanceNameLabel: df.Name,
+				KubernetesManagedByLabelKey:    DragonflyOperatorName,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: df.APIVersion,
+					Kind:       df.Kind,
+					Name:       df.Name,
+					UID:        df.UID,
+				},
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:           master.Spec.NodeName,
+			RestartPolicy:      corev1.RestartPolicyNever,
+			ServiceAccountName: backup.ServiceAccountName,
+			Containers:         []corev1.Container{container},
+			Volumes: []corev1.Volume{
+				{
+					Name: SnapshotVolumeName,
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: fmt.Sprintf("%s-%s", SnapshotVolumeName, master.Name),
+							ReadOnly:  true,
+						},
+					},
+				},
+			},
+			Tolerations:     master.Spec.Tolerations,
+			SecurityContext: master.Spec.SecurityContext,
+		},
+	}, nil
+}
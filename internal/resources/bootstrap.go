@@ -0,0 +1,169 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+
+	resourcesv1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// BootstrapContainerName is the name of the init container that
+	// downloads the bootstrap snapshot
+	BootstrapContainerName = "bootstrap"
+
+	// Default images that download the bootstrap snapshot per scheme
+	BootstrapHTTPSImage = "curlimages/curl:8.4.0"
+	BootstrapS3Image    = "amazon/aws-cli:2.13.30"
+
+	// BootstrapSourceVolumeName is the name of the volume of the claim
+	// that the bootstrap snapshot is copied from
+	BootstrapSourceVolumeName = "bootstrap-source"
+
+	// bootstrapSourceDir is where the claim of the bootstrap snapshot is
+	// mounted
+	bootstrapSourceDir = "/bootstrap"
+
+	// snapshotDir is the directory in which Dragonfly saves and loads
+	// its snapshots
+	snapshotDir = "/dragonfly/snapshots"
+)
+
+// bootstrapCopyScript copies $SNAPSHOT_SOURCE to $SNAPSHOT_DIR, i.e the
+// file, or the content of the directory. The files are copied to a
+// temporary directory first, so that an interrupted copy is started over.
+const bootstrapCopyScript = `rm -rf "$SNAPSHOT_DIR/.bootstrap" && mkdir "$SNAPSHOT_DIR/.bootstrap" && ` +
+	`if [ -d "$SNAPSHOT_SOURCE" ]; then cp -R "$SNAPSHOT_SOURCE/." "$SNAPSHOT_DIR/.bootstrap/"; else cp "$SNAPSHOT_SOURCE" "$SNAPSHOT_DIR/.bootstrap/"; fi && ` +
+	`mv "$SNAPSHOT_DIR"/.bootstrap/* "$SNAPSHOT_DIR/" && rmdir "$SNAPSHOT_DIR/.bootstrap"`
+
+// bootstrapScripts download $SNAPSHOT_URI to $SNAPSHOT_FILE per scheme,
+// unless the snapshot directory has data already, e.g after a restart
+var bootstrapScripts = map[string]string{
+	"https": `curl -fsSL -o "$SNAPSHOT_FILE.tmp" "$SNAPSHOT_URI" && mv "$SNAPSHOT_FILE.tmp" "$SNAPSHOT_FILE"`,
+	"s3":    `aws s3 cp "$SNAPSHOT_URI" "$SNAPSHOT_FILE.tmp" && mv "$SNAPSHOT_FILE.tmp" "$SNAPSHOT_FILE"`,
+}
+
+// getBootstrapContainer returns the init container that seeds the
+// snapshot directory with the bootstrap snapshot of the Dragonfly object,
+// and the volume of the claim it's copied from, if any
+func getBootstrapContainer(df *resourcesv1.Dragonfly) (corev1.Container, *corev1.Volume, error) {
+	bootstrap := df.Spec.Bootstrap
+	if (bootstrap.SnapshotURI == "") == (bootstrap.PersistentVolumeClaim == nil) {
+		return corev1.Container{}, nil, fmt.Errorf("exactly one of snapshotURI and persistentVolumeClaim must be set in bootstrap")
+	}
+
+	// the log files don't count as data
+	listing := fmt.Sprintf("ls -A %s", snapshotDir)
+	if df.Spec.Logging != nil && df.Spec.Logging.OnSnapshotVolume {
+		listing = fmt.Sprintf("%s | grep -vx %s", listing, logsSubPath)
+	}
+
+	container := corev1.Container{
+		Name:    BootstrapContainerName,
+		Command: []string{"/bin/sh", "-c"},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      SnapshotVolumeName,
+				MountPath: snapshotDir,
+			},
+		},
+	}
+
+	var script string
+	var volume *corev1.Volume
+	if claim := bootstrap.PersistentVolumeClaim; claim != nil {
+		script = bootstrapCopyScript
+		listing = fmt.Sprintf("%s | grep -vx .bootstrap", listing)
+		container.Image = BootstrapHTTPSImage
+		container.Env = []corev1.EnvVar{
+			{
+				Name:  "SNAPSHOT_SOURCE",
+				Value: path.Join(bootstrapSourceDir, claim.Path),
+			},
+			{
+				Name:  "SNAPSHOT_DIR",
+				Value: snapshotDir,
+			},
+		}
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      BootstrapSourceVolumeName,
+			MountPath: bootstrapSourceDir,
+			ReadOnly:  true,
+		})
+		volume = &corev1.Volume{
+			Name: BootstrapSourceVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: claim.ClaimName,
+					ReadOnly:  true,
+				},
+			},
+		}
+	} else {
+		uri, err := url.Parse(bootstrap.SnapshotURI)
+		if err != nil {
+			return corev1.Container{}, nil, fmt.Errorf("invalid bootstrap snapshot URI: %w", err)
+		}
+
+		var ok bool
+		script, ok = bootstrapScripts[uri.Scheme]
+		if !ok {
+			return corev1.Container{}, nil, fmt.Errorf("unsupported scheme %s of the bootstrap snapshot URI", uri.Scheme)
+		}
+
+		fileName := path.Base(uri.Path)
+		if fileName == "." || fileName == "/" {
+			return corev1.Container{}, nil, fmt.Errorf("bootstrap snapshot URI %s has no file name", bootstrap.SnapshotURI)
+		}
+
+		container.Image = BootstrapHTTPSImage
+		if uri.Scheme == "s3" {
+			container.Image = BootstrapS3Image
+		}
+		container.Env = []corev1.EnvVar{
+			{
+				Name:  "SNAPSHOT_URI",
+				Value: bootstrap.SnapshotURI,
+			},
+			{
+				Name:  "SNAPSHOT_FILE",
+				Value: path.Join(snapshotDir, fileName),
+			},
+		}
+	}
+
+	if bootstrap.Image != "" {
+		container.Image = bootstrap.Image
+	}
+	container.Args = []string{fmt.Sprintf(`if [ -n "$(%s)" ]; then echo "snapshot directory is not empty, skipping bootstrap"; exit 0; fi; %s`, listing, script)}
+
+	if bootstrap.CredentialsSecretRef != nil {
+		container.EnvFrom = []corev1.EnvFromSource{
+			{
+				SecretRef: &corev1.SecretEnvSource{
+					LocalObjectReference: *bootstrap.CredentialsSecretRef,
+				},
+			},
+		}
+	}
+
+	return container, volume, nil
+}
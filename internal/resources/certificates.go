@@ -0,0 +1,85 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+
+	resourcesv1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// CertificateGVK is the cert-manager Certificate kind. It is handled as an
+// unstructured object, so that cert-manager is only needed when it's used.
+var CertificateGVK = schema.GroupVersionKind{
+	Group:   "cert-manager.io",
+	Version: "v1",
+	Kind:    "Certificate",
+}
+
+// GetServerCertificateSecretName returns the name of the secret of the
+// server certificate issued by cert-manager
+func GetServerCertificateSecretName(df *resourcesv1.Dragonfly) string {
+	return fmt.Sprintf("%s-tls", df.Name)
+}
+
+// GetClientCertificateSecretName returns the name of the secret of the
+// client certificate issued by cert-manager
+func GetClientCertificateSecretName(df *resourcesv1.Dragonfly) string {
+	return fmt.Sprintf("%s-client-tls", df.Name)
+}
+
+// GetCertificates returns the cert-manager Certificates of a Dragonfly
+// instance. Dragonfly serves a single certificate on all of its ports, so
+// the server certificate is used for the replication link as well.
+func GetCertificates(df *resourcesv1.Dragonfly) []*unstructured.Unstructured {
+	issuerRef := df.Spec.TLS.CertManager.IssuerRef
+	issuer := map[string]interface{}{
+		"name":  issuerRef.Name,
+		"kind":  defaultString(issuerRef.Kind, "Issuer"),
+		"group": defaultString(issuerRef.Group, CertificateGVK.Group),
+	}
+
+	// the master Service and the per-pod hostnames of the statefulset
+	dnsNames := make([]interface{}, 0)
+	for _, domain := range []string{
+		df.Name,
+		fmt.Sprintf("%s.%s", df.Name, df.Namespace),
+		fmt.Sprintf("%s.%s.svc", df.Name, df.Namespace),
+		fmt.Sprintf("%s.%s.svc.%s", df.Name, df.Namespace, ClusterDomain),
+	} {
+		dnsNames = append(dnsNames, domain, fmt.Sprintf("*.%s", domain))
+	}
+
+	server := newUnstructured(df, CertificateGVK, df.Name, map[string]interface{}{
+		"secretName": GetServerCertificateSecretName(df),
+		"issuerRef":  issuer,
+		"commonName": df.Name,
+		"dnsNames":   dnsNames,
+		"usages":     []interface{}{"server auth", "client auth"},
+	})
+
+	client := newUnstructured(df, CertificateGVK, fmt.Sprintf("%s-client", df.Name), map[string]interface{}{
+		"secretName": GetClientCertificateSecretName(df),
+		"issuerRef":  issuer,
+		"commonName": fmt.Sprintf("%s-client", df.Name),
+		"usages":     []interface{}{"client auth"},
+	})
+
+	return []*unstructured.Unstructured{server, client}
+}
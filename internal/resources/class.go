@@ -0,0 +1,44 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+
+	resourcesv1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+)
+
+// ApplyDragonflyClass merges the spec of the Dragonfly object into the
+// defaults of its class, so that the fields it sets take precedence
+func ApplyDragonflyClass(df *resourcesv1.Dragonfly, class *resourcesv1.DragonflyClass) error {
+	defaults := *class.Spec.Defaults.DeepCopy()
+	defaults.ClassName = ""
+
+	patch, err := json.Marshal(df.Spec)
+	if err != nil {
+		return err
+	}
+
+	spec, err := strategicMerge(defaults, patch)
+	if err != nil {
+		return fmt.Errorf("could not apply dragonfly class %s: %w", class.Name, err)
+	}
+
+	df.Spec = spec
+	return nil
+}
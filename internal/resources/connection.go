@@ -0,0 +1,159 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	resourcesv1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterDomain is the DNS domain of the cluster, which the host of the
+// connection Secrets and the certificates of the instances end with
+var ClusterDomain = "cluster.local"
+
+// GetConnectionSecretName returns the name of the connection Secret
+// of a Dragonfly instance
+func GetConnectionSecretName(df *resourcesv1.Dragonfly) string {
+	if df.Spec.ConnectionSecret != nil && df.Spec.ConnectionSecret.Name != "" {
+		return df.Spec.ConnectionSecret.Name
+	}
+
+	return fmt.Sprintf("%s-connection", df.Name)
+}
+
+// GetTLSSecretName returns the name of the secret with the certificate
+// Dragonfly serves, or "" if TLS is not enabled
+func GetTLSSecretName(df *resourcesv1.Dragonfly) string {
+	switch {
+	case df.Spec.TLSSecretRef != nil:
+		return df.Spec.TLSSecretRef.Name
+	case df.Spec.TLS != nil && df.Spec.TLS.CertManager != nil:
+		return GetServerCertificateSecretName(df)
+	}
+
+	return ""
+}
+
+// GetTLSCAKey returns the key of the CA certificate in the TLS secret
+func GetTLSCAKey(df *resourcesv1.Dragonfly) string {
+	if df.Spec.TLSSecretKeys != nil {
+		return defaultString(df.Spec.TLSSecretKeys.CA, "ca.crt")
+	}
+
+	return "ca.crt"
+}
+
+// GetPlainPassword returns the password of a Dragonfly instance that is
+// set in its args or env instead of a secret
+func GetPlainPassword(df *resourcesv1.Dragonfly) string {
+	if password, ok := getArgValue(df.Spec.Args, RequirePassArg); ok {
+		return password
+	}
+
+	if env := GetPasswordEnv(df); env != nil {
+		return env.Value
+	}
+
+	return ""
+}
+
+// GetPasswordEnv returns the env var that sets the password of a Dragonfly
+// instance, which may take it from a secret or config map, or nil if the
+// password isn't set in its env. The args take precedence over it.
+func GetPasswordEnv(df *resourcesv1.Dragonfly) *corev1.EnvVar {
+	if _, ok := getArgValue(df.Spec.Args, RequirePassArg); ok {
+		return nil
+	}
+
+	for i, env := range df.Spec.Env {
+		if env.Name == "DFLY_PASSWORD" || env.Name == "DFLY_requirepass" {
+			return &df.Spec.Env[i]
+		}
+	}
+
+	return nil
+}
+
+// GetConnectionSecret returns the connection Secret of a Dragonfly
+// instance. Its keys follow the Service Binding specification.
+func GetConnectionSecret(df *resourcesv1.Dragonfly, password string, caCert []byte) *corev1.Secret {
+	host := fmt.Sprintf("%s.%s.svc.%s", df.Name, df.Namespace, ClusterDomain)
+	port := strconv.Itoa(DragonflyPort)
+
+	uri := url.URL{
+		Scheme: "redis",
+		Host:   fmt.Sprintf("%s:%s", host, port),
+	}
+	if caCert != nil {
+		uri.Scheme = "rediss"
+	}
+	if password != "" {
+		uri.User = url.UserPassword("default", password)
+	}
+
+	data := map[string][]byte{
+		"type":     []byte("redis"),
+		"provider": []byte("dragonfly"),
+		"host":     []byte(host),
+		"port":     []byte(port),
+		"uri":      []byte(uri.String()),
+		"tls":      []byte(strconv.FormatBool(caCert != nil)),
+	}
+
+	if password != "" {
+		data["password"] = []byte(password)
+	}
+
+	if caCert != nil {
+		data["ca.crt"] = caCert
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetConnectionSecretName(df),
+			Namespace: df.Namespace,
+			// Useful for automatically deleting the resources when the Dragonfly object is deleted
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: df.APIVersion,
+					Kind:       df.Kind,
+					Name:       df.Name,
+					UID:        df.UID,
+				},
+			},
+			Labels: map[string]string{
+				KubernetesAppComponentLabelKey: "Dragonfly",
+				KubernetesAppInstanceNameLabel: df.Name,
+				KubernetesAppNameLabelKey:      "dragonfly",
+				KubernetesAppVersionLabelKey:   Version,
+				KubernetesPartOfLabelKey:       "dragonfly",
+				KubernetesManagedByLabelKey:    DragonflyOperatorName,
+				"app":                          df.Name,
+			},
+		},
+		Type: corev1.SecretType("servicebinding.io/redis"),
+		Data: data,
+	}
+
+	setCommonMetadata(df, secret)
+	return secret
+}
@@ -29,12 +29,33 @@ const (
 	// DragonflyPortName is the name of the port on which the Dragonfly instance listens
 	DragonflyPortName = "redis"
 
+	// DragonflyAdminPortName is the name of the admin port of the Dragonfly instance
+	DragonflyAdminPortName = "admin"
+
+	// DragonflyMetricsPort is the port on which the metrics sidecar serves
+	// the metrics of the admin port, and nothing else
+	DragonflyMetricsPort = 9998
+
+	// DragonflyMetricsPortName is the name of the port of the metrics Service
+	DragonflyMetricsPortName = "metrics"
+
+	// DragonflyMetricsPath is the path on which Dragonfly exposes its metrics
+	DragonflyMetricsPath = "/metrics"
+
+	// Prometheus scrape annotations of the metrics Service
+	PrometheusScrapeAnnotation = "prometheus.io/scrape"
+	PrometheusPortAnnotation   = "prometheus.io/port"
+	PrometheusPathAnnotation   = "prometheus.io/path"
+
 	// DragonflyOperatorName is the name of the operator
 	DragonflyOperatorName = "dragonfly-operator"
 
 	// DragonflyImage is the default image of the Dragonfly to use
 	DragonflyImage = "docker.dragonflydb.io/dragonflydb/dragonfly"
 
+	// DragonflyBinary is the name of the Dragonfly binary in the image
+	DragonflyBinary = "dragonfly"
+
 	// DragonflyHealthCheckPath is the path on which the Dragonfly exposes its health check
 	DragonflyHealthCheckPath = "/health"
 
@@ -63,6 +84,87 @@ const (
 	Master string = "master"
 
 	Replica string = "replica"
+
+	// Velero backup hook annotations
+	VeleroPreBackupHookContainerAnnotation = "pre.hook.backup.velero.io/container"
+	VeleroPreBackupHookCommandAnnotation   = "pre.hook.backup.velero.io/command"
+	VeleroPreBackupHookOnErrorAnnotation   = "pre.hook.backup.velero.io/on-error"
+	VeleroPreBackupHookTimeoutAnnotation   = "pre.hook.backup.velero.io/timeout"
+
+	// SecretsHashAnnotation is the hash of the secrets referenced by the
+	// Dragonfly object, so that pods are rolled when they change
+	SecretsHashAnnotation = "dragonflydb.io/secrets-hash"
+
+	// ConfigMapsHashAnnotation is the hash of the config maps referenced by
+	// the Dragonfly object, so that pods are rolled when they change
+	ConfigMapsHashAnnotation = "dragonflydb.io/configmaps-hash"
+
+	// SaveRequestAnnotation requests a snapshot of the master of the
+	// Dragonfly object. A SAVE is issued whenever its value changes.
+	SaveRequestAnnotation = "dragonflydb.io/save-request"
+
+	// ExplainRequestAnnotation requests the plan of the changes the
+	// operator would make to the Dragonfly object, without making them.
+	// The plan is recorded in its status whenever the value changes.
+	ExplainRequestAnnotation = "dragonflydb.io/explain-request"
+
+	// FailoverToAnnotation requests a hand over of the master role of the
+	// Dragonfly object to the replica pod of the given name. The request is
+	// handled whenever its value changes.
+	FailoverToAnnotation = "dragonflydb.io/failover-to"
+
+	// RestartRequestAnnotation requests a rolling restart of the pods of
+	// the Dragonfly object. The pods are rolled whenever its value changes.
+	RestartRequestAnnotation = "dragonflydb.io/restart-request"
+
+	// DataLossConfirmationAnnotation confirms, when set to "true", that the
+	// data of a Dragonfly object without persistence may be lost by
+	// deleting it or scaling it to zero replicas
+	DataLossConfirmationAnnotation = "dragonflydb.io/confirm-data-loss"
+
+	// DataLossProtectionFinalizer blocks the deletion of Dragonfly objects
+	// without persistence until the data loss is confirmed
+	DataLossProtectionFinalizer = "dragonflydb.io/data-loss-protection"
+
+	// PriorityLabel marks Dragonfly objects as critical when set to
+	// PriorityCritical, so that their events are reconciled by controllers
+	// of their own
+	PriorityLabel = "dragonflydb.io/priority"
+
+	PriorityCritical = "critical"
+
+	// StandbyOfAnnotation is the name of the DragonflyReplicationLink that
+	// the Dragonfly object is a standby of. The replication of its pods is
+	// configured by the link instead of the pod lifecycle controller.
+	StandbyOfAnnotation = "dragonflydb.io/standby-of"
+
+	// PromoteAnnotation requests the promotion of the standby of a
+	// DragonflyReplicationLink with the given name, or cluster/name for
+	// standbys in remote clusters
+	PromoteAnnotation = "dragonflydb.io/promote"
+
+	// ReplicationLinkFinalizer releases the standbys of a
+	// DragonflyReplicationLink when it is deleted
+	ReplicationLinkFinalizer = "dragonflydb.io/replication-link"
+
+	// AnnounceSourceNodeIP announces the IP of the node the pod runs on
+	AnnounceSourceNodeIP string = "NodeIP"
+
+	// AnnounceSourceTemplate announces a templated address
+	AnnounceSourceTemplate string = "Template"
+
+	// LastReplicaOfAnnotation is the time at which the pod was last
+	// configured as a replica by the operator
+	LastReplicaOfAnnotation string = "dragonflydb.io/last-replicaof"
+
+	// FailoverPriorityAnnotation is the priority of the pod when selecting a
+	// new master. Pods with a lower value are preferred, and pods with a
+	// priority of 0 are never promoted. Defaults to DefaultFailoverPriority.
+	FailoverPriorityAnnotation string = "dragonflydb.io/failover-priority"
+
+	// DefaultFailoverPriority is the failover priority of pods without
+	// the failover priority annotation
+	DefaultFailoverPriority = 100
 )
 
 var DefaultDragonflyArgs = []string{
@@ -0,0 +1,71 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+
+	resourcesv1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// defaultReplicas is the number of pods of new instances that don't
+	// set it, a master and a replica
+	defaultReplicas int32 = 2
+
+	// defaultSize is the size preset whose resources are requested by
+	// new instances that set neither resources nor a size
+	defaultSize = "small"
+)
+
+// DefaultDragonflySpec fills in the defaults of the fields that the spec
+// of a new Dragonfly object doesn't set, so that its stored spec is fully
+// specified and doesn't change with the defaults of later operator
+// versions. Objects with a class are left as is, as their class provides
+// their defaults. The replicas are only defaulted if they aren't set, as
+// they may be set to 0.
+func DefaultDragonflySpec(df *resourcesv1.Dragonfly, replicasSet bool) {
+	if df.Spec.ClassName != "" {
+		return
+	}
+
+	if df.Spec.Image == "" {
+		df.Spec.Image = fmt.Sprintf("%s:%s", DragonflyImage, Version)
+	}
+
+	if !replicasSet {
+		df.Spec.Replicas = defaultReplicas
+	}
+
+	// the size preset sets the limits and --maxmemory along with the
+	// requests, so only the requests are defaulted
+	if df.Spec.Resources == nil && df.Spec.Size == "" {
+		preset := sizePresets[defaultSize]
+		df.Spec.Resources = &corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse(preset.cpu),
+				corev1.ResourceMemory: resource.MustParse(preset.memory),
+			},
+		}
+	}
+
+	if df.Spec.Affinity == nil && df.Spec.AntiAffinityMode == "" {
+		df.Spec.AntiAffinityMode = AntiAffinityModePreferred
+	}
+}
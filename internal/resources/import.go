@@ -0,0 +1,191 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+	"strings"
+
+	resourcesv1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ImportComponent is the component label of the jobs that import
+	// a Redis Cluster
+	ImportComponent = "import"
+
+	// ImportImage is the default image of the import jobs
+	ImportImage = "redis:7.2"
+
+	// ImportSourceAnnotation is the source of an import job
+	ImportSourceAnnotation = "dragonflydb.io/import-source"
+)
+
+// importScript copies the keys of the shard of $SHARDS at the completion
+// index of the job to $TARGET_HOST with DUMP and RESTORE, in batches of
+// 1000 keys, as Dragonfly doesn't support the RESTORE-ASKING of MIGRATE.
+// The keys and payloads are passed in the quoted form of redis-cli, so
+// binary values survive the pipes. Keys that expired meanwhile are
+// skipped. It fails if any command replies with an error, or any RESTORE
+// replies with something else than OK.
+const importScript = `set -e
+set -- $SHARDS
+shift "$JOB_COMPLETION_INDEX"
+host="${1%:*}"
+port="${1##*:}"
+target() {
+  if [ -n "$TARGET_PASSWORD" ]; then
+    REDISCLI_AUTH="$TARGET_PASSWORD" redis-cli -h "$TARGET_HOST" -p "$TARGET_PORT" --no-raw
+  else
+    env -u REDISCLI_AUTH redis-cli -h "$TARGET_HOST" -p "$TARGET_PORT" --no-raw
+  fi
+}
+cd "$(mktemp -d)"
+redis-cli -h "$host" -p "$port" --no-raw --scan --count 1000 > keys
+split -l 1000 keys batch.
+for batch in batch.*; do
+  [ -e "$batch" ] || continue
+  awk '{ print "PTTL " $0; print "DUMP " $0 }' "$batch" | redis-cli -h "$host" -p "$port" --no-raw > dumps
+  if grep '^(error)' dumps >&2; then exit 1; fi
+  awk 'NR == FNR { keys[NR] = $0; next }
+    FNR % 2 == 1 { ttl = $2; next }
+    ttl != -2 && $0 != "(nil)" { print "RESTORE " keys[FNR / 2] " " (ttl == -1 ? 0 : ttl) " " $0 " REPLACE" }' "$batch" dumps \
+    | target | { ! grep -v '^OK$'; }
+done`
+
+// GetImportJobName returns the name of the job that imports the Redis
+// Cluster of the Dragonfly object
+func GetImportJobName(df *resourcesv1.Dragonfly) string {
+	return fmt.Sprintf("%s-%s", df.Name, ImportComponent)
+}
+
+// GetImportJob returns the indexed job that imports the given master
+// shards of the Redis Cluster of the Dragonfly object, one per index.
+// The keys are copied, so the cluster keeps serving them meanwhile.
+func GetImportJob(df *resourcesv1.Dragonfly, shards []string) (*batchv1.Job, error) {
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("redis cluster %s has no master shards to import", df.Spec.Import.Source)
+	}
+
+	if GetTLSSecretName(df) != "" {
+		return nil, fmt.Errorf("import isn't supported on instances with TLS")
+	}
+
+	image := df.Spec.Import.Image
+	if image == "" {
+		image = ImportImage
+	}
+
+	env := []corev1.EnvVar{
+		{
+			Name:  "SHARDS",
+			Value: strings.Join(shards, " "),
+		},
+		{
+			Name:  "TARGET_HOST",
+			Value: fmt.Sprintf("%s.%s.svc", df.Name, df.Namespace),
+		},
+		{
+			Name:  "TARGET_PORT",
+			Value: fmt.Sprintf("%d", DragonflyPort),
+		},
+	}
+
+	if df.Spec.Import.PasswordFromSecret != nil {
+		env = append(env, corev1.EnvVar{
+			Name: "REDISCLI_AUTH",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: df.Spec.Import.PasswordFromSecret,
+			},
+		})
+	}
+
+	switch {
+	case df.Spec.Authentication != nil && df.Spec.Authentication.PasswordFromSecret != nil:
+		env = append(env, corev1.EnvVar{
+			Name: "TARGET_PASSWORD",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: df.Spec.Authentication.PasswordFromSecret,
+			},
+		})
+	case GetPlainPassword(df) != "":
+		env = append(env, corev1.EnvVar{
+			Name:  "TARGET_PASSWORD",
+			Value: GetPlainPassword(df),
+		})
+	case GetPasswordEnv(df) != nil && GetPasswordEnv(df).ValueFrom != nil:
+		env = append(env, corev1.EnvVar{
+			Name:      "TARGET_PASSWORD",
+			ValueFrom: GetPasswordEnv(df).ValueFrom,
+		})
+	}
+
+	labels := map[string]string{
+		KubernetesAppComponentLabelKey: ImportComponent,
+		KubernetesAppInstanceNameLabel: df.Name,
+		KubernetesManagedByLabelKey:    DragonflyOperatorName,
+	}
+
+	shardCount := int32(len(shards))
+	completionMode := batchv1.IndexedCompletion
+	isController := true
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetImportJobName(df),
+			Namespace: df.Namespace,
+			Labels:    labels,
+			Annotations: map[string]string{
+				ImportSourceAnnotation: df.Spec.Import.Source,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: df.APIVersion,
+					Kind:       df.Kind,
+					Name:       df.Name,
+					UID:        df.UID,
+					Controller: &isController,
+				},
+			},
+		},
+		Spec: batchv1.JobSpec{
+			CompletionMode: &completionMode,
+			Completions:    &shardCount,
+			Parallelism:    &shardCount,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{
+						{
+							Name:    ImportComponent,
+							Image:   image,
+							Command: []string{"/bin/sh", "-c"},
+							Args:    []string{importScript},
+							Env:     env,
+						},
+					},
+					Tolerations: df.Spec.Tolerations,
+				},
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+	"strings"
+
+	resourcesv1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// LogsVolumeName is the name of the volume of the log files, unless
+	// they are written to the snapshot volume
+	LogsVolumeName = "logs"
+
+	// logDir is the directory of the log files
+	logDir = "/var/log/dragonfly"
+
+	// logsSubPath is the directory of the log files in the snapshot volume
+	logsSubPath = "logs"
+)
+
+// logFlags are the flags of Dragonfly that configure its logs
+var logFlags = []string{"--v", "--vmodule", "--minloglevel", "--log_dir", "--max_log_size", "--logtostderr", "--alsologtostderr"}
+
+// minLogLevels are the values of --minloglevel per level
+var minLogLevels = map[string]int{
+	"INFO":    0,
+	"WARNING": 1,
+	"ERROR":   2,
+}
+
+// getLoggingArgs returns the args of the logging configuration of a
+// Dragonfly instance. The flags that its args set are skipped, as the args
+// take precedence.
+func getLoggingArgs(df *resourcesv1.Dragonfly) []string {
+	logging := df.Spec.Logging
+	args := []string{fmt.Sprintf("--log_dir=%s", logDir)}
+	if logging.Verbosity != nil {
+		args = append(args, fmt.Sprintf("--v=%d", *logging.Verbosity))
+	}
+	if logging.VModule != "" {
+		args = append(args, fmt.Sprintf("--vmodule=%s", logging.VModule))
+	}
+	if logging.MinLevel != "" {
+		args = append(args, fmt.Sprintf("--minloglevel=%d", minLogLevels[logging.MinLevel]))
+	}
+	if logging.MaxSizeMB != nil {
+		args = append(args, fmt.Sprintf("--max_log_size=%d", *logging.MaxSizeMB))
+	}
+
+	var effective []string
+	for _, arg := range args {
+		name, _, _ := strings.Cut(arg, "=")
+		if !hasArg(df.Spec.Args, name) {
+			effective = append(effective, arg)
+		}
+	}
+
+	return effective
+}
+
+// setLogging configures the logs of the Dragonfly container, writing the
+// log files to an emptyDir or to the snapshot volume
+func setLogging(df *resourcesv1.Dragonfly, statefulset *appsv1.StatefulSet) error {
+	container := &statefulset.Spec.Template.Spec.Containers[0]
+	container.Args = append(container.Args, getLoggingArgs(df)...)
+
+	if df.Spec.Logging.OnSnapshotVolume {
+		if df.Spec.Snapshot == nil || (df.Spec.Snapshot.PersistentVolumeClaimSpec == nil && df.Spec.Snapshot.EphemeralVolumeClaimSpec == nil) {
+			return fmt.Errorf("logging on the snapshot volume specified without a snapshot volume claim")
+		}
+
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      SnapshotVolumeName,
+			MountPath: logDir,
+			SubPath:   logsSubPath,
+		})
+		return nil
+	}
+
+	statefulset.Spec.Template.Spec.Volumes = append(statefulset.Spec.Template.Spec.Volumes, corev1.Volume{
+		Name: LogsVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{},
+		},
+	})
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      LogsVolumeName,
+		MountPath: logDir,
+	})
+
+	return nil
+}
+
+// GetLogArgs returns the log flags a Dragonfly instance runs with, those
+// of its args included. A flag that is set more than once is only
+// returned with the value that takes effect, i.e. its last one.
+func GetLogArgs(df *resourcesv1.Dragonfly) []string {
+	all := append([]string{}, DefaultDragonflyArgs...)
+	all = append(all, df.Spec.Args...)
+	if df.Spec.Logging != nil {
+		all = append(all, getLoggingArgs(df)...)
+	}
+
+	var args []string
+	index := make(map[string]int)
+	for _, arg := range all {
+		if !isLogFlag(arg) {
+			continue
+		}
+
+		name, _, _ := strings.Cut(arg, "=")
+		if i, ok := index[name]; ok {
+			args[i] = arg
+			continue
+		}
+
+		index[name] = len(args)
+		args = append(args, arg)
+	}
+
+	return args
+}
+
+// isLogFlag returns if the given arg is a log flag
+func isLogFlag(arg string) bool {
+	name, _, _ := strings.Cut(arg, "=")
+	for _, flag := range logFlags {
+		if name == flag {
+			return true
+		}
+	}
+
+	return false
+}
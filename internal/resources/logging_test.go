@@ -0,0 +1,60 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"reflect"
+	"testing"
+
+	resourcesv1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+)
+
+func TestGetLogArgs(t *testing.T) {
+	verbosity := int32(1)
+	tests := []struct {
+		name string
+		spec resourcesv1.DragonflySpec
+		want []string
+	}{
+		{
+			name: "defaults",
+			want: []string{"--alsologtostderr"},
+		},
+		{
+			name: "logging",
+			spec: resourcesv1.DragonflySpec{Logging: &resourcesv1.Logging{Verbosity: &verbosity, MinLevel: "WARNING"}},
+			want: []string{"--alsologtostderr", "--log_dir=/var/log/dragonfly", "--v=1", "--minloglevel=1"},
+		},
+		{
+			name: "args take precedence over logging",
+			spec: resourcesv1.DragonflySpec{
+				Args:    []string{"--v=2", "--alsologtostderr=false"},
+				Logging: &resourcesv1.Logging{Verbosity: &verbosity},
+			},
+			want: []string{"--alsologtostderr=false", "--v=2", "--log_dir=/var/log/dragonfly"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df := &resourcesv1.Dragonfly{Spec: tt.spec}
+			if got := GetLogArgs(df); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetLogArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+
+	resourcesv1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// MetricsContainerName is the name of the sidecar that serves the metrics
+const MetricsContainerName = "metrics"
+
+// getMetricsContainer returns the sidecar that exposes the metrics of
+// Dragonfly on the metrics port. The admin port doesn't require the
+// password, so only GET requests of the metrics path are forwarded to it.
+func getMetricsContainer(df *resourcesv1.Dragonfly) corev1.Container {
+	container := corev1.Container{
+		Name:  MetricsContainerName,
+		Image: defaultString(df.Spec.Metrics.Image, ProxyImage),
+		Args:  []string{"--config-yaml", getMetricsBootstrap(df)},
+		Ports: []corev1.ContainerPort{
+			{
+				Name:          DragonflyMetricsPortName,
+				ContainerPort: DragonflyMetricsPort,
+			},
+		},
+		ReadinessProbe: &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				TCPSocket: &corev1.TCPSocketAction{
+					Port: intstr.FromString(DragonflyMetricsPortName),
+				},
+			},
+			PeriodSeconds: 5,
+		},
+	}
+
+	if df.Spec.Metrics.Resources != nil {
+		container.Resources = *df.Spec.Metrics.Resources
+	}
+
+	return container
+}
+
+// getMetricsBootstrap returns the bootstrap configuration of Envoy, with
+// an HTTP listener on the metrics port that answers everything but
+// GET /metrics with a 404. It has no admin interface.
+func getMetricsBootstrap(df *resourcesv1.Dragonfly) string {
+	upstreamTLS := ""
+	if df.Spec.ReplicationTLS != nil {
+		// the admin port serves TLS along with the replication
+		upstreamTLS = `
+    transport_socket:
+      name: envoy.transport_sockets.tls
+      typed_config:
+        "@type": type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.UpstreamTlsContext`
+	}
+
+	return fmt.Sprintf(`static_resources:
+  listeners:
+  - name: metrics
+    address:
+      socket_address: {address: 0.0.0.0, port_value: %d}
+    filter_chains:
+    - filters:
+      - name: envoy.filters.network.http_connection_manager
+        typed_config:
+          "@type": type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager
+          stat_prefix: metrics
+          route_config:
+            virtual_hosts:
+            - name: metrics
+              domains: ["*"]
+              routes:
+              - match:
+                  path: %s
+                  headers:
+                  - name: ":method"
+                    string_match: {exact: GET}
+                route: {cluster: admin}
+              - match: {prefix: /}
+                direct_response: {status: 404}
+          http_filters:
+          - name: envoy.filters.http.router
+            typed_config:
+              "@type": type.googleapis.com/envoy.extensions.filters.http.router.v3.Router
+  clusters:
+  - name: admin
+    type: STATIC
+    connect_timeout: 1s
+    load_assignment:
+      cluster_name: admin
+      endpoints:
+      - lb_endpoints:
+        - endpoint:
+            address:
+              socket_address: {address: 127.0.0.1, port_value: %d}%s
+`, DragonflyMetricsPort, DragonflyMetricsPath, DragonflyAdminPort, upstreamTLS)
+}
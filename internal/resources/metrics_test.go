@@ -0,0 +1,87 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	resourcesv1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetDragonflyResourcesServesMetricsThroughTheSidecar(t *testing.T) {
+	df := &resourcesv1.Dragonfly{
+		ObjectMeta: metav1.ObjectMeta{Name: "df", Namespace: "default"},
+		Spec: resourcesv1.DragonflySpec{
+			Replicas: 2,
+			Metrics:  &resourcesv1.Metrics{},
+		},
+	}
+
+	objects, err := GetDragonflyResources(context.Background(), df)
+	if err != nil {
+		t.Fatalf("GetDragonflyResources() error = %v", err)
+	}
+
+	var statefulSet *appsv1.StatefulSet
+	for _, object := range objects {
+		if sts, ok := object.(*appsv1.StatefulSet); ok {
+			statefulSet = sts
+		}
+	}
+	if statefulSet == nil {
+		t.Fatal("no statefulset in the resources")
+	}
+
+	containers := statefulSet.Spec.Template.Spec.Containers
+	if len(containers) != 2 || containers[1].Name != MetricsContainerName {
+		t.Fatalf("containers = %v, want Dragonfly and the metrics sidecar", containers)
+	}
+	for _, port := range containers[0].Ports {
+		if port.Name == DragonflyMetricsPortName {
+			t.Errorf("Dragonfly exposes the %s port", DragonflyMetricsPortName)
+		}
+	}
+
+	service := GetMetricsService(df)
+	port := service.Spec.Ports[0]
+	if port.Port != DragonflyMetricsPort || port.TargetPort.StrVal != DragonflyMetricsPortName {
+		t.Errorf("metrics Service port = %v, want the port of the sidecar", port)
+	}
+}
+
+func TestGetMetricsBootstrap(t *testing.T) {
+	df := &resourcesv1.Dragonfly{Spec: resourcesv1.DragonflySpec{Metrics: &resourcesv1.Metrics{}}}
+
+	bootstrap := getMetricsBootstrap(df)
+	for _, want := range []string{"path: /metrics", "string_match: {exact: GET}", "direct_response: {status: 404}"} {
+		if !strings.Contains(bootstrap, want) {
+			t.Errorf("bootstrap doesn't contain %q", want)
+		}
+	}
+	if strings.Contains(bootstrap, "admin:") || strings.Contains(bootstrap, "UpstreamTlsContext") {
+		t.Error("bootstrap has an admin interface or an upstream TLS context")
+	}
+
+	df.Spec.ReplicationTLS = &resourcesv1.ReplicationTLS{}
+	if !strings.Contains(getMetricsBootstrap(df), "UpstreamTlsContext") {
+		t.Error("bootstrap doesn't connect to the admin port with TLS along with the replication")
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+	"time"
+
+	resourcesv1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	defaultReplicationLagThreshold     int64 = 10000
+	defaultMemoryUsageThresholdPercent int32 = 90
+	defaultMaxSnapshotAge                    = 24 * time.Hour
+)
+
+// PrometheusRuleGVK is the Prometheus Operator PrometheusRule kind
+var PrometheusRuleGVK = schema.GroupVersionKind{
+	Group:   "monitoring.coreos.com",
+	Version: "v1",
+	Kind:    "PrometheusRule",
+}
+
+// GetPrometheusRule returns a PrometheusRule with the default alerts of a
+// Dragonfly instance, based on the metrics Dragonfly exposes on its port
+func GetPrometheusRule(df *resourcesv1.Dragonfly) *unstructured.Unstructured {
+	spec := df.Spec.PrometheusRule
+	selector := fmt.Sprintf(`namespace="%s",pod=~"%s-[0-9]+"`, df.Namespace, df.Name)
+
+	lagThreshold := defaultReplicationLagThreshold
+	if spec.ReplicationLagThreshold != nil {
+		lagThreshold = *spec.ReplicationLagThreshold
+	}
+
+	memoryThreshold := defaultMemoryUsageThresholdPercent
+	if spec.MemoryUsageThresholdPercent != nil {
+		memoryThreshold = *spec.MemoryUsageThresholdPercent
+	}
+
+	rules := []interface{}{
+		newAlertingRule(df, "DragonflyMasterDown",
+			fmt.Sprintf(`sum(dragonfly_master{%s}) < 1 or absent(dragonfly_master{%s})`, selector, selector),
+			"1m", "critical", "Dragonfly instance has no master"),
+		newAlertingRule(df, "DragonflyReplicationLag",
+			fmt.Sprintf(`max(dragonfly_connected_replica_lag_records{%s}) > %d`, selector, lagThreshold),
+			"5m", "warning", fmt.Sprintf("Dragonfly replicas are more than %d records behind the master", lagThreshold)),
+		newAlertingRule(df, "DragonflyMemorySaturation",
+			fmt.Sprintf(`max(dragonfly_memory_used_bytes{%s} / dragonfly_memory_max_bytes{%s}) * 100 > %d`, selector, selector, memoryThreshold),
+			"5m", "warning", fmt.Sprintf("Dragonfly uses more than %d%% of its maxmemory", memoryThreshold)),
+	}
+
+	if df.Spec.Snapshot != nil && df.Spec.Snapshot.Cron != "" {
+		maxAge := defaultMaxSnapshotAge
+		if spec.MaxSnapshotAge != nil {
+			maxAge = spec.MaxSnapshotAge.Duration
+		}
+
+		rules = append(rules, newAlertingRule(df, "DragonflySnapshotFailed",
+			fmt.Sprintf(`time() - max(dragonfly_last_saved_timestamp{%s}) > %d`, selector, int64(maxAge.Seconds())),
+			"5m", "warning", fmt.Sprintf("Dragonfly has not saved a snapshot in %s", maxAge)))
+	}
+
+	rule := newUnstructured(df, PrometheusRuleGVK, df.Name, map[string]interface{}{
+		"groups": []interface{}{
+			map[string]interface{}{
+				"name":  fmt.Sprintf("dragonfly.%s.%s", df.Namespace, df.Name),
+				"rules": rules,
+			},
+		},
+	})
+
+	labels := rule.GetLabels()
+	for k, v := range spec.Labels {
+		labels[k] = v
+	}
+	rule.SetLabels(labels)
+
+	return rule
+}
+
+func newAlertingRule(df *resourcesv1.Dragonfly, alert, expr, duration, severity, summary string) map[string]interface{} {
+	return map[string]interface{}{
+		"alert": alert,
+		"expr":  expr,
+		"for":   duration,
+		"labels": map[string]interface{}{
+			"severity":  severity,
+			"dragonfly": df.Name,
+		},
+		"annotations": map[string]interface{}{
+			"summary": fmt.Sprintf("%s/%s: %s", df.Namespace, df.Name, summary),
+		},
+	}
+}
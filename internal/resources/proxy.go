@@ -0,0 +1,302 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	resourcesv1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ProxyImage is the default image of the proxies
+	ProxyImage = "envoyproxy/envoy:v1.27.2"
+
+	// ProxyConfigHashAnnotation is the hash of the bootstrap configuration
+	// of the proxies, so that they are rolled when it changes. Updates of
+	// the endpoints are picked up by the running proxies instead.
+	ProxyConfigHashAnnotation = "dragonflydb.io/proxy-config-hash"
+
+	proxyConfigDir       = "/etc/envoy"
+	proxyPasswordDir     = "/etc/envoy-auth"
+	proxyBootstrapKey    = "bootstrap.yaml"
+	proxyPasswordKey     = "password"
+	proxyClusterName     = "dragonfly"
+	proxyDefaultReplicas = int32(2)
+)
+
+// GetProxyName returns the name of the proxy Deployment, Service and
+// ConfigMap of a Dragonfly instance
+func GetProxyName(df *resourcesv1.Dragonfly) string {
+	return fmt.Sprintf("%s-proxy", df.Name)
+}
+
+// GetProxyUpstreamName returns the name of the headless Service of the
+// master that the proxies of a Dragonfly instance resolve
+func GetProxyUpstreamName(df *resourcesv1.Dragonfly) string {
+	return fmt.Sprintf("%s-proxy-upstream", df.Name)
+}
+
+// GetProxyResources returns the ConfigMap, Deployment and Service of the
+// proxies of a Dragonfly instance, and the headless Service of its master.
+// The proxies resolve the address of the master from the DNS record of the
+// headless Service every second, so that a new master is picked up as soon
+// as it's ready, without restarting them.
+func GetProxyResources(df *resourcesv1.Dragonfly) ([]client.Object, error) {
+	if GetTLSSecretName(df) != "" {
+		return nil, fmt.Errorf("proxy specified with TLS")
+	}
+
+	name := GetProxyName(df)
+	bootstrap := getProxyBootstrap(df)
+	hash := sha256.Sum256([]byte(bootstrap))
+
+	selector := map[string]string{
+		KubernetesAppNameLabelKey:      "dragonfly-proxy",
+		KubernetesAppInstanceNameLabel: df.Name,
+	}
+
+	labels := map[string]string{
+		KubernetesAppComponentLabelKey: "proxy",
+		KubernetesAppInstanceNameLabel: df.Name,
+		KubernetesAppNameLabelKey:      "dragonfly-proxy",
+		KubernetesAppVersionLabelKey:   Version,
+		KubernetesManagedByLabelKey:    DragonflyOperatorName,
+	}
+
+	objectMeta := metav1.ObjectMeta{
+		Name:      name,
+		Namespace: df.Namespace,
+		// Useful for automatically deleting the resources when the Dragonfly object is deleted
+		OwnerReferences: []metav1.OwnerReference{
+			{
+				APIVersion: df.APIVersion,
+				Kind:       df.Kind,
+				Name:       df.Name,
+				UID:        df.UID,
+			},
+		},
+		Labels: labels,
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: *objectMeta.DeepCopy(),
+		Data: map[string]string{
+			proxyBootstrapKey: bootstrap,
+		},
+	}
+
+	replicas := proxyDefaultReplicas
+	if df.Spec.Proxy.Replicas != nil {
+		replicas = *df.Spec.Proxy.Replicas
+	}
+
+	container := corev1.Container{
+		Name:  "envoy",
+		Image: defaultString(df.Spec.Proxy.Image, ProxyImage),
+		Args:  []string{"--config-path", fmt.Sprintf("%s/%s", proxyConfigDir, proxyBootstrapKey)},
+		Ports: []corev1.ContainerPort{
+			{
+				Name:          DragonflyPortName,
+				ContainerPort: DragonflyPort,
+			},
+		},
+		ReadinessProbe: &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				TCPSocket: &corev1.TCPSocketAction{
+					Port: intstr.FromString(DragonflyPortName),
+				},
+			},
+			PeriodSeconds: 5,
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      "config",
+				MountPath: proxyConfigDir,
+			},
+		},
+	}
+
+	if df.Spec.Proxy.Resources != nil {
+		container.Resources = *df.Spec.Proxy.Resources
+	}
+
+	volumes := []corev1.Volume{
+		{
+			Name: "config",
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: name},
+				},
+			},
+		},
+	}
+
+	if df.Spec.Authentication != nil && df.Spec.Authentication.PasswordFromSecret != nil {
+		volumes = append(volumes, corev1.Volume{
+			Name: "password",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: df.Spec.Authentication.PasswordFromSecret.Name,
+					Items: []corev1.KeyToPath{
+						{
+							Key:  df.Spec.Authentication.PasswordFromSecret.Key,
+							Path: proxyPasswordKey,
+						},
+					},
+				},
+			},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      "password",
+			MountPath: proxyPasswordDir,
+			ReadOnly:  true,
+		})
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: *objectMeta.DeepCopy(),
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: selector,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: selector,
+					Annotations: map[string]string{
+						ProxyConfigHashAnnotation: hex.EncodeToString(hash[:]),
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{container},
+					Volumes:    volumes,
+				},
+			},
+		},
+	}
+
+	service := &corev1.Service{
+		ObjectMeta: *objectMeta.DeepCopy(),
+		Spec: corev1.ServiceSpec{
+			Selector: selector,
+			Ports: []corev1.ServicePort{
+				{
+					Name:       DragonflyPortName,
+					Port:       DragonflyPort,
+					TargetPort: intstr.FromString(DragonflyPortName),
+				},
+			},
+		},
+	}
+
+	upstreamMeta := *objectMeta.DeepCopy()
+	upstreamMeta.Name = GetProxyUpstreamName(df)
+	upstream := &corev1.Service{
+		ObjectMeta: upstreamMeta,
+		Spec: corev1.ServiceSpec{
+			ClusterIP: corev1.ClusterIPNone,
+			Selector: map[string]string{
+				"app":                     df.Name,
+				KubernetesAppNameLabelKey: "dragonfly",
+				Role:                      Master,
+			},
+			Ports: []corev1.ServicePort{
+				{
+					Name:       DragonflyPortName,
+					Port:       DragonflyPort,
+					TargetPort: intstr.FromString(DragonflyPortName),
+				},
+			},
+		},
+	}
+
+	resources := []client.Object{configMap, deployment, service, upstream}
+	for _, resource := range resources {
+		setCommonMetadata(df, resource)
+	}
+
+	return resources, nil
+}
+
+// getProxyBootstrap returns the bootstrap configuration of Envoy, with a
+// Redis proxy listener routing all commands to the cluster of the master.
+// The password clients authenticate with is the one of the instance.
+func getProxyBootstrap(df *resourcesv1.Dragonfly) string {
+	auth := ""
+	password := ""
+	if df.Spec.Authentication != nil && df.Spec.Authentication.PasswordFromSecret != nil {
+		password = fmt.Sprintf("{filename: %s/%s}", proxyPasswordDir, proxyPasswordKey)
+	} else if plain := GetPlainPassword(df); plain != "" {
+		password = fmt.Sprintf("{inline_string: %q}", plain)
+	}
+
+	if password != "" {
+		auth = fmt.Sprintf(`
+          downstream_auth_passwords:
+          - %s`, password)
+	}
+
+	upstreamAuth := ""
+	if password != "" {
+		upstreamAuth = fmt.Sprintf(`
+    typed_extension_protocol_options:
+      envoy.filters.network.redis_proxy:
+        "@type": type.googleapis.com/envoy.extensions.filters.network.redis_proxy.v3.RedisProtocolOptions
+        auth_password: %s`, password)
+	}
+
+	return fmt.Sprintf(`admin:
+  address:
+    socket_address: {address: 127.0.0.1, port_value: 9901}
+static_resources:
+  listeners:
+  - name: redis
+    address:
+      socket_address: {address: 0.0.0.0, port_value: %d}
+    filter_chains:
+    - filters:
+      - name: envoy.filters.network.redis_proxy
+        typed_config:
+          "@type": type.googleapis.com/envoy.extensions.filters.network.redis_proxy.v3.RedisProxy
+          stat_prefix: redis
+          settings: {op_timeout: 5s}
+          prefix_routes:
+            catch_all_route: {cluster: %s}%s
+  clusters:
+  - name: %s
+    type: STRICT_DNS
+    connect_timeout: 1s
+    dns_refresh_rate: 1s
+    respect_dns_ttl: false
+    lb_policy: MAGLEV
+    load_assignment:
+      cluster_name: %s
+      endpoints:
+      - lb_endpoints:
+        - endpoint:
+            address:
+              socket_address: {address: %s.%s.svc, port_value: %d}%s
+`, DragonflyPort, proxyClusterName, auth, proxyClusterName, proxyClusterName, GetProxyUpstreamName(df), df.Namespace, DragonflyPort, upstreamAuth)
+}
@@ -18,12 +18,23 @@ package resources
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
 
 	resourcesv1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -33,10 +44,34 @@ var (
 )
 
 const (
-	TlsPath             = "/etc/dragonfly-tls"
-	TLSCACertDirArg     = "--tls_ca_cert_dir"
-	TLSCACertDir        = "/etc/dragonfly/client-ca-cert"
-	TLSCACertVolumeName = "client-ca-cert"
+	TlsPath                 = "/etc/dragonfly-tls"
+	TLSVolumeName           = "dragonfly-tls"
+	ReplicationCAPath       = "/etc/dragonfly-replication-ca"
+	ReplicationCAVolumeName = "dragonfly-replication-ca"
+	TLSCACertDirArg         = "--tls_ca_cert_dir"
+	TLSCACertDir            = "/etc/dragonfly/client-ca-cert"
+	TLSCACertVolumeName     = "client-ca-cert"
+	SnapshotVolumeName      = "df"
+	UnixSocketVolumeName    = "unix-socket"
+	UnixSocketDir           = "/var/run/dragonfly"
+	UnixSocketPath          = UnixSocketDir + "/dragonfly.sock"
+	UnixSocketArg           = "--unixsocket"
+	UnixSocketPermArg       = "--unixsocketperm"
+	TLSReplicationArg       = "--tls_replication"
+	RequirePassArg          = "--requirepass"
+	MasterAuthArg           = "--masterauth"
+	NotifyKeyspaceEventsArg = "--notify_keyspace_events"
+	CacheModeArg            = "--cache_mode"
+	DirArg                  = "--dir"
+
+	// Anti-affinity modes of the pods of an instance
+	AntiAffinityModeRequired  = "required"
+	AntiAffinityModePreferred = "preferred"
+	AntiAffinityModeNone      = "none"
+
+	// Eviction policies of Dragonfly once maxmemory is reached
+	EvictionPolicyNoEviction = "NoEviction"
+	EvictionPolicyCache      = "Cache"
 )
 
 // GetDragonflyResources returns the resources required for a Dragonfly
@@ -45,6 +80,14 @@ func GetDragonflyResources(ctx context.Context, df *resourcesv1.Dragonfly) ([]cl
 	log := log.FromContext(ctx)
 	log.Info(fmt.Sprintf("Creating resources for %s", df.Name))
 
+	if err := ValidateAuthentication(df); err != nil {
+		return nil, err
+	}
+
+	if err := ValidateImage(df); err != nil {
+		return nil, err
+	}
+
 	var resources []client.Object
 
 	image := df.Spec.Image
@@ -52,6 +95,15 @@ func GetDragonflyResources(ctx context.Context, df *resourcesv1.Dragonfly) ([]cl
 		image = fmt.Sprintf("%s:%s", DragonflyImage, Version)
 	}
 
+	imagePullPolicy := corev1.PullAlways
+	if strings.Contains(image, "@") {
+		imagePullPolicy = corev1.PullIfNotPresent
+	}
+
+	if df.Spec.ImagePullPolicy != "" {
+		imagePullPolicy = df.Spec.ImagePullPolicy
+	}
+
 	// Create a StatefulSet, Headless Service
 	statefulset := appsv1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
@@ -89,6 +141,8 @@ func GetDragonflyResources(ctx context.Context, df *resourcesv1.Dragonfly) ([]cl
 			UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
 				Type: appsv1.OnDeleteStatefulSetStrategyType,
 			},
+			PodManagementPolicy: df.Spec.PodManagementPolicy,
+			MinReadySeconds:     df.Spec.MinReadySeconds,
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: map[string]string{
@@ -108,7 +162,7 @@ func GetDragonflyResources(ctx context.Context, df *resourcesv1.Dragonfly) ([]cl
 									ContainerPort: DragonflyPort,
 								},
 								{
-									Name:          "admin",
+									Name:          DragonflyAdminPortName,
 									ContainerPort: DragonflyAdminPort,
 								},
 							},
@@ -144,7 +198,7 @@ func GetDragonflyResources(ctx context.Context, df *resourcesv1.Dragonfly) ([]cl
 								SuccessThreshold:    1,
 								TimeoutSeconds:      5,
 							},
-							ImagePullPolicy: corev1.PullAlways,
+							ImagePullPolicy: imagePullPolicy,
 						},
 					},
 					SecurityContext: &corev1.PodSecurityContext{
@@ -155,19 +209,73 @@ func GetDragonflyResources(ctx context.Context, df *resourcesv1.Dragonfly) ([]cl
 		},
 	}
 
+	for _, port := range df.Spec.ExtraPorts {
+		if port.Port == DragonflyPort || port.Port == DragonflyAdminPort || (df.Spec.Metrics != nil && port.Port == DragonflyMetricsPort) {
+			return nil, fmt.Errorf("extra port %s uses the reserved port %d", port.Name, port.Port)
+		}
+		if port.Name == DragonflyPortName || port.Name == DragonflyAdminPortName || (df.Spec.Metrics != nil && port.Name == DragonflyMetricsPortName) {
+			return nil, fmt.Errorf("extra port %s uses a reserved name", port.Name)
+		}
+
+		statefulset.Spec.Template.Spec.Containers[0].Ports = append(statefulset.Spec.Template.Spec.Containers[0].Ports, corev1.ContainerPort{
+			Name:          port.Name,
+			ContainerPort: port.Port,
+			Protocol:      port.Protocol,
+		})
+	}
+
+	if df.Spec.Command != nil {
+		if path.Base(df.Spec.Command[len(df.Spec.Command)-1]) != DragonflyBinary {
+			return nil, fmt.Errorf("command %v doesn't end with the %s binary", df.Spec.Command, DragonflyBinary)
+		}
+		statefulset.Spec.Template.Spec.Containers[0].Command = df.Spec.Command
+	}
+
+	if df.Spec.Size != "" {
+		sizeResources, err := getSizeResources(df)
+		if err != nil {
+			return nil, err
+		}
+		statefulset.Spec.Template.Spec.Containers[0].Resources = sizeResources
+	}
+
 	// set only if resources are specified
 	if df.Spec.Resources != nil {
 		statefulset.Spec.Template.Spec.Containers[0].Resources = *df.Spec.Resources
 	}
 
+	if df.Spec.Size != "" {
+		statefulset.Spec.Template.Spec.Containers[0].Args = append(statefulset.Spec.Template.Spec.Containers[0].Args, getSizeArgs(df, &statefulset.Spec.Template.Spec.Containers[0])...)
+	}
+
 	if df.Spec.Args != nil {
 		statefulset.Spec.Template.Spec.Containers[0].Args = append(statefulset.Spec.Template.Spec.Containers[0].Args, df.Spec.Args...)
+
+		// replicas authenticate to the master with the same password
+		if password, ok := getArgValue(df.Spec.Args, RequirePassArg); ok && !hasArg(df.Spec.Args, MasterAuthArg) {
+			statefulset.Spec.Template.Spec.Containers[0].Args = append(statefulset.Spec.Template.Spec.Containers[0].Args, fmt.Sprintf("%s=%s", MasterAuthArg, password))
+		}
 	}
 
 	if df.Spec.Snapshot != nil {
-		// err if pvc is not specified while cron is specified
-		if df.Spec.Snapshot.Cron != "" && df.Spec.Snapshot.PersistentVolumeClaimSpec == nil {
-			return nil, fmt.Errorf("cron specified without a persistent volume claim")
+		volumeSources := 0
+		for _, specified := range []bool{
+			df.Spec.Snapshot.PersistentVolumeClaimSpec != nil,
+			df.Spec.Snapshot.MemoryStaging != nil,
+			df.Spec.Snapshot.EphemeralVolumeClaimSpec != nil,
+		} {
+			if specified {
+				volumeSources++
+			}
+		}
+
+		// err if no volume is specified while cron is specified
+		if df.Spec.Snapshot.Cron != "" && volumeSources == 0 {
+			return nil, fmt.Errorf("cron specified without a snapshot volume")
+		}
+
+		if volumeSources > 1 {
+			return nil, fmt.Errorf("only one of persistentVolumeClaimSpec, memoryStaging and ephemeralVolumeClaimSpec can be specified")
 		}
 
 		if df.Spec.Snapshot.PersistentVolumeClaimSpec != nil {
@@ -175,7 +283,7 @@ func GetDragonflyResources(ctx context.Context, df *resourcesv1.Dragonfly) ([]cl
 			// attach and use the PVC if specified
 			statefulset.Spec.VolumeClaimTemplates = append(statefulset.Spec.VolumeClaimTemplates, corev1.PersistentVolumeClaim{
 				ObjectMeta: metav1.ObjectMeta{
-					Name: "df",
+					Name: SnapshotVolumeName,
 					Labels: map[string]string{
 						"app":                     df.Name,
 						KubernetesPartOfLabelKey:  "dragonfly",
@@ -184,50 +292,255 @@ func GetDragonflyResources(ctx context.Context, df *resourcesv1.Dragonfly) ([]cl
 				},
 				Spec: *df.Spec.Snapshot.PersistentVolumeClaimSpec,
 			})
+		}
+
+		if df.Spec.Snapshot.MemoryStaging != nil {
+			// stage the snapshots in a tmpfs volume
+			statefulset.Spec.Template.Spec.Volumes = append(statefulset.Spec.Template.Spec.Volumes, corev1.Volume{
+				Name: SnapshotVolumeName,
+				VolumeSource: corev1.VolumeSource{
+					EmptyDir: &corev1.EmptyDirVolumeSource{
+						Medium:    corev1.StorageMediumMemory,
+						SizeLimit: df.Spec.Snapshot.MemoryStaging.SizeLimit,
+					},
+				},
+			})
+		}
 
+		if df.Spec.Snapshot.EphemeralVolumeClaimSpec != nil {
+			// per pod volume that shares the lifecycle of the pod
+			statefulset.Spec.Template.Spec.Volumes = append(statefulset.Spec.Template.Spec.Volumes, corev1.Volume{
+				Name: SnapshotVolumeName,
+				VolumeSource: corev1.VolumeSource{
+					Ephemeral: &corev1.EphemeralVolumeSource{
+						VolumeClaimTemplate: &corev1.PersistentVolumeClaimTemplate{
+							ObjectMeta: metav1.ObjectMeta{
+								Labels: map[string]string{
+									"app":                     df.Name,
+									KubernetesPartOfLabelKey:  "dragonfly",
+									KubernetesAppNameLabelKey: "dragonfly",
+								},
+							},
+							Spec: *df.Spec.Snapshot.EphemeralVolumeClaimSpec,
+						},
+					},
+				},
+			})
+		}
+
+		if volumeSources > 0 {
 			statefulset.Spec.Template.Spec.Containers[0].VolumeMounts = append(statefulset.Spec.Template.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
-				Name:      "df",
-				MountPath: "/dragonfly/snapshots",
+				Name:      SnapshotVolumeName,
+				MountPath: snapshotDir,
 			})
 		}
 
-		statefulset.Spec.Template.Spec.Containers[0].Args = append(statefulset.Spec.Template.Spec.Containers[0].Args, "--dir=/dragonfly/snapshots")
+		statefulset.Spec.Template.Spec.Containers[0].Args = append(statefulset.Spec.Template.Spec.Containers[0].Args, fmt.Sprintf("%s=%s", DirArg, snapshotDir))
 		if df.Spec.Snapshot.Cron != "" {
 			statefulset.Spec.Template.Spec.Containers[0].Args = append(statefulset.Spec.Template.Spec.Containers[0].Args, fmt.Sprintf("--snapshot_cron=%s", df.Spec.Snapshot.Cron))
 		}
 	}
 
-	if df.Spec.TLSSecretRef != nil {
+	if df.Spec.Bootstrap != nil {
+		if df.Spec.Snapshot == nil || (df.Spec.Snapshot.PersistentVolumeClaimSpec == nil && df.Spec.Snapshot.MemoryStaging == nil && df.Spec.Snapshot.EphemeralVolumeClaimSpec == nil) {
+			return nil, fmt.Errorf("bootstrap specified without a snapshot volume")
+		}
+
+		container, volume, err := getBootstrapContainer(df)
+		if err != nil {
+			return nil, err
+		}
+		statefulset.Spec.Template.Spec.InitContainers = append(statefulset.Spec.Template.Spec.InitContainers, container)
+		if volume != nil {
+			statefulset.Spec.Template.Spec.Volumes = append(statefulset.Spec.Template.Spec.Volumes, *volume)
+		}
+	}
+
+	// Dragonfly serves a single certificate on all of its ports, and only
+	// serves TLS on the admin port along with the client port
+	tlsSecretRef := df.Spec.TLSSecretRef
+	if df.Spec.TLS != nil && df.Spec.TLS.CertManager != nil {
+		if tlsSecretRef != nil {
+			return nil, fmt.Errorf("tls.certManager can't be specified along with a TLS secret")
+		}
+		tlsSecretRef = &corev1.SecretReference{Name: GetServerCertificateSecretName(df)}
+	}
+
+	if df.Spec.ReplicationTLS != nil && tlsSecretRef == nil {
+		return nil, fmt.Errorf("replication TLS specified without a TLS secret")
+	}
+
+	if tlsSecretRef != nil {
 		statefulset.Spec.Template.Spec.Volumes = append(statefulset.Spec.Template.Spec.Volumes, corev1.Volume{
-			Name: "dragonfly-tls",
+			Name: TLSVolumeName,
 			VolumeSource: corev1.VolumeSource{
 				Secret: &corev1.SecretVolumeSource{
-					SecretName: df.Spec.TLSSecretRef.Name,
+					SecretName: tlsSecretRef.Name,
+					Items:      getTLSSecretItems(df),
 				},
 			},
 		})
 
 		statefulset.Spec.Template.Spec.Containers[0].VolumeMounts = append(statefulset.Spec.Template.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
-			Name:      "dragonfly-tls",
+			Name:      TLSVolumeName,
 			ReadOnly:  true,
 			MountPath: TlsPath,
 		})
 
-		statefulset.Spec.Template.Spec.Containers[0].Args = append(statefulset.Spec.Template.Spec.Containers[0].Args, []string{
+		if df.Spec.ReplicationTLS != nil {
+			if ref := df.Spec.ReplicationTLS.CASecretRef; ref != nil {
+				statefulset.Spec.Template.Spec.Volumes = append(statefulset.Spec.Template.Spec.Volumes, corev1.Volume{
+					Name: ReplicationCAVolumeName,
+					VolumeSource: corev1.VolumeSource{
+						Secret: &corev1.SecretVolumeSource{
+							SecretName: ref.Name,
+							Items:      []corev1.KeyToPath{{Key: ref.Key, Path: "ca.crt"}},
+						},
+					},
+				})
+
+				statefulset.Spec.Template.Spec.Containers[0].VolumeMounts = append(statefulset.Spec.Template.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+					Name:      ReplicationCAVolumeName,
+					ReadOnly:  true,
+					MountPath: ReplicationCAPath,
+				})
+			}
+
+			// replication runs over the admin port, so it has to serve TLS too
+			statefulset.Spec.Template.Spec.Containers[0].Args = append(statefulset.Spec.Template.Spec.Containers[0].Args, []string{
+				TLSReplicationArg,
+				fmt.Sprintf("--tls_ca_cert_file=%s", getReplicationCAFile(df)),
+			}...)
+		} else {
 			// no TLS on admin port by default
-			"--no_tls_on_admin_port",
+			statefulset.Spec.Template.Spec.Containers[0].Args = append(statefulset.Spec.Template.Spec.Containers[0].Args, "--no_tls_on_admin_port")
+		}
+
+		statefulset.Spec.Template.Spec.Containers[0].Args = append(statefulset.Spec.Template.Spec.Containers[0].Args, []string{
 			"--tls",
 			fmt.Sprintf("--tls_cert_file=%s/tls.crt", TlsPath),
 			fmt.Sprintf("--tls_key_file=%s/tls.key", TlsPath),
 		}...)
 	}
 
+	if df.Spec.Replication != nil {
+		statefulset.Spec.Template.Spec.Containers[0].Args = append(statefulset.Spec.Template.Spec.Containers[0].Args, getReplicationArgs(df.Spec.Replication)...)
+	}
+
+	if df.Spec.EvictionPolicy != "" {
+		if hasArg(df.Spec.Args, CacheModeArg) {
+			return nil, fmt.Errorf("evictionPolicy can't be specified along with the %s arg", CacheModeArg)
+		}
+
+		if df.Spec.EvictionPolicy == EvictionPolicyCache {
+			if df.Spec.Snapshot != nil && df.Spec.Snapshot.PersistentVolumeClaimSpec != nil {
+				return nil, fmt.Errorf("eviction policy %s can't be combined with a persistent snapshot volume", EvictionPolicyCache)
+			}
+			statefulset.Spec.Template.Spec.Containers[0].Args = append(statefulset.Spec.Template.Spec.Containers[0].Args, fmt.Sprintf("%s=true", CacheModeArg))
+		}
+	}
+
+	if df.Spec.KeyspaceNotifications != "" {
+		if hasArg(df.Spec.Args, NotifyKeyspaceEventsArg) {
+			return nil, fmt.Errorf("keyspaceNotifications can't be specified along with the %s arg", NotifyKeyspaceEventsArg)
+		}
+		statefulset.Spec.Template.Spec.Containers[0].Args = append(statefulset.Spec.Template.Spec.Containers[0].Args, fmt.Sprintf("%s=%s", NotifyKeyspaceEventsArg, df.Spec.KeyspaceNotifications))
+	}
+
+	if df.Spec.Announce != nil {
+		// expose the pod addresses, so that they can be used in the announced address
+		statefulset.Spec.Template.Spec.Containers[0].Env = append(statefulset.Spec.Template.Spec.Containers[0].Env,
+			corev1.EnvVar{
+				Name: "POD_NAME",
+				ValueFrom: &corev1.EnvVarSource{
+					FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
+				},
+			},
+			corev1.EnvVar{
+				Name: "POD_IP",
+				ValueFrom: &corev1.EnvVarSource{
+					FieldRef: &corev1.ObjectFieldSelector{FieldPath: "status.podIP"},
+				},
+			},
+			corev1.EnvVar{
+				Name: "NODE_IP",
+				ValueFrom: &corev1.EnvVarSource{
+					FieldRef: &corev1.ObjectFieldSelector{FieldPath: "status.hostIP"},
+				},
+			},
+		)
+
+		switch df.Spec.Announce.Source {
+		case AnnounceSourceNodeIP:
+			statefulset.Spec.Template.Spec.Containers[0].Args = append(statefulset.Spec.Template.Spec.Containers[0].Args, "--announce_ip=$(NODE_IP)")
+		case AnnounceSourceTemplate:
+			if df.Spec.Announce.Template == "" {
+				return nil, fmt.Errorf("announce source %s specified without a template", AnnounceSourceTemplate)
+			}
+			statefulset.Spec.Template.Spec.Containers[0].Args = append(statefulset.Spec.Template.Spec.Containers[0].Args, fmt.Sprintf("--announce_ip=%s", df.Spec.Announce.Template))
+		default:
+			return nil, fmt.Errorf("unknown announce source %s", df.Spec.Announce.Source)
+		}
+
+		if df.Spec.Announce.Port != 0 {
+			statefulset.Spec.Template.Spec.Containers[0].Args = append(statefulset.Spec.Template.Spec.Containers[0].Args, fmt.Sprintf("--announce_port=%d", df.Spec.Announce.Port))
+		}
+	}
+
+	// the annotations are copied, as the hashes of the referenced secrets
+	// and configmaps are added to them
 	if df.Spec.Annotations != nil {
-		statefulset.Spec.Template.ObjectMeta.Annotations = df.Spec.Annotations
+		statefulset.Spec.Template.ObjectMeta.Annotations = make(map[string]string, len(df.Spec.Annotations))
+		for k, v := range df.Spec.Annotations {
+			statefulset.Spec.Template.ObjectMeta.Annotations[k] = v
+		}
+	}
+
+	if df.Spec.Snapshot != nil && df.Spec.Snapshot.RestoreVerification != nil && df.Spec.Snapshot.PersistentVolumeClaimSpec == nil {
+		return nil, fmt.Errorf("restore verification specified without a persistent volume claim")
+	}
+
+	if df.Spec.Snapshot != nil && df.Spec.Snapshot.Backup != nil && df.Spec.Snapshot.PersistentVolumeClaimSpec == nil {
+		return nil, fmt.Errorf("backup specified without a persistent volume claim")
+	}
+
+	if df.Spec.Snapshot != nil && df.Spec.Snapshot.VeleroBackupHooks {
+		if df.Spec.Snapshot.PersistentVolumeClaimSpec == nil {
+			return nil, fmt.Errorf("velero backup hooks specified without a persistent volume claim")
+		}
+
+		annotations := make(map[string]string, len(df.Spec.Annotations)+4)
+		for k, v := range df.Spec.Annotations {
+			annotations[k] = v
+		}
+
+		// save a snapshot to the PVC before Velero backs it up. Writes are
+		// not paused during SAVE, so there is nothing to resume afterwards.
+		command, err := json.Marshal([]string{"/bin/sh", "-c", getVeleroSaveScript(df)})
+		if err != nil {
+			return nil, err
+		}
+		annotations[VeleroPreBackupHookContainerAnnotation] = "dragonfly"
+		annotations[VeleroPreBackupHookCommandAnnotation] = string(command)
+		annotations[VeleroPreBackupHookOnErrorAnnotation] = "Fail"
+		annotations[VeleroPreBackupHookTimeoutAnnotation] = "5m"
+		statefulset.Spec.Template.ObjectMeta.Annotations = annotations
+	}
+
+	// a change of the restart request rolls the pods
+	if request, ok := df.Annotations[RestartRequestAnnotation]; ok {
+		annotations := make(map[string]string, len(statefulset.Spec.Template.ObjectMeta.Annotations)+1)
+		for k, v := range statefulset.Spec.Template.ObjectMeta.Annotations {
+			annotations[k] = v
+		}
+		annotations[RestartRequestAnnotation] = request
+		statefulset.Spec.Template.ObjectMeta.Annotations = annotations
 	}
 
 	if df.Spec.Affinity != nil {
 		statefulset.Spec.Template.Spec.Affinity = df.Spec.Affinity
+	} else {
+		statefulset.Spec.Template.Spec.Affinity = getDefaultAffinity(df)
 	}
 
 	if df.Spec.Tolerations != nil {
@@ -238,6 +551,18 @@ func GetDragonflyResources(ctx context.Context, df *resourcesv1.Dragonfly) ([]cl
 		statefulset.Spec.Template.Spec.ServiceAccountName = df.Spec.ServiceAccountName
 	}
 
+	if df.Spec.DNSPolicy != "" {
+		statefulset.Spec.Template.Spec.DNSPolicy = df.Spec.DNSPolicy
+	}
+
+	if df.Spec.DNSConfig != nil {
+		statefulset.Spec.Template.Spec.DNSConfig = df.Spec.DNSConfig
+	}
+
+	if df.Spec.HostAliases != nil {
+		statefulset.Spec.Template.Spec.HostAliases = df.Spec.HostAliases
+	}
+
 	if df.Spec.Authentication != nil {
 		if df.Spec.Authentication.PasswordFromSecret != nil {
 			// load the secret key as a password into env
@@ -248,6 +573,14 @@ func GetDragonflyResources(ctx context.Context, df *resourcesv1.Dragonfly) ([]cl
 					SecretKeyRef: df.Spec.Authentication.PasswordFromSecret,
 				},
 			})
+
+			// replicas authenticate to the master with the same password
+			statefulset.Spec.Template.Spec.Containers[0].Env = append(statefulset.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{
+				Name: "DFLY_masterauth",
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: df.Spec.Authentication.PasswordFromSecret,
+				},
+			})
 		}
 
 		if df.Spec.Authentication.ClientCaCertSecret != nil {
@@ -273,6 +606,43 @@ func GetDragonflyResources(ctx context.Context, df *resourcesv1.Dragonfly) ([]cl
 		}
 	}
 
+	if df.Spec.Logging != nil {
+		if err := setLogging(df, &statefulset); err != nil {
+			return nil, err
+		}
+	}
+
+	if df.Spec.UnixSocket != nil {
+		// share the socket with the other containers of the pod
+		statefulset.Spec.Template.Spec.Volumes = append(statefulset.Spec.Template.Spec.Volumes, corev1.Volume{
+			Name: UnixSocketVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		})
+		statefulset.Spec.Template.Spec.Containers[0].VolumeMounts = append(statefulset.Spec.Template.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      UnixSocketVolumeName,
+			MountPath: UnixSocketDir,
+		})
+
+		statefulset.Spec.Template.Spec.Containers[0].Args = append(statefulset.Spec.Template.Spec.Containers[0].Args, fmt.Sprintf("%s=%s", UnixSocketArg, UnixSocketPath))
+		if df.Spec.UnixSocket.Permissions != "" {
+			statefulset.Spec.Template.Spec.Containers[0].Args = append(statefulset.Spec.Template.Spec.Containers[0].Args, fmt.Sprintf("%s=%s", UnixSocketPermArg, df.Spec.UnixSocket.Permissions))
+		}
+	}
+
+	if df.Spec.Metrics != nil {
+		statefulset.Spec.Template.Spec.Containers = append(statefulset.Spec.Template.Spec.Containers, getMetricsContainer(df))
+	}
+
+	if df.Spec.StatefulSetOverrides != nil {
+		merged, err := strategicMerge(statefulset, df.Spec.StatefulSetOverrides.Raw)
+		if err != nil {
+			return nil, fmt.Errorf("could not apply the statefulset overrides: %w", err)
+		}
+		statefulset = merged
+	}
+
 	resources = append(resources, &statefulset)
 
 	service := corev1.Service{
@@ -313,7 +683,552 @@ func GetDragonflyResources(ctx context.Context, df *resourcesv1.Dragonfly) ([]cl
 		},
 	}
 
+	for _, port := range df.Spec.ExtraPorts {
+		service.Spec.Ports = append(service.Spec.Ports, corev1.ServicePort{
+			Name:       port.Name,
+			Port:       port.Port,
+			Protocol:   port.Protocol,
+			TargetPort: intstr.FromString(port.Name),
+		})
+	}
+
+	// the operator manages the endpoints of the service
+	if df.Spec.ManageMasterEndpoints {
+		service.Spec.Selector = nil
+	}
+
+	if df.Spec.ServiceSpecOverride != nil {
+		serviceSpec, err := mergeServiceSpec(service.Spec, *df.Spec.ServiceSpecOverride)
+		if err != nil {
+			return nil, fmt.Errorf("could not merge the service spec override: %w", err)
+		}
+		service.Spec = serviceSpec
+	}
+
 	resources = append(resources, &service)
 
+	if df.Spec.Metrics != nil {
+		resources = append(resources, GetMetricsService(df))
+
+		if df.Spec.Metrics.ServiceMonitor != nil {
+			resources = append(resources, GetServiceMonitor(df))
+		}
+	}
+
+	if df.Spec.ReplicaService != nil {
+		resources = append(resources, GetReplicaService(df))
+	}
+
+	if df.Spec.EvictionProtection {
+		resources = append(resources, GetMasterPodDisruptionBudget(df))
+	}
+
+	if df.Spec.TLS != nil && df.Spec.TLS.CertManager != nil {
+		for _, certificate := range GetCertificates(df) {
+			resources = append(resources, certificate)
+		}
+	}
+
+	if df.Spec.PrometheusRule != nil {
+		resources = append(resources, GetPrometheusRule(df))
+	}
+
+	if df.Spec.Route != nil {
+		if tlsSecretRef == nil {
+			return nil, fmt.Errorf("route specified without TLS")
+		}
+		resources = append(resources, GetRoute(df))
+	}
+
+	for _, resource := range resources {
+		setCommonMetadata(df, resource)
+	}
+
 	return resources, nil
 }
+
+// GetMasterPodDisruptionBudget returns the PodDisruptionBudget that blocks
+// the eviction of the master of a Dragonfly instance, so that the operator
+// can hand over the master role first
+func GetMasterPodDisruptionBudget(df *resourcesv1.Dragonfly) *policyv1.PodDisruptionBudget {
+	maxUnavailable := intstr.FromInt(0)
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-master", df.Name),
+			Namespace: df.Namespace,
+			// Useful for automatically deleting the resources when the Dragonfly object is deleted
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: df.APIVersion,
+					Kind:       df.Kind,
+					Name:       df.Name,
+					UID:        df.UID,
+				},
+			},
+			Labels: map[string]string{
+				KubernetesAppComponentLabelKey: "Dragonfly",
+				KubernetesAppInstanceNameLabel: df.Name,
+				KubernetesAppNameLabelKey:      "dragonfly",
+				KubernetesAppVersionLabelKey:   Version,
+				KubernetesPartOfLabelKey:       "dragonfly",
+				KubernetesManagedByLabelKey:    DragonflyOperatorName,
+				"app":                          df.Name,
+			},
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app":                     df.Name,
+					KubernetesAppNameLabelKey: "dragonfly",
+					Role:                      Master,
+				},
+			},
+		},
+	}
+}
+
+// GetMetricsServiceName returns the name of the metrics Service of a
+// Dragonfly instance
+func GetMetricsServiceName(df *resourcesv1.Dragonfly) string {
+	return fmt.Sprintf("%s-metrics", df.Name)
+}
+
+// GetMetricsService returns the headless Service of the metrics of all
+// the pods of a Dragonfly instance. Dragonfly serves its metrics over
+// HTTP on the admin port, as HTTP is disabled on the client port, and
+// the metrics sidecar only exposes the metrics of it.
+func GetMetricsService(df *resourcesv1.Dragonfly) *corev1.Service {
+	labels := map[string]string{
+		KubernetesAppComponentLabelKey: "Dragonfly",
+		KubernetesAppInstanceNameLabel: df.Name,
+		KubernetesAppNameLabelKey:      "dragonfly",
+		KubernetesAppVersionLabelKey:   Version,
+		KubernetesPartOfLabelKey:       "dragonfly",
+		KubernetesManagedByLabelKey:    DragonflyOperatorName,
+		"app":                          df.Name,
+	}
+
+	annotations := map[string]string{
+		PrometheusScrapeAnnotation: "true",
+		PrometheusPortAnnotation:   strconv.Itoa(DragonflyMetricsPort),
+		PrometheusPathAnnotation:   DragonflyMetricsPath,
+	}
+
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetMetricsServiceName(df),
+			Namespace: df.Namespace,
+			// Useful for automatically deleting the resources when the Dragonfly object is deleted
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: df.APIVersion,
+					Kind:       df.Kind,
+					Name:       df.Name,
+					UID:        df.UID,
+				},
+			},
+			Labels:      mergeMissing(labels, df.Spec.Metrics.Labels),
+			Annotations: mergeMissing(annotations, df.Spec.Metrics.Annotations),
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: corev1.ClusterIPNone,
+			Selector: map[string]string{
+				"app":                     df.Name,
+				KubernetesAppNameLabelKey: "dragonfly",
+			},
+			Ports: []corev1.ServicePort{
+				{
+					Name:       DragonflyMetricsPortName,
+					Port:       DragonflyMetricsPort,
+					TargetPort: intstr.FromString(DragonflyMetricsPortName),
+				},
+			},
+		},
+	}
+}
+
+// GetReplicaServiceName returns the name of the Service of the replicas of
+// a Dragonfly instance
+func GetReplicaServiceName(df *resourcesv1.Dragonfly) string {
+	return fmt.Sprintf("%s-replicas", df.Name)
+}
+
+// GetReplicaService returns the Service of the replicas of a Dragonfly
+// instance, selected by their role label
+func GetReplicaService(df *resourcesv1.Dragonfly) *corev1.Service {
+	labels := map[string]string{
+		KubernetesAppComponentLabelKey: "Dragonfly",
+		KubernetesAppInstanceNameLabel: df.Name,
+		KubernetesAppNameLabelKey:      "dragonfly",
+		KubernetesAppVersionLabelKey:   Version,
+		KubernetesPartOfLabelKey:       "dragonfly",
+		KubernetesManagedByLabelKey:    DragonflyOperatorName,
+		"app":                          df.Name,
+	}
+
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetReplicaServiceName(df),
+			Namespace: df.Namespace,
+			// Useful for automatically deleting the resources when the Dragonfly object is deleted
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: df.APIVersion,
+					Kind:       df.Kind,
+					Name:       df.Name,
+					UID:        df.UID,
+				},
+			},
+			Labels:      mergeMissing(labels, df.Spec.ReplicaService.Labels),
+			Annotations: mergeMissing(nil, df.Spec.ReplicaService.Annotations),
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{
+				"app":                     df.Name,
+				KubernetesAppNameLabelKey: "dragonfly",
+				Role:                      Replica,
+			},
+			Ports: []corev1.ServicePort{
+				{
+					Name: DragonflyPortName,
+					Port: DragonflyPort,
+				},
+			},
+		},
+	}
+}
+
+// GetMasterEndpointSlice returns the EndpointSlice of the master Service
+// of a Dragonfly instance pointing to the given master pod. The address
+// type follows the IP family of the pod, e.g on IPv6 clusters.
+func GetMasterEndpointSlice(df *resourcesv1.Dragonfly, master *corev1.Pod) (*discoveryv1.EndpointSlice, error) {
+	ip := net.ParseIP(master.Status.PodIP)
+	if ip == nil {
+		return nil, fmt.Errorf("master pod %s has no valid IP %q", master.Name, master.Status.PodIP)
+	}
+
+	addressType := discoveryv1.AddressTypeIPv4
+	if ip.To4() == nil {
+		addressType = discoveryv1.AddressTypeIPv6
+	}
+
+	// the fields of every slice get their own values, so that changes
+	// of one slice don't leak into others
+	ready := true
+	nodeName := master.Spec.NodeName
+	portName := DragonflyPortName
+	port := int32(DragonflyPort)
+	endpointSlice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      df.Name,
+			Namespace: df.Namespace,
+			// Useful for automatically deleting the resources when the Dragonfly object is deleted
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: df.APIVersion,
+					Kind:       df.Kind,
+					Name:       df.Name,
+					UID:        df.UID,
+				},
+			},
+			Labels: map[string]string{
+				KubernetesAppComponentLabelKey: "Dragonfly",
+				KubernetesAppInstanceNameLabel: df.Name,
+				KubernetesAppNameLabelKey:      "dragonfly",
+				KubernetesAppVersionLabelKey:   Version,
+				KubernetesPartOfLabelKey:       "dragonfly",
+				KubernetesManagedByLabelKey:    DragonflyOperatorName,
+				"app":                          df.Name,
+				discoveryv1.LabelServiceName:   df.Name,
+				discoveryv1.LabelManagedBy:     DragonflyOperatorName,
+			},
+		},
+		AddressType: addressType,
+		Endpoints: []discoveryv1.Endpoint{
+			{
+				Addresses: []string{master.Status.PodIP},
+				Conditions: discoveryv1.EndpointConditions{
+					Ready: &ready,
+				},
+				NodeName: &nodeName,
+				TargetRef: &corev1.ObjectReference{
+					Kind:      "Pod",
+					Name:      master.Name,
+					Namespace: master.Namespace,
+					UID:       master.UID,
+				},
+			},
+		},
+		Ports: []discoveryv1.EndpointPort{
+			{
+				Name: &portName,
+				Port: &port,
+			},
+		},
+	}
+
+	for _, extraPort := range df.Spec.ExtraPorts {
+		extraPort := extraPort
+		endpointSlice.Ports = append(endpointSlice.Ports, discoveryv1.EndpointPort{
+			Name:     &extraPort.Name,
+			Port:     &extraPort.Port,
+			Protocol: protocolOrNil(extraPort.Protocol),
+		})
+	}
+
+	setCommonMetadata(df, endpointSlice)
+	return endpointSlice, nil
+}
+
+// protocolOrNil returns a pointer to the given protocol, or nil if it's
+// not set
+func protocolOrNil(protocol corev1.Protocol) *corev1.Protocol {
+	if protocol == "" {
+		return nil
+	}
+	return &protocol
+}
+
+// hasArg returns if the given flag is part of the given args
+func hasArg(args []string, flag string) bool {
+	_, ok := getArgValue(args, flag)
+	return ok
+}
+
+// getArgValue returns the value of the given flag in the given args.
+// Only the --flag=value form is supported.
+func getArgValue(args []string, flag string) (string, bool) {
+	for _, arg := range args {
+		if arg == flag {
+			return "", true
+		}
+
+		if strings.HasPrefix(arg, flag+"=") {
+			return strings.TrimPrefix(arg, flag+"="), true
+		}
+	}
+
+	return "", false
+}
+
+// getReplicationArgs returns the Dragonfly args for the given
+// replication tuning
+func getReplicationArgs(replication *resourcesv1.Replication) []string {
+	var args []string
+	if replication.Timeout != nil {
+		args = append(args, fmt.Sprintf("--replication_timeout=%d", replication.Timeout.Milliseconds()))
+	}
+
+	if replication.StreamTimeout != nil {
+		args = append(args, fmt.Sprintf("--replication_stream_timeout=%d", replication.StreamTimeout.Milliseconds()))
+	}
+
+	if replication.StreamOutputLimit != nil {
+		args = append(args, fmt.Sprintf("--replication_stream_output_limit=%d", replication.StreamOutputLimit.Value()))
+	}
+
+	if replication.ShardBacklogLength != nil {
+		args = append(args, fmt.Sprintf("--shard_repl_backlog_len=%d", *replication.ShardBacklogLength))
+	}
+
+	if replication.MasterConnectTimeout != nil {
+		args = append(args, fmt.Sprintf("--master_connect_timeout_ms=%d", replication.MasterConnectTimeout.Milliseconds()))
+	}
+
+	if replication.MasterReconnectTimeout != nil {
+		args = append(args, fmt.Sprintf("--master_reconnect_timeout_ms=%d", replication.MasterReconnectTimeout.Milliseconds()))
+	}
+
+	return args
+}
+
+// getDefaultAffinity returns the anti-affinity of the pods of an instance
+// for its anti-affinity mode, so that a single node failure can't take
+// out the master and all its replicas
+func getDefaultAffinity(df *resourcesv1.Dragonfly) *corev1.Affinity {
+	mode := defaultString(df.Spec.AntiAffinityMode, AntiAffinityModePreferred)
+	if mode == AntiAffinityModeNone {
+		return nil
+	}
+
+	term := func(topologyKey string) corev1.PodAffinityTerm {
+		return corev1.PodAffinityTerm{
+			LabelSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app":                     df.Name,
+					KubernetesAppNameLabelKey: "dragonfly",
+				},
+			},
+			TopologyKey: topologyKey,
+		}
+	}
+
+	antiAffinity := &corev1.PodAntiAffinity{
+		PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+			{Weight: 50, PodAffinityTerm: term(corev1.LabelTopologyZone)},
+		},
+	}
+
+	if mode == AntiAffinityModeRequired {
+		antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution = []corev1.PodAffinityTerm{term(corev1.LabelHostname)}
+	} else {
+		antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution,
+			corev1.WeightedPodAffinityTerm{Weight: 100, PodAffinityTerm: term(corev1.LabelHostname)})
+	}
+
+	return &corev1.Affinity{PodAntiAffinity: antiAffinity}
+}
+
+// getVeleroSaveScript returns the script of the Velero pre backup hook,
+// which saves a snapshot through the admin port with the redis-cli of the
+// Dragonfly image. The admin port serves TLS with replication TLS, so the
+// certificate is verified against the CA of the replication link, like
+// the operator does, and the hook fails clearly if the image has no redis-cli
+// or the SAVE fails, as redis-cli may exit with 0 on error replies.
+func getVeleroSaveScript(df *resourcesv1.Dragonfly) string {
+	tlsArgs := ""
+	if df.Spec.ReplicationTLS != nil {
+		tlsArgs = fmt.Sprintf("--tls --cacert %s ", getReplicationCAFile(df))
+	}
+
+	return `command -v redis-cli >/dev/null || { echo "redis-cli is not in the image of the dragonfly container" >&2; exit 1; }; ` +
+		fmt.Sprintf(`reply="$(redis-cli -p %d %sSAVE)"; `, DragonflyAdminPort, tlsArgs) +
+		`[ "$reply" = OK ] || { echo "SAVE failed: $reply" >&2; exit 1; }`
+}
+
+// getTLSSecretItems maps the configured key names of the TLS secret to
+// the file names Dragonfly is started with. nil mounts all keys as is.
+func getTLSSecretItems(df *resourcesv1.Dragonfly) []corev1.KeyToPath {
+	keys := df.Spec.TLSSecretKeys
+	if keys == nil {
+		return nil
+	}
+
+	items := []corev1.KeyToPath{
+		{Key: defaultString(keys.Cert, "tls.crt"), Path: "tls.crt"},
+		{Key: defaultString(keys.Key, "tls.key"), Path: "tls.key"},
+	}
+
+	if df.Spec.ReplicationTLS != nil && df.Spec.ReplicationTLS.CASecretRef == nil {
+		items = append(items, corev1.KeyToPath{Key: defaultString(keys.CA, "ca.crt"), Path: "ca.crt"})
+	}
+
+	return items
+}
+
+// getReplicationCAFile returns the path of the CA certificate that the
+// replication link of the instance trusts
+func getReplicationCAFile(df *resourcesv1.Dragonfly) string {
+	if df.Spec.ReplicationTLS != nil && df.Spec.ReplicationTLS.CASecretRef != nil {
+		return path.Join(ReplicationCAPath, "ca.crt")
+	}
+
+	return path.Join(TlsPath, "ca.crt")
+}
+
+func defaultString(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+
+	return value
+}
+
+// newUnstructured returns a resource of a kind that isn't known to the
+// operator, owned by the Dragonfly object
+func newUnstructured(df *resourcesv1.Dragonfly, gvk schema.GroupVersionKind, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	object := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"metadata": map[string]interface{}{
+				// Useful for automatically deleting the resources when the Dragonfly object is deleted
+				"ownerReferences": []interface{}{
+					map[string]interface{}{
+						"apiVersion": df.APIVersion,
+						"kind":       df.Kind,
+						"name":       df.Name,
+						"uid":        string(df.UID),
+					},
+				},
+			},
+			"spec": spec,
+		},
+	}
+
+	object.SetGroupVersionKind(gvk)
+	object.SetName(name)
+	object.SetNamespace(df.Namespace)
+	object.SetLabels(map[string]string{
+		KubernetesAppComponentLabelKey: "Dragonfly",
+		KubernetesAppInstanceNameLabel: df.Name,
+		KubernetesAppNameLabelKey:      "dragonfly",
+		KubernetesAppVersionLabelKey:   Version,
+		KubernetesPartOfLabelKey:       "dragonfly",
+		KubernetesManagedByLabelKey:    DragonflyOperatorName,
+		"app":                          df.Name,
+	})
+
+	return object
+}
+
+// setCommonMetadata adds the common labels and annotations of the Dragonfly
+// object to the given resource, without overriding the existing ones
+func setCommonMetadata(df *resourcesv1.Dragonfly, object metav1.Object) {
+	if df.Spec.CommonMetadata == nil {
+		return
+	}
+
+	object.SetLabels(mergeMissing(object.GetLabels(), df.Spec.CommonMetadata.Labels))
+	object.SetAnnotations(mergeMissing(object.GetAnnotations(), df.Spec.CommonMetadata.Annotations))
+}
+
+// mergeMissing adds the entries of from that are missing in to
+func mergeMissing(to, from map[string]string) map[string]string {
+	if len(from) == 0 {
+		return to
+	}
+
+	if to == nil {
+		to = make(map[string]string, len(from))
+	}
+
+	for key, value := range from {
+		if _, ok := to[key]; !ok {
+			to[key] = value
+		}
+	}
+
+	return to
+}
+
+// mergeServiceSpec strategically merges the override into the given
+// service spec
+func mergeServiceSpec(spec, override corev1.ServiceSpec) (corev1.ServiceSpec, error) {
+	patch, err := json.Marshal(override)
+	if err != nil {
+		return corev1.ServiceSpec{}, err
+	}
+
+	return strategicMerge(spec, patch)
+}
+
+// strategicMerge applies the strategic merge patch to the given object
+func strategicMerge[T any](object T, patch []byte) (T, error) {
+	var merged T
+
+	original, err := json.Marshal(object)
+	if err != nil {
+		return merged, err
+	}
+
+	mergedJSON, err := strategicpatch.StrategicMergePatch(original, patch, merged)
+	if err != nil {
+		return merged, err
+	}
+
+	if err := json.Unmarshal(mergedJSON, &merged); err != nil {
+		return merged, err
+	}
+
+	return merged, nil
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	resourcesv1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetDragonflyResourcesCopiesPodAnnotations(t *testing.T) {
+	df := &resourcesv1.Dragonfly{
+		ObjectMeta: metav1.ObjectMeta{Name: "df", Namespace: "default"},
+		Spec: resourcesv1.DragonflySpec{
+			Replicas:    2,
+			Annotations: map[string]string{"example.com/owner": "cache"},
+		},
+	}
+
+	objects, err := GetDragonflyResources(context.Background(), df)
+	if err != nil {
+		t.Fatalf("GetDragonflyResources() error = %v", err)
+	}
+
+	for _, object := range objects {
+		statefulSet, ok := object.(*appsv1.StatefulSet)
+		if !ok {
+			continue
+		}
+
+		if statefulSet.Spec.Template.Annotations["example.com/owner"] != "cache" {
+			t.Errorf("pod template annotations = %v, want the annotations of the spec", statefulSet.Spec.Template.Annotations)
+		}
+
+		statefulSet.Spec.Template.Annotations[SecretsHashAnnotation] = "hash"
+		if _, ok := df.Spec.Annotations[SecretsHashAnnotation]; ok {
+			t.Error("the pod template annotations alias the annotations of the spec")
+		}
+		return
+	}
+
+	t.Fatal("no statefulset in the resources")
+}
+
+func TestGetDragonflyResourcesVeleroBackupHooks(t *testing.T) {
+	tests := []struct {
+		name           string
+		replicationTLS *resourcesv1.ReplicationTLS
+		wantTLS        bool
+	}{
+		{name: "plain admin port", wantTLS: false},
+		{name: "replication TLS", replicationTLS: &resourcesv1.ReplicationTLS{}, wantTLS: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df := &resourcesv1.Dragonfly{
+				ObjectMeta: metav1.ObjectMeta{Name: "df", Namespace: "default"},
+				Spec: resourcesv1.DragonflySpec{
+					Replicas:       2,
+					TLSSecretRef:   &corev1.SecretReference{Name: "tls"},
+					ReplicationTLS: tt.replicationTLS,
+					Snapshot: &resourcesv1.Snapshot{
+						PersistentVolumeClaimSpec: &corev1.PersistentVolumeClaimSpec{},
+						VeleroBackupHooks:         true,
+					},
+				},
+			}
+
+			objects, err := GetDragonflyResources(context.Background(), df)
+			if err != nil {
+				t.Fatalf("GetDragonflyResources() error = %v", err)
+			}
+
+			for _, object := range objects {
+				statefulSet, ok := object.(*appsv1.StatefulSet)
+				if !ok {
+					continue
+				}
+
+				var command []string
+				if err := json.Unmarshal([]byte(statefulSet.Spec.Template.Annotations[VeleroPreBackupHookCommandAnnotation]), &command); err != nil {
+					t.Fatalf("pre backup hook command is not a JSON array: %v", err)
+				}
+
+				if len(command) != 3 || command[0] != "/bin/sh" {
+					t.Fatalf("pre backup hook command = %v, want a shell script", command)
+				}
+
+				if got := strings.Contains(command[2], "--tls --cacert /etc/dragonfly-tls/ca.crt"); got != tt.wantTLS {
+					t.Errorf("pre backup hook script %q uses TLS = %v, want %v", command[2], got, tt.wantTLS)
+				}
+				return
+			}
+
+			t.Fatal("no statefulset in the resources")
+		})
+	}
+}
+
+func TestGetMasterEndpointSlice(t *testing.T) {
+	tests := []struct {
+		name    string
+		podIP   string
+		want    discoveryv1.AddressType
+		wantErr bool
+	}{
+		{name: "IPv4", podIP: "10.0.0.1", want: discoveryv1.AddressTypeIPv4},
+		{name: "IPv6", podIP: "fd00::1", want: discoveryv1.AddressTypeIPv6},
+		{name: "no IP", podIP: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df := &resourcesv1.Dragonfly{ObjectMeta: metav1.ObjectMeta{Name: "df", Namespace: "default"}}
+			master := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "df-0", Namespace: "default"},
+				Spec:       corev1.PodSpec{NodeName: "node"},
+				Status:     corev1.PodStatus{PodIP: tt.podIP},
+			}
+
+			endpointSlice, err := GetMasterEndpointSlice(df, master)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetMasterEndpointSlice() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if endpointSlice.AddressType != tt.want {
+				t.Errorf("AddressType = %s, want %s", endpointSlice.AddressType, tt.want)
+			}
+
+			// the slices don't share their fields with each other or the pod
+			*endpointSlice.Ports[0].Port = 1
+			*endpointSlice.Endpoints[0].NodeName = "other"
+			other, err := GetMasterEndpointSlice(df, master)
+			if err != nil {
+				t.Fatalf("GetMasterEndpointSlice() error = %v", err)
+			}
+			if *other.Ports[0].Port != DragonflyPort {
+				t.Errorf("port = %d, want %d", *other.Ports[0].Port, DragonflyPort)
+			}
+			if master.Spec.NodeName != "node" {
+				t.Errorf("node name of the pod = %s, want node", master.Spec.NodeName)
+			}
+		})
+	}
+}
+
+func TestGetDragonflyResourcesReplicationCA(t *testing.T) {
+	df := &resourcesv1.Dragonfly{
+		ObjectMeta: metav1.ObjectMeta{Name: "df", Namespace: "default"},
+		Spec: resourcesv1.DragonflySpec{
+			Replicas:      2,
+			TLSSecretRef:  &corev1.SecretReference{Name: "tls"},
+			TLSSecretKeys: &resourcesv1.TLSSecretKeys{},
+			ReplicationTLS: &resourcesv1.ReplicationTLS{
+				CASecretRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "replication-ca"}, Key: "root.pem"},
+			},
+		},
+	}
+
+	objects, err := GetDragonflyResources(context.Background(), df)
+	if err != nil {
+		t.Fatalf("GetDragonflyResources() error = %v", err)
+	}
+
+	for _, object := range objects {
+		statefulSet, ok := object.(*appsv1.StatefulSet)
+		if !ok {
+			continue
+		}
+
+		podSpec := statefulSet.Spec.Template.Spec
+		for _, volume := range podSpec.Volumes {
+			switch volume.Name {
+			case TLSVolumeName:
+				for _, item := range volume.Secret.Items {
+					if item.Path == "ca.crt" {
+						t.Errorf("TLS secret items = %v, want no CA along with a replication CA", volume.Secret.Items)
+					}
+				}
+			case ReplicationCAVolumeName:
+				if volume.Secret.SecretName != "replication-ca" || len(volume.Secret.Items) != 1 || volume.Secret.Items[0].Key != "root.pem" {
+					t.Errorf("replication CA volume = %v, want key root.pem of replication-ca", volume.Secret)
+				}
+			}
+		}
+
+		if caFile, _ := getArgValue(podSpec.Containers[0].Args, "--tls_ca_cert_file"); caFile != ReplicationCAPath+"/ca.crt" {
+			t.Errorf("args = %v, want the replication CA", podSpec.Containers[0].Args)
+		}
+		return
+	}
+
+	t.Fatal("no statefulset in the resources")
+}
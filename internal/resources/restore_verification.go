@@ -0,0 +1,110 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+
+	resourcesv1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// RestoreVerificationComponent is the component label of the pods
+	// that verify the restore of a snapshot
+	RestoreVerificationComponent = "restore-verification"
+
+	DBFilenameArg = "--dbfilename"
+)
+
+// GetRestoreVerificationPodName returns the name of the pod that
+// verifies the restore of the snapshot of the Dragonfly object
+func GetRestoreVerificationPodName(df *resourcesv1.Dragonfly) string {
+	return fmt.Sprintf("%s-%s", df.Name, RestoreVerificationComponent)
+}
+
+// GetRestoreVerificationPod returns a throwaway pod that loads the
+// snapshot of the given master from its volume. The volume is mounted
+// read only, on the node of the master as it is ReadWriteOnce. The pod
+// isn't part of the instance, so it doesn't get the labels of its pods.
+func GetRestoreVerificationPod(df *resourcesv1.Dragonfly, master *corev1.Pod) (*corev1.Pod, error) {
+	if df.Spec.Snapshot == nil || df.Spec.Snapshot.PersistentVolumeClaimSpec == nil {
+		return nil, fmt.Errorf("restore verification specified without a persistent volume claim")
+	}
+
+	args := append([]string{}, DefaultDragonflyArgs...)
+	args = append(args, fmt.Sprintf("--dir=%s", snapshotDir))
+	if dbFilename, ok := getArgValue(df.Spec.Args, DBFilenameArg); ok {
+		args = append(args, fmt.Sprintf("%s=%s", DBFilenameArg, dbFilename))
+	}
+
+	container := master.Spec.Containers[0]
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetRestoreVerificationPodName(df),
+			Namespace: df.Namespace,
+			Labels: map[string]string{
+				KubernetesAppComponentLabelKey: RestoreVerificationComponent,
+				KubernetesAppInstanceNameLabel: df.Name,
+				KubernetesManagedByLabelKey:    DragonflyOperatorName,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: df.APIVersion,
+					Kind:       df.Kind,
+					Name:       df.Name,
+					UID:        df.UID,
+				},
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:      master.Spec.NodeName,
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{
+				{
+					Name:            "dragonfly",
+					Image:           container.Image,
+					ImagePullPolicy: container.ImagePullPolicy,
+					Command:         container.Command,
+					Args:            args,
+					Resources:       container.Resources,
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							Name:      SnapshotVolumeName,
+							MountPath: snapshotDir,
+							ReadOnly:  true,
+						},
+					},
+				},
+			},
+			Volumes: []corev1.Volume{
+				{
+					Name: SnapshotVolumeName,
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: fmt.Sprintf("%s-%s", SnapshotVolumeName, master.Name),
+							ReadOnly:  true,
+						},
+					},
+				},
+			},
+			Tolerations:     master.Spec.Tolerations,
+			SecurityContext: master.Spec.SecurityContext,
+		},
+	}, nil
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	resourcesv1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// RouteGVK is the OpenShift Route kind
+var RouteGVK = schema.GroupVersionKind{
+	Group:   "route.openshift.io",
+	Version: "v1",
+	Kind:    "Route",
+}
+
+// GetRoute returns an OpenShift Route to the master Service of a
+// Dragonfly instance. TLS is terminated by Dragonfly itself.
+func GetRoute(df *resourcesv1.Dragonfly) *unstructured.Unstructured {
+	spec := map[string]interface{}{
+		"to": map[string]interface{}{
+			"kind": "Service",
+			"name": df.Name,
+		},
+		"port": map[string]interface{}{
+			"targetPort": DragonflyPortName,
+		},
+		"tls": map[string]interface{}{
+			"termination": "passthrough",
+		},
+	}
+
+	if df.Spec.Route.Host != "" {
+		spec["host"] = df.Spec.Route.Host
+	}
+
+	return newUnstructured(df, RouteGVK, df.Name, spec)
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	resourcesv1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ServiceMonitorGVK is the Prometheus Operator ServiceMonitor kind
+var ServiceMonitorGVK = schema.GroupVersionKind{
+	Group:   "monitoring.coreos.com",
+	Version: "v1",
+	Kind:    "ServiceMonitor",
+}
+
+// GetServiceMonitorName returns the name of the ServiceMonitor of a
+// Dragonfly instance
+func GetServiceMonitorName(df *resourcesv1.Dragonfly) string {
+	return GetMetricsServiceName(df)
+}
+
+// GetServiceMonitor returns a ServiceMonitor that scrapes the metrics
+// port of the metrics Service of a Dragonfly instance
+func GetServiceMonitor(df *resourcesv1.Dragonfly) *unstructured.Unstructured {
+	spec := df.Spec.Metrics.ServiceMonitor
+
+	endpoint := map[string]interface{}{
+		"port": DragonflyMetricsPortName,
+		"path": DragonflyMetricsPath,
+	}
+	if spec.Interval != nil {
+		endpoint["interval"] = formatPrometheusDuration(spec.Interval.Duration)
+	}
+
+	// only the metrics Service of the instance has a metrics port
+	monitor := newUnstructured(df, ServiceMonitorGVK, GetServiceMonitorName(df), map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": map[string]interface{}{
+				"app":                     df.Name,
+				KubernetesAppNameLabelKey: "dragonfly",
+			},
+		},
+		"endpoints": []interface{}{endpoint},
+	})
+
+	labels := monitor.GetLabels()
+	for k, v := range spec.Labels {
+		labels[k] = v
+	}
+	monitor.SetLabels(labels)
+
+	return monitor
+}
+
+// formatPrometheusDuration returns the duration in the syntax of the
+// Prometheus durations, e.g 1m30s or 1s500ms, as the ServiceMonitor CRD
+// doesn't accept fractions like 1.5s. It's truncated to milliseconds.
+func formatPrometheusDuration(d time.Duration) string {
+	if d < time.Millisecond {
+		return "0s"
+	}
+
+	var b strings.Builder
+	for _, unit := range []struct {
+		suffix string
+		size   time.Duration
+	}{
+		{"h", time.Hour},
+		{"m", time.Minute},
+		{"s", time.Second},
+		{"ms", time.Millisecond},
+	} {
+		if n := d / unit.size; n > 0 {
+			fmt.Fprintf(&b, "%d%s", n, unit.suffix)
+			d -= n * unit.size
+		}
+	}
+
+	return b.String()
+}
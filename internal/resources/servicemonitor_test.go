@@ -0,0 +1,56 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+// prometheusDurationPattern is the pattern of the durations of the
+// ServiceMonitor CRD
+var prometheusDurationPattern = regexp.MustCompile(`^(0|(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?)$`)
+
+func TestFormatPrometheusDuration(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		want     string
+	}{
+		{duration: 0, want: "0s"},
+		{duration: 30 * time.Second, want: "30s"},
+		{duration: 1500 * time.Millisecond, want: "1s500ms"},
+		{duration: 250 * time.Millisecond, want: "250ms"},
+		{duration: 90 * time.Second, want: "1m30s"},
+		{duration: time.Hour, want: "1h"},
+		{duration: 25*time.Hour + 30*time.Millisecond, want: "25h30ms"},
+		{duration: 1500 * time.Microsecond, want: "1ms"},
+		{duration: time.Microsecond, want: "0s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.duration.String(), func(t *testing.T) {
+			got := formatPrometheusDuration(tt.duration)
+			if got != tt.want {
+				t.Errorf("formatPrometheusDuration(%v) = %q, want %q", tt.duration, got, tt.want)
+			}
+			if !prometheusDurationPattern.MatchString(got) {
+				t.Errorf("formatPrometheusDuration(%v) = %q doesn't match the ServiceMonitor pattern", tt.duration, got)
+			}
+		})
+	}
+}
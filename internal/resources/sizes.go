@@ -0,0 +1,86 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+
+	resourcesv1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	ProactorThreadsArg = "--proactor_threads"
+	MaxMemoryArg       = "--maxmemory"
+
+	// maxMemoryPercent is the share of the memory limit Dragonfly may use
+	// for data, leaving room for connections and snapshots
+	maxMemoryPercent = 90
+)
+
+// sizePreset is the vetted configuration of a size of Dragonfly instances.
+// Dragonfly scales with one thread per core.
+type sizePreset struct {
+	cpu     string
+	memory  string
+	threads int
+}
+
+var sizePresets = map[string]sizePreset{
+	"small":  {cpu: "1", memory: "2Gi", threads: 1},
+	"medium": {cpu: "2", memory: "8Gi", threads: 2},
+	"large":  {cpu: "4", memory: "32Gi", threads: 4},
+	"xlarge": {cpu: "8", memory: "64Gi", threads: 8},
+}
+
+// getSizeResources returns the resources of the size preset of
+// the Dragonfly object
+func getSizeResources(df *resourcesv1.Dragonfly) (corev1.ResourceRequirements, error) {
+	preset, ok := sizePresets[df.Spec.Size]
+	if !ok {
+		return corev1.ResourceRequirements{}, fmt.Errorf("unknown size %s", df.Spec.Size)
+	}
+
+	return corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(preset.cpu),
+			corev1.ResourceMemory: resource.MustParse(preset.memory),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceMemory: resource.MustParse(preset.memory),
+		},
+	}, nil
+}
+
+// getSizeArgs returns the Dragonfly args of the size preset of the
+// Dragonfly object that are not overridden in its args. maxmemory is
+// derived from the memory limit of the container.
+func getSizeArgs(df *resourcesv1.Dragonfly, container *corev1.Container) []string {
+	preset := sizePresets[df.Spec.Size]
+
+	args := make([]string, 0)
+	if !hasArg(df.Spec.Args, ProactorThreadsArg) {
+		args = append(args, fmt.Sprintf("%s=%d", ProactorThreadsArg, preset.threads))
+	}
+
+	if limit, ok := container.Resources.Limits[corev1.ResourceMemory]; ok && !hasArg(df.Spec.Args, MaxMemoryArg) {
+		args = append(args, fmt.Sprintf("%s=%d", MaxMemoryArg, limit.Value()*maxMemoryPercent/100))
+	}
+
+	return args
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+
+	resourcesv1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TemplateLabel is the name of the DragonflyTemplate that stamped out a
+// Dragonfly object
+const TemplateLabel = "dragonflydb.io/template"
+
+// GetTemplateInstance returns the Dragonfly object of the given instance
+// of the template, with the overrides of the instance merged into the
+// spec of the template
+func GetTemplateInstance(tpl *resourcesv1.DragonflyTemplate, instance *resourcesv1.TemplateInstance) (*resourcesv1.Dragonfly, error) {
+	spec := *tpl.Spec.Template.Spec.DeepCopy()
+	if instance.Overrides != nil && len(instance.Overrides.Raw) > 0 {
+		merged, err := strategicMerge(spec, instance.Overrides.Raw)
+		if err != nil {
+			return nil, fmt.Errorf("could not apply the overrides of instance %s/%s: %w", instance.Namespace, instance.Name, err)
+		}
+		spec = merged
+	}
+
+	labels := map[string]string{}
+	for key, value := range tpl.Spec.Template.Metadata.Labels {
+		labels[key] = value
+	}
+	labels[TemplateLabel] = tpl.Name
+
+	var annotations map[string]string
+	if len(tpl.Spec.Template.Metadata.Annotations) > 0 {
+		annotations = map[string]string{}
+		for key, value := range tpl.Spec.Template.Metadata.Annotations {
+			annotations[key] = value
+		}
+	}
+
+	isController := true
+	return &resourcesv1.Dragonfly{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        instance.Name,
+			Namespace:   instance.Namespace,
+			Labels:      labels,
+			Annotations: annotations,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: resourcesv1.GroupVersion.String(),
+					Kind:       "DragonflyTemplate",
+					Name:       tpl.Name,
+					UID:        tpl.UID,
+					Controller: &isController,
+				},
+			},
+		},
+		Spec: spec,
+	}, nil
+}
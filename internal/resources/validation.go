@@ -0,0 +1,86 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	resourcesv1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+)
+
+// imageDigestRegexp matches the digest of a digest pinned image
+var imageDigestRegexp = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// HasPersistence returns if the data of the instance survives the
+// deletion of its pods, as it's saved to a snapshot PVC, or to the
+// directory of a --dir arg, e.g on S3 or a volume of the overrides
+func HasPersistence(df *resourcesv1.Dragonfly) bool {
+	if df.Spec.Snapshot != nil && df.Spec.Snapshot.PersistentVolumeClaimSpec != nil {
+		return true
+	}
+
+	dir, ok := getArgValue(df.Spec.Args, DirArg)
+	return ok && dir != ""
+}
+
+// IsDataLossConfirmed returns if the loss of the data of the instance
+// was confirmed with the data loss confirmation annotation
+func IsDataLossConfirmed(df *resourcesv1.Dragonfly) bool {
+	return df.Annotations[DataLossConfirmationAnnotation] == "true"
+}
+
+// IsDataLossBlocked returns if the instance holds the only copy of its
+// data, and losing it wasn't confirmed
+func IsDataLossBlocked(df *resourcesv1.Dragonfly) bool {
+	return !HasPersistence(df) && !IsDataLossConfirmed(df)
+}
+
+// ValidateAuthentication returns an error if the replicas of the instance
+// can't authenticate to the master, as their masterauth doesn't match the
+// password of the master
+func ValidateAuthentication(df *resourcesv1.Dragonfly) error {
+	requirePass, hasRequirePass := getArgValue(df.Spec.Args, RequirePassArg)
+	masterAuth, hasMasterAuth := getArgValue(df.Spec.Args, MasterAuthArg)
+
+	if df.Spec.Authentication != nil && df.Spec.Authentication.PasswordFromSecret != nil {
+		if hasMasterAuth {
+			return fmt.Errorf("%s can't be specified along with passwordFromSecret", MasterAuthArg)
+		}
+
+		if hasRequirePass {
+			return fmt.Errorf("%s can't be specified along with passwordFromSecret", RequirePassArg)
+		}
+	}
+
+	if hasRequirePass && hasMasterAuth && requirePass != masterAuth {
+		return fmt.Errorf("%s must match %s, as the replicas authenticate to the master with it", MasterAuthArg, RequirePassArg)
+	}
+
+	return nil
+}
+
+// ValidateImage returns an error if the image of the instance is pinned
+// to a digest that isn't a sha256 digest
+func ValidateImage(df *resourcesv1.Dragonfly) error {
+	if _, digest, ok := strings.Cut(df.Spec.Image, "@"); ok && !imageDigestRegexp.MatchString(digest) {
+		return fmt.Errorf("image %s has an invalid digest %s, expected sha256:<64 hex characters>", df.Spec.Image, digest)
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"strings"
+	"testing"
+
+	resourcesv1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestHasPersistence(t *testing.T) {
+	tests := []struct {
+		name string
+		spec resourcesv1.DragonflySpec
+		want bool
+	}{
+		{name: "no snapshot", want: false},
+		{name: "snapshot PVC", spec: resourcesv1.DragonflySpec{Snapshot: &resourcesv1.Snapshot{PersistentVolumeClaimSpec: &corev1.PersistentVolumeClaimSpec{}}}, want: true},
+		{name: "ephemeral snapshot volume", spec: resourcesv1.DragonflySpec{Snapshot: &resourcesv1.Snapshot{EphemeralVolumeClaimSpec: &corev1.PersistentVolumeClaimSpec{}}}, want: false},
+		{name: "S3 dir", spec: resourcesv1.DragonflySpec{Args: []string{"--dir=s3://bucket/path"}}, want: true},
+		{name: "volume dir", spec: resourcesv1.DragonflySpec{Args: []string{"--dir=/data"}}, want: true},
+		{name: "empty dir", spec: resourcesv1.DragonflySpec{Args: []string{"--dir="}}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df := &resourcesv1.Dragonfly{Spec: tt.spec}
+			if got := HasPersistence(df); got != tt.want {
+				t.Errorf("HasPersistence() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateImage(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a1", 32)
+
+	tests := []struct {
+		name    string
+		image   string
+		wantErr bool
+	}{
+		{name: "default image", wantErr: false},
+		{name: "tag", image: "docker.dragonflydb.io/dragonflydb/dragonfly:v1.9.0", wantErr: false},
+		{name: "digest", image: "docker.dragonflydb.io/dragonflydb/dragonfly@" + digest, wantErr: false},
+		{name: "tag and digest", image: "docker.dragonflydb.io/dragonflydb/dragonfly:v1.9.0@" + digest, wantErr: false},
+		{name: "short digest", image: "docker.dragonflydb.io/dragonflydb/dragonfly@sha256:a1", wantErr: true},
+		{name: "upper case digest", image: "docker.dragonflydb.io/dragonflydb/dragonfly@" + strings.ToUpper(digest), wantErr: true},
+		{name: "other algorithm", image: "docker.dragonflydb.io/dragonflydb/dragonfly@md5:" + strings.Repeat("a1", 32), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			df := &resourcesv1.Dragonfly{Spec: resourcesv1.DragonflySpec{Image: tt.image}}
+			if err := ValidateImage(df); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateImage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
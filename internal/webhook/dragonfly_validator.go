@@ -0,0 +1,147 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+//+kubebuilder:webhook:path=/validate-dragonflydb-io-v1alpha1-dragonfly,mutating=false,failurePolicy=fail,sideEffects=None,groups=dragonflydb.io,resources=dragonflies,verbs=create;update,versions=v1alpha1,name=vdragonfly.kb.io,admissionReviewVersions=v1
+
+// DragonflyValidator refuses Dragonfly objects whose replicas can't
+// authenticate to the master, and scaling Dragonfly objects without
+// persistence to zero replicas, until the loss of their data is confirmed.
+// Their deletion is held by the data loss protection finalizer instead.
+type DragonflyValidator struct {
+	// Reader reads the classes of the Dragonfly objects
+	Reader client.Reader
+}
+
+var _ admission.CustomValidator = &DragonflyValidator{}
+
+// ValidateCreate refuses new objects whose replicas can't authenticate to
+// the master, or whose image is pinned to an invalid digest
+func (v *DragonflyValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	df, ok := obj.(*dfv1alpha1.Dragonfly)
+	if !ok {
+		return fmt.Errorf("expected a Dragonfly object, got %T", obj)
+	}
+
+	return v.validateSpec(ctx, df)
+}
+
+// ValidateUpdate refuses specs whose replicas can't authenticate to the
+// master or whose image is pinned to an invalid digest, and scaling an
+// instance without persistence to zero replicas, unless the loss of its
+// data is confirmed
+func (v *DragonflyValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	oldDf, ok := oldObj.(*dfv1alpha1.Dragonfly)
+	if !ok {
+		return fmt.Errorf("expected a Dragonfly object, got %T", oldObj)
+	}
+
+	df, ok := newObj.(*dfv1alpha1.Dragonfly)
+	if !ok {
+		return fmt.Errorf("expected a Dragonfly object, got %T", newObj)
+	}
+
+	// objects that are being deleted may still get their finalizers removed
+	if df.DeletionTimestamp != nil {
+		return nil
+	}
+
+	if err := v.validateSpec(ctx, df); err != nil {
+		return err
+	}
+
+	if df.Spec.Replicas != 0 || oldDf.Spec.Replicas == 0 {
+		return nil
+	}
+
+	if v.isDataLossBlocked(ctx, df) {
+		return fmt.Errorf("scaling %s to zero replicas loses its data, as it has no persistence. Set the %s annotation to \"true\" to confirm the loss of its data", df.Name, resources.DataLossConfirmationAnnotation)
+	}
+
+	return nil
+}
+
+// ValidateDelete accepts all deletions. Refusing them would block the
+// deletion of their namespace, so the operator holds them with a finalizer.
+func (v *DragonflyValidator) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	return nil
+}
+
+// validateSpec returns an error if the spec of the object, with the
+// defaults of its class, can't be reconciled
+func (v *DragonflyValidator) validateSpec(ctx context.Context, df *dfv1alpha1.Dragonfly) error {
+	df, ok := v.withClass(ctx, df)
+	if !ok {
+		return nil
+	}
+
+	if err := resources.ValidateAuthentication(df); err != nil {
+		return err
+	}
+
+	return resources.ValidateImage(df)
+}
+
+// isDataLossBlocked returns if the object, with the defaults of its class,
+// holds the only copy of its data and losing it wasn't confirmed. Objects
+// whose class can't be read aren't blocked, as their persistence is
+// unknown.
+func (v *DragonflyValidator) isDataLossBlocked(ctx context.Context, df *dfv1alpha1.Dragonfly) bool {
+	if resources.IsDataLossConfirmed(df) {
+		return false
+	}
+
+	df, ok := v.withClass(ctx, df)
+	if !ok {
+		return false
+	}
+
+	return resources.IsDataLossBlocked(df)
+}
+
+// withClass returns a copy of the object with the defaults of its class,
+// and false if the class can't be read. Objects without a class are
+// returned as is.
+func (v *DragonflyValidator) withClass(ctx context.Context, df *dfv1alpha1.Dragonfly) (*dfv1alpha1.Dragonfly, bool) {
+	if df.Spec.ClassName == "" {
+		return df, true
+	}
+
+	var class dfv1alpha1.DragonflyClass
+	if err := v.Reader.Get(ctx, types.NamespacedName{Name: df.Spec.ClassName}, &class); err != nil {
+		return nil, false
+	}
+
+	df = df.DeepCopy()
+	if err := resources.ApplyDragonflyClass(df, &class); err != nil {
+		return nil, false
+	}
+
+	return df, true
+}
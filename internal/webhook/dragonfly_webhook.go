@@ -0,0 +1,104 @@
+/*
+Copyright 2023 DragonflyDB authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	dfv1alpha1 "github.com/dragonflydb/dragonfly-operator/api/v1alpha1"
+	dfv1beta1 "github.com/dragonflydb/dragonfly-operator/api/v1beta1"
+	"github.com/dragonflydb/dragonfly-operator/internal/resources"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+//+kubebuilder:webhook:path=/mutate-dragonflydb-io-v1alpha1-dragonfly,mutating=true,failurePolicy=fail,sideEffects=None,groups=dragonflydb.io,resources=dragonflies,verbs=create,versions=v1alpha1,name=mdragonfly.kb.io,admissionReviewVersions=v1,matchPolicy=Exact
+//+kubebuilder:webhook:path=/mutate-dragonflydb-io-v1beta1-dragonfly,mutating=true,failurePolicy=fail,sideEffects=None,groups=dragonflydb.io,resources=dragonflies,verbs=create,versions=v1beta1,name=mdragonfly-v1beta1.kb.io,admissionReviewVersions=v1,matchPolicy=Exact
+
+// DragonflyDefaulter fills in the defaults of the specs of new Dragonfly
+// objects. Each version has a defaulting webhook of its own, as the
+// conversion drops the replicas that are set to 0, which then couldn't be
+// told apart from unset ones.
+type DragonflyDefaulter struct{}
+
+var _ admission.CustomDefaulter = &DragonflyDefaulter{}
+
+// SetupDragonflyWebhookWithManager registers the defaulting and validating
+// webhooks of the Dragonfly objects with the webhook server of the manager,
+// along with the conversion webhook of their versions, as v1alpha1 is their
+// hub
+func SetupDragonflyWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register("/mutate-dragonflydb-io-v1beta1-dragonfly", admission.WithCustomDefaulter(&dfv1beta1.Dragonfly{}, &DragonflyDefaulter{}))
+
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&dfv1alpha1.Dragonfly{}).
+		WithDefaulter(&DragonflyDefaulter{}).
+		WithValidator(&DragonflyValidator{Reader: mgr.GetClient()}).
+		Complete()
+}
+
+// Default fills in the defaults of the spec of the given Dragonfly object
+func (d *DragonflyDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	switch df := obj.(type) {
+	case *dfv1alpha1.Dragonfly:
+		d.defaultDragonfly(ctx, df)
+		return nil
+	case *dfv1beta1.Dragonfly:
+		hub := &dfv1alpha1.Dragonfly{}
+		if err := df.ConvertTo(hub); err != nil {
+			return err
+		}
+
+		d.defaultDragonfly(ctx, hub)
+		return df.ConvertFrom(hub)
+	default:
+		return fmt.Errorf("expected a Dragonfly object, got %T", obj)
+	}
+}
+
+func (d *DragonflyDefaulter) defaultDragonfly(ctx context.Context, df *dfv1alpha1.Dragonfly) {
+	// existing objects keep their spec, so that updates don't roll their
+	// pods to the defaults of the current operator version
+	if !df.CreationTimestamp.IsZero() {
+		return
+	}
+
+	resources.DefaultDragonflySpec(df, isReplicasSet(ctx, df))
+}
+
+// isReplicasSet returns if the admission request of the given object sets
+// its replicas, possibly to 0
+func isReplicasSet(ctx context.Context, df *dfv1alpha1.Dragonfly) bool {
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return df.Spec.Replicas != 0
+	}
+
+	var object struct {
+		Spec struct {
+			Replicas *int32 `json:"replicas"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(req.Object.Raw, &object); err != nil {
+		return df.Spec.Replicas != 0
+	}
+
+	return object.Spec.Replicas != nil
+}